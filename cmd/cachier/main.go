@@ -0,0 +1,352 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// The cachier command works with cachier's decisions offline, and queries
+// a running controller.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/export"
+	"github.com/mattmoor/cachier/pkg/extractors"
+	"github.com/mattmoor/cachier/pkg/imageindex"
+	"github.com/mattmoor/cachier/pkg/inventory"
+	"github.com/mattmoor/cachier/pkg/render"
+)
+
+const (
+	outputYAML   = "yaml"
+	outputImages = "images"
+	outputTable  = "table"
+	outputJSON   = "json"
+)
+
+// defaultResources are those of config/controller.yaml.
+var defaultResources = []schema.GroupVersionKind{
+	{Group: "apps", Version: "v1", Kind: "Deployment"},
+	{Group: "apps", Version: "v1", Kind: "ReplicaSet"},
+	{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+	{Group: "apps", Version: "v1", Kind: "DaemonSet"},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: %s <command> [flags]
+
+Commands:
+  render  Print the Images that cachier would create for the resources in manifests.
+  images  Ask a controller which workloads use the images that match a query.
+  export  Export the images that a controller manages, most used first (e.g. to bake into node images).
+`, os.Args[0])
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "render":
+		err = renderCmd(os.Args[2:])
+	case "images":
+		err = imagesCmd(os.Args[2:])
+	case "export":
+		err = exportCmd(os.Args[2:])
+	case "help", "-h", "-help", "--help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// renderCmd implements `cachier render`.
+func renderCmd(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+
+	var files stringListFlag
+	fs.Var(&files, "f", "A manifest file, a directory of them, or - for stdin. May be repeated.")
+
+	var resources gvkListFlag
+	fs.Var(&resources, "resource", "The resources whose images are cached, as with the controller, in the form: Kind.version.group (e.g. Deployment.v1.apps). Defaults to those of config/controller.yaml.")
+
+	var extractorConfig string
+	fs.StringVar(&extractorConfig, "extractors", "", "Path to a file configuring JSONPath image extractors for resources that aren't PodSpecable.")
+
+	var defaultMode string
+	fs.StringVar(&defaultMode, "default-mode", string(cachierv1alpha1.ModeOptOut), "Whether resources are cached unless they (or their Namespace) opt out (OptOut), or only when they opt in (OptIn).")
+
+	var namespace string
+	fs.StringVar(&namespace, "namespace", "default", "The namespace of the resources and CachePolicies that don't name one.")
+
+	var output string
+	fs.StringVar(&output, "o", outputYAML, "Whether to print the Images (yaml), or just the deduplicated list of their images (images).")
+
+	var verbose bool
+	fs.BoolVar(&verbose, "v", false, "Whether to explain the decision for each resource on stderr.")
+
+	fs.Parse(args)
+
+	mode := cachierv1alpha1.Mode(defaultMode)
+	if mode != cachierv1alpha1.ModeOptIn && mode != cachierv1alpha1.ModeOptOut {
+		return fmt.Errorf("-default-mode must be %q or %q, got %q", cachierv1alpha1.ModeOptIn, cachierv1alpha1.ModeOptOut, defaultMode)
+	}
+	if output != outputYAML && output != outputImages {
+		return fmt.Errorf("-o must be %q or %q, got %q", outputYAML, outputImages, output)
+	}
+	if len(files) == 0 {
+		files = stringListFlag{"-"}
+	}
+	if len(resources) == 0 {
+		resources = defaultResources
+	}
+
+	opts := render.Options{
+		Resources:   resources,
+		DefaultMode: mode,
+		Namespace:   namespace,
+	}
+	if extractorConfig != "" {
+		exts, err := extractors.Load(extractorConfig)
+		if err != nil {
+			return fmt.Errorf("loading extractors: %v", err)
+		}
+		opts.Extractors = exts
+	}
+
+	var objs []*unstructured.Unstructured
+	for _, f := range files {
+		read, err := render.ReadPath(f)
+		if err != nil {
+			return err
+		}
+		objs = append(objs, read...)
+	}
+
+	results, err := render.Render(objs, opts)
+	if err != nil {
+		return err
+	}
+
+	if verbose {
+		for _, r := range results {
+			thing := r.Resource
+			fmt.Fprintf(os.Stderr, "%s %s/%s: Cache: %v (%s)", thing.Kind, thing.Namespace, thing.Name, r.Decision.Cache, r.Decision.Reason)
+			if len(r.Decision.ExcludedContainers) > 0 {
+				fmt.Fprintf(os.Stderr, ", excluding containers: %s", strings.Join(r.Decision.ExcludedContainers, ","))
+			}
+			if len(r.ExcludedImages) > 0 {
+				fmt.Fprintf(os.Stderr, ", excluding images: %s", strings.Join(r.ExcludedImages, ","))
+			}
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+
+	switch output {
+	case outputImages:
+		for _, ref := range render.Images(results) {
+			fmt.Println(ref)
+		}
+	default:
+		b, err := render.ToYAML(results)
+		if err != nil {
+			return err
+		}
+		os.Stdout.Write(b)
+	}
+	return nil
+}
+
+// imagesCmd implements `cachier images`.
+func imagesCmd(args []string) error {
+	fs := flag.NewFlagSet("images", flag.ExitOnError)
+
+	var server string
+	fs.StringVar(&server, "server", "http://localhost:8008", "The URL of the controller's -debug-addr (e.g. via kubectl port-forward).")
+
+	var q imageindex.Query
+	fs.StringVar(&q.Image, "image", "", "Match this image (e.g. openssl-base:1.2), once normalized (e.g. to index.docker.io/library/openssl-base:1.2).")
+	fs.StringVar(&q.Prefix, "prefix", "", "Match the normalized images with this prefix (e.g. gcr.io/my-project/).")
+	fs.StringVar(&q.Digest, "digest", "", "Match only the workloads known to run this digest (e.g. sha256:...).")
+
+	var re string
+	fs.StringVar(&re, "regex", "", "Match the normalized images that this regular expression matches.")
+
+	var output string
+	fs.StringVar(&output, "o", outputTable, "Whether to print a table (table), or the matches as JSON (json).")
+
+	fs.Parse(args)
+
+	if output != outputTable && output != outputJSON {
+		return fmt.Errorf("-o must be %q or %q, got %q", outputTable, outputJSON, output)
+	}
+	if re != "" {
+		var err error
+		if q.Regexp, err = regexp.Compile(re); err != nil {
+			return fmt.Errorf("-regex: %v", err)
+		}
+	}
+
+	resp, err := http.Get(strings.TrimSuffix(server, "/") + "/api/images?" + q.Values().Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Request.URL, resp.Status)
+	}
+	var matches []imageindex.Match
+	if err := json.NewDecoder(resp.Body).Decode(&matches); err != nil {
+		return fmt.Errorf("decoding response: %v", err)
+	}
+
+	if output == outputJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(matches)
+	}
+	if len(matches) == 0 {
+		fmt.Fprintln(os.Stderr, "No workloads use matching images.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "IMAGE\tDIGEST\tNAMESPACE\tKIND\tNAME")
+	for _, m := range matches {
+		for _, u := range m.Workloads {
+			kind := u.Kind
+			if u.Group != "" {
+				kind += "." + u.Group
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", m.Image, u.Digest, u.Namespace, kind, u.Name)
+		}
+	}
+	return tw.Flush()
+}
+
+// exportCmd implements `cachier export`.
+func exportCmd(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+
+	var server, file string
+	fs.StringVar(&server, "server", "http://localhost:8008", "The URL of the controller's -debug-addr (e.g. via kubectl port-forward).")
+	fs.StringVar(&file, "f", "", "Export from an inventory saved from the controller's /api/inventory (or - for stdin), rather than from -server.")
+
+	var opts export.Options
+	var namespaces, policies stringListFlag
+	fs.Var(&namespaces, "namespace", "Export just the images of this namespace. May be repeated.")
+	fs.Var(&policies, "policy", "Export just the images of the workloads that this CachePolicy or ClusterCachePolicy applies to, as NAME or KIND/NAME. May be repeated.")
+	fs.BoolVar(&opts.Weighted, "weighted", false, "Whether to weight images by the replicas of the workloads that use them, rather than by the number of workloads.")
+	fs.IntVar(&opts.Limit, "limit", 0, "Export only this many of the most used images, if positive.")
+
+	var output string
+	fs.StringVar(&output, "o", export.FormatList, "Whether to print a list of references (list), a script that pulls them with containerd's ctr (ctr), or a JSON manifest (json).")
+
+	fs.Parse(args)
+	opts.Namespaces, opts.Policies = namespaces, policies
+
+	report, err := readInventory(server, file, opts.Namespaces)
+	if err != nil {
+		return err
+	}
+	return export.Write(os.Stdout, output, export.Compute(report, opts))
+}
+
+// readInventory reads the inventory from the file, if any, or else fetches
+// it from the controller, just for the namespace when there is one.
+func readInventory(server, file string, namespaces []string) (*inventory.Report, error) {
+	var r io.ReadCloser
+	if file == "-" {
+		r = os.Stdin
+	} else if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		r = f
+	} else {
+		q := url.Values{}
+		if len(namespaces) == 1 {
+			q.Set("namespace", namespaces[0])
+		}
+		resp, err := http.Get(strings.TrimSuffix(server, "/") + "/api/inventory?" + q.Encode())
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s: %s", resp.Request.URL, resp.Status)
+		}
+		r = resp.Body
+	}
+	defer r.Close()
+
+	report := &inventory.Report{}
+	if err := json.NewDecoder(r).Decode(report); err != nil {
+		return nil, fmt.Errorf("decoding inventory: %v", err)
+	}
+	return report, nil
+}
+
+// Custom flag type for reading repeated strings.
+type stringListFlag []string
+
+func (s *stringListFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringListFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// Custom flag type for reading GroupVersionKind.
+type gvkListFlag []schema.GroupVersionKind
+
+func (i *gvkListFlag) String() string {
+	strs := []string{}
+	for _, x := range []schema.GroupVersionKind(*i) {
+		strs = append(strs, x.String())
+	}
+	return strings.Join(strs, ",")
+}
+
+func (i *gvkListFlag) Set(value string) error {
+	gvk, _ := schema.ParseKindArg(value)
+	if gvk == nil {
+		return fmt.Errorf("not a valid GroupVersionKind: %q", value)
+	}
+	*i = append(*i, *gvk)
+	return nil
+}
@@ -18,28 +18,86 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"expvar"
 	"flag"
 	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
 	cachingclientset "github.com/knative/caching/pkg/client/clientset/versioned"
 	cachinginformers "github.com/knative/caching/pkg/client/informers/externalversions"
+	"github.com/knative/pkg/apis"
 	"github.com/knative/pkg/apis/duck"
-	"github.com/knative/pkg/controller"
 	"github.com/knative/pkg/logging"
 	"github.com/knative/pkg/signals"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
 	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/budget"
+	cachierclientset "github.com/mattmoor/cachier/pkg/client/clientset/versioned"
+	cachierinformers "github.com/mattmoor/cachier/pkg/client/informers/externalversions"
+	"github.com/mattmoor/cachier/pkg/dryrun"
+	"github.com/mattmoor/cachier/pkg/export"
+	"github.com/mattmoor/cachier/pkg/extractors"
+	"github.com/mattmoor/cachier/pkg/fairqueue"
+	"github.com/mattmoor/cachier/pkg/imageindex"
+	"github.com/mattmoor/cachier/pkg/informers"
+	"github.com/mattmoor/cachier/pkg/inventory"
+	"github.com/mattmoor/cachier/pkg/ownerchain"
+	"github.com/mattmoor/cachier/pkg/rbac"
+	"github.com/mattmoor/cachier/pkg/reconciler/cachedimageset"
 	"github.com/mattmoor/cachier/pkg/reconciler/cachier"
+	"github.com/mattmoor/cachier/pkg/reconciler/podowners"
+	"github.com/mattmoor/cachier/pkg/reconciler/pods"
+	"github.com/mattmoor/cachier/pkg/reconciler/prewarm"
+	"github.com/mattmoor/cachier/pkg/registry"
+	"github.com/mattmoor/cachier/pkg/sharding"
 )
 
 const (
-	threadsPerController = 2
+	// ownerCacheSize bounds the number of intermediate owners (e.g.
+	// ReplicaSets) whose own owner we remember when following Pods'
+	// owner chains.
+	ownerCacheSize = 10000
+
+	// The bounds of the backoff of resources whose Images are deferred
+	// because the budgets of their registries are spent.
+	minBudgetBackoff = time.Second
+	maxBudgetBackoff = 5 * time.Minute
+
+	// budgetReloadPeriod is how often we check -registry-budgets for
+	// changes, e.g. to its ConfigMap.
+	budgetReloadPeriod = 10 * time.Second
+
+	// The values of the -mode flag.
+	modeTemplates = "templates"
+	modePods      = "pods"
+
+	// rbacName names the roles that -print-rbac emits.
+	rbacName = "cachier-controller"
+
+	// The rate of the SelfSubjectAccessReviews with which we check our
+	// permissions at startup, of which there are several per watched
+	// namespace, so that -kube-api-qps doesn't hold up starting.
+	permissionCheckQPS   = 50
+	permissionCheckBurst = 100
 )
 
 func main() {
@@ -52,6 +110,103 @@ func main() {
 	var resources gvkListFlag
 	flag.Var(&resources, "resource", "The list of resources to operate over, in the form: Kind.version.group (e.g. Deployment.v1.app)")
 
+	var controllerMode string
+	flag.StringVar(&controllerMode, "mode", modeTemplates, "Whether to cache the images of the pod templates of the configured resources (templates), or the images that Pods actually run, attributed to their top-level owners (pods).")
+
+	var podDebounce time.Duration
+	flag.DurationVar(&podDebounce, "pod-debounce", 30*time.Second, "With -mode=pods, how long to wait after Pods change before updating their owner's Images, and how long an image must go unseen before its Image is deleted.")
+
+	var learnFromPods bool
+	flag.BoolVar(&learnFromPods, "learn-from-pods", false, "Whether to also cache images that resources' running Pods have, but their pod templates don't (e.g. sidecars injected by webhooks).")
+
+	var pinDigests bool
+	flag.BoolVar(&pinDigests, "pin-digests", false, "Whether to pin resources' Images to the digests that the kubelet reports their running Pods run.")
+
+	var resolveCredentials bool
+	flag.BoolVar(&resolveCredentials, "resolve-credentials", false, "Whether to resolve the pull secrets of resources' ServiceAccounts, and check that pull credentials exist before caching.")
+
+	var validateImages bool
+	flag.BoolVar(&validateImages, "validate-images", false, "Whether to check with their registries that images exist (and that resources' pull credentials may pull them) before caching them.")
+
+	var validatePositiveTTL, validateNegativeTTL time.Duration
+	flag.DurationVar(&validatePositiveTTL, "validate-ttl", time.Hour, "With -validate-images, how long to remember that an image exists.")
+	flag.DurationVar(&validateNegativeTTL, "validate-negative-ttl", time.Minute, "With -validate-images, how long to remember that an image doesn't exist, or may not be pulled.")
+
+	var registryQPS float64
+	var registryBurst int
+	flag.Float64Var(&registryQPS, "registry-qps", 5, "With -validate-images, the maximum rate of requests to each registry.")
+	flag.IntVar(&registryBurst, "registry-burst", 10, "With -validate-images, the maximum burst of requests to each registry.")
+
+	var insecureRegistries string
+	flag.StringVar(&insecureRegistries, "insecure-registries", "", "With -validate-images, a comma-separated list of registries to talk to over plain HTTP (in addition to localhost).")
+
+	var apiQPS float64
+	var apiBurst int
+	flag.Float64Var(&apiQPS, "kube-api-qps", 5, "The maximum rate of requests to the API server.")
+	flag.IntVar(&apiBurst, "kube-api-burst", 10, "The maximum burst of requests to the API server.")
+
+	var budgetConfig string
+	flag.StringVar(&budgetConfig, "registry-budgets", "", "Path to a file configuring the rate at which Images may be created for the images of each registry.")
+
+	var debugAddr string
+	flag.StringVar(&debugAddr, "debug-addr", "", "The address on which to serve debug endpoints, the images, inventory and export APIs, and the inventory page (e.g. :8008), which are disabled when empty.")
+
+	var cachePolicies bool
+	flag.BoolVar(&cachePolicies, "cache-policies", true, "Whether to honor CachePolicy and ClusterCachePolicy resources.")
+
+	var cachedImageSets bool
+	flag.BoolVar(&cachedImageSets, "cached-image-sets", true, "Whether to cache the images listed by CachedImageSet resources. Off by default with -dry-run, which doesn't cover them.")
+
+	var prewarmSchedules bool
+	flag.BoolVar(&prewarmSchedules, "prewarm-schedules", true, "Whether to cache the images of PrewarmSchedule resources during their windows. Off by default with -dry-run, which doesn't cover them.")
+
+	var defaultMode string
+	flag.StringVar(&defaultMode, "default-mode", string(cachierv1alpha1.ModeOptOut), "Whether resources are cached unless they (or their Namespace) opt out (OptOut), or only when they opt in (OptIn).")
+
+	var trimServingImages bool
+	flag.BoolVar(&trimServingImages, "trim-serving-images", true, "Whether to skip the images of Knative Serving Services and Configurations that Serving has already created Images for, for one of their Revisions.")
+
+	var trimInformers bool
+	flag.BoolVar(&trimInformers, "trim-informers", true, "Whether to only keep the fields of resources that cachier needs (e.g. not env, volumes or probes) in its informers' caches.")
+
+	var extractorConfig string
+	flag.StringVar(&extractorConfig, "extractors", "", "Path to a file configuring JSONPath image extractors for resources that aren't PodSpecable.")
+
+	var shard bool
+	flag.BoolVar(&shard, "shard", false, "Whether to share the namespaces to reconcile among the replicas of the controller, each of which reconciles only the namespaces it owns.")
+
+	var shardIdentity, shardNamespace, shardGroup string
+	flag.StringVar(&shardIdentity, "shard-identity", defaultIdentity(), "With -shard, the name of this replica, which must be unique within its group.")
+	flag.StringVar(&shardNamespace, "shard-namespace", "cachier-system", "With -shard, the namespace of the Leases with which replicas announce themselves.")
+	flag.StringVar(&shardGroup, "shard-group", "cachier", "With -shard, the name of the group of replicas that share namespaces.")
+
+	var shardLeaseDuration time.Duration
+	flag.DurationVar(&shardLeaseDuration, "shard-lease-duration", 15*time.Second, "With -shard, how long a replica's Lease lasts without renewal, after which the others take over its namespaces.")
+
+	var defaultWorkers int
+	flag.IntVar(&defaultWorkers, "default-workers", 2, "The number of workers reconciling each resource, unless configured with -workers.")
+
+	workers := workerCountsFlag{}
+	flag.Var(&workers, "workers", "The number of workers reconciling a resource, in the form: Kind.version.group=N (e.g. Job.v1.batch=8). May be repeated.")
+
+	var watchNamespaces string
+	flag.StringVar(&watchNamespaces, "namespaces", "", "A comma-separated list of the namespaces to watch, which are all of them when empty. With namespaces, cachier only needs Roles in them, but ignores the decorate label and annotation on Namespaces, and ClusterCachePolicies.")
+
+	var printRBAC bool
+	flag.BoolVar(&printRBAC, "print-rbac", false, "Print the ClusterRole or Roles (and their bindings) that cachier needs with the rest of its flags as YAML, and exit.")
+
+	var rbacSubject string
+	flag.StringVar(&rbacSubject, "rbac-service-account", "cachier-system/cachier-controller", "With -print-rbac, the ServiceAccount to bind the roles to, in the form: namespace/name.")
+
+	var dryRun bool
+	flag.BoolVar(&dryRun, "dry-run", false, "Whether to only report the Images that cachier would create and delete for the configured resources, rather than make any writes. Namespaces may override this with the "+dryrun.AnnotationKey+" label or annotation.")
+
+	var dryRunReport string
+	flag.StringVar(&dryRunReport, "dry-run-report", "cachier-dry-run", "The name of the ConfigMap in each namespace to which dry-run reports are written, which are only logged and exported as metrics when empty.")
+
+	var checkPermissions bool
+	flag.BoolVar(&checkPermissions, "check-permissions", true, "Whether to check that cachier has the permissions it needs with SelfSubjectAccessReviews before starting.")
+
 	flag.Parse()
 
 	// set up signals so we handle the first shutdown signal gracefully
@@ -59,11 +214,123 @@ func main() {
 
 	logger := logging.FromContext(context.TODO()).Named("controller")
 
+	mode := cachierv1alpha1.Mode(defaultMode)
+	if mode != cachierv1alpha1.ModeOptIn && mode != cachierv1alpha1.ModeOptOut {
+		logger.Fatalf("-default-mode must be %q or %q, got %q", cachierv1alpha1.ModeOptIn, cachierv1alpha1.ModeOptOut, defaultMode)
+	}
+
+	switch controllerMode {
+	case modeTemplates:
+	case modePods:
+		if len(resources) > 0 || extractorConfig != "" {
+			logger.Fatalf("-resource and -extractors don't apply with -mode=%s", modePods)
+		}
+		if dryRun {
+			logger.Fatalf("-dry-run doesn't apply with -mode=%s", modePods)
+		}
+	default:
+		logger.Fatalf("-mode must be %q or %q, got %q", modeTemplates, modePods, controllerMode)
+	}
+
+	if dryRun {
+		// CachedImageSets and PrewarmSchedules write their Images directly,
+		// so rather than have them cache while the rest only reports, they
+		// are off in dry runs, and asking for them is an error.
+		flag.Visit(func(f *flag.Flag) {
+			if (f.Name == "cached-image-sets" && cachedImageSets) || (f.Name == "prewarm-schedules" && prewarmSchedules) {
+				logger.Fatalf("-dry-run doesn't apply to -%s", f.Name)
+			}
+		})
+		cachedImageSets, prewarmSchedules = false, false
+	}
+
+	var namespaces []string
+	if watchNamespaces != "" {
+		namespaces = strings.Split(watchNamespaces, ",")
+	}
+
+	var exts []*extractors.Extractor
+	if extractorConfig != "" {
+		var err error
+		exts, err = extractors.Load(extractorConfig)
+		if err != nil {
+			logger.Fatalf("Error loading extractors: %v", err)
+		}
+	}
+
+	watchPods := learnFromPods || pinDigests || controllerMode == modePods
+	rbacOpts := rbac.Options{
+		Pods:                 watchPods,
+		Owners:               rbac.WellKnownOwners,
+		Credentials:          resolveCredentials,
+		ValidateImages:       validateImages,
+		CachePolicies:        cachePolicies,
+		ClusterCachePolicies: cachePolicies && len(namespaces) == 0,
+		Namespaces:           len(namespaces) == 0,
+		CachedImageSets:      cachedImageSets,
+		PrewarmSchedules:     prewarmSchedules,
+		DryRunReports:        dryRunReport != "" && controllerMode == modeTemplates,
+	}
+	for _, gvk := range resources {
+		gvr, _ := meta.UnsafeGuessKindToResource(gvk)
+		rbacOpts.Resources = append(rbacOpts.Resources, gvr)
+	}
+	for _, ext := range exts {
+		gvr, _ := meta.UnsafeGuessKindToResource(ext.GVK)
+		rbacOpts.Resources = append(rbacOpts.Resources, gvr)
+	}
+	rbacOpts.Owners = append(rbacOpts.Owners, rbacOpts.Resources...)
+	if shard {
+		rbacOpts.ShardNamespace = shardNamespace
+	}
+	rules := rbac.Rules(rbacOpts)
+
+	if printRBAC {
+		parts := strings.SplitN(rbacSubject, "/", 2)
+		if len(parts) != 2 {
+			logger.Fatalf("-rbac-service-account must be of the form namespace/name, got %q", rbacSubject)
+		}
+		b, err := rbac.ToYAML(rbac.Render(rules, rbac.RenderOptions{
+			Name: rbacName,
+			Subject: rbacv1.Subject{
+				Kind:      rbacv1.ServiceAccountKind,
+				Namespace: parts[0],
+				Name:      parts[1],
+			},
+			Namespaces: namespaces,
+		}))
+		if err != nil {
+			logger.Fatalf("Error rendering RBAC: %v", err)
+		}
+		os.Stdout.Write(b)
+		return
+	}
+
 	cfg, err := clientcmd.BuildConfigFromFlags(masterURL, kubeconfig)
 	if err != nil {
 		logger.Fatalf("Error building kubeconfig: %s", err.Error())
 	}
 
+	if checkPermissions {
+		checkCfg := rest.CopyConfig(cfg)
+		checkCfg.QPS = permissionCheckQPS
+		checkCfg.Burst = permissionCheckBurst
+		checkClient, err := dynamic.NewForConfig(checkCfg)
+		if err != nil {
+			logger.Fatalf("Error building permission check client: %v", err)
+		}
+		missing, err := rbac.Missing(rules, namespaces, rbac.SelfSubjectAccessReviewer(checkClient))
+		if err != nil {
+			logger.Fatalf("Error checking permissions: %v", err)
+		}
+		if len(missing) > 0 {
+			logger.Fatalf("Missing permissions (see -print-rbac): %s", strings.Join(missing, ", "))
+		}
+	}
+
+	cfg.QPS = float32(apiQPS)
+	cfg.Burst = apiBurst
+
 	dynamicClient, err := dynamic.NewForConfig(cfg)
 	if err != nil {
 		logger.Fatalf("Error building build clientset: %v", err)
@@ -74,43 +341,345 @@ func main() {
 		logger.Fatalf("Error building caching clientset: %v", err)
 	}
 
-	resyncPeriod := 10 * time.Hour
-
-	tif := &duck.TypedInformerFactory{
-		Client:       dynamicClient,
-		Type:         &v1alpha1.WithPod{},
-		ResyncPeriod: resyncPeriod,
-		StopChannel:  stopCh,
+	cachierClient, err := cachierclientset.NewForConfig(cfg)
+	if err != nil {
+		logger.Fatalf("Error building cachier clientset: %v", err)
 	}
 
+	resyncPeriod := 10 * time.Hour
+
 	cachingInformerFactory := cachinginformers.NewSharedInformerFactory(cachingClient, resyncPeriod)
+	cachierInformerFactory := cachierinformers.NewSharedInformerFactory(cachierClient, resyncPeriod)
+
+	if len(namespaces) > 0 {
+		// The generated factories watch one namespace or all of them, so
+		// register informers that only watch ours before they make their
+		// own.
+		cachingInformerFactory.InformerFor(&caching.Image{},
+			func(c cachingclientset.Interface, resync time.Duration) cache.SharedIndexInformer {
+				return informers.NewNamespacedInformer(c.CachingV1alpha1().RESTClient(),
+					"images", &caching.Image{}, namespaces, resync)
+			})
+		// Only register those that we use, since the factory starts every
+		// informer that it has.
+		objs := map[string]runtime.Object{}
+		if cachePolicies {
+			objs["cachepolicies"] = &cachierv1alpha1.CachePolicy{}
+		}
+		if cachedImageSets {
+			objs["cachedimagesets"] = &cachierv1alpha1.CachedImageSet{}
+		}
+		if prewarmSchedules {
+			objs["prewarmschedules"] = &cachierv1alpha1.PrewarmSchedule{}
+		}
+		for resource, obj := range objs {
+			resource, obj := resource, obj
+			cachierInformerFactory.InformerFor(obj,
+				func(c cachierclientset.Interface, resync time.Duration) cache.SharedIndexInformer {
+					return informers.NewNamespacedInformer(c.CachierV1alpha1().RESTClient(),
+						resource, obj, namespaces, resync)
+				})
+		}
+	}
 
 	imageInformer := cachingInformerFactory.Caching().V1alpha1().Images()
 
-	controllers := make([]*controller.Impl, 0, len(resources))
+	budgetCfg := &budget.Config{}
+	if budgetConfig != "" {
+		budgetCfg, err = budget.Load(budgetConfig)
+		if err != nil {
+			logger.Fatalf("Error loading registry budgets: %v", err)
+		}
+	}
+	imageBudget := budget.New(budgetCfg, clock.RealClock{}, minBudgetBackoff, maxBudgetBackoff)
+	if budgetConfig != "" {
+		imageBudget.Watch(budgetConfig, budgetReloadPeriod, logger, stopCh)
+	}
+	expvar.Publish("registryBudgets", expvar.Func(func() interface{} {
+		return imageBudget.Stats()
+	}))
+
+	var sharder *sharding.Sharder
+	if shard {
+		if shardIdentity == "" {
+			logger.Fatal("-shard-identity must be set with -shard")
+		}
+		sharder = sharding.New(sharding.Options{
+			Client:        dynamicClient,
+			Namespace:     shardNamespace,
+			Group:         shardGroup,
+			Identity:      shardIdentity,
+			LeaseDuration: shardLeaseDuration,
+			Logger:        logger.Named("sharding"),
+		})
+	}
+
+	reportOpts := dryrun.Options{
+		Name:   dryRunReport,
+		Logger: logger.Named("dry-run"),
+	}
+	if rbacOpts.DryRunReports {
+		reportOpts.Client = dynamicClient
+	}
+	reporter := dryrun.NewReporter(reportOpts)
+	expvar.Publish("dryRun", expvar.Func(func() interface{} {
+		return reporter.Stats()
+	}))
+
+	// index maps images to the workloads that use them, for /api/images.
+	index := imageindex.New()
+	// inv reports on everything we manage, for /api/inventory.
+	inv := inventory.New(imageInformer.Lister(), clock.RealClock{})
+
+	opts := cachier.Options{
+		LearnImages:       learnFromPods,
+		PinDigests:        pinDigests,
+		Budget:            imageBudget,
+		Shard:             sharder,
+		DryRun:            dryRun,
+		Reporter:          reporter,
+		Index:             index,
+		Inventory:         inv,
+		TrimServingImages: trimServingImages,
+	}
+	if watchPods {
+		pif := &informers.TypedInformerFactory{
+			Client:       dynamicClient,
+			Type:         &v1alpha1.PodImages{},
+			Namespaces:   namespaces,
+			ResyncPeriod: resyncPeriod,
+			StopChannel:  stopCh,
+		}
+		podInformer, _, err := pif.Get(v1alpha1.PodsResource)
+		if err != nil {
+			logger.Fatalf("Error building Pod informer: %v", err)
+		}
+		opts.PodInformer = podInformer
+		opts.Resolver = ownerchain.NewResolver(dynamicClient, ownerCacheSize)
+	}
+
+	if resolveCredentials {
+		for _, x := range []struct {
+			typ      apis.Listable
+			gvr      schema.GroupVersionResource
+			informer *cache.SharedIndexInformer
+			indexers cache.Indexers
+		}{{
+			typ:      &v1alpha1.WithImagePullSecrets{},
+			gvr:      v1alpha1.ServiceAccountsResource,
+			informer: &opts.ServiceAccountInformer,
+			indexers: cachier.ServiceAccountIndexers(),
+		}, {
+			typ:      &v1alpha1.WithSecretType{},
+			gvr:      v1alpha1.SecretsResource,
+			informer: &opts.SecretInformer,
+		}} {
+			inf, _, err := (&informers.TypedInformerFactory{
+				Client:       dynamicClient,
+				Type:         x.typ,
+				Namespaces:   namespaces,
+				ResyncPeriod: resyncPeriod,
+				StopChannel:  stopCh,
+				Indexers:     x.indexers,
+			}).Get(x.gvr)
+			if err != nil {
+				logger.Fatalf("Error building informer for %v: %v", x.gvr, err)
+			}
+			*x.informer = inf
+		}
+	}
+
+	if validateImages {
+		var insecure []string
+		if insecureRegistries != "" {
+			insecure = strings.Split(insecureRegistries, ",")
+		}
+		opts.Checker = registry.NewChecker(registry.Options{
+			PositiveTTL: validatePositiveTTL,
+			NegativeTTL: validateNegativeTTL,
+			QPS:         registryQPS,
+			Burst:       registryBurst,
+			Insecure:    insecure,
+		})
+	}
+
+	synced := []cache.InformerSynced{
+		imageInformer.Informer().HasSynced,
+	}
+	if cachePolicies {
+		opts.CachePolicyInformer = cachierInformerFactory.Cachier().V1alpha1().CachePolicies()
+		synced = append(synced, opts.CachePolicyInformer.Informer().HasSynced)
+		if rbacOpts.ClusterCachePolicies {
+			opts.ClusterCachePolicyInformer = cachierInformerFactory.Cachier().V1alpha1().ClusterCachePolicies()
+			synced = append(synced, opts.ClusterCachePolicyInformer.Informer().HasSynced)
+		}
+	}
+
+	opts.DefaultMode = mode
+	if rbacOpts.Namespaces {
+		// Namespaces are cluster-scoped, so we only watch them when we watch
+		// all of them.
+		nif := &duck.TypedInformerFactory{
+			Client:       dynamicClient,
+			Type:         &v1alpha1.WithMetadata{},
+			ResyncPeriod: resyncPeriod,
+			StopChannel:  stopCh,
+		}
+		opts.NamespaceInformer, _, err = nif.Get(v1alpha1.NamespacesResource)
+		if err != nil {
+			logger.Fatalf("Error building Namespace informer: %v", err)
+		}
+	}
+
+	controllers := make([]*fairqueue.Impl, 0, len(resources)+len(exts)+3)
+	threads := make(map[*fairqueue.Impl]int, len(resources)+len(exts)+3)
+	addController := func(gvk schema.GroupVersionKind, impl *fairqueue.Impl) {
+		controllers = append(controllers, impl)
+		threads[impl] = defaultWorkers
+		if n, ok := workers[gvk]; ok {
+			threads[impl] = n
+			delete(workers, gvk)
+		}
+	}
+
+	if cachedImageSets {
+		setInformer := cachierInformerFactory.Cachier().V1alpha1().CachedImageSets()
+		synced = append(synced, setInformer.Informer().HasSynced)
+		addController(cachierv1alpha1.SchemeGroupVersion.WithKind("CachedImageSet"), cachedimageset.NewController(
+			logger, cachierClient, setInformer, cachingClient, imageInformer, imageBudget, sharder))
+	}
+	if prewarmSchedules {
+		scheduleInformer := cachierInformerFactory.Cachier().V1alpha1().PrewarmSchedules()
+		synced = append(synced, scheduleInformer.Informer().HasSynced)
+		addController(cachierv1alpha1.SchemeGroupVersion.WithKind("PrewarmSchedule"), prewarm.NewController(
+			logger, cachierClient, scheduleInformer, cachingClient, imageInformer, clock.RealClock{}, imageBudget, sharder))
+	}
+
+	if watchPods && controllerMode == modeTemplates {
+		// The resources' controllers share one Pod handler, which routes
+		// Pods' events to the controllers of the kinds in their owner
+		// chains.
+		impl, router := podowners.NewController(logger, opts.PodInformer, opts.Resolver, sharder)
+		opts.PodRouter = router
+		addController(corev1.SchemeGroupVersion.WithKind("Pod"), impl)
+	}
+	if controllerMode == modePods {
+		addController(corev1.SchemeGroupVersion.WithKind("Pod"), pods.NewController(
+			logger, cachingClient, imageInformer, pods.Options{
+				PodInformer:       opts.PodInformer,
+				Resolver:          opts.Resolver,
+				NamespaceInformer: opts.NamespaceInformer,
+				DefaultMode:       opts.DefaultMode,
+				Debounce:          podDebounce,
+				Budget:            imageBudget,
+				Shard:             sharder,
+			}))
+	}
+
+	// With credentials, the resources' informers index the pull secrets and
+	// ServiceAccounts they use, which must be set up before they start.
+	credentialIndexers := func(convert cachier.Converter) cache.Indexers {
+		if !resolveCredentials {
+			return nil
+		}
+		return cachier.CredentialIndexers(convert)
+	}
+
+	seen := make(map[schema.GroupVersionKind]bool, len(resources)+len(exts))
 	for _, gvk := range resources {
-		controllers = append(controllers, cachier.NewController(
-			logger, dynamicClient, tif, cachingClient, imageInformer, gvk))
+		seen[gvk] = true
+		// Each kind is decoded with the shape that knows where it keeps
+		// its pod template.
+		var tif duck.InformerFactory = &informers.TypedInformerFactory{
+			Client:       dynamicClient,
+			Type:         v1alpha1.DuckTypeFor(gvk),
+			Namespaces:   namespaces,
+			ResyncPeriod: resyncPeriod,
+			StopChannel:  stopCh,
+			Indexers:     credentialIndexers(cachier.AsWithPod),
+		}
+		if trimInformers {
+			// Only keep the fields we need in memory.
+			tif = &informers.TrimmedInformerFactory{
+				Client:       dynamicClient,
+				Type:         v1alpha1.DuckTypeFor(gvk),
+				Namespaces:   namespaces,
+				ResyncPeriod: resyncPeriod,
+				StopChannel:  stopCh,
+				Indexers:     credentialIndexers(cachier.AsWithPod),
+			}
+		}
+		addController(gvk, cachier.NewController(
+			logger, dynamicClient, tif, cachier.AsWithPod, cachingClient, imageInformer, gvk, opts))
+	}
+
+	// Resources with configured extractors are watched via unstructured
+	// informers, since they don't share a common shape.
+	uif := &informers.UnstructuredInformerFactory{
+		Client:       dynamicClient,
+		Namespaces:   namespaces,
+		ResyncPeriod: resyncPeriod,
+		StopChannel:  stopCh,
+	}
+	for _, ext := range exts {
+		if seen[ext.GVK] {
+			logger.Fatalf("Resource %v is configured more than once", ext.GVK)
+		}
+		seen[ext.GVK] = true
+		// Each kind's informer is indexed with its own extractor.
+		extInformers := *uif
+		extInformers.Indexers = credentialIndexers(ext.Extract)
+		addController(ext.GVK, cachier.NewController(
+			logger, dynamicClient, &extInformers, ext.Extract, cachingClient, imageInformer, ext.GVK, opts))
+	}
+	for gvk := range workers {
+		logger.Fatalf("-workers configures %v, which isn't reconciled", gvk)
+	}
+
+	if debugAddr != "" {
+		handlers := map[string]http.Handler{
+			"/debug/vars":    expvar.Handler(),
+			"/debug/budgets": jsonHandler(func() interface{} { return imageBudget.Stats() }),
+			"/metrics":       metricsHandler(imageBudget),
+			"/debug/dry-run": jsonHandler(func() interface{} { return reporter.Stats() }),
+			"/api/images":    imagesHandler(index),
+			"/api/inventory": inv.APIHandler(),
+			"/inventory":     inv.PageHandler("/api/inventory"),
+			"/api/export":    export.Handler(inv),
+		}
+		if sharder != nil {
+			handlers["/debug/shards"] = shardsHandler(sharder)
+		}
+		go serveDebug(logger, debugAddr, handlers)
 	}
 
 	cachingInformerFactory.Start(stopCh)
+	cachierInformerFactory.Start(stopCh)
 
 	// Wait for the caches to be synced before starting controllers.
 	logger.Info("Waiting for informer caches to sync")
-	for i, synced := range []cache.InformerSynced{
-		imageInformer.Informer().HasSynced,
-	} {
+	for i, synced := range synced {
 		if ok := cache.WaitForCacheSync(stopCh, synced); !ok {
 			logger.Fatalf("failed to wait for cache at index %v to sync", i)
 		}
 	}
 
+	if sharder != nil {
+		// Join the group before reconciling anything, so that we know
+		// which namespaces we own.
+		if err := sharder.Start(stopCh); err != nil {
+			logger.Fatalf("Error joining shard group %q: %v", shardGroup, err)
+		}
+	}
+
+	reporter.Start(stopCh)
+
 	// Start all of the controllers.
 	for _, ctrlr := range controllers {
-		go func(ctrlr *controller.Impl) {
+		go func(ctrlr *fairqueue.Impl) {
 			// We don't expect this to return until stop is called,
 			// but if it does, propagate it back.
-			if err := ctrlr.Run(threadsPerController, stopCh); err != nil {
+			if err := ctrlr.Run(threads[ctrlr], stopCh); err != nil {
 				logger.Fatalf("Error running controller: %s", err.Error())
 			}
 		}(ctrlr)
@@ -138,3 +707,164 @@ func (i *gvkListFlag) Set(value string) error {
 	*i = append(*i, *gvk)
 	return nil
 }
+
+// Custom flag type for reading the number of workers per GroupVersionKind.
+type workerCountsFlag map[schema.GroupVersionKind]int
+
+func (w workerCountsFlag) String() string {
+	strs := []string{}
+	for gvk, n := range w {
+		strs = append(strs, fmt.Sprintf("%s=%d", gvk, n))
+	}
+	sort.Strings(strs)
+	return strings.Join(strs, ",")
+}
+
+func (w workerCountsFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("not of the form Kind.version.group=N: %q", value)
+	}
+	gvk, _ := schema.ParseKindArg(parts[0])
+	if gvk == nil {
+		return fmt.Errorf("not a valid GroupVersionKind: %q", parts[0])
+	}
+	n, err := strconv.Atoi(parts[1])
+	if err != nil || n < 1 {
+		return fmt.Errorf("not a positive number of workers: %q", parts[1])
+	}
+	w[*gvk] = n
+	return nil
+}
+
+// serveDebug serves the given debug endpoints on addr.
+func serveDebug(logger *zap.SugaredLogger, addr string, handlers map[string]http.Handler) {
+	mux := http.NewServeMux()
+	for path, h := range handlers {
+		mux.Handle(path, h)
+	}
+	logger.Infof("Serving debug endpoints on %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		logger.Errorf("Error serving debug endpoints: %v", err)
+	}
+}
+
+// jsonHandler serves what f returns as JSON.
+func jsonHandler(f func() interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// metricsHandler serves the counts of the registry budgets and of the work
+// queues in the Prometheus text format, for scraping alongside the rest of
+// the cluster.
+func metricsHandler(b *budget.Budget) http.Handler {
+	budgetMetrics := []struct {
+		name, kind, help string
+		value            func(budget.Stats) int64
+	}{{
+		"cachier_registry_budget_created_total", "counter",
+		"The number of Images admitted by the budget of each registry.",
+		func(s budget.Stats) int64 { return s.Created },
+	}, {
+		"cachier_registry_budget_deferred_total", "counter",
+		"The number of times Images were deferred by the budget of each registry.",
+		func(s budget.Stats) int64 { return s.Deferred },
+	}, {
+		"cachier_registry_budget_queued", "gauge",
+		"The number of Images currently waiting on the budget of each registry.",
+		func(s budget.Stats) int64 { return int64(s.Queued) },
+	}}
+	queueMetrics := []struct {
+		name, kind, help string
+		value            func(fairqueue.QueueStats) int64
+	}{{
+		"cachier_workqueue_depth", "gauge",
+		"The number of keys waiting in each work queue.",
+		func(s fairqueue.QueueStats) int64 { return int64(s.Depth) },
+	}, {
+		"cachier_workqueue_adds_total", "counter",
+		"The number of keys added to each work queue.",
+		func(s fairqueue.QueueStats) int64 { return s.Adds },
+	}}
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		budgets := b.Stats()
+		registries := make([]string, 0, len(budgets))
+		for registry := range budgets {
+			registries = append(registries, registry)
+		}
+		sort.Strings(registries)
+
+		queues := fairqueue.Stats()
+		names := make([]string, 0, len(queues))
+		for name := range queues {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		for _, m := range budgetMetrics {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+			for _, registry := range registries {
+				fmt.Fprintf(w, "%s{registry=\"%s\"} %d\n", m.name, escape.Replace(registry), m.value(budgets[registry]))
+			}
+		}
+		for _, m := range queueMetrics {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+			for _, name := range names {
+				fmt.Fprintf(w, "%s{name=\"%s\"} %d\n", m.name, escape.Replace(name), m.value(queues[name]))
+			}
+		}
+	})
+}
+
+// shardsHandler serves the members of the shard group, and with
+// ?namespace= which of them owns that namespace.
+func shardsHandler(s *sharding.Sharder) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out := map[string]interface{}{
+			"identity": s.Identity(),
+			"members":  s.Members(),
+		}
+		if ns := r.URL.Query().Get("namespace"); ns != "" {
+			out["namespace"] = ns
+			out["owner"] = s.OwnerOf(ns)
+		}
+		jsonHandler(func() interface{} { return out }).ServeHTTP(w, r)
+	})
+}
+
+// imagesHandler serves the workloads that use the images that the query
+// parameters select (see imageindex.ParseQuery).
+func imagesHandler(index *imageindex.Index) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q, err := imageindex.ParseQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		matches := index.Lookup(q)
+		if matches == nil {
+			matches = []imageindex.Match{}
+		}
+		jsonHandler(func() interface{} { return matches }).ServeHTTP(w, r)
+	})
+}
+
+// defaultIdentity names this replica after its Pod (via the POD_NAME
+// environment variable), or failing that its hostname.
+func defaultIdentity() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	name, _ := os.Hostname()
+	return name
+}
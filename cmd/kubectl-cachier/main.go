@@ -0,0 +1,334 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// The kubectl-cachier command is a kubectl plugin that shows what cachier
+// does for a workload, and why.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	cachingclientset "github.com/knative/caching/pkg/client/clientset/versioned"
+	"github.com/knative/pkg/apis/duck"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/extractors"
+	"github.com/mattmoor/cachier/pkg/inspect"
+	"github.com/mattmoor/cachier/pkg/render"
+)
+
+func usage() {
+	fmt.Fprint(os.Stderr, `Usage: kubectl cachier <command> TYPE/NAME [flags]
+
+Commands:
+  status   List the workload's Images by generation, with their Ready conditions.
+  explain  Explain whether, and why, cachier caches the workload's images.
+`)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd := os.Args[1]
+	switch cmd {
+	case "status", "explain":
+	case "help", "-h", "-help", "--help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", cmd)
+		usage()
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+
+	var kubeconfig, kubecontext, namespace string
+	fs.StringVar(&kubeconfig, "kubeconfig", "", "Path to a kubeconfig. Defaults to that of kubectl.")
+	fs.StringVar(&kubecontext, "context", "", "The kubeconfig context to use.")
+	fs.StringVar(&namespace, "namespace", "", "The namespace of the workload. Defaults to that of the context.")
+	fs.StringVar(&namespace, "n", "", "Shorthand for -namespace.")
+
+	var defaultMode, extractorConfig string
+	if cmd == "explain" {
+		fs.StringVar(&defaultMode, "default-mode", string(cachierv1alpha1.ModeOptOut), "The controller's -default-mode.")
+		fs.StringVar(&extractorConfig, "extractors", "", "The controller's -extractors, for workloads that aren't PodSpecable.")
+	}
+
+	args, err := parseArgs(fs, os.Args[2:])
+	if err == nil {
+		err = run(cmd, args, kubeconfig, kubecontext, namespace, defaultMode, extractorConfig)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseArgs parses the flags in args, wherever they appear (kubectl users
+// are used to flags after the resource), returning the rest.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// workload is the resource that a command is about.
+type workload struct {
+	gvr schema.GroupVersionResource
+	gvk schema.GroupVersionKind
+	obj *unstructured.Unstructured
+}
+
+func run(cmd string, args []string, kubeconfig, kubecontext, namespace, defaultMode, extractorConfig string) error {
+	var ref string
+	switch len(args) {
+	case 1:
+		ref = args[0]
+	case 2:
+		ref = args[0] + "/" + args[1]
+	default:
+		return fmt.Errorf("expected TYPE/NAME, got %q", strings.Join(args, " "))
+	}
+	parts := strings.SplitN(ref, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("expected TYPE/NAME, got %q", ref)
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubecontext}
+	overrides.Context.Namespace = namespace
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+	cfg, err := clientConfig.ClientConfig()
+	if err != nil {
+		return err
+	}
+	if namespace, _, err = clientConfig.Namespace(); err != nil {
+		return err
+	}
+
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	lists, err := discovery.ServerPreferredResources(dc)
+	// Groups whose discovery failed (e.g. an unavailable aggregated API)
+	// don't keep us from finding the others.
+	if err != nil && len(lists) == 0 {
+		return err
+	}
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	w := workload{}
+	if w.gvr, w.gvk, err = inspect.FindResource(lists, parts[0]); err != nil {
+		return err
+	}
+	if w.obj, err = dynamicClient.Resource(w.gvr).Namespace(namespace).Get(parts[1], metav1.GetOptions{}); err != nil {
+		return err
+	}
+
+	if cmd == "status" {
+		cachingClient, err := cachingclientset.NewForConfig(cfg)
+		if err != nil {
+			return err
+		}
+		return status(w, cachingClient)
+	}
+
+	mode := cachierv1alpha1.Mode(defaultMode)
+	if mode != cachierv1alpha1.ModeOptIn && mode != cachierv1alpha1.ModeOptOut {
+		return fmt.Errorf("-default-mode must be %q or %q, got %q", cachierv1alpha1.ModeOptIn, cachierv1alpha1.ModeOptOut, defaultMode)
+	}
+	opts := render.Options{
+		Resources:   []schema.GroupVersionKind{w.gvk},
+		DefaultMode: mode,
+	}
+	if extractorConfig != "" {
+		if opts.Extractors, err = extractors.Load(extractorConfig); err != nil {
+			return fmt.Errorf("loading extractors: %v", err)
+		}
+	}
+	return explain(w, dynamicClient, opts)
+}
+
+// describe names the workload like "Deployment foo/bar".
+func (w workload) describe() string {
+	return fmt.Sprintf("%s %s/%s", w.gvk.Kind, w.obj.GetNamespace(), w.obj.GetName())
+}
+
+// printStatus prints what the controller last reported on the workload.
+func (w workload) printStatus() {
+	s := v1alpha1.GetStatus(w.obj)
+	if s == nil {
+		fmt.Println("Status: not reported by cachier (yet)")
+		return
+	}
+	fmt.Printf("Status: cached: %v (%s), as of generation %d\n", s.Cached, s.Reason, s.ObservedGeneration)
+	if s.Policy != "" {
+		fmt.Printf("Policy: %s\n", s.Policy)
+	}
+	for _, x := range []struct {
+		what string
+		list []string
+	}{
+		{"Excluded containers", s.ExcludedContainers},
+		{"Excluded images", s.ExcludedImages},
+		{"Credential errors", s.CredentialErrors},
+		{"Image errors", s.ImageErrors},
+		{"Digest conflicts", s.DigestConflicts},
+	} {
+		if len(x.list) > 0 {
+			fmt.Printf("%s: %s\n", x.what, strings.Join(x.list, ", "))
+		}
+	}
+}
+
+// status implements `kubectl cachier status`.
+func status(w workload, client cachingclientset.Interface) error {
+	thing := &v1alpha1.WithMetadata{}
+	if err := duck.FromUnstructured(w.obj, thing); err != nil {
+		return err
+	}
+	imgs, err := client.CachingV1alpha1().Images(thing.Namespace).List(metav1.ListOptions{
+		LabelSelector: inspect.ImagesSelector(thing).String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s, generation %d\n", w.describe(), thing.Generation)
+	w.printStatus()
+	fmt.Println()
+
+	gens := inspect.Generations(thing, imgs.Items)
+	if len(gens) == 0 {
+		fmt.Println("No Images.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "GENERATION\tNAME\tIMAGE\tREADY\tREASON\tMESSAGE")
+	for _, g := range gens {
+		gen := fmt.Sprint(g.Generation)
+		if g.Current {
+			gen += " (current)"
+		}
+		for _, img := range g.Images {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", gen, img.Name, img.Image, img.Ready, img.Reason, img.Message)
+		}
+	}
+	return tw.Flush()
+}
+
+// explain implements `kubectl cachier explain`.  It decides with the same
+// inputs as the controller: the workload, its Namespace, and the
+// CachePolicies and ClusterCachePolicies, as far as we may read them.
+func explain(w workload, client dynamic.Interface, opts render.Options) error {
+	objs := []*unstructured.Unstructured{w.obj}
+	ns, err := client.Resource(v1alpha1.NamespacesResource).Get(w.obj.GetNamespace(), metav1.GetOptions{})
+	if err == nil {
+		objs = append(objs, ns)
+	} else if !errors.IsForbidden(err) {
+		return err
+	} else {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring the Namespace: %v\n", err)
+	}
+	for _, x := range []struct {
+		gvr       schema.GroupVersionResource
+		namespace string
+	}{
+		{cachierv1alpha1.SchemeGroupVersion.WithResource("cachepolicies"), w.obj.GetNamespace()},
+		{cachierv1alpha1.SchemeGroupVersion.WithResource("clustercachepolicies"), ""},
+	} {
+		list, err := client.Resource(x.gvr).Namespace(x.namespace).List(metav1.ListOptions{})
+		if errors.IsForbidden(err) || errors.IsNotFound(err) {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring %s: %v\n", x.gvr.Resource, err)
+			continue
+		} else if err != nil {
+			return err
+		}
+		for i := range list.Items {
+			objs = append(objs, &list.Items[i])
+		}
+	}
+
+	results, err := render.Render(objs, opts)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("%s isn't a workload whose images cachier caches", w.describe())
+	}
+	r := results[0]
+
+	fmt.Println(w.describe())
+	for i, step := range r.Path {
+		fmt.Printf("  %d. %s\n", i+1, step)
+	}
+	fmt.Printf("Decision: cache: %v (%s)\n", r.Decision.Cache, r.Decision.Reason)
+	if len(r.Decision.ExcludedContainers) > 0 {
+		fmt.Printf("Excluded containers: %s\n", strings.Join(r.Decision.ExcludedContainers, ", "))
+	}
+	if r.Decision.Cache {
+		fmt.Println("Images:")
+		refs := append(render.Images(results[:1]), r.ExcludedImages...)
+		for _, ref := range refs {
+			allowed, why := r.Decision.ExplainImage(ref)
+			fmt.Printf("  %s: %s\n", ref, imageVerdict(allowed, why))
+		}
+	}
+
+	fmt.Println()
+	w.printStatus()
+	if s := v1alpha1.GetStatus(w.obj); s != nil && (s.Cached != r.Decision.Cache || s.Reason != r.Decision.Reason) {
+		fmt.Println("Note: this differs from what the controller last reported, so its flags (e.g. -default-mode) may differ, or it may not have caught up yet.")
+	}
+	return nil
+}
+
+// imageVerdict describes whether an image is cached, and why.
+func imageVerdict(allowed bool, why string) string {
+	if allowed {
+		return "cached (" + why + ")"
+	}
+	return "not cached (" + why + ")"
+}
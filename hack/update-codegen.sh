@@ -22,6 +22,20 @@ source $(dirname $0)/../vendor/github.com/knative/test-infra/scripts/library.sh
 
 CODEGEN_PKG=${CODEGEN_PKG:-$(cd ${REPO_ROOT_DIR}; ls -d -1 ./vendor/k8s.io/code-generator 2>/dev/null || echo ../code-generator)}
 
+${CODEGEN_PKG}/generate-groups.sh "deepcopy,informer,lister" \
+  github.com/mattmoor/cachier/pkg/client github.com/mattmoor/cachier/pkg/apis \
+  "cachier:v1alpha1" \
+  --go-header-file ${REPO_ROOT_DIR}/hack/boilerplate.go.txt
+
+# The clientset is generated separately, without the fake, because we don't
+# vendor k8s.io/client-go/testing, which the fake needs.
+(cd ${CODEGEN_PKG} && go install ./cmd/client-gen)
+${GOPATH}/bin/client-gen --clientset-name versioned --input-base "" \
+  --input github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1 \
+  --output-package github.com/mattmoor/cachier/pkg/client/clientset \
+  --fake-clientset=false \
+  --go-header-file ${REPO_ROOT_DIR}/hack/boilerplate.go.txt
+
 # Only deepcopy the Duck types, as they are not real resources.
 ${CODEGEN_PKG}/generate-groups.sh "deepcopy" \
   github.com/mattmoor/cachier/pkg/client github.com/mattmoor/cachier/pkg/apis \
@@ -0,0 +1,99 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/knative/pkg/apis"
+)
+
+// GetCondition returns the condition of the given type, or nil.
+func (ss *CachedImageSetStatus) GetCondition(t CachedImageSetConditionType) *CachedImageSetCondition {
+	for i := range ss.Conditions {
+		if ss.Conditions[i].Type == t {
+			return &ss.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// IsReady returns whether all of the Images are ready.
+func (ss *CachedImageSetStatus) IsReady() bool {
+	c := ss.GetCondition(CachedImageSetConditionReady)
+	return c != nil && c.Status == corev1.ConditionTrue
+}
+
+// setCondition sets the given condition, preserving its LastTransitionTime
+// when its status doesn't change.
+func (ss *CachedImageSetStatus) setCondition(new CachedImageSetCondition) {
+	if old := ss.GetCondition(new.Type); old != nil {
+		if old.Status == new.Status {
+			new.LastTransitionTime = old.LastTransitionTime
+		} else {
+			new.LastTransitionTime = apis.VolatileTime{Inner: metav1.NewTime(time.Now())}
+		}
+		*old = new
+		return
+	}
+	new.LastTransitionTime = apis.VolatileTime{Inner: metav1.NewTime(time.Now())}
+	ss.Conditions = append(ss.Conditions, new)
+}
+
+// PropagateImages records the state of the Images, and aggregates their
+// readiness into the Ready condition: it is False if any Image isn't
+// ready, True if all of them are, and Unknown otherwise.
+func (ss *CachedImageSetStatus) PropagateImages(images []CachedImageState) {
+	ss.Images = images
+
+	var notReady, unknown []string
+	for _, img := range images {
+		switch img.Ready {
+		case corev1.ConditionTrue:
+		case corev1.ConditionFalse:
+			notReady = append(notReady, img.Image)
+		default:
+			unknown = append(unknown, img.Image)
+		}
+	}
+
+	switch {
+	case len(notReady) > 0:
+		ss.setCondition(CachedImageSetCondition{
+			Type:    CachedImageSetConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  "ImagesNotReady",
+			Message: fmt.Sprintf("%d of %d images are not ready: %v", len(notReady), len(images), notReady),
+		})
+	case len(unknown) > 0:
+		ss.setCondition(CachedImageSetCondition{
+			Type:    CachedImageSetConditionReady,
+			Status:  corev1.ConditionUnknown,
+			Reason:  "ImagesPending",
+			Message: fmt.Sprintf("waiting for %d of %d images", len(unknown), len(images)),
+		})
+	default:
+		ss.setCondition(CachedImageSetCondition{
+			Type:   CachedImageSetConditionReady,
+			Status: corev1.ConditionTrue,
+		})
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestPropagateImages(t *testing.T) {
+	tests := []struct {
+		name   string
+		images []CachedImageState
+		want   corev1.ConditionStatus
+	}{{
+		name: "empty",
+		want: corev1.ConditionTrue,
+	}, {
+		name: "all ready",
+		images: []CachedImageState{{
+			Image: "busybox",
+			Ready: corev1.ConditionTrue,
+		}, {
+			Image: "nginx",
+			Ready: corev1.ConditionTrue,
+		}},
+		want: corev1.ConditionTrue,
+	}, {
+		name: "pending",
+		images: []CachedImageState{{
+			Image: "busybox",
+			Ready: corev1.ConditionTrue,
+		}, {
+			Image: "nginx",
+			Ready: corev1.ConditionUnknown,
+		}},
+		want: corev1.ConditionUnknown,
+	}, {
+		name: "failed beats pending",
+		images: []CachedImageState{{
+			Image: "busybox",
+			Ready: corev1.ConditionFalse,
+		}, {
+			Image: "nginx",
+			Ready: corev1.ConditionUnknown,
+		}},
+		want: corev1.ConditionFalse,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status := &CachedImageSetStatus{}
+			status.PropagateImages(test.images)
+			c := status.GetCondition(CachedImageSetConditionReady)
+			if c == nil {
+				t.Fatal("GetCondition(Ready) = nil")
+			}
+			if c.Status != test.want {
+				t.Errorf("Ready = %v, wanted %v", c.Status, test.want)
+			}
+			if got, want := status.IsReady(), test.want == corev1.ConditionTrue; got != want {
+				t.Errorf("IsReady() = %v, wanted %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPropagateImagesPreservesTransitionTime(t *testing.T) {
+	status := &CachedImageSetStatus{}
+	ready := []CachedImageState{{Image: "busybox", Ready: corev1.ConditionTrue}}
+	status.PropagateImages(ready)
+	before := status.GetCondition(CachedImageSetConditionReady).LastTransitionTime
+
+	status.PropagateImages(ready)
+	if after := status.GetCondition(CachedImageSetConditionReady).LastTransitionTime; after != before {
+		t.Errorf("LastTransitionTime = %v, wanted %v", after, before)
+	}
+}
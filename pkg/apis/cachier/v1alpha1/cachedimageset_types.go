@@ -0,0 +1,140 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/knative/pkg/apis"
+	"github.com/knative/pkg/kmeta"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CachedImageSet lists images that should be cached, which aren't tied to
+// any workload (e.g. images for debugging, or for an upcoming launch).
+type CachedImageSet struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec holds the desired state of the CachedImageSet (from the client).
+	// +optional
+	Spec CachedImageSetSpec `json:"spec,omitempty"`
+
+	// Status communicates the observed state of the CachedImageSet (from the controller).
+	// +optional
+	Status CachedImageSetStatus `json:"status,omitempty"`
+}
+
+// Check that CachedImageSet can be validated and own resources.
+var _ apis.Validatable = (*CachedImageSet)(nil)
+var _ kmeta.OwnerRefable = (*CachedImageSet)(nil)
+
+// CachedImageSetSpec holds the desired state of the CachedImageSet.
+type CachedImageSetSpec struct {
+	// Images holds the references of the images to cache.
+	Images []string `json:"images"`
+
+	// ServiceAccountName is the name of the ServiceAccount whose pull
+	// secrets are used to pull the images.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// ImagePullSecrets names additional Secrets with which to pull the
+	// images.
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+}
+
+// CachedImageSetConditionType is the type of a CachedImageSet condition.
+type CachedImageSetConditionType string
+
+const (
+	// CachedImageSetConditionReady becomes true when all of the Images of
+	// the CachedImageSet are ready.
+	CachedImageSetConditionReady CachedImageSetConditionType = "Ready"
+)
+
+// CachedImageSetCondition defines a readiness condition for a CachedImageSet.
+type CachedImageSetCondition struct {
+	Type CachedImageSetConditionType `json:"type" description:"type of CachedImageSet condition"`
+
+	Status corev1.ConditionStatus `json:"status" description:"status of the condition, one of True, False, Unknown"`
+
+	// +optional
+	// We use VolatileTime in place of metav1.Time to exclude this from creating equality.Semantic
+	// differences (all other things held constant).
+	LastTransitionTime apis.VolatileTime `json:"lastTransitionTime,omitempty" description:"last time the condition transit from one status to another"`
+
+	// +optional
+	Reason string `json:"reason,omitempty" description:"one-word CamelCase reason for the condition's last transition"`
+
+	// +optional
+	Message string `json:"message,omitempty" description:"human-readable message indicating details about last transition"`
+}
+
+// CachedImageState is the observed state of one of the Images of a
+// CachedImageSet.
+type CachedImageState struct {
+	// Image is the reference of the image.
+	Image string `json:"image"`
+
+	// Name is the name of the Image resource caching it.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Ready is the status of the Image's Ready condition.
+	Ready corev1.ConditionStatus `json:"ready"`
+
+	// Message explains why the Image isn't ready.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// CachedImageSetStatus communicates the observed state of the CachedImageSet.
+type CachedImageSetStatus struct {
+	// ObservedGeneration is the generation of the CachedImageSet to which
+	// this status applies.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions communicates the aggregate readiness of the Images.
+	// +optional
+	Conditions []CachedImageSetCondition `json:"conditions,omitempty"`
+
+	// Images holds the state of each of the Images.
+	// +optional
+	Images []CachedImageState `json:"images,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CachedImageSetList is a list of CachedImageSet resources
+type CachedImageSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []CachedImageSet `json:"items"`
+}
+
+func (s *CachedImageSet) GetGroupVersionKind() schema.GroupVersionKind {
+	return SchemeGroupVersion.WithKind("CachedImageSet")
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"strings"
+
+	"github.com/knative/pkg/apis"
+)
+
+func (s *CachedImageSet) Validate() *apis.FieldError {
+	return s.Spec.Validate().ViaField("spec")
+}
+
+func (ss *CachedImageSetSpec) Validate() *apis.FieldError {
+	var errs *apis.FieldError
+	if len(ss.Images) == 0 {
+		errs = errs.Also(apis.ErrMissingField("images"))
+	}
+	for i, image := range ss.Images {
+		if strings.TrimSpace(image) == "" {
+			errs = errs.Also(apis.ErrInvalidValue(image, apis.CurrentField).ViaFieldIndex("images", i))
+		}
+	}
+	for i, ref := range ss.ImagePullSecrets {
+		if ref.Name == "" {
+			errs = errs.Also(apis.ErrMissingField("name").ViaFieldIndex("imagePullSecrets", i))
+		}
+	}
+	return errs
+}
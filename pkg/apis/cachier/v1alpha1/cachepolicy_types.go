@@ -0,0 +1,169 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/knative/pkg/apis"
+	"github.com/knative/pkg/kmeta"
+)
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CachePolicy configures how cachier treats the resources in its namespace.
+// It takes precedence over any ClusterCachePolicy.
+type CachePolicy struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec holds the desired state of the CachePolicy (from the client).
+	// +optional
+	Spec CachePolicySpec `json:"spec,omitempty"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterCachePolicy configures how cachier treats the resources in the
+// namespaces that it selects.
+type ClusterCachePolicy struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec holds the desired state of the ClusterCachePolicy (from the client).
+	// +optional
+	Spec CachePolicySpec `json:"spec,omitempty"`
+}
+
+// Check that our policies can be validated, defaulted and own resources.
+var _ apis.Validatable = (*CachePolicy)(nil)
+var _ apis.Defaultable = (*CachePolicy)(nil)
+var _ kmeta.OwnerRefable = (*CachePolicy)(nil)
+var _ apis.Validatable = (*ClusterCachePolicy)(nil)
+var _ apis.Defaultable = (*ClusterCachePolicy)(nil)
+var _ kmeta.OwnerRefable = (*ClusterCachePolicy)(nil)
+
+// Mode is whether resources are cached unless they opt out, or only when
+// they opt in.
+type Mode string
+
+const (
+	// ModeOptOut caches resources unless they opt out.
+	ModeOptOut Mode = "OptOut"
+
+	// ModeOptIn only caches resources that opt in.
+	ModeOptIn Mode = "OptIn"
+)
+
+// CachePolicySpec holds the desired state of a CachePolicy or
+// ClusterCachePolicy.
+type CachePolicySpec struct {
+	// Selector selects the resources to which the policy applies by their
+	// labels.  When omitted, it applies to all resources.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// NamespaceSelector selects the namespaces to which the policy applies
+	// by their labels.  When omitted, it applies to all namespaces.  It is
+	// only allowed on ClusterCachePolicy.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Mode is the default for the resources to which the policy applies.
+	// Defaults to OptOut.
+	// +optional
+	Mode Mode `json:"mode,omitempty"`
+
+	// Images filters the image references that are cached.
+	// +optional
+	Images ImageFilter `json:"images,omitempty"`
+
+	// Overrides changes the mode for particular kinds of resource.
+	// +optional
+	Overrides []ResourceOverride `json:"overrides,omitempty"`
+
+	// AllowAnnotationOverride is whether the cachier.mattmoor.io/decorate
+	// annotation on resources may override the policy.  Defaults to true.
+	// +optional
+	AllowAnnotationOverride *bool `json:"allowAnnotationOverride,omitempty"`
+}
+
+// ImageFilter filters image references with glob patterns, where `*`
+// matches any sequence of characters (including `/`) and `?` matches any
+// single character.
+type ImageFilter struct {
+	// Include, when non-empty, limits caching to references that match one
+	// of these patterns.
+	// +optional
+	Include []string `json:"include,omitempty"`
+
+	// Exclude keeps references that match any of these patterns from being
+	// cached.  It takes precedence over Include.
+	// +optional
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// ResourceOverride changes the mode for a particular kind of resource.
+type ResourceOverride struct {
+	// Resource is the kind to which this applies, in the form Kind.group
+	// (e.g. Deployment.apps, or Job.batch).
+	Resource string `json:"resource"`
+
+	// Mode is the mode for resources of this kind.
+	Mode Mode `json:"mode"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CachePolicyList is a list of CachePolicy resources
+type CachePolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []CachePolicy `json:"items"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterCachePolicyList is a list of ClusterCachePolicy resources
+type ClusterCachePolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []ClusterCachePolicy `json:"items"`
+}
+
+// GroupKind returns the kind to which the override applies.
+func (o *ResourceOverride) GroupKind() schema.GroupKind {
+	return schema.ParseGroupKind(o.Resource)
+}
+
+func (p *CachePolicy) GetGroupVersionKind() schema.GroupVersionKind {
+	return SchemeGroupVersion.WithKind("CachePolicy")
+}
+
+func (p *ClusterCachePolicy) GetGroupVersionKind() schema.GroupVersionKind {
+	return SchemeGroupVersion.WithKind("ClusterCachePolicy")
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"strings"
+
+	"github.com/knative/pkg/apis"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (p *CachePolicy) Validate() *apis.FieldError {
+	errs := p.Spec.Validate().ViaField("spec")
+	if p.Spec.NamespaceSelector != nil {
+		errs = errs.Also(apis.ErrDisallowedFields("spec.namespaceSelector"))
+	}
+	return errs
+}
+
+func (p *ClusterCachePolicy) Validate() *apis.FieldError {
+	errs := p.Spec.Validate().ViaField("spec")
+	if err := validateSelector(p.Spec.NamespaceSelector); err != nil {
+		errs = errs.Also(err.ViaField("spec", "namespaceSelector"))
+	}
+	return errs
+}
+
+func (ps *CachePolicySpec) Validate() *apis.FieldError {
+	var errs *apis.FieldError
+	if err := validateSelector(ps.Selector); err != nil {
+		errs = errs.Also(err.ViaField("selector"))
+	}
+	if err := validateMode(ps.Mode, true); err != nil {
+		errs = errs.Also(err.ViaField("mode"))
+	}
+	errs = errs.Also(ps.Images.Validate().ViaField("images"))
+	for i, o := range ps.Overrides {
+		errs = errs.Also(o.Validate().ViaFieldIndex("overrides", i))
+	}
+	return errs
+}
+
+func (f *ImageFilter) Validate() *apis.FieldError {
+	var errs *apis.FieldError
+	for i, pattern := range f.Include {
+		if strings.TrimSpace(pattern) == "" {
+			errs = errs.Also(apis.ErrInvalidValue(pattern, apis.CurrentField).ViaFieldIndex("include", i))
+		}
+	}
+	for i, pattern := range f.Exclude {
+		if strings.TrimSpace(pattern) == "" {
+			errs = errs.Also(apis.ErrInvalidValue(pattern, apis.CurrentField).ViaFieldIndex("exclude", i))
+		}
+	}
+	return errs
+}
+
+func (o *ResourceOverride) Validate() *apis.FieldError {
+	var errs *apis.FieldError
+	if o.Resource == "" {
+		errs = errs.Also(apis.ErrMissingField("resource"))
+	} else if gk := o.GroupKind(); gk.Kind == "" {
+		errs = errs.Also(apis.ErrInvalidValue(o.Resource, "resource"))
+	}
+	if err := validateMode(o.Mode, false); err != nil {
+		errs = errs.Also(err.ViaField("mode"))
+	}
+	return errs
+}
+
+func validateSelector(s *metav1.LabelSelector) *apis.FieldError {
+	if s == nil {
+		return nil
+	}
+	if _, err := metav1.LabelSelectorAsSelector(s); err != nil {
+		return &apis.FieldError{
+			Message: err.Error(),
+			Paths:   []string{apis.CurrentField},
+		}
+	}
+	return nil
+}
+
+func validateMode(m Mode, optional bool) *apis.FieldError {
+	switch m {
+	case ModeOptIn, ModeOptOut:
+		return nil
+	case "":
+		if optional {
+			return nil
+		}
+		return apis.ErrMissingField(apis.CurrentField)
+	default:
+		return apis.ErrInvalidValue(string(m), apis.CurrentField)
+	}
+}
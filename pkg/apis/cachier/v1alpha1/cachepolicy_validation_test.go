@@ -0,0 +1,104 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/knative/pkg/apis"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCachePolicyValidation(t *testing.T) {
+	badSelector := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      "env",
+			Operator: "Maybe",
+		}},
+	}
+
+	tests := []struct {
+		name string
+		p    apis.Validatable
+		want string
+	}{{
+		name: "empty",
+		p:    &CachePolicy{},
+	}, {
+		name: "valid",
+		p: &ClusterCachePolicy{
+			Spec: CachePolicySpec{
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"env": "prod"},
+				},
+				Mode: ModeOptIn,
+				Images: ImageFilter{
+					Include: []string{"gcr.io/*"},
+				},
+				Overrides: []ResourceOverride{{
+					Resource: "Job.batch",
+					Mode:     ModeOptOut,
+				}},
+			},
+		},
+	}, {
+		name: "namespace selector on CachePolicy",
+		p: &CachePolicy{
+			Spec: CachePolicySpec{
+				NamespaceSelector: &metav1.LabelSelector{},
+			},
+		},
+		want: "must not set the field(s): spec.namespaceSelector",
+	}, {
+		name: "bad mode",
+		p: &CachePolicy{
+			Spec: CachePolicySpec{Mode: "Sometimes"},
+		},
+		want: `invalid value "Sometimes": spec.mode`,
+	}, {
+		name: "bad selector",
+		p: &ClusterCachePolicy{
+			Spec: CachePolicySpec{NamespaceSelector: badSelector},
+		},
+		want: `"Maybe" is not a valid pod selector operator: spec.namespaceSelector`,
+	}, {
+		name: "empty pattern",
+		p: &CachePolicy{
+			Spec: CachePolicySpec{
+				Images: ImageFilter{Exclude: []string{"ok", " "}},
+			},
+		},
+		want: `invalid value " ": spec.images.exclude[1]`,
+	}, {
+		name: "override without mode",
+		p: &CachePolicy{
+			Spec: CachePolicySpec{
+				Overrides: []ResourceOverride{{Resource: "Job.batch"}},
+			},
+		},
+		want: "missing field(s): spec.overrides[0].mode",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.p.Validate()
+			if gotStr := got.Error(); gotStr != test.want {
+				t.Errorf("Validate() = %q, wanted %q", gotStr, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"time"
+
+	"github.com/mattmoor/cachier/pkg/cron"
+)
+
+// Window is a span of time during which images are cached.
+// +k8s:deepcopy-gen=false
+type Window struct {
+	Start, End time.Time
+}
+
+// Contains returns whether t is within the window.
+func (w Window) Contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// Windows returns the window that is in progress at now, or else the last
+// one before it, and the next one to begin after now.  Either may be nil.
+// The spec is assumed to be valid.
+func (ps *PrewarmScheduleSpec) Windows(now time.Time) (current, next *Window) {
+	if ps.Start != nil {
+		w := &Window{Start: ps.Start.Time}
+		if ps.Expiry != nil {
+			w.End = ps.Expiry.Time
+		} else if ps.Duration != nil {
+			w.End = w.Start.Add(ps.Duration.Duration)
+		}
+		if now.Before(w.Start) {
+			return nil, w
+		}
+		return w, nil
+	}
+
+	schedule, err := cron.Parse(ps.Schedule)
+	if err != nil || ps.Duration == nil {
+		return nil, nil
+	}
+	now = now.UTC()
+	if start := schedule.Prev(now); !start.IsZero() {
+		current = &Window{Start: start, End: start.Add(ps.Duration.Duration)}
+	}
+	if start := schedule.Next(now); !start.IsZero() {
+		next = &Window{Start: start, End: start.Add(ps.Duration.Duration)}
+	}
+	return current, next
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWindows(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatalf("time.Parse(%q) = %v", s, err)
+		}
+		return tm
+	}
+	mtime := func(s string) *metav1.Time {
+		mt := metav1.NewTime(at(s))
+		return &mt
+	}
+	hours := func(h int) *metav1.Duration {
+		return &metav1.Duration{Duration: time.Duration(h) * time.Hour}
+	}
+
+	tests := []struct {
+		name        string
+		spec        PrewarmScheduleSpec
+		now         string
+		wantCurrent *Window
+		wantNext    *Window
+		wantActive  bool
+	}{{
+		name: "before single window",
+		spec: PrewarmScheduleSpec{
+			Start:  mtime("2018-10-01T06:00:00Z"),
+			Expiry: mtime("2018-10-01T18:00:00Z"),
+		},
+		now:      "2018-10-01T05:00:00Z",
+		wantNext: &Window{Start: at("2018-10-01T06:00:00Z"), End: at("2018-10-01T18:00:00Z")},
+	}, {
+		name: "during single window",
+		spec: PrewarmScheduleSpec{
+			Start:    mtime("2018-10-01T06:00:00Z"),
+			Duration: hours(4),
+		},
+		now:         "2018-10-01T09:59:00Z",
+		wantCurrent: &Window{Start: at("2018-10-01T06:00:00Z"), End: at("2018-10-01T10:00:00Z")},
+		wantActive:  true,
+	}, {
+		name: "after single window",
+		spec: PrewarmScheduleSpec{
+			Start:    mtime("2018-10-01T06:00:00Z"),
+			Duration: hours(4),
+		},
+		now:         "2018-10-01T10:00:00Z",
+		wantCurrent: &Window{Start: at("2018-10-01T06:00:00Z"), End: at("2018-10-01T10:00:00Z")},
+	}, {
+		name: "during recurring window",
+		spec: PrewarmScheduleSpec{
+			Schedule: "0 6 * * *",
+			Duration: hours(2),
+		},
+		now:         "2018-10-01T07:30:00Z",
+		wantCurrent: &Window{Start: at("2018-10-01T06:00:00Z"), End: at("2018-10-01T08:00:00Z")},
+		wantNext:    &Window{Start: at("2018-10-02T06:00:00Z"), End: at("2018-10-02T08:00:00Z")},
+		wantActive:  true,
+	}, {
+		name: "between recurring windows",
+		spec: PrewarmScheduleSpec{
+			Schedule: "0 6 * * *",
+			Duration: hours(2),
+		},
+		now:         "2018-10-01T12:00:00Z",
+		wantCurrent: &Window{Start: at("2018-10-01T06:00:00Z"), End: at("2018-10-01T08:00:00Z")},
+		wantNext:    &Window{Start: at("2018-10-02T06:00:00Z"), End: at("2018-10-02T08:00:00Z")},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now := at(test.now)
+			current, next := test.spec.Windows(now)
+			if !sameWindow(current, test.wantCurrent) {
+				t.Errorf("Windows() current = %v, wanted %v", current, test.wantCurrent)
+			}
+			if !sameWindow(next, test.wantNext) {
+				t.Errorf("Windows() next = %v, wanted %v", next, test.wantNext)
+			}
+			if got := current != nil && current.Contains(now); got != test.wantActive {
+				t.Errorf("Contains() = %v, wanted %v", got, test.wantActive)
+			}
+		})
+	}
+}
+
+func sameWindow(a, b *Window) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Start.Equal(b.Start) && a.End.Equal(b.End)
+}
+
+func TestPrewarmScheduleValidation(t *testing.T) {
+	start := metav1.NewTime(time.Date(2018, 10, 1, 6, 0, 0, 0, time.UTC))
+	hour := &metav1.Duration{Duration: time.Hour}
+
+	tests := []struct {
+		name string
+		spec PrewarmScheduleSpec
+		want string
+	}{{
+		name: "valid single window",
+		spec: PrewarmScheduleSpec{Images: []string{"busybox"}, Start: &start, Duration: hour},
+	}, {
+		name: "valid recurring window",
+		spec: PrewarmScheduleSpec{Images: []string{"busybox"}, Schedule: "0 6 * * 1-5", Duration: hour},
+	}, {
+		name: "no images",
+		spec: PrewarmScheduleSpec{Start: &start, Duration: hour},
+		want: "expected exactly one, got neither: spec.images, spec.manifest",
+	}, {
+		name: "no window",
+		spec: PrewarmScheduleSpec{Images: []string{"busybox"}},
+		want: "expected exactly one, got neither: spec.schedule, spec.start",
+	}, {
+		name: "bad schedule",
+		spec: PrewarmScheduleSpec{Images: []string{"busybox"}, Schedule: "0 25 * * *", Duration: hour},
+		want: "invalid hour \"25\": 25-25 is outside of 0-23: spec.schedule",
+	}, {
+		name: "expiry before start",
+		spec: PrewarmScheduleSpec{Images: []string{"busybox"}, Start: &start, Expiry: &start},
+		want: "invalid value \"" + start.String() + "\": spec.expiry",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ps := &PrewarmSchedule{Spec: test.spec}
+			if got := ps.Validate().Error(); got != test.want {
+				t.Errorf("Validate() = %q, wanted %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/knative/pkg/apis"
+	"github.com/knative/pkg/kmeta"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PrewarmSchedule caches images during a window of time, e.g. in the hours
+// before a planned release rolls them out.
+type PrewarmSchedule struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec holds the desired state of the PrewarmSchedule (from the client).
+	// +optional
+	Spec PrewarmScheduleSpec `json:"spec,omitempty"`
+
+	// Status communicates the observed state of the PrewarmSchedule (from the controller).
+	// +optional
+	Status PrewarmScheduleStatus `json:"status,omitempty"`
+}
+
+// Check that PrewarmSchedule can be validated and own resources.
+var _ apis.Validatable = (*PrewarmSchedule)(nil)
+var _ kmeta.OwnerRefable = (*PrewarmSchedule)(nil)
+
+// PrewarmScheduleSpec holds the desired state of the PrewarmSchedule.  It
+// names the images to cache, via Images and/or Manifest, and either a single
+// window (Start until Expiry, or for Duration) or a recurring one (Schedule,
+// for Duration).
+type PrewarmScheduleSpec struct {
+	// Images holds the references of images to cache.
+	// +optional
+	Images []string `json:"images,omitempty"`
+
+	// Manifest is a resource (e.g. the Deployment about to be rolled out)
+	// from whose pod template to extract images to cache.
+	// +optional
+	Manifest *runtime.RawExtension `json:"manifest,omitempty"`
+
+	// ServiceAccountName is the name of the ServiceAccount whose pull
+	// secrets are used to pull the images.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// ImagePullSecrets names additional Secrets with which to pull the
+	// images.
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// Start is when a single window begins.
+	// +optional
+	Start *metav1.Time `json:"start,omitempty"`
+
+	// Schedule is a cron expression (in UTC) for when recurring windows
+	// begin, e.g. "0 6 * * 1-5".
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+
+	// Duration is how long each window lasts.  It is required with
+	// Schedule, and with Start unless Expiry is set.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// Expiry is when a single window ends.
+	// +optional
+	Expiry *metav1.Time `json:"expiry,omitempty"`
+}
+
+// PrewarmScheduleStatus communicates the observed state of the PrewarmSchedule.
+type PrewarmScheduleStatus struct {
+	// ObservedGeneration is the generation of the PrewarmSchedule to which
+	// this status applies.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Active is whether a window is in progress.
+	Active bool `json:"active"`
+
+	// WindowStart and WindowEnd bound the window in progress, or else the
+	// last one.
+	// +optional
+	WindowStart *metav1.Time `json:"windowStart,omitempty"`
+	// +optional
+	WindowEnd *metav1.Time `json:"windowEnd,omitempty"`
+
+	// NextWindowStart is when the next window begins, if any.
+	// +optional
+	NextWindowStart *metav1.Time `json:"nextWindowStart,omitempty"`
+
+	// Images holds the references of the images cached during windows.
+	// +optional
+	Images []string `json:"images,omitempty"`
+
+	// RetainedImages holds the references of images that are kept cached
+	// after the window, because workloads have since adopted them.
+	// +optional
+	RetainedImages []string `json:"retainedImages,omitempty"`
+
+	// Error describes why the images couldn't be determined, e.g. an
+	// invalid manifest.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PrewarmScheduleList is a list of PrewarmSchedule resources
+type PrewarmScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []PrewarmSchedule `json:"items"`
+}
+
+func (s *PrewarmSchedule) GetGroupVersionKind() schema.GroupVersionKind {
+	return SchemeGroupVersion.WithKind("PrewarmSchedule")
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"strings"
+
+	"github.com/knative/pkg/apis"
+
+	"github.com/mattmoor/cachier/pkg/cron"
+)
+
+func (s *PrewarmSchedule) Validate() *apis.FieldError {
+	return s.Spec.Validate().ViaField("spec")
+}
+
+func (ps *PrewarmScheduleSpec) Validate() *apis.FieldError {
+	var errs *apis.FieldError
+	if len(ps.Images) == 0 && ps.Manifest == nil {
+		errs = errs.Also(apis.ErrMissingOneOf("images", "manifest"))
+	}
+	for i, image := range ps.Images {
+		if strings.TrimSpace(image) == "" {
+			errs = errs.Also(apis.ErrInvalidValue(image, apis.CurrentField).ViaFieldIndex("images", i))
+		}
+	}
+	if ps.Duration != nil && ps.Duration.Duration <= 0 {
+		errs = errs.Also(apis.ErrInvalidValue(ps.Duration.Duration.String(), "duration"))
+	}
+
+	switch {
+	case ps.Start != nil && ps.Schedule != "":
+		errs = errs.Also(apis.ErrMultipleOneOf("start", "schedule"))
+	case ps.Start != nil:
+		switch {
+		case ps.Expiry != nil && ps.Duration != nil:
+			errs = errs.Also(apis.ErrMultipleOneOf("duration", "expiry"))
+		case ps.Expiry == nil && ps.Duration == nil:
+			errs = errs.Also(apis.ErrMissingOneOf("duration", "expiry"))
+		case ps.Expiry != nil && !ps.Expiry.After(ps.Start.Time):
+			errs = errs.Also(apis.ErrInvalidValue(ps.Expiry.String(), "expiry"))
+		}
+	case ps.Schedule != "":
+		if _, err := cron.Parse(ps.Schedule); err != nil {
+			errs = errs.Also(&apis.FieldError{
+				Message: err.Error(),
+				Paths:   []string{"schedule"},
+			})
+		}
+		if ps.Duration == nil {
+			errs = errs.Also(apis.ErrMissingField("duration"))
+		}
+		if ps.Expiry != nil {
+			errs = errs.Also(apis.ErrDisallowedFields("expiry"))
+		}
+	default:
+		errs = errs.Also(apis.ErrMissingOneOf("start", "schedule"))
+	}
+	return errs
+}
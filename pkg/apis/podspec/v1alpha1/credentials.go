@@ -0,0 +1,101 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/knative/pkg/apis"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WithImagePullSecrets is a lean view of a ServiceAccount, which only
+// decodes the pull secrets attached to it.
+type WithImagePullSecrets struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WithImagePullSecretsList is a list of WithImagePullSecrets resources
+type WithImagePullSecretsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []WithImagePullSecrets `json:"items"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WithSecretType is a lean view of a Secret, which only decodes its type.
+// In particular, we never hold on to the contents of Secrets.
+type WithSecretType struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Type corev1.SecretType `json:"type,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WithSecretTypeList is a list of WithSecretType resources
+type WithSecretTypeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []WithSecretType `json:"items"`
+}
+
+// Ensure WithImagePullSecrets and WithSecretType satisfy apis.Listable
+var _ apis.Listable = (*WithImagePullSecrets)(nil)
+var _ apis.Listable = (*WithSecretType)(nil)
+
+var (
+	// ServiceAccountsResource is the resource we watch to populate
+	// WithImagePullSecrets.
+	ServiceAccountsResource = schema.GroupVersionResource{Version: "v1", Resource: "serviceaccounts"}
+
+	// SecretsResource is the resource we watch to populate WithSecretType.
+	SecretsResource = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+)
+
+// GetListType implements apis.Listable
+func (sa *WithImagePullSecrets) GetListType() runtime.Object {
+	return &WithImagePullSecretsList{}
+}
+
+// GetListType implements apis.Listable
+func (s *WithSecretType) GetListType() runtime.Object {
+	return &WithSecretTypeList{}
+}
+
+// IsDockerConfig returns whether the Secret holds registry credentials.
+func (s *WithSecretType) IsDockerConfig() bool {
+	switch s.Type {
+	case corev1.SecretTypeDockerConfigJson, corev1.SecretTypeDockercfg:
+		return true
+	default:
+		return false
+	}
+}
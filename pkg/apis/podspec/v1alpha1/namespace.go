@@ -0,0 +1,56 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/knative/pkg/apis"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WithMetadata is a lean view of a resource (e.g. a Namespace), which only
+// decodes its metadata.
+type WithMetadata struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WithMetadataList is a list of WithMetadata resources
+type WithMetadataList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []WithMetadata `json:"items"`
+}
+
+// Ensure WithMetadata satisfies apis.Listable
+var _ apis.Listable = (*WithMetadata)(nil)
+
+// NamespacesResource is the resource we watch to populate WithMetadata
+// with Namespaces.
+var NamespacesResource = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+// GetListType implements apis.Listable
+func (m *WithMetadata) GetListType() runtime.Object {
+	return &WithMetadataList{}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/knative/pkg/apis"
+)
+
+// ContainerImage is the subset of a Container that we track for Pods.
+type ContainerImage struct {
+	Name  string `json:"name,omitempty"`
+	Image string `json:"image,omitempty"`
+}
+
+// ContainerImageStatus is the subset of a ContainerStatus that we track
+// for Pods.
+type ContainerImageStatus struct {
+	Name    string `json:"name,omitempty"`
+	Image   string `json:"image,omitempty"`
+	ImageID string `json:"imageID,omitempty"`
+}
+
+// PodImagesSpec is the subset of a PodSpec that we track for Pods.
+type PodImagesSpec struct {
+	InitContainers []ContainerImage `json:"initContainers,omitempty"`
+	Containers     []ContainerImage `json:"containers,omitempty"`
+
+	// ServiceAccountName and ImagePullSecrets are the credentials with
+	// which the kubelet pulls the Pod's images.
+	ServiceAccountName string                        `json:"serviceAccountName,omitempty"`
+	ImagePullSecrets   []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+}
+
+// PodImagesStatus is the subset of a PodStatus that we track for Pods.
+type PodImagesStatus struct {
+	Phase corev1.PodPhase `json:"phase,omitempty"`
+
+	InitContainerStatuses []ContainerImageStatus `json:"initContainerStatuses,omitempty"`
+	ContainerStatuses     []ContainerImageStatus `json:"containerStatuses,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PodImages is a lean view of a Pod, which only decodes the images that
+// the Pod runs.  This keeps the memory used by Pod informers in check.
+type PodImages struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PodImagesSpec   `json:"spec,omitempty"`
+	Status PodImagesStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PodImagesList is a list of PodImages resources
+type PodImagesList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []PodImages `json:"items"`
+}
+
+// Ensure PodImages satisfies apis.Listable
+var _ apis.Listable = (*PodImages)(nil)
+
+// PodsResource is the resource we watch to populate PodImages.
+var PodsResource = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+// GetListType implements apis.Listable
+func (p *PodImages) GetListType() runtime.Object {
+	return &PodImagesList{}
+}
+
+// AllContainers returns the Pod's init containers and containers.
+func (p *PodImages) AllContainers() []ContainerImage {
+	all := make([]ContainerImage, 0, len(p.Spec.InitContainers)+len(p.Spec.Containers))
+	all = append(all, p.Spec.InitContainers...)
+	return append(all, p.Spec.Containers...)
+}
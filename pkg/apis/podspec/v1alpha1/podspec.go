@@ -42,12 +42,32 @@ type WithPod struct {
 }
 
 type WithPodSpec struct {
+	// Replicas is the desired number of Pods of the resources that have
+	// one (e.g. Deployments and StatefulSets), which weights their images
+	// when exporting them.
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
 	Template PodSpecable `json:"template,omitempty"`
 }
 
+// Podable is implemented by the duck types from which we extract images.
+// They project their embedded pod template onto the WithPod shape, which
+// is what the rest of cachier operates on.  The result may share memory
+// with the receiver and must not be mutated.
+type Podable interface {
+	apis.Listable
+	kmeta.OwnerRefable
+
+	AsWithPod() *WithPod
+}
+
 // Ensure WithPod satisfies apis.Listable
 var _ apis.Listable = (*WithPod)(nil)
 
+// Ensure WithPod satisfies Podable
+var _ Podable = (*WithPod)(nil)
+
 // Ensure WithPod satisfies apis.Listable
 var _ kmeta.OwnerRefable = (*WithPod)(nil)
 
@@ -64,6 +84,11 @@ func (t *WithPod) GetGroupVersionKind() schema.GroupVersionKind {
 	return t.TypeMeta.GroupVersionKind()
 }
 
+// AsWithPod implements Podable
+func (t *WithPod) AsWithPod() *WithPod {
+	return t
+}
+
 // Populate implements duck.Populatable
 func (t *WithPod) Populate() {
 	t.Spec.Template = PodSpecable{
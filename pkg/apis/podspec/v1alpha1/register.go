@@ -48,6 +48,12 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		SchemeGroupVersion,
 		&WithPod{},
 		(&WithPod{}).GetListType(),
+		&WithRevision{},
+		(&WithRevision{}).GetListType(),
+		&WithConfiguration{},
+		(&WithConfiguration{}).GetListType(),
+		&WithService{},
+		(&WithService{}).GetListType(),
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
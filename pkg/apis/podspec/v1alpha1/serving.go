@@ -0,0 +1,241 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// ServingGroupName is the API group of Knative Serving resources.
+	ServingGroupName = "serving.knative.dev"
+
+	// ServingConfigurationLabelKey and ServingServiceLabelKey are the labels
+	// Knative Serving places on the resources (including Images) that it
+	// creates on behalf of a Revision.
+	ServingConfigurationLabelKey = ServingGroupName + "/configuration"
+	ServingServiceLabelKey       = ServingGroupName + "/service"
+)
+
+// RevisionSpec is the subset of a Knative Serving RevisionSpec that we
+// need to extract images.  Older versions of Serving embed a single
+// `container`, newer versions inline a full PodSpec.
+type RevisionSpec struct {
+	Container *corev1.Container `json:"container,omitempty"`
+
+	corev1.PodSpec `json:",inline"`
+}
+
+// RevisionTemplateSpec mirrors the Knative Serving RevisionTemplateSpec.
+type RevisionTemplateSpec struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RevisionSpec `json:"spec,omitempty"`
+}
+
+// ConfigurationSpec mirrors the Knative Serving ConfigurationSpec, which
+// has spelled its template as both `revisionTemplate` and `template`.
+type ConfigurationSpec struct {
+	RevisionTemplate *RevisionTemplateSpec `json:"revisionTemplate,omitempty"`
+	Template         *RevisionTemplateSpec `json:"template,omitempty"`
+}
+
+// ServiceConfiguration is the shape shared by the runLatest, pinned and
+// release modes of a Knative Serving Service.
+type ServiceConfiguration struct {
+	Configuration ConfigurationSpec `json:"configuration,omitempty"`
+}
+
+// ServiceSpec mirrors the Knative Serving ServiceSpec.  Newer versions of
+// Serving inline the ConfigurationSpec, older versions nest it under one
+// of several modes.
+type ServiceSpec struct {
+	RunLatest *ServiceConfiguration `json:"runLatest,omitempty"`
+	Pinned    *ServiceConfiguration `json:"pinned,omitempty"`
+	Release   *ServiceConfiguration `json:"release,omitempty"`
+
+	ConfigurationSpec `json:",inline"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WithRevision is the shape of a Knative Serving Revision.
+type WithRevision struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RevisionSpec `json:"spec,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WithRevisionList is a list of WithRevision resources
+type WithRevisionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []WithRevision `json:"items"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WithConfiguration is the shape of a Knative Serving Configuration.
+type WithConfiguration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ConfigurationSpec `json:"spec,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WithConfigurationList is a list of WithConfiguration resources
+type WithConfigurationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []WithConfiguration `json:"items"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WithService is the shape of a Knative Serving Service.
+type WithService struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ServiceSpec `json:"spec,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WithServiceList is a list of WithService resources
+type WithServiceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []WithService `json:"items"`
+}
+
+// Ensure the Serving shapes satisfy Podable
+var _ Podable = (*WithRevision)(nil)
+var _ Podable = (*WithConfiguration)(nil)
+var _ Podable = (*WithService)(nil)
+
+// podSpec flattens the single `container` form into a PodSpec.
+func (rs *RevisionSpec) podSpec() corev1.PodSpec {
+	ps := rs.PodSpec
+	if rs.Container != nil {
+		ps.Containers = append([]corev1.Container{*rs.Container}, rs.PodSpec.Containers...)
+	}
+	return ps
+}
+
+// template returns whichever spelling of the revision template is set.
+func (cs *ConfigurationSpec) template() *RevisionTemplateSpec {
+	if cs.Template != nil {
+		return cs.Template
+	}
+	return cs.RevisionTemplate
+}
+
+// configuration returns the ConfigurationSpec for whichever mode is set.
+func (ss *ServiceSpec) configuration() *ConfigurationSpec {
+	for _, sc := range []*ServiceConfiguration{ss.RunLatest, ss.Pinned, ss.Release} {
+		if sc != nil {
+			return &sc.Configuration
+		}
+	}
+	return &ss.ConfigurationSpec
+}
+
+// asWithPod projects a revision template onto the WithPod shape.
+func asWithPod(tm metav1.TypeMeta, om metav1.ObjectMeta, rt *RevisionTemplateSpec) *WithPod {
+	wp := &WithPod{
+		TypeMeta:   tm,
+		ObjectMeta: om,
+	}
+	if rt != nil {
+		wp.Spec.Template = PodSpecable{
+			ObjectMeta: rt.ObjectMeta,
+			Spec:       rt.Spec.podSpec(),
+		}
+	}
+	return wp
+}
+
+// AsWithPod implements Podable
+func (t *WithRevision) AsWithPod() *WithPod {
+	return asWithPod(t.TypeMeta, t.ObjectMeta, &RevisionTemplateSpec{Spec: t.Spec})
+}
+
+// AsWithPod implements Podable
+func (t *WithConfiguration) AsWithPod() *WithPod {
+	return asWithPod(t.TypeMeta, t.ObjectMeta, t.Spec.template())
+}
+
+// AsWithPod implements Podable
+func (t *WithService) AsWithPod() *WithPod {
+	return asWithPod(t.TypeMeta, t.ObjectMeta, t.Spec.configuration().template())
+}
+
+func (t *WithRevision) GetGroupVersionKind() schema.GroupVersionKind {
+	return t.TypeMeta.GroupVersionKind()
+}
+
+func (t *WithConfiguration) GetGroupVersionKind() schema.GroupVersionKind {
+	return t.TypeMeta.GroupVersionKind()
+}
+
+func (t *WithService) GetGroupVersionKind() schema.GroupVersionKind {
+	return t.TypeMeta.GroupVersionKind()
+}
+
+// GetListType implements apis.Listable
+func (t *WithRevision) GetListType() runtime.Object {
+	return &WithRevisionList{}
+}
+
+// GetListType implements apis.Listable
+func (t *WithConfiguration) GetListType() runtime.Object {
+	return &WithConfigurationList{}
+}
+
+// GetListType implements apis.Listable
+func (t *WithService) GetListType() runtime.Object {
+	return &WithServiceList{}
+}
+
+// DuckTypeFor returns the shape with which resources of the given kind
+// should be decoded.  Knative Serving resources get their own shapes, and
+// everything else is assumed to be PodSpecable.
+func DuckTypeFor(gvk schema.GroupVersionKind) Podable {
+	if gvk.Group == ServingGroupName {
+		switch gvk.Kind {
+		case "Revision":
+			return &WithRevision{}
+		case "Configuration":
+			return &WithConfiguration{}
+		case "Service":
+			return &WithService{}
+		}
+	}
+	return &WithPod{}
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestServingAsWithPod(t *testing.T) {
+	tests := []struct {
+		name string
+		gvk  schema.GroupVersionKind
+		raw  string
+		want PodSpecable
+	}{{
+		name: "revision with single container",
+		gvk:  schema.GroupVersionKind{Group: "serving.knative.dev", Version: "v1alpha1", Kind: "Revision"},
+		raw: `{
+  "metadata": {"name": "foo-00001"},
+  "spec": {
+    "serviceAccountName": "builder",
+    "container": {"image": "busybox"}
+  }
+}`,
+		want: PodSpecable{
+			Spec: corev1.PodSpec{
+				ServiceAccountName: "builder",
+				Containers: []corev1.Container{{
+					Image: "busybox",
+				}},
+			},
+		},
+	}, {
+		name: "configuration with revisionTemplate",
+		gvk:  schema.GroupVersionKind{Group: "serving.knative.dev", Version: "v1alpha1", Kind: "Configuration"},
+		raw: `{
+  "metadata": {"name": "foo"},
+  "spec": {
+    "revisionTemplate": {
+      "metadata": {"labels": {"a": "b"}},
+      "spec": {"container": {"image": "busybox"}}
+    }
+  }
+}`,
+		want: PodSpecable{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{"a": "b"},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Image: "busybox",
+				}},
+			},
+		},
+	}, {
+		name: "configuration with template and containers",
+		gvk:  schema.GroupVersionKind{Group: "serving.knative.dev", Version: "v1beta1", Kind: "Configuration"},
+		raw: `{
+  "metadata": {"name": "foo"},
+  "spec": {
+    "template": {
+      "spec": {"containers": [{"image": "busybox"}, {"image": "ubuntu"}]}
+    }
+  }
+}`,
+		want: PodSpecable{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Image: "busybox",
+				}, {
+					Image: "ubuntu",
+				}},
+			},
+		},
+	}, {
+		name: "service with runLatest",
+		gvk:  schema.GroupVersionKind{Group: "serving.knative.dev", Version: "v1alpha1", Kind: "Service"},
+		raw: `{
+  "metadata": {"name": "foo"},
+  "spec": {
+    "runLatest": {
+      "configuration": {
+        "revisionTemplate": {
+          "spec": {"container": {"image": "busybox"}}
+        }
+      }
+    }
+  }
+}`,
+		want: PodSpecable{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Image: "busybox",
+				}},
+			},
+		},
+	}, {
+		name: "service with release",
+		gvk:  schema.GroupVersionKind{Group: "serving.knative.dev", Version: "v1alpha1", Kind: "Service"},
+		raw: `{
+  "metadata": {"name": "foo"},
+  "spec": {
+    "release": {
+      "configuration": {
+        "revisionTemplate": {
+          "spec": {"container": {"image": "busybox"}}
+        }
+      }
+    }
+  }
+}`,
+		want: PodSpecable{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Image: "busybox",
+				}},
+			},
+		},
+	}, {
+		name: "service with inline template",
+		gvk:  schema.GroupVersionKind{Group: "serving.knative.dev", Version: "v1beta1", Kind: "Service"},
+		raw: `{
+  "metadata": {"name": "foo"},
+  "spec": {
+    "template": {
+      "spec": {"containers": [{"image": "busybox"}]}
+    }
+  }
+}`,
+		want: PodSpecable{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Image: "busybox",
+				}},
+			},
+		},
+	}, {
+		name: "service in manual mode",
+		gvk:  schema.GroupVersionKind{Group: "serving.knative.dev", Version: "v1alpha1", Kind: "Service"},
+		raw: `{
+  "metadata": {"name": "foo"},
+  "spec": {"manual": {}}
+}`,
+		want: PodSpecable{},
+	}, {
+		name: "deployment",
+		gvk:  schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		raw: `{
+  "metadata": {"name": "foo"},
+  "spec": {
+    "template": {
+      "spec": {"containers": [{"image": "busybox"}]}
+    }
+  }
+}`,
+		want: PodSpecable{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Image: "busybox",
+				}},
+			},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			duck := DuckTypeFor(test.gvk)
+			if err := json.Unmarshal([]byte(test.raw), duck); err != nil {
+				t.Fatalf("Unmarshal() = %v", err)
+			}
+			got := duck.AsWithPod()
+			if diff := cmp.Diff(test.want, got.Spec.Template, cmpopts.IgnoreUnexported(resource.Quantity{})); diff != "" {
+				t.Errorf("AsWithPod (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// StatusAnnotationKey is the annotation on which cachier reports its
+// Status for the resources that it processes.  We can't rely on these
+// resources having a status we may write to, so we use an annotation.
+const StatusAnnotationKey = "cachier.mattmoor.io/status"
+
+// Status is what cachier reports about a resource that it processes.
+type Status struct {
+	// ObservedGeneration is the generation of the resource to which this
+	// Status applies.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Cached is whether Images are created for the resource.
+	Cached bool `json:"cached"`
+
+	// Reason is a human-readable explanation of why the resource is (or
+	// isn't) cached.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Policy describes the CachePolicy or ClusterCachePolicy that applies
+	// to the resource, if any (e.g. `CachePolicy "foo"`).
+	// +optional
+	Policy string `json:"policy,omitempty"`
+
+	// ExcludedContainers holds the names of the containers whose images
+	// are excluded from caching.
+	// +optional
+	ExcludedContainers []string `json:"excludedContainers,omitempty"`
+
+	// ExcludedImages holds the image references that the policy of the
+	// resource excludes from caching.
+	// +optional
+	ExcludedImages []string `json:"excludedImages,omitempty"`
+
+	// DiscoveredImages holds the images that the resource's Pods run, but
+	// which aren't in its pod template (e.g. sidecars injected by webhooks).
+	// These are remembered here so that they survive scaling to zero.
+	// +optional
+	DiscoveredImages []string `json:"discoveredImages,omitempty"`
+
+	// PinnedImages maps the images of the resource to the digests (in the
+	// form repository@digest) that its Pods run, per the kubelet, which are
+	// what we cache.  These are remembered here so that they survive
+	// scaling to zero.
+	// +optional
+	PinnedImages map[string]string `json:"pinnedImages,omitempty"`
+
+	// DigestConflicts describes the images whose Pods run different digests
+	// (e.g. mid-rollout, or because the tag moved), which aren't pinned.
+	// +optional
+	DigestConflicts []string `json:"digestConflicts,omitempty"`
+
+	// CredentialErrors describes the problems with the pull credentials of
+	// the resource (e.g. a missing ServiceAccount or Secret), which keep
+	// us from caching its images.
+	// +optional
+	CredentialErrors []string `json:"credentialErrors,omitempty"`
+
+	// ImageErrors describes the images of the resource that their
+	// registries report don't exist, or that we may not pull with its
+	// credentials, which aren't cached.  These are what would otherwise
+	// show up as ImagePullBackOff on its Pods.
+	// +optional
+	ImageErrors []string `json:"imageErrors,omitempty"`
+}
+
+// GetStatus returns the Status reported on the given resource, or nil if
+// there is none (or it is malformed).
+func GetStatus(om metav1.Object) *Status {
+	raw, ok := om.GetAnnotations()[StatusAnnotationKey]
+	if !ok {
+		return nil
+	}
+	s := &Status{}
+	if err := json.Unmarshal([]byte(raw), s); err != nil {
+		return nil
+	}
+	return s
+}
+
+// Serialize returns the form of the Status stored in StatusAnnotationKey.
+func (s *Status) Serialize() (string, error) {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LastAppliedConfigAnnotation is where kubectl apply keeps a copy of the
+// whole resource, which we never need.
+const LastAppliedConfigAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// Trim returns a copy of the WithPod that keeps only the fields cachier
+// uses: the metadata of the resource and its pod template, its replicas,
+// the names and images of the template's containers, its pull secrets, and
+// its service account.  Everything else (e.g. env, volumes and probes) is
+// dropped, so that caches of many resources stay small.  The maps and
+// slices it keeps (e.g. labels and pull secrets) aren't copied, since the
+// informers that call it discard t.
+func (t *WithPod) Trim() *WithPod {
+	ps := &t.Spec.Template.Spec
+	return &WithPod{
+		TypeMeta:   t.TypeMeta,
+		ObjectMeta: trimObjectMeta(t.ObjectMeta),
+		Spec: WithPodSpec{
+			Replicas: t.Spec.Replicas,
+			Template: PodSpecable{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      t.Spec.Template.Labels,
+					Annotations: t.Spec.Template.Annotations,
+				},
+				Spec: corev1.PodSpec{
+					InitContainers:     trimContainers(ps.InitContainers),
+					Containers:         trimContainers(ps.Containers),
+					ServiceAccountName: ps.ServiceAccountName,
+					ImagePullSecrets:   ps.ImagePullSecrets,
+				},
+			},
+		},
+	}
+}
+
+// trimObjectMeta drops the last applied configuration from the metadata.
+func trimObjectMeta(om metav1.ObjectMeta) metav1.ObjectMeta {
+	if _, ok := om.Annotations[LastAppliedConfigAnnotation]; !ok {
+		return om
+	}
+	annos := make(map[string]string, len(om.Annotations)-1)
+	for k, v := range om.Annotations {
+		if k != LastAppliedConfigAnnotation {
+			annos[k] = v
+		}
+	}
+	om.Annotations = annos
+	return om
+}
+
+// trimContainers keeps only the names and images of the containers.
+func trimContainers(containers []corev1.Container) []corev1.Container {
+	if containers == nil {
+		return nil
+	}
+	out := make([]corev1.Container, len(containers))
+	for i, c := range containers {
+		out[i] = corev1.Container{Name: c.Name, Image: c.Image}
+	}
+	return out
+}
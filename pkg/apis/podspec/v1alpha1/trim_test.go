@@ -0,0 +1,106 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTrim(t *testing.T) {
+	full := &WithPod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "ns",
+			Name:       "foo",
+			UID:        "uid",
+			Generation: 3,
+			Labels:     map[string]string{"app": "foo"},
+			Annotations: map[string]string{
+				LastAppliedConfigAnnotation: "{...}",
+				StatusAnnotationKey:         "{}",
+			},
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Bar", Name: "bar"}},
+		},
+		Spec: WithPodSpec{
+			Template: PodSpecable{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "ignored",
+					Labels:      map[string]string{"app": "foo"},
+					Annotations: map[string]string{"cachier.mattmoor.io/decorate": "disable"},
+				},
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{{
+						Name:    "init",
+						Image:   "busybox",
+						Command: []string{"true"},
+					}},
+					Containers: []corev1.Container{{
+						Name:  "app",
+						Image: "gcr.io/foo/app",
+						Env:   []corev1.EnvVar{{Name: "FOO", Value: "bar"}},
+						ReadinessProbe: &corev1.Probe{
+							Handler: corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"true"}}},
+						},
+					}},
+					Volumes:            []corev1.Volume{{Name: "data"}},
+					ServiceAccountName: "builder",
+					ImagePullSecrets:   []corev1.LocalObjectReference{{Name: "creds"}},
+					NodeSelector:       map[string]string{"a": "b"},
+				},
+			},
+		},
+	}
+
+	want := &WithPod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "ns",
+			Name:            "foo",
+			UID:             "uid",
+			Generation:      3,
+			Labels:          map[string]string{"app": "foo"},
+			Annotations:     map[string]string{StatusAnnotationKey: "{}"},
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Bar", Name: "bar"}},
+		},
+		Spec: WithPodSpec{
+			Template: PodSpecable{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      map[string]string{"app": "foo"},
+					Annotations: map[string]string{"cachier.mattmoor.io/decorate": "disable"},
+				},
+				Spec: corev1.PodSpec{
+					InitContainers:     []corev1.Container{{Name: "init", Image: "busybox"}},
+					Containers:         []corev1.Container{{Name: "app", Image: "gcr.io/foo/app"}},
+					ServiceAccountName: "builder",
+					ImagePullSecrets:   []corev1.LocalObjectReference{{Name: "creds"}},
+				},
+			},
+		},
+	}
+
+	before := full.DeepCopy()
+	if diff := cmp.Diff(want, full.Trim()); diff != "" {
+		t.Errorf("Trim (-want, +got) = %v", diff)
+	}
+	if diff := cmp.Diff(before, full); diff != "" {
+		t.Errorf("Trim mutated its receiver (-want, +got) = %v", diff)
+	}
+}
@@ -0,0 +1,318 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package budget limits the rate at which Images are created for the images
+// of each registry, so that bulk changes (or the controller starting) don't
+// have the caching backend stampede registries.
+package budget
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/mattmoor/cachier/pkg/reference"
+)
+
+// Limit is a token bucket, which refills at QPS and holds up to Burst.
+type Limit struct {
+	QPS   float64 `json:"qps"`
+	Burst int     `json:"burst"`
+}
+
+// Config holds the budgets of registries.
+type Config struct {
+	// Default is the budget of registries that Registries doesn't list.
+	// When omitted, they are unlimited.
+	Default *Limit `json:"default,omitempty"`
+
+	// Registries maps registries (e.g. gcr.io, or docker.io) to their
+	// budgets.
+	Registries map[string]Limit `json:"registries,omitempty"`
+}
+
+// Load reads the Config from the given path (e.g. a mounted ConfigMap).
+func Load(path string) (*Config, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parse(path, raw)
+}
+
+// parse parses the Config read from path.
+func parse(path string, raw []byte) (*Config, error) {
+	var cfg Config
+	if err := yaml.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	return &cfg, nil
+}
+
+// Validate checks that the Config's limits make sense.
+func (cfg *Config) Validate() error {
+	check := func(name string, l Limit) error {
+		if l.QPS <= 0 {
+			return fmt.Errorf("%s: qps must be positive, got %v", name, l.QPS)
+		}
+		if l.Burst < 1 {
+			return fmt.Errorf("%s: burst must be at least 1, got %v", name, l.Burst)
+		}
+		return nil
+	}
+	if cfg.Default != nil {
+		if err := check("default", *cfg.Default); err != nil {
+			return err
+		}
+	}
+	for registry, l := range cfg.Registries {
+		if err := check(registry, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats are the counts for a registry, as reported by Budget.Stats.
+type Stats struct {
+	// Created is the number of Images admitted.
+	Created int64 `json:"created"`
+
+	// Deferred is the number of times Images were deferred.
+	Deferred int64 `json:"deferred"`
+
+	// Queued is the number of Images currently waiting on the budget.
+	Queued int `json:"queued"`
+}
+
+// Budget tracks the budgets of registries.  A nil Budget admits everything.
+type Budget struct {
+	clock   clock.Clock
+	limits  map[string]Limit
+	deflt   *Limit
+	backoff workqueue.RateLimiter
+
+	m       sync.Mutex
+	buckets map[string]*rate.Limiter
+	stats   map[string]*Stats
+	// queued maps the callers with Images waiting on the budget to the
+	// number waiting for each registry.
+	queued map[string]map[string]int
+}
+
+// New returns a Budget with the given Config.  Callers whose Images are
+// deferred are asked to retry with exponential backoff between the given
+// bounds (or when the budget refills, if that's later).
+func New(cfg *Config, clk clock.Clock, minBackoff, maxBackoff time.Duration) *Budget {
+	if clk == nil {
+		clk = clock.RealClock{}
+	}
+	b := &Budget{
+		clock:   clk,
+		backoff: workqueue.NewItemExponentialFailureRateLimiter(minBackoff, maxBackoff),
+		stats:   make(map[string]*Stats),
+		queued:  make(map[string]map[string]int),
+	}
+	b.setConfig(cfg)
+	return b
+}
+
+// setConfig replaces the budgets with those of the Config, refilling every
+// bucket.  It must be called with b.m held, or before b is shared.
+func (b *Budget) setConfig(cfg *Config) {
+	b.limits = make(map[string]Limit, len(cfg.Registries))
+	for registry, l := range cfg.Registries {
+		b.limits[reference.NormalizeRegistry(registry)] = l
+	}
+	b.deflt = cfg.Default
+	b.buckets = make(map[string]*rate.Limiter)
+}
+
+// Update replaces the budgets with those of the given Config.  The counts
+// in Stats, and the Images waiting on the budget, carry over.
+func (b *Budget) Update(cfg *Config) {
+	if b == nil {
+		return
+	}
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.setConfig(cfg)
+}
+
+// Watch checks the file at path (e.g. a mounted ConfigMap, which the
+// kubelet updates in place) every period until stopCh is closed, and
+// updates the budgets when its contents change.  A Config that doesn't load
+// is logged, and the budgets it would replace are kept.
+func (b *Budget) Watch(path string, period time.Duration, logger *zap.SugaredLogger, stopCh <-chan struct{}) {
+	last, err := ioutil.ReadFile(path)
+	if err != nil {
+		logger.Errorf("Error reading registry budgets: %v", err)
+	}
+	go wait.Until(func() {
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			logger.Errorf("Error reading registry budgets: %v", err)
+			return
+		}
+		if bytes.Equal(raw, last) {
+			return
+		}
+		last = raw
+		cfg, err := parse(path, raw)
+		if err != nil {
+			logger.Errorf("Error loading registry budgets, keeping the previous ones: %v", err)
+			return
+		}
+		logger.Infof("Registry budgets changed: %s", raw)
+		b.Update(cfg)
+	}, period, stopCh)
+}
+
+// registryOf returns the registry of the image, or "" if it's malformed
+// (which we leave to the caching backend to report).
+func registryOf(image string) string {
+	r, err := reference.Parse(image)
+	if err != nil {
+		return ""
+	}
+	return r.Registry
+}
+
+// bucket returns the token bucket of the registry, or nil if it's
+// unlimited.  It must be called with b.m held.
+func (b *Budget) bucket(registry string) *rate.Limiter {
+	if lim, ok := b.buckets[registry]; ok {
+		return lim
+	}
+	l, ok := b.limits[registry]
+	if !ok && b.deflt != nil {
+		l, ok = *b.deflt, true
+	}
+	var lim *rate.Limiter
+	if ok && registry != "" {
+		lim = rate.NewLimiter(rate.Limit(l.QPS), l.Burst)
+	}
+	b.buckets[registry] = lim
+	return lim
+}
+
+func (b *Budget) statsOf(registry string) *Stats {
+	s, ok := b.stats[registry]
+	if !ok {
+		s = &Stats{}
+		b.stats[registry] = s
+	}
+	return s
+}
+
+// Admit returns those of the given images whose Images may be created now,
+// spending the budgets of their registries.  The rest are deferred on
+// behalf of the caller, identified by id (e.g. the key of the resource
+// being reconciled), which should try them again after the returned delay.
+// The delay is zero when nothing is deferred.
+func (b *Budget) Admit(id string, images []string) ([]string, time.Duration) {
+	if b == nil {
+		return images, 0
+	}
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	now := b.clock.Now()
+	admitted := make([]string, 0, len(images))
+	waiting := make(map[string]int)
+	var wait time.Duration
+	for _, image := range images {
+		registry := registryOf(image)
+		if lim := b.bucket(registry); lim != nil {
+			r := lim.ReserveN(now, 1)
+			if d := r.DelayFrom(now); !r.OK() || d > 0 {
+				r.CancelAt(now)
+				waiting[registry]++
+				b.statsOf(registry).Deferred++
+				if d > wait {
+					wait = d
+				}
+				continue
+			}
+		}
+		admitted = append(admitted, image)
+		b.statsOf(registry).Created++
+	}
+
+	b.setQueued(id, waiting)
+	if len(waiting) == 0 {
+		b.backoff.Forget(id)
+		return admitted, 0
+	}
+	if d := b.backoff.When(id); d > wait {
+		wait = d
+	}
+	return admitted, wait
+}
+
+// Forget drops the images that the caller identified by id has waiting on
+// the budget, e.g. because it was deleted.
+func (b *Budget) Forget(id string) {
+	if b == nil {
+		return
+	}
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.setQueued(id, nil)
+	b.backoff.Forget(id)
+}
+
+// setQueued records the images that the caller has waiting on the budget.
+// It must be called with b.m held.
+func (b *Budget) setQueued(id string, waiting map[string]int) {
+	for registry, n := range b.queued[id] {
+		b.statsOf(registry).Queued -= n
+	}
+	if len(waiting) == 0 {
+		delete(b.queued, id)
+		return
+	}
+	for registry, n := range waiting {
+		b.statsOf(registry).Queued += n
+	}
+	b.queued[id] = waiting
+}
+
+// Stats returns the counts for each registry that we've seen.
+func (b *Budget) Stats() map[string]Stats {
+	if b == nil {
+		return nil
+	}
+	b.m.Lock()
+	defer b.m.Unlock()
+	out := make(map[string]Stats, len(b.stats))
+	for registry, s := range b.stats {
+		out[registry] = *s
+	}
+	return out
+}
@@ -0,0 +1,232 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package budget
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestAdmit(t *testing.T) {
+	start := time.Now()
+	clk := clock.NewFakeClock(start)
+	b := New(&Config{
+		Registries: map[string]Limit{
+			"gcr.io":    {QPS: 1, Burst: 2},
+			"docker.io": {QPS: 10, Burst: 1},
+		},
+	}, clk, 100*time.Millisecond, time.Minute)
+
+	admit := func(id string, images []string, want []string, wantWait time.Duration) {
+		t.Helper()
+		got, wait := b.Admit(id, images)
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Admit (-want, +got) = %v", diff)
+		}
+		if wait != wantWait {
+			t.Errorf("Admit() wait = %v, wanted %v", wait, wantWait)
+		}
+	}
+
+	// The first two gcr.io images spend its burst, and quay.io (which isn't
+	// configured) is unlimited.  The Docker Hub image is named differently
+	// than its budget, but is covered by it.
+	admit("a", []string{"gcr.io/a/b:1", "gcr.io/a/b:2", "gcr.io/a/b:3", "quay.io/a/b:1", "ubuntu"},
+		[]string{"gcr.io/a/b:1", "gcr.io/a/b:2", "quay.io/a/b:1", "ubuntu"}, time.Second)
+	admit("b", []string{"nginx", "gcr.io/c/d:1"}, []string{}, time.Second)
+
+	want := map[string]Stats{
+		"gcr.io":          {Created: 2, Deferred: 2, Queued: 2},
+		"index.docker.io": {Created: 1, Deferred: 1, Queued: 1},
+		"quay.io":         {Created: 1},
+	}
+	if diff := cmp.Diff(want, b.Stats()); diff != "" {
+		t.Errorf("Stats (-want, +got) = %v", diff)
+	}
+
+	// Once the budget refills, what was deferred is admitted.
+	clk.SetTime(start.Add(time.Second))
+	admit("a", []string{"gcr.io/a/b:3"}, []string{"gcr.io/a/b:3"}, 0)
+	// But there isn't enough left for "b" too.
+	admit("b", []string{"gcr.io/c/d:1"}, []string{}, time.Second)
+	b.Forget("b")
+
+	want = map[string]Stats{
+		"gcr.io":          {Created: 3, Deferred: 3},
+		"index.docker.io": {Created: 1, Deferred: 1},
+		"quay.io":         {Created: 1},
+	}
+	if diff := cmp.Diff(want, b.Stats()); diff != "" {
+		t.Errorf("Stats (-want, +got) = %v", diff)
+	}
+}
+
+func TestAdmitBackoff(t *testing.T) {
+	clk := clock.NewFakeClock(time.Now())
+	b := New(&Config{Default: &Limit{QPS: 1000, Burst: 1}}, clk, time.Second, 4*time.Second)
+
+	b.Admit("a", []string{"gcr.io/a/b:1"})
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		if _, wait := b.Admit("a", []string{"gcr.io/a/b:2"}); wait != want {
+			t.Errorf("Admit() wait = %v, wanted %v", wait, want)
+		}
+	}
+}
+
+func TestNilBudget(t *testing.T) {
+	var b *Budget
+	images := []string{"gcr.io/a/b:1", "ubuntu"}
+	got, wait := b.Admit("a", images)
+	if diff := cmp.Diff(images, got); diff != "" || wait != 0 {
+		t.Errorf("Admit() = %v, %v, wanted everything now", got, wait)
+	}
+	b.Forget("a")
+	if got := b.Stats(); got != nil {
+		t.Errorf("Stats() = %v, wanted nil", got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    *Config
+		wantErr bool
+	}{{
+		name: "empty",
+		data: "",
+		want: &Config{},
+	}, {
+		name: "valid",
+		data: `
+default:
+  qps: 5
+  burst: 20
+registries:
+  docker.io:
+    qps: 0.5
+    burst: 10
+`,
+		want: &Config{
+			Default:    &Limit{QPS: 5, Burst: 20},
+			Registries: map[string]Limit{"docker.io": {QPS: 0.5, Burst: 10}},
+		},
+	}, {
+		name:    "bad qps",
+		data:    "default: {qps: 0, burst: 1}",
+		wantErr: true,
+	}, {
+		name:    "bad burst",
+		data:    "registries: {gcr.io: {qps: 1}}",
+		wantErr: true,
+	}, {
+		name:    "malformed",
+		data:    "registries: [",
+		wantErr: true,
+	}}
+
+	dir, err := ioutil.TempDir("", "budget")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, "budgets.yaml")
+			if err := ioutil.WriteFile(path, []byte(test.data), 0644); err != nil {
+				t.Fatalf("WriteFile() = %v", err)
+			}
+			got, err := Load(path)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Load() = %v, wantErr %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Load (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	clk := clock.NewFakeClock(time.Now())
+	b := New(&Config{
+		Registries: map[string]Limit{"gcr.io": {QPS: 1, Burst: 1}},
+	}, clk, time.Second, time.Minute)
+
+	if _, wait := b.Admit("a", []string{"gcr.io/a/b:1", "gcr.io/a/b:2"}); wait == 0 {
+		t.Error("Admit() admitted everything, wanted gcr.io/a/b:2 deferred")
+	}
+
+	// Raising the budget admits what was deferred, and the counts carry
+	// over.
+	b.Update(&Config{
+		Registries: map[string]Limit{"gcr.io": {QPS: 1, Burst: 10}},
+	})
+	got, wait := b.Admit("a", []string{"gcr.io/a/b:2"})
+	if diff := cmp.Diff([]string{"gcr.io/a/b:2"}, got); diff != "" || wait != 0 {
+		t.Errorf("Admit() = %v, %v, wanted everything admitted (-want, +got) = %v", got, wait, diff)
+	}
+	want := map[string]Stats{"gcr.io": {Created: 2, Deferred: 1}}
+	if diff := cmp.Diff(want, b.Stats()); diff != "" {
+		t.Errorf("Stats (-want, +got) = %v", diff)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "budget")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "budgets.yaml")
+	write := func(data string) {
+		t.Helper()
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("WriteFile() = %v", err)
+		}
+	}
+
+	write("default: {qps: 1, burst: 1}")
+	b := New(&Config{Default: &Limit{QPS: 1, Burst: 1}}, nil, time.Second, time.Minute)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	b.Watch(path, 10*time.Millisecond, zap.NewNop().Sugar(), stopCh)
+
+	// A malformed Config keeps the budgets we have, and the next good one
+	// replaces them.
+	write("default: [")
+	time.Sleep(50 * time.Millisecond)
+	write("default: {qps: 1, burst: 5}")
+	images := []string{"gcr.io/a/b:1", "gcr.io/a/b:2", "gcr.io/a/b:3"}
+	err = wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		b.Forget("a")
+		got, _ := b.Admit("a", images)
+		return len(got) == len(images), nil
+	})
+	if err != nil {
+		t.Errorf("Budgets weren't updated: %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	v1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	scheme "github.com/mattmoor/cachier/pkg/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// CachedImageSetsGetter has a method to return a CachedImageSetInterface.
+// A group's client should implement this interface.
+type CachedImageSetsGetter interface {
+	CachedImageSets(namespace string) CachedImageSetInterface
+}
+
+// CachedImageSetInterface has methods to work with CachedImageSet resources.
+type CachedImageSetInterface interface {
+	Create(*v1alpha1.CachedImageSet) (*v1alpha1.CachedImageSet, error)
+	Update(*v1alpha1.CachedImageSet) (*v1alpha1.CachedImageSet, error)
+	UpdateStatus(*v1alpha1.CachedImageSet) (*v1alpha1.CachedImageSet, error)
+	Delete(name string, options *v1.DeleteOptions) error
+	DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error
+	Get(name string, options v1.GetOptions) (*v1alpha1.CachedImageSet, error)
+	List(opts v1.ListOptions) (*v1alpha1.CachedImageSetList, error)
+	Watch(opts v1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.CachedImageSet, err error)
+	CachedImageSetExpansion
+}
+
+// cachedImageSets implements CachedImageSetInterface
+type cachedImageSets struct {
+	client rest.Interface
+	ns     string
+}
+
+// newCachedImageSets returns a CachedImageSets
+func newCachedImageSets(c *CachierV1alpha1Client, namespace string) *cachedImageSets {
+	return &cachedImageSets{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the cachedImageSet, and returns the corresponding cachedImageSet object, and an error if there is any.
+func (c *cachedImageSets) Get(name string, options v1.GetOptions) (result *v1alpha1.CachedImageSet, err error) {
+	result = &v1alpha1.CachedImageSet{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("cachedimagesets").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of CachedImageSets that match those selectors.
+func (c *cachedImageSets) List(opts v1.ListOptions) (result *v1alpha1.CachedImageSetList, err error) {
+	result = &v1alpha1.CachedImageSetList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("cachedimagesets").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested cachedImageSets.
+func (c *cachedImageSets) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("cachedimagesets").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Watch()
+}
+
+// Create takes the representation of a cachedImageSet and creates it.  Returns the server's representation of the cachedImageSet, and an error, if there is any.
+func (c *cachedImageSets) Create(cachedImageSet *v1alpha1.CachedImageSet) (result *v1alpha1.CachedImageSet, err error) {
+	result = &v1alpha1.CachedImageSet{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("cachedimagesets").
+		Body(cachedImageSet).
+		Do().
+		Into(result)
+	return
+}
+
+// Update takes the representation of a cachedImageSet and updates it. Returns the server's representation of the cachedImageSet, and an error, if there is any.
+func (c *cachedImageSets) Update(cachedImageSet *v1alpha1.CachedImageSet) (result *v1alpha1.CachedImageSet, err error) {
+	result = &v1alpha1.CachedImageSet{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("cachedimagesets").
+		Name(cachedImageSet.Name).
+		Body(cachedImageSet).
+		Do().
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+
+func (c *cachedImageSets) UpdateStatus(cachedImageSet *v1alpha1.CachedImageSet) (result *v1alpha1.CachedImageSet, err error) {
+	result = &v1alpha1.CachedImageSet{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("cachedimagesets").
+		Name(cachedImageSet.Name).
+		SubResource("status").
+		Body(cachedImageSet).
+		Do().
+		Into(result)
+	return
+}
+
+// Delete takes name of the cachedImageSet and deletes it. Returns an error if one occurs.
+func (c *cachedImageSets) Delete(name string, options *v1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("cachedimagesets").
+		Name(name).
+		Body(options).
+		Do().
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *cachedImageSets) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("cachedimagesets").
+		VersionedParams(&listOptions, scheme.ParameterCodec).
+		Body(options).
+		Do().
+		Error()
+}
+
+// Patch applies the patch and returns the patched cachedImageSet.
+func (c *cachedImageSets) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.CachedImageSet, err error) {
+	result = &v1alpha1.CachedImageSet{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("cachedimagesets").
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do().
+		Into(result)
+	return
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	v1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	scheme "github.com/mattmoor/cachier/pkg/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// CachePoliciesGetter has a method to return a CachePolicyInterface.
+// A group's client should implement this interface.
+type CachePoliciesGetter interface {
+	CachePolicies(namespace string) CachePolicyInterface
+}
+
+// CachePolicyInterface has methods to work with CachePolicy resources.
+type CachePolicyInterface interface {
+	Create(*v1alpha1.CachePolicy) (*v1alpha1.CachePolicy, error)
+	Update(*v1alpha1.CachePolicy) (*v1alpha1.CachePolicy, error)
+	Delete(name string, options *v1.DeleteOptions) error
+	DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error
+	Get(name string, options v1.GetOptions) (*v1alpha1.CachePolicy, error)
+	List(opts v1.ListOptions) (*v1alpha1.CachePolicyList, error)
+	Watch(opts v1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.CachePolicy, err error)
+	CachePolicyExpansion
+}
+
+// cachePolicies implements CachePolicyInterface
+type cachePolicies struct {
+	client rest.Interface
+	ns     string
+}
+
+// newCachePolicies returns a CachePolicies
+func newCachePolicies(c *CachierV1alpha1Client, namespace string) *cachePolicies {
+	return &cachePolicies{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the cachePolicy, and returns the corresponding cachePolicy object, and an error if there is any.
+func (c *cachePolicies) Get(name string, options v1.GetOptions) (result *v1alpha1.CachePolicy, err error) {
+	result = &v1alpha1.CachePolicy{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("cachepolicies").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of CachePolicies that match those selectors.
+func (c *cachePolicies) List(opts v1.ListOptions) (result *v1alpha1.CachePolicyList, err error) {
+	result = &v1alpha1.CachePolicyList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("cachepolicies").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested cachePolicies.
+func (c *cachePolicies) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("cachepolicies").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Watch()
+}
+
+// Create takes the representation of a cachePolicy and creates it.  Returns the server's representation of the cachePolicy, and an error, if there is any.
+func (c *cachePolicies) Create(cachePolicy *v1alpha1.CachePolicy) (result *v1alpha1.CachePolicy, err error) {
+	result = &v1alpha1.CachePolicy{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("cachepolicies").
+		Body(cachePolicy).
+		Do().
+		Into(result)
+	return
+}
+
+// Update takes the representation of a cachePolicy and updates it. Returns the server's representation of the cachePolicy, and an error, if there is any.
+func (c *cachePolicies) Update(cachePolicy *v1alpha1.CachePolicy) (result *v1alpha1.CachePolicy, err error) {
+	result = &v1alpha1.CachePolicy{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("cachepolicies").
+		Name(cachePolicy.Name).
+		Body(cachePolicy).
+		Do().
+		Into(result)
+	return
+}
+
+// Delete takes name of the cachePolicy and deletes it. Returns an error if one occurs.
+func (c *cachePolicies) Delete(name string, options *v1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("cachepolicies").
+		Name(name).
+		Body(options).
+		Do().
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *cachePolicies) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("cachepolicies").
+		VersionedParams(&listOptions, scheme.ParameterCodec).
+		Body(options).
+		Do().
+		Error()
+}
+
+// Patch applies the patch and returns the patched cachePolicy.
+func (c *cachePolicies) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.CachePolicy, err error) {
+	result = &v1alpha1.CachePolicy{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("cachepolicies").
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do().
+		Into(result)
+	return
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	v1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	scheme "github.com/mattmoor/cachier/pkg/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// ClusterCachePoliciesGetter has a method to return a ClusterCachePolicyInterface.
+// A group's client should implement this interface.
+type ClusterCachePoliciesGetter interface {
+	ClusterCachePolicies() ClusterCachePolicyInterface
+}
+
+// ClusterCachePolicyInterface has methods to work with ClusterCachePolicy resources.
+type ClusterCachePolicyInterface interface {
+	Create(*v1alpha1.ClusterCachePolicy) (*v1alpha1.ClusterCachePolicy, error)
+	Update(*v1alpha1.ClusterCachePolicy) (*v1alpha1.ClusterCachePolicy, error)
+	Delete(name string, options *v1.DeleteOptions) error
+	DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error
+	Get(name string, options v1.GetOptions) (*v1alpha1.ClusterCachePolicy, error)
+	List(opts v1.ListOptions) (*v1alpha1.ClusterCachePolicyList, error)
+	Watch(opts v1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.ClusterCachePolicy, err error)
+	ClusterCachePolicyExpansion
+}
+
+// clusterCachePolicies implements ClusterCachePolicyInterface
+type clusterCachePolicies struct {
+	client rest.Interface
+}
+
+// newClusterCachePolicies returns a ClusterCachePolicies
+func newClusterCachePolicies(c *CachierV1alpha1Client) *clusterCachePolicies {
+	return &clusterCachePolicies{
+		client: c.RESTClient(),
+	}
+}
+
+// Get takes name of the clusterCachePolicy, and returns the corresponding clusterCachePolicy object, and an error if there is any.
+func (c *clusterCachePolicies) Get(name string, options v1.GetOptions) (result *v1alpha1.ClusterCachePolicy, err error) {
+	result = &v1alpha1.ClusterCachePolicy{}
+	err = c.client.Get().
+		Resource("clustercachepolicies").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of ClusterCachePolicies that match those selectors.
+func (c *clusterCachePolicies) List(opts v1.ListOptions) (result *v1alpha1.ClusterCachePolicyList, err error) {
+	result = &v1alpha1.ClusterCachePolicyList{}
+	err = c.client.Get().
+		Resource("clustercachepolicies").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested clusterCachePolicies.
+func (c *clusterCachePolicies) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.client.Get().
+		Resource("clustercachepolicies").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Watch()
+}
+
+// Create takes the representation of a clusterCachePolicy and creates it.  Returns the server's representation of the clusterCachePolicy, and an error, if there is any.
+func (c *clusterCachePolicies) Create(clusterCachePolicy *v1alpha1.ClusterCachePolicy) (result *v1alpha1.ClusterCachePolicy, err error) {
+	result = &v1alpha1.ClusterCachePolicy{}
+	err = c.client.Post().
+		Resource("clustercachepolicies").
+		Body(clusterCachePolicy).
+		Do().
+		Into(result)
+	return
+}
+
+// Update takes the representation of a clusterCachePolicy and updates it. Returns the server's representation of the clusterCachePolicy, and an error, if there is any.
+func (c *clusterCachePolicies) Update(clusterCachePolicy *v1alpha1.ClusterCachePolicy) (result *v1alpha1.ClusterCachePolicy, err error) {
+	result = &v1alpha1.ClusterCachePolicy{}
+	err = c.client.Put().
+		Resource("clustercachepolicies").
+		Name(clusterCachePolicy.Name).
+		Body(clusterCachePolicy).
+		Do().
+		Into(result)
+	return
+}
+
+// Delete takes name of the clusterCachePolicy and deletes it. Returns an error if one occurs.
+func (c *clusterCachePolicies) Delete(name string, options *v1.DeleteOptions) error {
+	return c.client.Delete().
+		Resource("clustercachepolicies").
+		Name(name).
+		Body(options).
+		Do().
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *clusterCachePolicies) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	return c.client.Delete().
+		Resource("clustercachepolicies").
+		VersionedParams(&listOptions, scheme.ParameterCodec).
+		Body(options).
+		Do().
+		Error()
+}
+
+// Patch applies the patch and returns the patched clusterCachePolicy.
+func (c *clusterCachePolicies) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.ClusterCachePolicy, err error) {
+	result = &v1alpha1.ClusterCachePolicy{}
+	err = c.client.Patch(pt).
+		Resource("clustercachepolicies").
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do().
+		Into(result)
+	return
+}
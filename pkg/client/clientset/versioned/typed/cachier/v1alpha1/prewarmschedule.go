@@ -0,0 +1,171 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	v1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	scheme "github.com/mattmoor/cachier/pkg/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// PrewarmSchedulesGetter has a method to return a PrewarmScheduleInterface.
+// A group's client should implement this interface.
+type PrewarmSchedulesGetter interface {
+	PrewarmSchedules(namespace string) PrewarmScheduleInterface
+}
+
+// PrewarmScheduleInterface has methods to work with PrewarmSchedule resources.
+type PrewarmScheduleInterface interface {
+	Create(*v1alpha1.PrewarmSchedule) (*v1alpha1.PrewarmSchedule, error)
+	Update(*v1alpha1.PrewarmSchedule) (*v1alpha1.PrewarmSchedule, error)
+	UpdateStatus(*v1alpha1.PrewarmSchedule) (*v1alpha1.PrewarmSchedule, error)
+	Delete(name string, options *v1.DeleteOptions) error
+	DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error
+	Get(name string, options v1.GetOptions) (*v1alpha1.PrewarmSchedule, error)
+	List(opts v1.ListOptions) (*v1alpha1.PrewarmScheduleList, error)
+	Watch(opts v1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.PrewarmSchedule, err error)
+	PrewarmScheduleExpansion
+}
+
+// prewarmSchedules implements PrewarmScheduleInterface
+type prewarmSchedules struct {
+	client rest.Interface
+	ns     string
+}
+
+// newPrewarmSchedules returns a PrewarmSchedules
+func newPrewarmSchedules(c *CachierV1alpha1Client, namespace string) *prewarmSchedules {
+	return &prewarmSchedules{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the prewarmSchedule, and returns the corresponding prewarmSchedule object, and an error if there is any.
+func (c *prewarmSchedules) Get(name string, options v1.GetOptions) (result *v1alpha1.PrewarmSchedule, err error) {
+	result = &v1alpha1.PrewarmSchedule{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("prewarmschedules").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of PrewarmSchedules that match those selectors.
+func (c *prewarmSchedules) List(opts v1.ListOptions) (result *v1alpha1.PrewarmScheduleList, err error) {
+	result = &v1alpha1.PrewarmScheduleList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("prewarmschedules").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested prewarmSchedules.
+func (c *prewarmSchedules) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("prewarmschedules").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Watch()
+}
+
+// Create takes the representation of a prewarmSchedule and creates it.  Returns the server's representation of the prewarmSchedule, and an error, if there is any.
+func (c *prewarmSchedules) Create(prewarmSchedule *v1alpha1.PrewarmSchedule) (result *v1alpha1.PrewarmSchedule, err error) {
+	result = &v1alpha1.PrewarmSchedule{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("prewarmschedules").
+		Body(prewarmSchedule).
+		Do().
+		Into(result)
+	return
+}
+
+// Update takes the representation of a prewarmSchedule and updates it. Returns the server's representation of the prewarmSchedule, and an error, if there is any.
+func (c *prewarmSchedules) Update(prewarmSchedule *v1alpha1.PrewarmSchedule) (result *v1alpha1.PrewarmSchedule, err error) {
+	result = &v1alpha1.PrewarmSchedule{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("prewarmschedules").
+		Name(prewarmSchedule.Name).
+		Body(prewarmSchedule).
+		Do().
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+
+func (c *prewarmSchedules) UpdateStatus(prewarmSchedule *v1alpha1.PrewarmSchedule) (result *v1alpha1.PrewarmSchedule, err error) {
+	result = &v1alpha1.PrewarmSchedule{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("prewarmschedules").
+		Name(prewarmSchedule.Name).
+		SubResource("status").
+		Body(prewarmSchedule).
+		Do().
+		Into(result)
+	return
+}
+
+// Delete takes name of the prewarmSchedule and deletes it. Returns an error if one occurs.
+func (c *prewarmSchedules) Delete(name string, options *v1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("prewarmschedules").
+		Name(name).
+		Body(options).
+		Do().
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *prewarmSchedules) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("prewarmschedules").
+		VersionedParams(&listOptions, scheme.ParameterCodec).
+		Body(options).
+		Do().
+		Error()
+}
+
+// Patch applies the patch and returns the patched prewarmSchedule.
+func (c *prewarmSchedules) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.PrewarmSchedule, err error) {
+	result = &v1alpha1.PrewarmSchedule{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("prewarmschedules").
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do().
+		Into(result)
+	return
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	time "time"
+
+	cachier_v1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	versioned "github.com/mattmoor/cachier/pkg/client/clientset/versioned"
+	internalinterfaces "github.com/mattmoor/cachier/pkg/client/informers/externalversions/internalinterfaces"
+	v1alpha1 "github.com/mattmoor/cachier/pkg/client/listers/cachier/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// CachePolicyInformer provides access to a shared informer and lister for
+// CachePolicies.
+type CachePolicyInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() v1alpha1.CachePolicyLister
+}
+
+type cachePolicyInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewCachePolicyInformer constructs a new informer for CachePolicy type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewCachePolicyInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredCachePolicyInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredCachePolicyInformer constructs a new informer for CachePolicy type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredCachePolicyInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.CachierV1alpha1().CachePolicies(namespace).List(options)
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.CachierV1alpha1().CachePolicies(namespace).Watch(options)
+			},
+		},
+		&cachier_v1alpha1.CachePolicy{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *cachePolicyInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredCachePolicyInformer(client, f.namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *cachePolicyInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&cachier_v1alpha1.CachePolicy{}, f.defaultInformer)
+}
+
+func (f *cachePolicyInformer) Lister() v1alpha1.CachePolicyLister {
+	return v1alpha1.NewCachePolicyLister(f.Informer().GetIndexer())
+}
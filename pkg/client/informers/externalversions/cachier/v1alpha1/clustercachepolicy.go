@@ -0,0 +1,85 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	time "time"
+
+	cachier_v1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	versioned "github.com/mattmoor/cachier/pkg/client/clientset/versioned"
+	internalinterfaces "github.com/mattmoor/cachier/pkg/client/informers/externalversions/internalinterfaces"
+	v1alpha1 "github.com/mattmoor/cachier/pkg/client/listers/cachier/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// ClusterCachePolicyInformer provides access to a shared informer and lister for
+// ClusterCachePolicies.
+type ClusterCachePolicyInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() v1alpha1.ClusterCachePolicyLister
+}
+
+type clusterCachePolicyInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+}
+
+// NewClusterCachePolicyInformer constructs a new informer for ClusterCachePolicy type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewClusterCachePolicyInformer(client versioned.Interface, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredClusterCachePolicyInformer(client, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredClusterCachePolicyInformer constructs a new informer for ClusterCachePolicy type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredClusterCachePolicyInformer(client versioned.Interface, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.CachierV1alpha1().ClusterCachePolicies().List(options)
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.CachierV1alpha1().ClusterCachePolicies().Watch(options)
+			},
+		},
+		&cachier_v1alpha1.ClusterCachePolicy{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *clusterCachePolicyInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredClusterCachePolicyInformer(client, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *clusterCachePolicyInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&cachier_v1alpha1.ClusterCachePolicy{}, f.defaultInformer)
+}
+
+func (f *clusterCachePolicyInformer) Lister() v1alpha1.ClusterCachePolicyLister {
+	return v1alpha1.NewClusterCachePolicyLister(f.Informer().GetIndexer())
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	internalinterfaces "github.com/mattmoor/cachier/pkg/client/informers/externalversions/internalinterfaces"
+)
+
+// Interface provides access to all the informers in this group version.
+type Interface interface {
+	// CachePolicies returns a CachePolicyInformer.
+	CachePolicies() CachePolicyInformer
+	// CachedImageSets returns a CachedImageSetInformer.
+	CachedImageSets() CachedImageSetInformer
+	// ClusterCachePolicies returns a ClusterCachePolicyInformer.
+	ClusterCachePolicies() ClusterCachePolicyInformer
+	// PrewarmSchedules returns a PrewarmScheduleInformer.
+	PrewarmSchedules() PrewarmScheduleInformer
+}
+
+type version struct {
+	factory          internalinterfaces.SharedInformerFactory
+	namespace        string
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+}
+
+// New returns a new Interface.
+func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakListOptions internalinterfaces.TweakListOptionsFunc) Interface {
+	return &version{factory: f, namespace: namespace, tweakListOptions: tweakListOptions}
+}
+
+// CachePolicies returns a CachePolicyInformer.
+func (v *version) CachePolicies() CachePolicyInformer {
+	return &cachePolicyInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// CachedImageSets returns a CachedImageSetInformer.
+func (v *version) CachedImageSets() CachedImageSetInformer {
+	return &cachedImageSetInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// ClusterCachePolicies returns a ClusterCachePolicyInformer.
+func (v *version) ClusterCachePolicies() ClusterCachePolicyInformer {
+	return &clusterCachePolicyInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// PrewarmSchedules returns a PrewarmScheduleInformer.
+func (v *version) PrewarmSchedules() PrewarmScheduleInformer {
+	return &prewarmScheduleInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
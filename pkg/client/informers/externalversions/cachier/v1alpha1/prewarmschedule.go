@@ -0,0 +1,86 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	time "time"
+
+	cachier_v1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	versioned "github.com/mattmoor/cachier/pkg/client/clientset/versioned"
+	internalinterfaces "github.com/mattmoor/cachier/pkg/client/informers/externalversions/internalinterfaces"
+	v1alpha1 "github.com/mattmoor/cachier/pkg/client/listers/cachier/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// PrewarmScheduleInformer provides access to a shared informer and lister for
+// PrewarmSchedules.
+type PrewarmScheduleInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() v1alpha1.PrewarmScheduleLister
+}
+
+type prewarmScheduleInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewPrewarmScheduleInformer constructs a new informer for PrewarmSchedule type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewPrewarmScheduleInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredPrewarmScheduleInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredPrewarmScheduleInformer constructs a new informer for PrewarmSchedule type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredPrewarmScheduleInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.CachierV1alpha1().PrewarmSchedules(namespace).List(options)
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.CachierV1alpha1().PrewarmSchedules(namespace).Watch(options)
+			},
+		},
+		&cachier_v1alpha1.PrewarmSchedule{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *prewarmScheduleInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredPrewarmScheduleInformer(client, f.namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *prewarmScheduleInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&cachier_v1alpha1.PrewarmSchedule{}, f.defaultInformer)
+}
+
+func (f *prewarmScheduleInformer) Lister() v1alpha1.PrewarmScheduleLister {
+	return v1alpha1.NewPrewarmScheduleLister(f.Informer().GetIndexer())
+}
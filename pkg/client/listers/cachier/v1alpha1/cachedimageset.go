@@ -0,0 +1,91 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	v1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// CachedImageSetLister helps list CachedImageSets.
+type CachedImageSetLister interface {
+	// List lists all CachedImageSets in the indexer.
+	List(selector labels.Selector) (ret []*v1alpha1.CachedImageSet, err error)
+	// CachedImageSets returns an object that can list and get CachedImageSets.
+	CachedImageSets(namespace string) CachedImageSetNamespaceLister
+	CachedImageSetListerExpansion
+}
+
+// cachedImageSetLister implements the CachedImageSetLister interface.
+type cachedImageSetLister struct {
+	indexer cache.Indexer
+}
+
+// NewCachedImageSetLister returns a new CachedImageSetLister.
+func NewCachedImageSetLister(indexer cache.Indexer) CachedImageSetLister {
+	return &cachedImageSetLister{indexer: indexer}
+}
+
+// List lists all CachedImageSets in the indexer.
+func (s *cachedImageSetLister) List(selector labels.Selector) (ret []*v1alpha1.CachedImageSet, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.CachedImageSet))
+	})
+	return ret, err
+}
+
+// CachedImageSets returns an object that can list and get CachedImageSets.
+func (s *cachedImageSetLister) CachedImageSets(namespace string) CachedImageSetNamespaceLister {
+	return cachedImageSetNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// CachedImageSetNamespaceLister helps list and get CachedImageSets.
+type CachedImageSetNamespaceLister interface {
+	// List lists all CachedImageSets in the indexer for a given namespace.
+	List(selector labels.Selector) (ret []*v1alpha1.CachedImageSet, err error)
+	// Get retrieves the CachedImageSet from the indexer for a given namespace and name.
+	Get(name string) (*v1alpha1.CachedImageSet, error)
+	CachedImageSetNamespaceListerExpansion
+}
+
+// cachedImageSetNamespaceLister implements the CachedImageSetNamespaceLister
+// interface.
+type cachedImageSetNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all CachedImageSets in the indexer for a given namespace.
+func (s cachedImageSetNamespaceLister) List(selector labels.Selector) (ret []*v1alpha1.CachedImageSet, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.CachedImageSet))
+	})
+	return ret, err
+}
+
+// Get retrieves the CachedImageSet from the indexer for a given namespace and name.
+func (s cachedImageSetNamespaceLister) Get(name string) (*v1alpha1.CachedImageSet, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("cachedimageset"), name)
+	}
+	return obj.(*v1alpha1.CachedImageSet), nil
+}
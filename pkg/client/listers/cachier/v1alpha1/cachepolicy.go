@@ -0,0 +1,91 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	v1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// CachePolicyLister helps list CachePolicies.
+type CachePolicyLister interface {
+	// List lists all CachePolicies in the indexer.
+	List(selector labels.Selector) (ret []*v1alpha1.CachePolicy, err error)
+	// CachePolicies returns an object that can list and get CachePolicies.
+	CachePolicies(namespace string) CachePolicyNamespaceLister
+	CachePolicyListerExpansion
+}
+
+// cachePolicyLister implements the CachePolicyLister interface.
+type cachePolicyLister struct {
+	indexer cache.Indexer
+}
+
+// NewCachePolicyLister returns a new CachePolicyLister.
+func NewCachePolicyLister(indexer cache.Indexer) CachePolicyLister {
+	return &cachePolicyLister{indexer: indexer}
+}
+
+// List lists all CachePolicies in the indexer.
+func (s *cachePolicyLister) List(selector labels.Selector) (ret []*v1alpha1.CachePolicy, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.CachePolicy))
+	})
+	return ret, err
+}
+
+// CachePolicies returns an object that can list and get CachePolicies.
+func (s *cachePolicyLister) CachePolicies(namespace string) CachePolicyNamespaceLister {
+	return cachePolicyNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// CachePolicyNamespaceLister helps list and get CachePolicies.
+type CachePolicyNamespaceLister interface {
+	// List lists all CachePolicies in the indexer for a given namespace.
+	List(selector labels.Selector) (ret []*v1alpha1.CachePolicy, err error)
+	// Get retrieves the CachePolicy from the indexer for a given namespace and name.
+	Get(name string) (*v1alpha1.CachePolicy, error)
+	CachePolicyNamespaceListerExpansion
+}
+
+// cachePolicyNamespaceLister implements the CachePolicyNamespaceLister
+// interface.
+type cachePolicyNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all CachePolicies in the indexer for a given namespace.
+func (s cachePolicyNamespaceLister) List(selector labels.Selector) (ret []*v1alpha1.CachePolicy, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.CachePolicy))
+	})
+	return ret, err
+}
+
+// Get retrieves the CachePolicy from the indexer for a given namespace and name.
+func (s cachePolicyNamespaceLister) Get(name string) (*v1alpha1.CachePolicy, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("cachepolicy"), name)
+	}
+	return obj.(*v1alpha1.CachePolicy), nil
+}
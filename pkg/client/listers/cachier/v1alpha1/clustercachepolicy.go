@@ -0,0 +1,62 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	v1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ClusterCachePolicyLister helps list ClusterCachePolicies.
+type ClusterCachePolicyLister interface {
+	// List lists all ClusterCachePolicies in the indexer.
+	List(selector labels.Selector) (ret []*v1alpha1.ClusterCachePolicy, err error)
+	// Get retrieves the ClusterCachePolicy from the index for a given name.
+	Get(name string) (*v1alpha1.ClusterCachePolicy, error)
+	ClusterCachePolicyListerExpansion
+}
+
+// clusterCachePolicyLister implements the ClusterCachePolicyLister interface.
+type clusterCachePolicyLister struct {
+	indexer cache.Indexer
+}
+
+// NewClusterCachePolicyLister returns a new ClusterCachePolicyLister.
+func NewClusterCachePolicyLister(indexer cache.Indexer) ClusterCachePolicyLister {
+	return &clusterCachePolicyLister{indexer: indexer}
+}
+
+// List lists all ClusterCachePolicies in the indexer.
+func (s *clusterCachePolicyLister) List(selector labels.Selector) (ret []*v1alpha1.ClusterCachePolicy, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.ClusterCachePolicy))
+	})
+	return ret, err
+}
+
+// Get retrieves the ClusterCachePolicy from the index for a given name.
+func (s *clusterCachePolicyLister) Get(name string) (*v1alpha1.ClusterCachePolicy, error) {
+	obj, exists, err := s.indexer.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("clustercachepolicy"), name)
+	}
+	return obj.(*v1alpha1.ClusterCachePolicy), nil
+}
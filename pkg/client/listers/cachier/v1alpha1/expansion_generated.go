@@ -0,0 +1,44 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+// CachePolicyListerExpansion allows custom methods to be added to
+// CachePolicyLister.
+type CachePolicyListerExpansion interface{}
+
+// CachePolicyNamespaceListerExpansion allows custom methods to be added to
+// CachePolicyNamespaceLister.
+type CachePolicyNamespaceListerExpansion interface{}
+
+// CachedImageSetListerExpansion allows custom methods to be added to
+// CachedImageSetLister.
+type CachedImageSetListerExpansion interface{}
+
+// CachedImageSetNamespaceListerExpansion allows custom methods to be added to
+// CachedImageSetNamespaceLister.
+type CachedImageSetNamespaceListerExpansion interface{}
+
+// ClusterCachePolicyListerExpansion allows custom methods to be added to
+// ClusterCachePolicyLister.
+type ClusterCachePolicyListerExpansion interface{}
+
+// PrewarmScheduleListerExpansion allows custom methods to be added to
+// PrewarmScheduleLister.
+type PrewarmScheduleListerExpansion interface{}
+
+// PrewarmScheduleNamespaceListerExpansion allows custom methods to be added to
+// PrewarmScheduleNamespaceLister.
+type PrewarmScheduleNamespaceListerExpansion interface{}
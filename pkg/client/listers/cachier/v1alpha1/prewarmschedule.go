@@ -0,0 +1,91 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	v1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PrewarmScheduleLister helps list PrewarmSchedules.
+type PrewarmScheduleLister interface {
+	// List lists all PrewarmSchedules in the indexer.
+	List(selector labels.Selector) (ret []*v1alpha1.PrewarmSchedule, err error)
+	// PrewarmSchedules returns an object that can list and get PrewarmSchedules.
+	PrewarmSchedules(namespace string) PrewarmScheduleNamespaceLister
+	PrewarmScheduleListerExpansion
+}
+
+// prewarmScheduleLister implements the PrewarmScheduleLister interface.
+type prewarmScheduleLister struct {
+	indexer cache.Indexer
+}
+
+// NewPrewarmScheduleLister returns a new PrewarmScheduleLister.
+func NewPrewarmScheduleLister(indexer cache.Indexer) PrewarmScheduleLister {
+	return &prewarmScheduleLister{indexer: indexer}
+}
+
+// List lists all PrewarmSchedules in the indexer.
+func (s *prewarmScheduleLister) List(selector labels.Selector) (ret []*v1alpha1.PrewarmSchedule, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.PrewarmSchedule))
+	})
+	return ret, err
+}
+
+// PrewarmSchedules returns an object that can list and get PrewarmSchedules.
+func (s *prewarmScheduleLister) PrewarmSchedules(namespace string) PrewarmScheduleNamespaceLister {
+	return prewarmScheduleNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// PrewarmScheduleNamespaceLister helps list and get PrewarmSchedules.
+type PrewarmScheduleNamespaceLister interface {
+	// List lists all PrewarmSchedules in the indexer for a given namespace.
+	List(selector labels.Selector) (ret []*v1alpha1.PrewarmSchedule, err error)
+	// Get retrieves the PrewarmSchedule from the indexer for a given namespace and name.
+	Get(name string) (*v1alpha1.PrewarmSchedule, error)
+	PrewarmScheduleNamespaceListerExpansion
+}
+
+// prewarmScheduleNamespaceLister implements the PrewarmScheduleNamespaceLister
+// interface.
+type prewarmScheduleNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all PrewarmSchedules in the indexer for a given namespace.
+func (s prewarmScheduleNamespaceLister) List(selector labels.Selector) (ret []*v1alpha1.PrewarmSchedule, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.PrewarmSchedule))
+	})
+	return ret, err
+}
+
+// Get retrieves the PrewarmSchedule from the indexer for a given namespace and name.
+func (s prewarmScheduleNamespaceLister) Get(name string) (*v1alpha1.PrewarmSchedule, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("prewarmschedule"), name)
+	}
+	return obj.(*v1alpha1.PrewarmSchedule), nil
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func mustTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	tm, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatalf("time.Parse(%q) = %v", s, err)
+	}
+	return tm
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) = nil, wanted error", spec)
+		}
+	}
+}
+
+func TestNextAndPrev(t *testing.T) {
+	tests := []struct {
+		spec string
+		at   string
+		next string
+		prev string
+	}{{
+		spec: "* * * * *",
+		at:   "2018-10-01T10:15:30Z",
+		next: "2018-10-01T10:16:00Z",
+		prev: "2018-10-01T10:15:00Z",
+	}, {
+		spec: "30 2 * * *",
+		at:   "2018-10-01T10:15:00Z",
+		next: "2018-10-02T02:30:00Z",
+		prev: "2018-10-01T02:30:00Z",
+	}, {
+		spec: "0 9 * * 1-5",
+		// A Saturday.
+		at:   "2018-10-06T12:00:00Z",
+		next: "2018-10-08T09:00:00Z",
+		prev: "2018-10-05T09:00:00Z",
+	}, {
+		spec: "*/15 0 1 1 *",
+		at:   "2018-10-01T00:00:00Z",
+		next: "2019-01-01T00:00:00Z",
+		prev: "2018-01-01T00:45:00Z",
+	}, {
+		// Either day field matching suffices when both are restricted.
+		spec: "0 0 13 * 5",
+		at:   "2018-10-01T00:00:00Z",
+		next: "2018-10-05T00:00:00Z",
+		prev: "2018-09-28T00:00:00Z",
+	}, {
+		spec: "0 0 * * 7",
+		at:   "2018-10-01T00:00:00Z",
+		next: "2018-10-07T00:00:00Z",
+		prev: "2018-09-30T00:00:00Z",
+	}, {
+		spec: "0 0 30 2 *",
+		at:   "2018-10-01T00:00:00Z",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			s, err := Parse(test.spec)
+			if err != nil {
+				t.Fatalf("Parse() = %v", err)
+			}
+			at := mustTime(t, test.at)
+
+			var wantNext, wantPrev time.Time
+			if test.next != "" {
+				wantNext = mustTime(t, test.next)
+			}
+			if test.prev != "" {
+				wantPrev = mustTime(t, test.prev)
+			}
+			if got := s.Next(at); !got.Equal(wantNext) {
+				t.Errorf("Next(%v) = %v, wanted %v", at, got, wantNext)
+			}
+			if got := s.Prev(at); !got.Equal(wantPrev) {
+				t.Errorf("Prev(%v) = %v, wanted %v", at, got, wantPrev)
+			}
+		})
+	}
+}
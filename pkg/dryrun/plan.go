@@ -0,0 +1,190 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dryrun lets the controller work out the Images that it would
+// create and delete, and report them, without writing anything.
+package dryrun
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	cachingv1alpha1 "github.com/knative/caching/pkg/client/clientset/versioned/typed/caching/v1alpha1"
+	cachinglisters "github.com/knative/caching/pkg/client/listers/caching/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+)
+
+// AnnotationKey enables or disables dry-run mode for the resources in a
+// Namespace when placed on it, as a label or an annotation (a label takes
+// precedence), overriding the controller's default.
+const AnnotationKey = "cachier.mattmoor.io/dry-run"
+
+// errUnsupported is returned for the writes that dry-run mode doesn't
+// expect, rather than making them.
+var errUnsupported = errors.New("unsupported in dry-run mode")
+
+// Enabled returns whether dry-run mode applies to the resources in the given
+// Namespace (which may be nil, if it isn't known), defaulting to def.
+func Enabled(ns *v1alpha1.WithMetadata, def bool) bool {
+	if ns == nil {
+		return def
+	}
+	for _, m := range []map[string]string{ns.Labels, ns.Annotations} {
+		if v, ok := m[AnnotationKey]; ok {
+			if enabled, err := strconv.ParseBool(v); err == nil {
+				return enabled
+			}
+		}
+	}
+	return def
+}
+
+// NamespaceChanged returns whether the change to a Namespace may change
+// whether dry-run mode applies to its resources.
+func NamespaceChanged(old, new *v1alpha1.WithMetadata) bool {
+	return old.Labels[AnnotationKey] != new.Labels[AnnotationKey] ||
+		old.Annotations[AnnotationKey] != new.Annotations[AnnotationKey]
+}
+
+// Report lists the image references of the Images that a reconcile would
+// create and delete.
+type Report struct {
+	Create []string `json:"create,omitempty"`
+	Delete []string `json:"delete,omitempty"`
+}
+
+// Empty returns whether the report has no changes.
+func (r Report) Empty() bool {
+	return len(r.Create) == 0 && len(r.Delete) == 0
+}
+
+// Plan records the writes to Images that a reconcile would make, instead
+// of making them.  It implements cachingv1alpha1.ImagesGetter, so that
+// reconcilers compute it just as they would make the writes.
+type Plan struct {
+	client cachingv1alpha1.ImagesGetter
+	lister cachinglisters.ImageLister
+
+	m      sync.Mutex
+	report Report
+}
+
+var _ cachingv1alpha1.ImagesGetter = (*Plan)(nil)
+
+// NewPlan returns an empty Plan, which reads Images through client, and
+// resolves the Images that it would delete through lister.
+func NewPlan(client cachingv1alpha1.ImagesGetter, lister cachinglisters.ImageLister) *Plan {
+	return &Plan{client: client, lister: lister}
+}
+
+// Images implements cachingv1alpha1.ImagesGetter
+func (p *Plan) Images(namespace string) cachingv1alpha1.ImageInterface {
+	return &planImages{
+		ImageInterface: p.client.Images(namespace),
+		plan:           p,
+		namespace:      namespace,
+	}
+}
+
+// Report returns what the plan would create and delete, sorted.
+func (p *Plan) Report() Report {
+	p.m.Lock()
+	defer p.m.Unlock()
+	out := Report{
+		Create: append([]string(nil), p.report.Create...),
+		Delete: append([]string(nil), p.report.Delete...),
+	}
+	sort.Strings(out.Create)
+	sort.Strings(out.Delete)
+	return out
+}
+
+func (p *Plan) create(image string) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	p.report.Create = append(p.report.Create, image)
+}
+
+func (p *Plan) delete(imgs ...*caching.Image) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	for _, img := range imgs {
+		p.report.Delete = append(p.report.Delete, img.Spec.Image)
+	}
+}
+
+// planImages records the writes to the Images of a namespace in its plan.
+// Reads go to the API server.
+type planImages struct {
+	cachingv1alpha1.ImageInterface
+	plan      *Plan
+	namespace string
+}
+
+// Create implements cachingv1alpha1.ImageInterface
+func (pi *planImages) Create(img *caching.Image) (*caching.Image, error) {
+	pi.plan.create(img.Spec.Image)
+	return img.DeepCopy(), nil
+}
+
+// Delete implements cachingv1alpha1.ImageInterface
+func (pi *planImages) Delete(name string, _ *metav1.DeleteOptions) error {
+	img, err := pi.plan.lister.Images(pi.namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	pi.plan.delete(img)
+	return nil
+}
+
+// DeleteCollection implements cachingv1alpha1.ImageInterface
+func (pi *planImages) DeleteCollection(_ *metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	selector, err := labels.Parse(listOpts.LabelSelector)
+	if err != nil {
+		return err
+	}
+	imgs, err := pi.plan.lister.Images(pi.namespace).List(selector)
+	if err != nil {
+		return err
+	}
+	pi.plan.delete(imgs...)
+	return nil
+}
+
+// Update implements cachingv1alpha1.ImageInterface
+func (pi *planImages) Update(*caching.Image) (*caching.Image, error) {
+	return nil, errUnsupported
+}
+
+// UpdateStatus implements cachingv1alpha1.ImageInterface
+func (pi *planImages) UpdateStatus(*caching.Image) (*caching.Image, error) {
+	return nil, errUnsupported
+}
+
+// Patch implements cachingv1alpha1.ImageInterface
+func (pi *planImages) Patch(string, types.PatchType, []byte, ...string) (*caching.Image, error) {
+	return nil, errUnsupported
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dryrun
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	cachingv1alpha1 "github.com/knative/caching/pkg/client/clientset/versioned/typed/caching/v1alpha1"
+	cachinglisters "github.com/knative/caching/pkg/client/listers/caching/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+)
+
+func TestEnabled(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		def         bool
+		want        bool
+	}{{
+		name: "default off",
+	}, {
+		name: "default on",
+		def:  true,
+		want: true,
+	}, {
+		name:        "annotation enables",
+		annotations: map[string]string{AnnotationKey: "true"},
+		want:        true,
+	}, {
+		name:   "label disables",
+		labels: map[string]string{AnnotationKey: "false"},
+		def:    true,
+	}, {
+		name:        "label wins",
+		labels:      map[string]string{AnnotationKey: "false"},
+		annotations: map[string]string{AnnotationKey: "true"},
+		def:         true,
+	}, {
+		name:        "invalid value",
+		annotations: map[string]string{AnnotationKey: "maybe"},
+		def:         true,
+		want:        true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ns := &v1alpha1.WithMetadata{ObjectMeta: metav1.ObjectMeta{
+				Name:        "foo",
+				Labels:      test.labels,
+				Annotations: test.annotations,
+			}}
+			if got := Enabled(ns, test.def); got != test.want {
+				t.Errorf("Enabled() = %v, wanted %v", got, test.want)
+			}
+		})
+	}
+
+	if !Enabled(nil, true) {
+		t.Error("Enabled(nil, true) = false, wanted the default")
+	}
+}
+
+func image(name, ref string, labels map[string]string) *caching.Image {
+	return &caching.Image{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "foo", Name: name, Labels: labels},
+		Spec:       caching.ImageSpec{Image: ref},
+	}
+}
+
+// noImages panics on any call to the API server.
+type noImages struct {
+	cachingv1alpha1.ImageInterface
+}
+
+type noImagesGetter struct{}
+
+func (noImagesGetter) Images(string) cachingv1alpha1.ImageInterface {
+	return noImages{}
+}
+
+func TestPlan(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, img := range []*caching.Image{
+		image("a", "gcr.io/foo/a", map[string]string{"generation": "1"}),
+		image("b", "gcr.io/foo/b", map[string]string{"generation": "1"}),
+		image("c", "gcr.io/foo/c", map[string]string{"generation": "2"}),
+		image("d", "gcr.io/foo/d", nil),
+	} {
+		indexer.Add(img)
+	}
+	// Nothing is written to the API server.
+	plan := NewPlan(noImagesGetter{}, cachinglisters.NewImageLister(indexer))
+
+	images := plan.Images("foo")
+	if _, err := images.Create(image("e", "gcr.io/foo/e", nil)); err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+	if err := images.DeleteCollection(&metav1.DeleteOptions{}, metav1.ListOptions{LabelSelector: "generation=1"}); err != nil {
+		t.Fatalf("DeleteCollection() = %v", err)
+	}
+	if err := images.Delete("d", &metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete(d) = %v", err)
+	}
+	// Images that are already gone aren't reported.
+	if err := images.Delete("missing", &metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete(missing) = %v", err)
+	}
+	if _, err := images.Update(image("c", "gcr.io/foo/c", nil)); err == nil {
+		t.Error("Update() = nil, wanted an error")
+	}
+
+	want := Report{
+		Create: []string{"gcr.io/foo/e"},
+		Delete: []string{"gcr.io/foo/a", "gcr.io/foo/b", "gcr.io/foo/d"},
+	}
+	if diff := cmp.Diff(want, plan.Report()); diff != "" {
+		t.Errorf("Report() (-want, +got) = %v", diff)
+	}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dryrun
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+)
+
+// ConfigMapsResource is the resource in which we write reports.
+var ConfigMapsResource = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+// Options configures a Reporter.
+type Options struct {
+	// Client writes the report ConfigMaps.  When nil, reports are only
+	// logged and counted.
+	Client dynamic.Interface
+
+	// Name names the report ConfigMap in each namespace.
+	Name string
+
+	// Period is how often changed reports are written.  Defaults to 10s.
+	Period time.Duration
+
+	Logger *zap.SugaredLogger
+}
+
+// Stats counts the reported changes.
+type Stats struct {
+	Resources int `json:"resources"`
+	Creates   int `json:"creates"`
+	Deletes   int `json:"deletes"`
+}
+
+// Reporter collects the Reports of the resources in dry-run mode, and
+// periodically writes those of each namespace to a ConfigMap there, keyed
+// by resource.  It is shared by the controllers of all resources, so that
+// only one thing writes each ConfigMap.  A nil Reporter reports nothing.
+type Reporter struct {
+	client dynamic.Interface
+	name   string
+	period time.Duration
+	logger *zap.SugaredLogger
+
+	m       sync.Mutex
+	reports map[string]map[string]Report
+	dirty   sets.String
+}
+
+// NewReporter returns a Reporter with the given Options.
+func NewReporter(opts Options) *Reporter {
+	r := &Reporter{
+		client:  opts.Client,
+		name:    opts.Name,
+		period:  opts.Period,
+		logger:  opts.Logger,
+		reports: map[string]map[string]Report{},
+		dirty:   sets.NewString(),
+	}
+	if r.period == 0 {
+		r.period = 10 * time.Second
+	}
+	return r
+}
+
+// Report records the Report of the named resource in the namespace, where
+// name must be a valid ConfigMap key that identifies the resource amongst
+// those of every kind (e.g. "deployments.apps.foo").  Empty reports are
+// dropped.
+func (r *Reporter) Report(namespace, name string, report Report) {
+	if r == nil {
+		return
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	if report.Empty() {
+		r.forget(namespace, name)
+		return
+	}
+	if reflect.DeepEqual(r.reports[namespace][name], report) {
+		return
+	}
+	if r.reports[namespace] == nil {
+		r.reports[namespace] = map[string]Report{}
+	}
+	r.reports[namespace][name] = report
+	r.dirty.Insert(namespace)
+}
+
+// Forget drops the Report of the named resource in the namespace, e.g.
+// because it was deleted, or dry-run mode no longer applies to it.
+func (r *Reporter) Forget(namespace, name string) {
+	if r == nil {
+		return
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.forget(namespace, name)
+}
+
+func (r *Reporter) forget(namespace, name string) {
+	if _, ok := r.reports[namespace][name]; !ok {
+		return
+	}
+	delete(r.reports[namespace], name)
+	r.dirty.Insert(namespace)
+}
+
+// Stats returns the totals of the current Reports, by namespace.
+func (r *Reporter) Stats() map[string]Stats {
+	if r == nil {
+		return nil
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	out := make(map[string]Stats, len(r.reports))
+	for ns, reports := range r.reports {
+		if len(reports) == 0 {
+			continue
+		}
+		var s Stats
+		for _, report := range reports {
+			s.Resources++
+			s.Creates += len(report.Create)
+			s.Deletes += len(report.Delete)
+		}
+		out[ns] = s
+	}
+	return out
+}
+
+// Start writes the changed reports every period until stopCh is closed.
+func (r *Reporter) Start(stopCh <-chan struct{}) {
+	if r == nil || r.client == nil {
+		return
+	}
+	go wait.Until(r.flush, r.period, stopCh)
+}
+
+// flush writes the ConfigMaps of the namespaces whose reports changed.
+func (r *Reporter) flush() {
+	r.m.Lock()
+	namespaces := r.dirty.List()
+	r.dirty = sets.NewString()
+	r.m.Unlock()
+
+	for _, ns := range namespaces {
+		data, err := r.data(ns)
+		if err == nil {
+			err = r.write(ns, data)
+		}
+		if err != nil {
+			r.logger.Errorf("Error writing dry-run report %s/%s: %v", ns, r.name, err)
+			// Try again next time.
+			r.m.Lock()
+			r.dirty.Insert(ns)
+			r.m.Unlock()
+		}
+	}
+}
+
+// data returns the contents of the namespace's ConfigMap: the JSON of the
+// Report of each of its resources, keyed by resource.
+func (r *Reporter) data(namespace string) (map[string]interface{}, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	data := make(map[string]interface{}, len(r.reports[namespace]))
+	for name, report := range r.reports[namespace] {
+		b, err := json.Marshal(report)
+		if err != nil {
+			return nil, err
+		}
+		data[name] = string(b)
+	}
+	return data, nil
+}
+
+// write creates or updates the namespace's ConfigMap with the given data.
+func (r *Reporter) write(namespace string, data map[string]interface{}) error {
+	cms := r.client.Resource(ConfigMapsResource).Namespace(namespace)
+	cm, err := cms.Get(r.name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		if len(data) == 0 {
+			return nil
+		}
+		cm = &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":      r.name,
+				"namespace": namespace,
+			},
+			"data": data,
+		}}
+		cm.SetGroupVersionKind(ConfigMapsResource.GroupVersion().WithKind("ConfigMap"))
+		_, err = cms.Create(cm)
+		return err
+	} else if err != nil {
+		return err
+	}
+	if err := unstructured.SetNestedField(cm.Object, data, "data"); err != nil {
+		return err
+	}
+	_, err = cms.Update(cm)
+	return err
+}
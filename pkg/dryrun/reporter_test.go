@@ -0,0 +1,74 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dryrun
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/zap"
+)
+
+func TestReporter(t *testing.T) {
+	var nilReporter *Reporter
+	nilReporter.Report("foo", "deployments.apps.bar", Report{Create: []string{"a"}})
+	nilReporter.Forget("foo", "deployments.apps.bar")
+	if got := nilReporter.Stats(); got != nil {
+		t.Errorf("nil Stats() = %v, wanted nil", got)
+	}
+
+	r := NewReporter(Options{Name: "cachier-dry-run", Logger: zap.NewNop().Sugar()})
+	r.Report("foo", "deployments.apps.bar", Report{Create: []string{"a", "b"}, Delete: []string{"c"}})
+	r.Report("foo", "statefulsets.apps.baz", Report{Create: []string{"d"}})
+	r.Report("qux", "deployments.apps.bar", Report{Delete: []string{"e"}})
+	// Empty reports are dropped.
+	r.Report("quux", "deployments.apps.bar", Report{})
+
+	want := map[string]Stats{
+		"foo": {Resources: 2, Creates: 3, Deletes: 1},
+		"qux": {Resources: 1, Deletes: 1},
+	}
+	if diff := cmp.Diff(want, r.Stats()); diff != "" {
+		t.Errorf("Stats() (-want, +got) = %v", diff)
+	}
+	if diff := cmp.Diff([]string{"foo", "qux"}, r.dirty.List()); diff != "" {
+		t.Errorf("dirty (-want, +got) = %v", diff)
+	}
+
+	data, err := r.data("foo")
+	if err != nil {
+		t.Fatalf("data() = %v", err)
+	}
+	wantData := map[string]interface{}{
+		"deployments.apps.bar":  `{"create":["a","b"],"delete":["c"]}`,
+		"statefulsets.apps.baz": `{"create":["d"]}`,
+	}
+	if diff := cmp.Diff(wantData, data); diff != "" {
+		t.Errorf("data() (-want, +got) = %v", diff)
+	}
+
+	// Forgetting a resource, or reporting that it no longer has changes,
+	// drops it.
+	r.Forget("foo", "statefulsets.apps.baz")
+	r.Report("qux", "deployments.apps.bar", Report{})
+	want = map[string]Stats{
+		"foo": {Resources: 1, Creates: 2, Deletes: 1},
+	}
+	if diff := cmp.Diff(want, r.Stats()); diff != "" {
+		t.Errorf("Stats() (-want, +got) = %v", diff)
+	}
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package export computes the deduplicated set of images that cachier
+// manages, most used first, e.g. to pre-load them into node images.
+package export
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/mattmoor/cachier/pkg/inventory"
+	"github.com/mattmoor/cachier/pkg/reference"
+)
+
+// Options configures Compute.
+type Options struct {
+	// Namespaces, when set, limits the export to the Images in them.
+	Namespaces []string
+
+	// Policies, when set, limits the export to the Images of the workloads
+	// that these CachePolicies or ClusterCachePolicies apply to, named
+	// either "name" or "Kind/name" (e.g. "ClusterCachePolicy/default").
+	Policies []string
+
+	// Weighted weights each image by the replicas of the workloads that
+	// use it (counting one for workloads without replicas, e.g.
+	// DaemonSets), rather than by the number of workloads.
+	Weighted bool
+
+	// Limit, when positive, keeps only that many of the most used images.
+	Limit int
+}
+
+// Manifest is the exported set of images.
+type Manifest struct {
+	Generated time.Time `json:"generated"`
+	Weighted  bool      `json:"weighted"`
+
+	// Images are sorted by weight, heaviest first, and then by reference.
+	Images []Entry `json:"images"`
+}
+
+// Entry is an image, and how much it is used.
+type Entry struct {
+	// Reference is the normalized reference to pull, which is pinned to a
+	// digest when one is known.
+	Reference string `json:"reference"`
+
+	// Digest is the digest of Reference, if any.
+	Digest string `json:"digest,omitempty"`
+
+	// Tags are the normalized tagged references that the workloads use,
+	// which were pinned to Digest, if any.  Node images should be tagged
+	// with these too, so that the kubelet finds them.
+	Tags []string `json:"tags,omitempty"`
+
+	// Weight is the number of workloads that use the image, or when
+	// weighted the sum of their replicas.
+	Weight int64 `json:"weight"`
+
+	Workloads  int      `json:"workloads"`
+	Namespaces []string `json:"namespaces"`
+}
+
+// ParseOptions parses Options from the parameters namespace and policy
+// (which may be repeated), weighted and limit, as the Values method encodes
+// them.
+func ParseOptions(v url.Values) (Options, error) {
+	opts := Options{
+		Namespaces: v["namespace"],
+		Policies:   v["policy"],
+	}
+	if s := v.Get("weighted"); s != "" {
+		var err error
+		if opts.Weighted, err = strconv.ParseBool(s); err != nil {
+			return Options{}, fmt.Errorf("invalid weighted: %v", err)
+		}
+	}
+	if s := v.Get("limit"); s != "" {
+		var err error
+		if opts.Limit, err = strconv.Atoi(s); err != nil {
+			return Options{}, fmt.Errorf("invalid limit: %v", err)
+		}
+	}
+	return opts, nil
+}
+
+// Values encodes the options as parameters for ParseOptions.
+func (opts Options) Values() url.Values {
+	v := url.Values{}
+	for _, ns := range opts.Namespaces {
+		v.Add("namespace", ns)
+	}
+	for _, p := range opts.Policies {
+		v.Add("policy", p)
+	}
+	if opts.Weighted {
+		v.Set("weighted", "true")
+	}
+	if opts.Limit > 0 {
+		v.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	return v
+}
+
+// Compute returns the deduplicated set of the images of the Images in the
+// inventory that the options select.
+func Compute(r *inventory.Report, opts Options) *Manifest {
+	namespaces := sets.NewString(opts.Namespaces...)
+
+	type usage struct {
+		Entry
+		tags       sets.String
+		workloads  sets.String
+		namespaces sets.String
+	}
+	byRef := map[string]*usage{}
+	for _, ns := range r.Namespaces {
+		if namespaces.Len() > 0 && !namespaces.Has(ns.Name) {
+			continue
+		}
+		for _, w := range ns.Workloads {
+			if len(opts.Policies) > 0 && !appliesTo(w.Decision, opts.Policies) {
+				continue
+			}
+			weight := int64(1)
+			if opts.Weighted && w.Replicas != nil {
+				weight = int64(*w.Replicas)
+			}
+			key := strings.Join([]string{ns.Name, w.Group, w.Kind, w.Name}, "/")
+
+			for _, img := range w.Images {
+				ref, tags := pin(img.Image, w.PinnedImages)
+				e := byRef[ref]
+				if e == nil {
+					e = &usage{
+						Entry:      Entry{Reference: ref},
+						tags:       sets.NewString(),
+						workloads:  sets.NewString(),
+						namespaces: sets.NewString(),
+					}
+					if parsed, err := reference.Parse(ref); err == nil {
+						e.Digest = parsed.Digest
+					}
+					byRef[ref] = e
+				}
+				e.tags.Insert(tags...)
+				// Workloads count once, whatever their number of Images
+				// (e.g. mid-rollout) of the image.
+				if !e.workloads.Has(key) {
+					e.workloads.Insert(key)
+					e.Weight += weight
+				}
+				e.namespaces.Insert(ns.Name)
+			}
+		}
+	}
+
+	m := &Manifest{
+		Generated: r.Generated,
+		Weighted:  opts.Weighted,
+		Images:    make([]Entry, 0, len(byRef)),
+	}
+	for _, e := range byRef {
+		if e.tags.Len() > 0 {
+			e.Tags = e.tags.List()
+		}
+		e.Workloads = e.workloads.Len()
+		e.Namespaces = e.namespaces.List()
+		m.Images = append(m.Images, e.Entry)
+	}
+	sort.Slice(m.Images, func(i, j int) bool {
+		a, b := m.Images[i], m.Images[j]
+		if a.Weight != b.Weight {
+			return a.Weight > b.Weight
+		}
+		return a.Reference < b.Reference
+	})
+	if opts.Limit > 0 && len(m.Images) > opts.Limit {
+		m.Images = m.Images[:opts.Limit]
+	}
+	return m
+}
+
+// pin returns the normalized reference of the image, pinned to the digest
+// that the workload's pinned images record, if any, and the normalized
+// tagged references that were pinned to it.
+func pin(image string, pinned map[string]string) (string, []string) {
+	var tags []string
+	if p, ok := pinned[image]; ok {
+		// The Image predates pinning.
+		tags = append(tags, reference.Normalize(image))
+		image = p
+	} else {
+		for tagged, p := range pinned {
+			if p == image {
+				tags = append(tags, reference.Normalize(tagged))
+			}
+		}
+	}
+	return reference.Normalize(image), tags
+}
+
+// appliesTo returns whether one of the policies is that of the decision.
+func appliesTo(d *inventory.Decision, policies []string) bool {
+	if d == nil || d.Policy == "" {
+		return false
+	}
+	// Policies are described like `CachePolicy "foo"`.
+	parts := strings.SplitN(d.Policy, " ", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	name, err := strconv.Unquote(parts[1])
+	if err != nil {
+		return false
+	}
+	for _, p := range policies {
+		if p == name || p == parts[0]+"/"+name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/mattmoor/cachier/pkg/inventory"
+)
+
+var now = time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+
+func images(refs ...string) []inventory.Image {
+	var imgs []inventory.Image
+	for _, ref := range refs {
+		imgs = append(imgs, inventory.Image{Image: ref})
+	}
+	return imgs
+}
+
+func replicas(n int32) *int32 {
+	return &n
+}
+
+var report = &inventory.Report{
+	Generated: now,
+	Namespaces: []inventory.Namespace{{
+		Name: "a",
+		Workloads: []inventory.Workload{{
+			Kind:     "Deployment",
+			Name:     "web",
+			Replicas: replicas(5),
+			Decision: &inventory.Decision{Cached: true, Policy: `CachePolicy "web"`},
+			// Mid-rollout, with Images of two generations.
+			Images: images("index.docker.io/library/nginx@sha256:1", "index.docker.io/library/nginx@sha256:1", "gcr.io/foo/web:v2"),
+			PinnedImages: map[string]string{
+				"nginx:1.15": "index.docker.io/library/nginx@sha256:1",
+			},
+		}, {
+			Kind:     "DaemonSet",
+			Name:     "agent",
+			Decision: &inventory.Decision{Cached: true, Policy: `ClusterCachePolicy "default"`},
+			Images:   images("gcr.io/foo/agent:v1", "gcr.io/foo/base"),
+		}},
+	}, {
+		Name: "b",
+		Workloads: []inventory.Workload{{
+			Kind:     "StatefulSet",
+			Name:     "db",
+			Replicas: replicas(0),
+			Decision: &inventory.Decision{Cached: true, Policy: `ClusterCachePolicy "default"`},
+			// An Image from before pinning.
+			Images: images("gcr.io/foo/base"),
+			PinnedImages: map[string]string{
+				"gcr.io/foo/base": "gcr.io/foo/base@sha256:2",
+			},
+		}, {
+			Kind:   "CachedImageSet",
+			Name:   "set",
+			Images: images("gcr.io/foo/base"),
+		}},
+	}},
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want []Entry
+	}{{
+		name: "by workloads",
+		want: []Entry{{
+			Reference:  "gcr.io/foo/base:latest",
+			Weight:     2,
+			Workloads:  2,
+			Namespaces: []string{"a", "b"},
+		}, {
+			Reference:  "gcr.io/foo/agent:v1",
+			Weight:     1,
+			Workloads:  1,
+			Namespaces: []string{"a"},
+		}, {
+			Reference:  "gcr.io/foo/base@sha256:2",
+			Digest:     "sha256:2",
+			Tags:       []string{"gcr.io/foo/base:latest"},
+			Weight:     1,
+			Workloads:  1,
+			Namespaces: []string{"b"},
+		}, {
+			Reference:  "gcr.io/foo/web:v2",
+			Weight:     1,
+			Workloads:  1,
+			Namespaces: []string{"a"},
+		}, {
+			Reference:  "index.docker.io/library/nginx@sha256:1",
+			Digest:     "sha256:1",
+			Tags:       []string{"index.docker.io/library/nginx:1.15"},
+			Weight:     1,
+			Workloads:  1,
+			Namespaces: []string{"a"},
+		}},
+	}, {
+		name: "by replicas, top 3",
+		opts: Options{Weighted: true, Limit: 3},
+		want: []Entry{{
+			Reference:  "gcr.io/foo/web:v2",
+			Weight:     5,
+			Workloads:  1,
+			Namespaces: []string{"a"},
+		}, {
+			Reference:  "index.docker.io/library/nginx@sha256:1",
+			Digest:     "sha256:1",
+			Tags:       []string{"index.docker.io/library/nginx:1.15"},
+			Weight:     5,
+			Workloads:  1,
+			Namespaces: []string{"a"},
+		}, {
+			Reference:  "gcr.io/foo/base:latest",
+			Weight:     2,
+			Workloads:  2,
+			Namespaces: []string{"a", "b"},
+		}},
+	}, {
+		name: "by namespace",
+		opts: Options{Namespaces: []string{"b"}},
+		want: []Entry{{
+			Reference:  "gcr.io/foo/base:latest",
+			Weight:     1,
+			Workloads:  1,
+			Namespaces: []string{"b"},
+		}, {
+			Reference:  "gcr.io/foo/base@sha256:2",
+			Digest:     "sha256:2",
+			Tags:       []string{"gcr.io/foo/base:latest"},
+			Weight:     1,
+			Workloads:  1,
+			Namespaces: []string{"b"},
+		}},
+	}, {
+		name: "by policy",
+		opts: Options{Policies: []string{"web", "ClusterCachePolicy/other"}},
+		want: []Entry{{
+			Reference:  "gcr.io/foo/web:v2",
+			Weight:     1,
+			Workloads:  1,
+			Namespaces: []string{"a"},
+		}, {
+			Reference:  "index.docker.io/library/nginx@sha256:1",
+			Digest:     "sha256:1",
+			Tags:       []string{"index.docker.io/library/nginx:1.15"},
+			Weight:     1,
+			Workloads:  1,
+			Namespaces: []string{"a"},
+		}},
+	}, {
+		name: "by qualified policy",
+		opts: Options{Policies: []string{"ClusterCachePolicy/default"}, Namespaces: []string{"b"}},
+		want: []Entry{{
+			Reference:  "gcr.io/foo/base@sha256:2",
+			Digest:     "sha256:2",
+			Tags:       []string{"gcr.io/foo/base:latest"},
+			Weight:     1,
+			Workloads:  1,
+			Namespaces: []string{"b"},
+		}},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Compute(report, test.opts)
+			if got.Generated != now || got.Weighted != test.opts.Weighted {
+				t.Errorf("Compute() = %v, %v, wanted %v, %v", got.Generated, got.Weighted, now, test.opts.Weighted)
+			}
+			if diff := cmp.Diff(test.want, got.Images); diff != "" {
+				t.Errorf("Compute() (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	want := Options{
+		Namespaces: []string{"a", "b"},
+		Policies:   []string{"ClusterCachePolicy/default"},
+		Weighted:   true,
+		Limit:      10,
+	}
+	got, err := ParseOptions(want.Values())
+	if err != nil {
+		t.Fatalf("ParseOptions() = %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseOptions() (-want, +got) = %v", diff)
+	}
+
+	for _, v := range []url.Values{
+		{"weighted": []string{"maybe"}},
+		{"limit": []string{"ten"}},
+	} {
+		if _, err := ParseOptions(v); err == nil {
+			t.Errorf("ParseOptions(%v) = nil, wanted error", v)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/mattmoor/cachier/pkg/inventory"
+	"github.com/mattmoor/cachier/pkg/reference"
+)
+
+const (
+	// FormatList is one reference per line.
+	FormatList = "list"
+	// FormatCtr is a shell script that pulls the images into containerd.
+	FormatCtr = "ctr"
+	// FormatJSON is the Manifest as JSON.
+	FormatJSON = "json"
+)
+
+// Formats are those that Write supports.
+var Formats = []string{FormatList, FormatCtr, FormatJSON}
+
+// Write writes the manifest in the given format.
+func Write(w io.Writer, format string, m *Manifest) error {
+	switch format {
+	case FormatList:
+		for _, e := range m.Images {
+			if _, err := fmt.Fprintln(w, e.Reference); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatCtr:
+		return writeScript(w, m)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+	default:
+		return fmt.Errorf("format must be one of %s, got %q", strings.Join(Formats, ", "), format)
+	}
+}
+
+// writeScript writes a script that pulls the images into the k8s.io
+// namespace of containerd, where the kubelet finds them, and tags those
+// pulled by digest with the tags the workloads use.
+func writeScript(w io.Writer, m *Manifest) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `#!/bin/sh
+# The images that cachier manages, as of %s, most used first.
+# Set CTR_FLAGS to pass flags to ctr images pull (e.g. --user).
+set -e
+`, m.Generated.UTC().Format("2006-01-02T15:04:05Z"))
+	for _, e := range m.Images {
+		ref := ctrName(e.Reference)
+		fmt.Fprintf(&b, "ctr -n k8s.io images pull $CTR_FLAGS %s\n", shellQuote(ref))
+		for _, tag := range e.Tags {
+			fmt.Fprintf(&b, "ctr -n k8s.io images tag --force %s %s\n", shellQuote(ref), shellQuote(ctrName(tag)))
+		}
+	}
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// ctrName returns the name by which containerd knows the normalized
+// reference, which uses docker.io for Docker Hub.
+func ctrName(ref string) string {
+	return strings.Replace(ref, reference.DockerHub+"/", "docker.io/", 1)
+}
+
+// shellQuote quotes s for sh.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// Handler serves the export of the inventory, in the format of ?format=
+// (default list), with the options of the other parameters (see
+// ParseOptions).
+func Handler(inv *inventory.Inventory) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		opts, err := ParseOptions(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		format := r.URL.Query().Get("format")
+		switch format {
+		case "", FormatList:
+			format = FormatList
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		case FormatCtr:
+			w.Header().Set("Content-Type", "text/x-shellscript")
+		case FormatJSON:
+			w.Header().Set("Content-Type", "application/json")
+		default:
+			http.Error(w, fmt.Sprintf("format must be one of %s, got %q", strings.Join(Formats, ", "), format), http.StatusBadRequest)
+			return
+		}
+
+		// Namespaced exports only need the inventory of that namespace.
+		namespace := ""
+		if len(opts.Namespaces) == 1 {
+			namespace = opts.Namespaces[0]
+		}
+		report, err := inv.Report(namespace)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := Write(w, format, Compute(report, opts)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
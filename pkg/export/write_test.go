@@ -0,0 +1,90 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWrite(t *testing.T) {
+	m := &Manifest{
+		Generated: now,
+		Images: []Entry{{
+			Reference: "index.docker.io/library/nginx@sha256:1",
+			Digest:    "sha256:1",
+			Tags:      []string{"index.docker.io/library/nginx:1.15"},
+			Weight:    2,
+		}, {
+			Reference: "gcr.io/foo/web:v2",
+			Weight:    1,
+		}},
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{{
+		format: FormatList,
+		want: `index.docker.io/library/nginx@sha256:1
+gcr.io/foo/web:v2
+`,
+	}, {
+		format: FormatCtr,
+		want: `#!/bin/sh
+# The images that cachier manages, as of 2018-10-01T12:00:00Z, most used first.
+# Set CTR_FLAGS to pass flags to ctr images pull (e.g. --user).
+set -e
+ctr -n k8s.io images pull $CTR_FLAGS 'docker.io/library/nginx@sha256:1'
+ctr -n k8s.io images tag --force 'docker.io/library/nginx@sha256:1' 'docker.io/library/nginx:1.15'
+ctr -n k8s.io images pull $CTR_FLAGS 'gcr.io/foo/web:v2'
+`,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, test.format, m); err != nil {
+				t.Fatalf("Write() = %v", err)
+			}
+			if diff := cmp.Diff(test.want, buf.String()); diff != "" {
+				t.Errorf("Write() (-want, +got) = %v", diff)
+			}
+		})
+	}
+
+	t.Run(FormatJSON, func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := Write(&buf, FormatJSON, m); err != nil {
+			t.Fatalf("Write() = %v", err)
+		}
+		got := &Manifest{}
+		if err := json.Unmarshal(buf.Bytes(), got); err != nil {
+			t.Fatalf("Unmarshal() = %v", err)
+		}
+		if diff := cmp.Diff(m, got); diff != "" {
+			t.Errorf("Write() round trip (-want, +got) = %v", diff)
+		}
+	})
+
+	if err := Write(&bytes.Buffer{}, "yaml", m); err == nil {
+		t.Error("Write(yaml) = nil, wanted error")
+	}
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package extractors implements configurable, JSONPath-based extraction
+// of images from arbitrary resources that don't fit the PodSpecable duck
+// type.
+package extractors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+	"github.com/knative/pkg/apis/duck"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/jsonpath"
+)
+
+// Config is the file format with which extractors are configured, e.g.
+//
+//	extractors:
+//	- resource: Task.v1alpha1.tekton.dev
+//	  images:
+//	  - "{.spec.steps[*].image}"
+type Config struct {
+	Extractors []Spec `json:"extractors,omitempty"`
+}
+
+// Spec configures the extraction of images from a single kind of resource.
+type Spec struct {
+	// Resource is the kind to which this applies, in the form
+	// Kind.version.group (e.g. TFJob.v1beta1.kubeflow.org).
+	Resource string `json:"resource"`
+
+	// Images are JSONPath expressions selecting image references.
+	// +optional
+	Images []string `json:"images,omitempty"`
+
+	// PodSpecs are JSONPath expressions selecting embedded PodSpecs,
+	// whose init containers, containers, service account and pull secrets
+	// are all used.
+	// +optional
+	PodSpecs []string `json:"podSpecs,omitempty"`
+
+	// ServiceAccountName is a JSONPath expression selecting the name of
+	// the service account with which the images are pulled.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// ImagePullSecrets is a JSONPath expression selecting the names of
+	// the pull secrets (either as strings or as {name: ...} objects) with
+	// which the images are pulled.
+	// +optional
+	ImagePullSecrets string `json:"imagePullSecrets,omitempty"`
+}
+
+// Extractor extracts images from unstructured resources of a single kind.
+type Extractor struct {
+	GVK schema.GroupVersionKind
+
+	images             []*jsonpath.Path
+	podSpecs           []*jsonpath.Path
+	serviceAccountName *jsonpath.Path
+	imagePullSecrets   *jsonpath.Path
+}
+
+// Load reads and parses the extractor configuration at the given path.
+func Load(path string) ([]*Extractor, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	exts := make([]*Extractor, 0, len(cfg.Extractors))
+	for _, spec := range cfg.Extractors {
+		ext, err := New(spec)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %v", path, err)
+		}
+		exts = append(exts, ext)
+	}
+	return exts, nil
+}
+
+// New parses the given Spec into an Extractor.
+func New(spec Spec) (*Extractor, error) {
+	gvk, _ := schema.ParseKindArg(spec.Resource)
+	if gvk == nil {
+		return nil, fmt.Errorf("not a valid GroupVersionKind: %q", spec.Resource)
+	}
+	if len(spec.Images) == 0 && len(spec.PodSpecs) == 0 {
+		return nil, fmt.Errorf("%s: one of images or podSpecs is required", spec.Resource)
+	}
+
+	ext := &Extractor{GVK: *gvk}
+	for _, expr := range spec.Images {
+		p, err := jsonpath.Parse(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", spec.Resource, err)
+		}
+		ext.images = append(ext.images, p)
+	}
+	for _, expr := range spec.PodSpecs {
+		p, err := jsonpath.Parse(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", spec.Resource, err)
+		}
+		ext.podSpecs = append(ext.podSpecs, p)
+	}
+	var err error
+	if spec.ServiceAccountName != "" {
+		if ext.serviceAccountName, err = jsonpath.Parse(spec.ServiceAccountName); err != nil {
+			return nil, fmt.Errorf("%s: %v", spec.Resource, err)
+		}
+	}
+	if spec.ImagePullSecrets != "" {
+		if ext.imagePullSecrets, err = jsonpath.Parse(spec.ImagePullSecrets); err != nil {
+			return nil, fmt.Errorf("%s: %v", spec.Resource, err)
+		}
+	}
+	return ext, nil
+}
+
+// Extract projects the given unstructured resource onto the WithPod shape,
+// with a container for each of the images selected by our expressions.
+func (e *Extractor) Extract(obj runtime.Object) (*v1alpha1.WithPod, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("expected *unstructured.Unstructured, got %T", obj)
+	}
+
+	// Pick up TypeMeta and ObjectMeta, ignoring the spec.
+	wp := &v1alpha1.WithPod{}
+	if err := duck.FromUnstructured(&unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": u.GetAPIVersion(),
+		"kind":       u.GetKind(),
+		"metadata":   u.Object["metadata"],
+	}}, wp); err != nil {
+		return nil, err
+	}
+	ps := &wp.Spec.Template.Spec
+
+	for _, p := range e.podSpecs {
+		for _, v := range p.Eval(u.Object) {
+			var spec corev1.PodSpec
+			if err := convert(v, &spec); err != nil {
+				return nil, fmt.Errorf("%s: %v", p, err)
+			}
+			// The images of init containers are cached like the rest.
+			ps.Containers = append(ps.Containers, spec.InitContainers...)
+			ps.Containers = append(ps.Containers, spec.Containers...)
+			ps.ImagePullSecrets = append(ps.ImagePullSecrets, spec.ImagePullSecrets...)
+			if ps.ServiceAccountName == "" {
+				ps.ServiceAccountName = spec.ServiceAccountName
+			}
+		}
+	}
+
+	for _, p := range e.images {
+		for _, v := range p.Eval(u.Object) {
+			img, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: expected a string, got %T", p, v)
+			}
+			ps.Containers = append(ps.Containers, corev1.Container{Image: img})
+		}
+	}
+
+	if e.serviceAccountName != nil {
+		for _, v := range e.serviceAccountName.Eval(u.Object) {
+			if sa, ok := v.(string); ok && sa != "" {
+				ps.ServiceAccountName = sa
+				break
+			}
+		}
+	}
+
+	if e.imagePullSecrets != nil {
+		for _, v := range e.imagePullSecrets.Eval(u.Object) {
+			switch t := v.(type) {
+			case string:
+				ps.ImagePullSecrets = append(ps.ImagePullSecrets, corev1.LocalObjectReference{Name: t})
+			default:
+				var ref corev1.LocalObjectReference
+				if err := convert(v, &ref); err != nil {
+					return nil, fmt.Errorf("%s: %v", e.imagePullSecrets, err)
+				}
+				ps.ImagePullSecrets = append(ps.ImagePullSecrets, ref)
+			}
+		}
+	}
+
+	return wp, nil
+}
+
+// convert round-trips the generic value v through JSON into target.
+func convert(v interface{}, target interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, target)
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package extractors
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name string
+		spec Spec
+		obj  map[string]interface{}
+		want corev1.PodSpec
+	}{{
+		name: "tekton steps",
+		spec: Spec{
+			Resource:           "Task.v1alpha1.tekton.dev",
+			Images:             []string{"{.spec.steps[*].image}"},
+			ServiceAccountName: "{.spec.serviceAccount}",
+		},
+		obj: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"serviceAccount": "builder",
+				"steps": []interface{}{
+					map[string]interface{}{"image": "busybox"},
+					map[string]interface{}{"image": "ubuntu"},
+				},
+			},
+		},
+		want: corev1.PodSpec{
+			ServiceAccountName: "builder",
+			Containers: []corev1.Container{{
+				Image: "busybox",
+			}, {
+				Image: "ubuntu",
+			}},
+		},
+	}, {
+		name: "spark driver and executor",
+		spec: Spec{
+			Resource:         "SparkApplication.v1beta1.sparkoperator.k8s.io",
+			Images:           []string{".spec.driver.image", ".spec.executor.image"},
+			ImagePullSecrets: ".spec.imagePullSecrets[*]",
+		},
+		obj: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"driver":           map[string]interface{}{"image": "spark:driver"},
+				"executor":         map[string]interface{}{"image": "spark:executor"},
+				"imagePullSecrets": []interface{}{"secret1", "secret2"},
+			},
+		},
+		want: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Image: "spark:driver",
+			}, {
+				Image: "spark:executor",
+			}},
+			ImagePullSecrets: []corev1.LocalObjectReference{{
+				Name: "secret1",
+			}, {
+				Name: "secret2",
+			}},
+		},
+	}, {
+		name: "kubeflow replica specs",
+		spec: Spec{
+			Resource: "TFJob.v1beta1.kubeflow.org",
+			PodSpecs: []string{".spec.tfReplicaSpecs.*.template.spec"},
+		},
+		obj: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"tfReplicaSpecs": map[string]interface{}{
+					"Worker": map[string]interface{}{
+						"template": map[string]interface{}{
+							"spec": map[string]interface{}{
+								"initContainers": []interface{}{
+									map[string]interface{}{"name": "fetch", "image": "tf:fetch"},
+								},
+								"containers": []interface{}{
+									map[string]interface{}{"name": "tensorflow", "image": "tf:worker"},
+								},
+							},
+						},
+					},
+					"PS": map[string]interface{}{
+						"template": map[string]interface{}{
+							"spec": map[string]interface{}{
+								"serviceAccountName": "trainer",
+								"imagePullSecrets": []interface{}{
+									map[string]interface{}{"name": "secret1"},
+								},
+								"containers": []interface{}{
+									map[string]interface{}{"name": "tensorflow", "image": "tf:ps"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		want: corev1.PodSpec{
+			ServiceAccountName: "trainer",
+			Containers: []corev1.Container{{
+				Name:  "tensorflow",
+				Image: "tf:ps",
+			}, {
+				Name:  "fetch",
+				Image: "tf:fetch",
+			}, {
+				Name:  "tensorflow",
+				Image: "tf:worker",
+			}},
+			ImagePullSecrets: []corev1.LocalObjectReference{{
+				Name: "secret1",
+			}},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ext, err := New(test.spec)
+			if err != nil {
+				t.Fatalf("New() = %v", err)
+			}
+			u := &unstructured.Unstructured{Object: test.obj}
+			u.SetAPIVersion("foo.dev/v1")
+			u.SetKind("Foo")
+			u.SetName("foo")
+			u.SetNamespace("bar")
+
+			got, err := ext.Extract(u)
+			if err != nil {
+				t.Fatalf("Extract() = %v", err)
+			}
+			if got.Name != "foo" || got.Namespace != "bar" || got.Kind != "Foo" {
+				t.Errorf("Extract() = %v/%v (%v), wanted bar/foo (Foo)", got.Namespace, got.Name, got.Kind)
+			}
+			if diff := cmp.Diff(test.want, got.Spec.Template.Spec, cmpopts.IgnoreUnexported(resource.Quantity{})); diff != "" {
+				t.Errorf("Extract (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, spec := range []Spec{{
+		Resource: "NotAGVK",
+		Images:   []string{".spec.image"},
+	}, {
+		Resource: "Task.v1alpha1.tekton.dev",
+	}, {
+		Resource: "Task.v1alpha1.tekton.dev",
+		Images:   []string{".spec..image"},
+	}} {
+		if _, err := New(spec); err == nil {
+			t.Errorf("New(%v) = nil, wanted error", spec)
+		}
+	}
+}
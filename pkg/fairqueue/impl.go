@@ -0,0 +1,143 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fairqueue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/knative/pkg/controller"
+	"github.com/knative/pkg/logging"
+	"github.com/knative/pkg/logging/logkey"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Impl is like controller.Impl, but feeds its Reconciler from a Queue.
+// (controller.Impl can only be made by controller.NewImpl, which makes a
+// work queue of its own.)
+type Impl struct {
+	// Reconciler is fed the keys from the WorkQueue.
+	Reconciler controller.Reconciler
+
+	// WorkQueue is the Queue, or the view of it for resyncs.
+	WorkQueue workqueue.RateLimitingInterface
+
+	logger *zap.SugaredLogger
+}
+
+// NewImpl is like controller.NewImpl, but its work queue is a Queue of the
+// given name. It also returns a copy of the controller whose Enqueue methods
+// queue items at Low priority, for resyncs.
+func NewImpl(r controller.Reconciler, logger *zap.SugaredLogger, workQueueName string) (impl, resyncs *Impl) {
+	q := New(workQueueName, workqueue.DefaultControllerRateLimiter(), clock.RealClock{})
+	impl = &Impl{
+		Reconciler: r,
+		WorkQueue:  q,
+		logger:     logger,
+	}
+	copied := *impl
+	copied.WorkQueue = q.Resyncs()
+	return impl, &copied
+}
+
+// Enqueue takes a resource, converts it into a namespace/name string,
+// and passes it to EnqueueKey.
+func (c *Impl) Enqueue(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		c.logger.Error(zap.Error(err))
+		return
+	}
+	c.EnqueueKey(key)
+}
+
+// EnqueueControllerOf takes a resource, identifies its controller resource,
+// converts it into a namespace/name string, and passes that to EnqueueKey.
+func (c *Impl) EnqueueControllerOf(obj interface{}) {
+	object, err := meta.Accessor(obj)
+	if err != nil {
+		c.logger.Error(zap.Error(err))
+		return
+	}
+	if owner := metav1.GetControllerOf(object); owner != nil {
+		c.EnqueueKey(object.GetNamespace() + "/" + owner.Name)
+	}
+}
+
+// EnqueueKey takes a namespace/name string and puts it onto the work queue.
+func (c *Impl) EnqueueKey(key string) {
+	c.WorkQueue.AddRateLimited(key)
+}
+
+// Run starts the controller's worker threads, the number of which is
+// threadiness. It then blocks until stopCh is closed, at which point it shuts
+// down the work queue and waits for workers to finish their current items.
+func (c *Impl) Run(threadiness int, stopCh <-chan struct{}) error {
+	defer runtime.HandleCrash()
+	defer c.WorkQueue.ShutDown()
+
+	c.logger.Info("Starting controller and workers")
+	for i := 0; i < threadiness; i++ {
+		go wait.Until(func() {
+			for c.processNextWorkItem() {
+			}
+		}, time.Second, stopCh)
+	}
+
+	c.logger.Info("Started workers")
+	<-stopCh
+	c.logger.Info("Shutting down workers")
+
+	return nil
+}
+
+// processNextWorkItem reads a single key off the work queue and passes it
+// to the Reconciler. Like controller.Impl, it doesn't Forget keys that fail,
+// so that the rate limiter keeps backing off those that are queued again.
+func (c *Impl) processNextWorkItem() bool {
+	obj, shutdown := c.WorkQueue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.WorkQueue.Done(obj)
+
+	key, ok := obj.(string)
+	if !ok {
+		// Retrying won't make it a key.
+		c.WorkQueue.Forget(obj)
+		c.logger.Errorf("expected string in workqueue but got %#v", obj)
+		return true
+	}
+
+	logger := c.logger.With(zap.String(logkey.Key, key))
+	ctx := logging.WithLogger(context.TODO(), logger)
+	if err := c.Reconciler.Reconcile(ctx, key); err != nil {
+		c.logger.Error(zap.Error(fmt.Errorf("error syncing %q: %v", key, err)))
+		return true
+	}
+	c.WorkQueue.Forget(obj)
+	c.logger.Infof("Successfully synced %q", key)
+	return true
+}
@@ -0,0 +1,429 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fairqueue provides a work queue that is fair across namespaces,
+// so that one namespace with many resources can't starve the others, and
+// that works off changes before periodic resyncs.
+package fairqueue
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Priority is the tier in which an item waits.
+type Priority int
+
+const (
+	// High is for changes (and anything else that isn't a resync).
+	High Priority = iota
+	// Low is for resyncs, which are only worked off when there are no
+	// changes waiting.
+	Low
+)
+
+// Queue is a workqueue.RateLimitingInterface that hands out items from
+// namespaces in turn (taking the namespace from keys of the form
+// namespace/name), and items of High priority before those of Low. Like
+// the client-go queues, an item is only ever queued once, and isn't handed
+// out again while it's being processed.
+type Queue struct {
+	name        string
+	rateLimiter workqueue.RateLimiter
+	clock       clock.Clock
+
+	cond *sync.Cond
+	// tiers holds the items waiting, by Priority.
+	tiers [2]tier
+	// queued holds the Priority at which each waiting item is queued.
+	queued map[interface{}]Priority
+	// processing holds the items that have been handed out, but aren't
+	// Done.
+	processing map[interface{}]struct{}
+	// dirty holds the items added while they were being processed, which
+	// are queued again (at the given Priority) once they're Done.
+	dirty map[interface{}]Priority
+	// waiting holds the items added after a delay that hasn't passed yet,
+	// so that adding them again before then doesn't queue them twice.
+	waiting map[interface{}]*delayed
+
+	// adds counts the items queued, for Stats.
+	adds int64
+
+	shuttingDown bool
+	stopCh       chan struct{}
+}
+
+var _ workqueue.RateLimitingInterface = (*Queue)(nil)
+
+// delayed is an item's pending AddAfter.
+type delayed struct {
+	at       time.Time
+	priority Priority
+}
+
+// tier holds the items waiting at one Priority, in a queue per namespace.
+type tier struct {
+	// order holds the namespaces with items waiting, in the order that
+	// they take their turns.
+	order []string
+	items map[string][]interface{}
+}
+
+// New returns a Queue that delays AddRateLimited items per rateLimiter, and
+// tells the time with clk. Queues with a name are reported by Stats, like
+// the named queues of client-go.
+func New(name string, rateLimiter workqueue.RateLimiter, clk clock.Clock) *Queue {
+	q := &Queue{
+		name:        name,
+		rateLimiter: rateLimiter,
+		clock:       clk,
+		cond:        sync.NewCond(&sync.Mutex{}),
+		queued:      make(map[interface{}]Priority),
+		processing:  make(map[interface{}]struct{}),
+		dirty:       make(map[interface{}]Priority),
+		waiting:     make(map[interface{}]*delayed),
+		stopCh:      make(chan struct{}),
+	}
+	for i := range q.tiers {
+		q.tiers[i].items = make(map[string][]interface{})
+	}
+	if name != "" {
+		named.Lock()
+		defer named.Unlock()
+		named.queues[name] = q
+	}
+	return q
+}
+
+// named holds the Queues with a name, for Stats.
+var named = struct {
+	sync.Mutex
+	queues map[string]*Queue
+}{queues: make(map[string]*Queue)}
+
+// QueueStats are the counts of a Queue.
+type QueueStats struct {
+	// Depth is the number of items waiting.
+	Depth int
+	// Adds is the number of items queued, including those added again
+	// once they were Done.
+	Adds int64
+}
+
+// Stats returns the counts of the Queues with a name, by name.
+func Stats() map[string]QueueStats {
+	named.Lock()
+	defer named.Unlock()
+	stats := make(map[string]QueueStats, len(named.queues))
+	for name, q := range named.queues {
+		q.cond.L.Lock()
+		stats[name] = QueueStats{Depth: len(q.queued), Adds: q.adds}
+		q.cond.L.Unlock()
+	}
+	return stats
+}
+
+// Name returns the name that the Queue was made with.
+func (q *Queue) Name() string {
+	return q.name
+}
+
+// PassNew is like controller.PassNew, but passes objects that didn't change
+// (the informer's periodic resyncs) to resynced instead.
+func PassNew(changed, resynced func(interface{})) func(interface{}, interface{}) {
+	return func(first, second interface{}) {
+		if sameVersion(first, second) {
+			resynced(second)
+		} else {
+			changed(second)
+		}
+	}
+}
+
+// sameVersion returns whether the objects have the same ResourceVersion.
+func sameVersion(a, b interface{}) bool {
+	ma, err := meta.Accessor(a)
+	if err != nil {
+		return false
+	}
+	mb, err := meta.Accessor(b)
+	if err != nil {
+		return false
+	}
+	return ma.GetResourceVersion() != "" && ma.GetResourceVersion() == mb.GetResourceVersion()
+}
+
+// Add queues the item at High priority.
+func (q *Queue) Add(item interface{}) {
+	q.AddWithPriority(item, High)
+}
+
+// AddWithPriority queues the item at the given priority, raising the
+// priority of the item if it's already queued lower.
+func (q *Queue) AddWithPriority(item interface{}, p Priority) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.shuttingDown {
+		return
+	}
+	if _, ok := q.processing[item]; ok {
+		if old, ok := q.dirty[item]; !ok || p < old {
+			q.dirty[item] = p
+		}
+		return
+	}
+	if old, ok := q.queued[item]; ok {
+		if p >= old {
+			return
+		}
+		q.tiers[old].remove(item)
+	} else {
+		q.adds++
+	}
+	q.queued[item] = p
+	q.tiers[p].push(item)
+	q.cond.Signal()
+}
+
+// AddAfter queues the item at High priority once the duration has passed.
+// Like the client-go queues, adding an item that is already waiting only
+// moves it up (to the earlier of the two times, and the higher of the two
+// priorities), so that a burst of adds queues it once.
+func (q *Queue) AddAfter(item interface{}, d time.Duration) {
+	q.addAfter(item, d, High)
+}
+
+func (q *Queue) addAfter(item interface{}, d time.Duration, p Priority) {
+	if d <= 0 {
+		q.AddWithPriority(item, p)
+		return
+	}
+
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.shuttingDown {
+		return
+	}
+	at := q.clock.Now().Add(d)
+	if w, ok := q.waiting[item]; ok {
+		if p < w.priority {
+			w.priority = p
+		}
+		if !at.Before(w.at) {
+			return
+		}
+		p = w.priority
+	}
+	w := &delayed{at: at, priority: p}
+	q.waiting[item] = w
+	// Start the timer now, rather than whenever the goroutine runs.
+	after := q.clock.After(d)
+	go func() {
+		select {
+		case <-after:
+		case <-q.stopCh:
+			return
+		}
+		q.cond.L.Lock()
+		if q.waiting[item] != w {
+			// An earlier add superseded this one.
+			q.cond.L.Unlock()
+			return
+		}
+		delete(q.waiting, item)
+		p := w.priority
+		q.cond.L.Unlock()
+		q.AddWithPriority(item, p)
+	}()
+}
+
+// AddRateLimited queues the item at High priority once the rate limiter
+// says it's ok.
+func (q *Queue) AddRateLimited(item interface{}) {
+	q.AddAfter(item, q.rateLimiter.When(item))
+}
+
+// Forget stops the rate limiter from tracking the item.
+func (q *Queue) Forget(item interface{}) {
+	q.rateLimiter.Forget(item)
+}
+
+// NumRequeues returns how many times the rate limiter has delayed the
+// item.
+func (q *Queue) NumRequeues(item interface{}) int {
+	return q.rateLimiter.NumRequeues(item)
+}
+
+// Len returns the number of items waiting.
+func (q *Queue) Len() int {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return len(q.queued)
+}
+
+// Get blocks until an item is waiting, and hands out the next one: that of
+// the namespace whose turn it is in the highest tier with items waiting.
+// It returns shutdown once the Queue is shut down and drained.
+func (q *Queue) Get() (item interface{}, shutdown bool) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	for len(q.queued) == 0 && !q.shuttingDown {
+		q.cond.Wait()
+	}
+	if len(q.queued) == 0 {
+		return nil, true
+	}
+	for i := range q.tiers {
+		if item, ok := q.tiers[i].pop(); ok {
+			delete(q.queued, item)
+			q.processing[item] = struct{}{}
+			return item, false
+		}
+	}
+	// Not reached, since queued and tiers agree.
+	return nil, true
+}
+
+// Done marks the item as done processing, and queues it again if it was
+// added while being processed.
+func (q *Queue) Done(item interface{}) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	delete(q.processing, item)
+	if p, ok := q.dirty[item]; ok {
+		delete(q.dirty, item)
+		if !q.shuttingDown {
+			q.queued[item] = p
+			q.tiers[p].push(item)
+			q.adds++
+			q.cond.Signal()
+		}
+	}
+}
+
+// ShutDown has the Queue ignore new items, and Get return shutdown once
+// the items waiting are drained.
+func (q *Queue) ShutDown() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.shuttingDown {
+		return
+	}
+	q.shuttingDown = true
+	close(q.stopCh)
+	q.cond.Broadcast()
+}
+
+// ShuttingDown returns whether ShutDown was called.
+func (q *Queue) ShuttingDown() bool {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return q.shuttingDown
+}
+
+// Resyncs returns a view of the Queue whose Add methods queue items at Low
+// priority. Resyncs skip the rate limiter, which is shared by all items
+// (to protect the API server from hot loops), so that a resync of
+// thousands of resources doesn't delay changes behind it.
+func (q *Queue) Resyncs() workqueue.RateLimitingInterface {
+	return resyncs{q}
+}
+
+type resyncs struct {
+	*Queue
+}
+
+func (r resyncs) Add(item interface{}) {
+	r.AddWithPriority(item, Low)
+}
+
+func (r resyncs) AddAfter(item interface{}, d time.Duration) {
+	r.addAfter(item, d, Low)
+}
+
+func (r resyncs) AddRateLimited(item interface{}) {
+	r.AddWithPriority(item, Low)
+}
+
+// namespaceOf returns the namespace of items that are keys of the form
+// namespace/name, and "" for everything else.
+func namespaceOf(item interface{}) string {
+	key, ok := item.(string)
+	if !ok {
+		return ""
+	}
+	ns, _, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return ""
+	}
+	return ns
+}
+
+func (t *tier) push(item interface{}) {
+	ns := namespaceOf(item)
+	if len(t.items[ns]) == 0 {
+		t.order = append(t.order, ns)
+	}
+	t.items[ns] = append(t.items[ns], item)
+}
+
+// pop takes the next item of the namespace whose turn it is, which then
+// goes to the back of the line if it has more items.
+func (t *tier) pop() (interface{}, bool) {
+	if len(t.order) == 0 {
+		return nil, false
+	}
+	ns := t.order[0]
+	t.order = t.order[1:]
+	items := t.items[ns]
+	item := items[0]
+	if len(items) == 1 {
+		delete(t.items, ns)
+	} else {
+		t.items[ns] = items[1:]
+		t.order = append(t.order, ns)
+	}
+	return item, true
+}
+
+func (t *tier) remove(item interface{}) {
+	ns := namespaceOf(item)
+	items := t.items[ns]
+	for i, x := range items {
+		if x != item {
+			continue
+		}
+		items = append(items[:i:i], items[i+1:]...)
+		break
+	}
+	if len(items) > 0 {
+		t.items[ns] = items
+		return
+	}
+	delete(t.items, ns)
+	for i, x := range t.order {
+		if x == ns {
+			t.order = append(t.order[:i:i], t.order[i+1:]...)
+			break
+		}
+	}
+}
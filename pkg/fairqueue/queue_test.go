@@ -0,0 +1,259 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fairqueue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/util/workqueue"
+)
+
+type add struct {
+	key      string
+	priority Priority
+}
+
+func TestOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		adds []add
+		want []string
+	}{{
+		name: "one namespace is first in, first out",
+		adds: []add{{"a/1", High}, {"a/2", High}, {"a/3", High}},
+		want: []string{"a/1", "a/2", "a/3"},
+	}, {
+		name: "namespaces take turns",
+		adds: []add{
+			{"busy/1", High}, {"busy/2", High}, {"busy/3", High}, {"busy/4", High},
+			{"quiet/1", High}, {"other/1", High}, {"quiet/2", High},
+		},
+		want: []string{"busy/1", "quiet/1", "other/1", "busy/2", "quiet/2", "busy/3", "busy/4"},
+	}, {
+		name: "changes before resyncs",
+		adds: []add{{"a/1", Low}, {"a/2", Low}, {"b/1", High}, {"a/3", High}},
+		want: []string{"b/1", "a/3", "a/1", "a/2"},
+	}, {
+		name: "duplicates are queued once",
+		adds: []add{{"a/1", High}, {"b/1", High}, {"a/1", High}, {"a/1", Low}},
+		want: []string{"a/1", "b/1"},
+	}, {
+		name: "changes promote queued resyncs",
+		adds: []add{{"a/1", Low}, {"a/2", Low}, {"b/1", High}, {"a/2", High}},
+		want: []string{"b/1", "a/2", "a/1"},
+	}, {
+		name: "cluster-scoped keys share a namespace",
+		adds: []add{{"x", High}, {"y", High}, {"a/1", High}},
+		want: []string{"x", "a/1", "y"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := New("", workqueue.DefaultControllerRateLimiter(), clock.RealClock{})
+			for _, a := range test.adds {
+				q.AddWithPriority(a.key, a.priority)
+			}
+			if got, want := q.Len(), len(test.want); got != want {
+				t.Errorf("Len() = %d, wanted %d", got, want)
+			}
+			var got []string
+			for q.Len() > 0 {
+				item, _ := q.Get()
+				got = append(got, item.(string))
+				q.Done(item)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Get() (-want +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestAddWhileProcessing(t *testing.T) {
+	q := New("", workqueue.DefaultControllerRateLimiter(), clock.RealClock{})
+	q.AddWithPriority("a/1", Low)
+	item, _ := q.Get()
+
+	// Items being processed aren't handed out again until they're Done.
+	q.AddWithPriority("a/1", Low)
+	q.AddWithPriority("a/1", High)
+	q.AddWithPriority("b/1", Low)
+	if got, want := q.Len(), 1; got != want {
+		t.Fatalf("Len() = %d, wanted %d", got, want)
+	}
+	q.Done(item)
+
+	// Once Done, it's queued again at the highest priority it was added.
+	var got []string
+	for q.Len() > 0 {
+		item, _ := q.Get()
+		got = append(got, item.(string))
+		q.Done(item)
+	}
+	if diff := cmp.Diff([]string{"a/1", "b/1"}, got); diff != "" {
+		t.Errorf("Get() (-want +got) = %v", diff)
+	}
+}
+
+func TestAddAfter(t *testing.T) {
+	clk := clock.NewFakeClock(time.Now())
+	q := New("", workqueue.DefaultControllerRateLimiter(), clk)
+	defer q.ShutDown()
+
+	q.Resyncs().AddAfter("a/1", time.Minute)
+	q.AddAfter("a/2", time.Minute)
+	for !clk.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+	if got := q.Len(); got != 0 {
+		t.Fatalf("Len() = %d before the delay, wanted 0", got)
+	}
+
+	// Keep stepping until both have waited out their delay.
+	for q.Len() < 2 {
+		clk.Step(time.Minute)
+		time.Sleep(time.Millisecond)
+	}
+	var got []string
+	for len(got) < 2 {
+		item, _ := q.Get()
+		got = append(got, item.(string))
+		q.Done(item)
+	}
+	if diff := cmp.Diff([]string{"a/2", "a/1"}, got); diff != "" {
+		t.Errorf("Get() (-want +got) = %v", diff)
+	}
+}
+
+func TestAddAfterCoalesces(t *testing.T) {
+	clk := clock.NewFakeClock(time.Now())
+	q := New("", workqueue.DefaultControllerRateLimiter(), clk)
+	defer q.ShutDown()
+
+	// Adding an item that is waiting only moves it up, so the burst waits
+	// out the shortest delay, once.
+	q.AddAfter("a/1", 2*time.Minute)
+	q.AddAfter("a/1", 3*time.Minute)
+	q.AddAfter("a/1", time.Minute)
+	for !clk.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+	clk.Step(time.Minute)
+	for q.Len() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	item, _ := q.Get()
+	q.Done(item)
+
+	clk.Step(2 * time.Minute)
+	time.Sleep(10 * time.Millisecond)
+	if got := q.Len(); got != 0 {
+		t.Errorf("Len() = %d after the longer delays, wanted 0", got)
+	}
+}
+
+func TestResyncsSkipRateLimiter(t *testing.T) {
+	// A rate limiter that would hold every item for an hour.
+	q := New("", workqueue.NewItemExponentialFailureRateLimiter(time.Hour, time.Hour), clock.RealClock{})
+	defer q.ShutDown()
+
+	r := q.Resyncs()
+	for _, key := range []string{"a/1", "a/2", "b/1"} {
+		r.AddRateLimited(key)
+	}
+	if got, want := q.Len(), 3; got != want {
+		t.Errorf("Len() = %d, wanted %d", got, want)
+	}
+	if got := q.NumRequeues("a/1"); got != 0 {
+		t.Errorf("NumRequeues() = %d, wanted 0", got)
+	}
+}
+
+func TestShutDown(t *testing.T) {
+	q := New("", workqueue.DefaultControllerRateLimiter(), clock.RealClock{})
+	q.Add("a/1")
+	q.ShutDown()
+	q.Add("a/2")
+
+	// Items waiting are drained before Get reports shutdown.
+	if item, shutdown := q.Get(); shutdown || item != "a/1" {
+		t.Errorf("Get() = %v, %v, wanted a/1, false", item, shutdown)
+	}
+	if _, shutdown := q.Get(); !shutdown {
+		t.Error("Get() = false, wanted shutdown")
+	}
+}
+
+func TestStats(t *testing.T) {
+	impl, resyncs := NewImpl(nil, zap.NewNop().Sugar(), "Things")
+	defer impl.WorkQueue.ShutDown()
+
+	impl.EnqueueKey("a/1")
+	resyncs.EnqueueKey("a/2")
+	// Already waiting, so not added again.
+	impl.EnqueueKey("a/2")
+	resyncs.EnqueueKey("a/1")
+
+	want := QueueStats{Depth: 2, Adds: 2}
+	if diff := cmp.Diff(want, Stats()["Things"]); diff != "" {
+		t.Errorf("Stats() (-want +got) = %v", diff)
+	}
+	if _, ok := Stats()[""]; ok {
+		t.Error("Stats() has a Queue without a name")
+	}
+}
+
+func TestPassNew(t *testing.T) {
+	obj := func(rv string) *metav1.ObjectMeta {
+		return &metav1.ObjectMeta{Name: "foo", ResourceVersion: rv}
+	}
+	tests := []struct {
+		name     string
+		old, new interface{}
+		want     string
+	}{{
+		name: "changed",
+		old:  obj("1"),
+		new:  obj("2"),
+		want: "changed",
+	}, {
+		name: "resynced",
+		old:  obj("2"),
+		new:  obj("2"),
+		want: "resynced",
+	}, {
+		name: "no version",
+		old:  obj(""),
+		new:  obj(""),
+		want: "changed",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got string
+			PassNew(func(interface{}) { got = "changed" }, func(interface{}) { got = "resynced" })(test.old, test.new)
+			if got != test.want {
+				t.Errorf("PassNew() called %s, wanted %s", got, test.want)
+			}
+		})
+	}
+}
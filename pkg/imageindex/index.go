@@ -0,0 +1,291 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package imageindex maintains a reverse index from the images that
+// workloads reference to the workloads, e.g. to find everything that runs
+// a vulnerable image.
+package imageindex
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/reconciler/cachier/resources"
+	"github.com/mattmoor/cachier/pkg/reference"
+)
+
+// Workload identifies a resource that references images.
+type Workload struct {
+	Group     string `json:"group,omitempty"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// Use is a workload's reference to an image.
+type Use struct {
+	Workload
+
+	// Digest is that of the image that the workload runs, when known
+	// (i.e. the reference has a digest, or it is pinned).
+	Digest string `json:"digest,omitempty"`
+}
+
+// Match is an image, and the workloads that reference it.
+type Match struct {
+	// Image is the normalized reference (see reference.Parse).
+	Image string `json:"image"`
+
+	// Workloads are sorted by namespace, kind and name.
+	Workloads []Use `json:"workloads"`
+}
+
+// Query selects images.  Its zero value selects all of them, and otherwise
+// images must satisfy each of its set fields.
+type Query struct {
+	// Image matches the image with this reference, once normalized (e.g.
+	// "openssl-base:1.2" is "index.docker.io/library/openssl-base:1.2").
+	Image string
+
+	// Prefix matches the normalized references with this prefix.
+	Prefix string
+
+	// Regexp matches the normalized references it matches anywhere.
+	Regexp *regexp.Regexp
+
+	// Digest matches the uses of images with this digest.
+	Digest string
+}
+
+// ParseQuery parses a Query from the parameters image, prefix, regex and
+// digest, as the Values method encodes it.
+func ParseQuery(v url.Values) (Query, error) {
+	q := Query{
+		Image:  v.Get("image"),
+		Prefix: v.Get("prefix"),
+		Digest: v.Get("digest"),
+	}
+	if re := v.Get("regex"); re != "" {
+		var err error
+		if q.Regexp, err = regexp.Compile(re); err != nil {
+			return Query{}, fmt.Errorf("invalid regex: %v", err)
+		}
+	}
+	return q, nil
+}
+
+// Values encodes the query as parameters for ParseQuery.
+func (q Query) Values() url.Values {
+	v := url.Values{}
+	for key, value := range map[string]string{
+		"image":  q.Image,
+		"prefix": q.Prefix,
+		"digest": q.Digest,
+	} {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	if q.Regexp != nil {
+		v.Set("regex", q.Regexp.String())
+	}
+	return v
+}
+
+// Index maps the normalized references of images to the workloads that
+// reference them.  It is safe for concurrent use.
+type Index struct {
+	m sync.RWMutex
+	// byWorkload holds the digest (or "") of each image each workload
+	// references, so that we can drop its old references on updates.
+	byWorkload map[Workload]map[string]string
+	byImage    map[string]map[Workload]string
+}
+
+// New returns an empty Index.
+func New() *Index {
+	return &Index{
+		byWorkload: map[Workload]map[string]string{},
+		byImage:    map[string]map[Workload]string{},
+	}
+}
+
+// Set records the images that the workload references, replacing those it
+// referenced before.  The images are mapped to their digests (or "" when
+// unknown).
+func (x *Index) Set(w Workload, images map[string]string) {
+	x.m.Lock()
+	defer x.m.Unlock()
+	x.delete(w)
+	if len(images) == 0 {
+		return
+	}
+	x.byWorkload[w] = images
+	for image, digest := range images {
+		if x.byImage[image] == nil {
+			x.byImage[image] = map[Workload]string{}
+		}
+		x.byImage[image][w] = digest
+	}
+}
+
+// Delete forgets the workload.
+func (x *Index) Delete(w Workload) {
+	x.m.Lock()
+	defer x.m.Unlock()
+	x.delete(w)
+}
+
+func (x *Index) delete(w Workload) {
+	for image := range x.byWorkload[w] {
+		delete(x.byImage[image], w)
+		if len(x.byImage[image]) == 0 {
+			delete(x.byImage, image)
+		}
+	}
+	delete(x.byWorkload, w)
+}
+
+// Lookup returns the images that the query selects, sorted by image.
+func (x *Index) Lookup(q Query) []Match {
+	if q.Image != "" {
+		q.Image = reference.Normalize(q.Image)
+	}
+
+	x.m.RLock()
+	defer x.m.RUnlock()
+	var out []Match
+	for image, uses := range x.byImage {
+		if !q.matches(image) {
+			continue
+		}
+		m := Match{Image: image}
+		for w, digest := range uses {
+			if q.Digest != "" && digest != q.Digest {
+				continue
+			}
+			m.Workloads = append(m.Workloads, Use{Workload: w, Digest: digest})
+		}
+		if len(m.Workloads) == 0 {
+			continue
+		}
+		sort.Slice(m.Workloads, func(i, j int) bool {
+			a, b := m.Workloads[i], m.Workloads[j]
+			if a.Namespace != b.Namespace {
+				return a.Namespace < b.Namespace
+			}
+			if a.Group+"/"+a.Kind != b.Group+"/"+b.Kind {
+				return a.Group+"/"+a.Kind < b.Group+"/"+b.Kind
+			}
+			return a.Name < b.Name
+		})
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Image < out[j].Image })
+	return out
+}
+
+// matches returns whether the query selects the normalized image.
+func (q Query) matches(image string) bool {
+	return (q.Image == "" || image == q.Image) &&
+		(q.Prefix == "" || strings.HasPrefix(image, q.Prefix)) &&
+		(q.Regexp == nil || q.Regexp.MatchString(image))
+}
+
+// Handler returns the event handlers for an informer of resources of the
+// given kind, which keep the index up to date with their images.
+func (x *Index) Handler(gvk schema.GroupVersionKind, convert func(runtime.Object) (*v1alpha1.WithPod, error)) cache.ResourceEventHandler {
+	set := func(obj interface{}) {
+		o, ok := obj.(runtime.Object)
+		if !ok {
+			return
+		}
+		thing, err := convert(o)
+		if err != nil {
+			return
+		}
+		x.Set(Workload{
+			Group:     gvk.Group,
+			Kind:      gvk.Kind,
+			Namespace: thing.Namespace,
+			Name:      thing.Name,
+		}, ImagesOf(thing))
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    set,
+		UpdateFunc: func(_, obj interface{}) { set(obj) },
+		DeleteFunc: func(obj interface{}) {
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err != nil {
+				return
+			}
+			namespace, name, err := cache.SplitMetaNamespaceKey(key)
+			if err != nil {
+				return
+			}
+			x.Delete(Workload{Group: gvk.Group, Kind: gvk.Kind, Namespace: namespace, Name: name})
+		},
+	}
+}
+
+// ImagesOf returns the normalized references of the images that the
+// resource references, whether cached or not: those of its pod template's
+// containers and init containers, its extra images, and the images that
+// its Pods were seen to run, mapped to their digests when known.
+func ImagesOf(thing *v1alpha1.WithPod) map[string]string {
+	var refs []string
+	spec := thing.Spec.Template.Spec
+	for _, c := range spec.InitContainers {
+		refs = append(refs, c.Image)
+	}
+	for _, c := range spec.Containers {
+		refs = append(refs, c.Image)
+	}
+	refs = append(refs, resources.ExtraImages(thing)...)
+
+	var pinned map[string]string
+	if status := v1alpha1.GetStatus(thing); status != nil {
+		refs = append(refs, status.DiscoveredImages...)
+		pinned = status.PinnedImages
+	}
+
+	images := make(map[string]string, len(refs))
+	for _, ref := range refs {
+		if ref = strings.TrimSpace(ref); ref == "" {
+			continue
+		}
+		image, digest := reference.Normalize(ref), ""
+		if r, err := reference.Parse(ref); err == nil {
+			digest = r.Digest
+		}
+		if p, ok := pinned[ref]; ok {
+			if i := strings.Index(p, "@"); i >= 0 {
+				digest = p[i+1:]
+			}
+		}
+		images[image] = digest
+	}
+	return images
+}
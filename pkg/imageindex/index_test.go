@@ -0,0 +1,186 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package imageindex
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+)
+
+func withPod(namespace, name string, images ...string) *v1alpha1.WithPod {
+	thing := &v1alpha1.WithPod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+	}
+	for _, image := range images {
+		thing.Spec.Template.Spec.Containers = append(thing.Spec.Template.Spec.Containers, corev1.Container{Image: image})
+	}
+	return thing
+}
+
+func TestImagesOf(t *testing.T) {
+	thing := withPod("ns", "foo", "openssl-base:1.2", "gcr.io/foo/bar@sha256:abcd")
+	thing.Spec.Template.Spec.InitContainers = []corev1.Container{{Image: "busybox"}}
+	status, err := (&v1alpha1.Status{
+		DiscoveredImages: []string{"istio/proxyv2:1.0.2"},
+		PinnedImages: map[string]string{
+			"openssl-base:1.2": "index.docker.io/library/openssl-base@sha256:1234",
+		},
+	}).Serialize()
+	if err != nil {
+		t.Fatalf("Serialize() = %v", err)
+	}
+	thing.Annotations = map[string]string{v1alpha1.StatusAnnotationKey: status}
+
+	want := map[string]string{
+		"index.docker.io/library/openssl-base:1.2": "sha256:1234",
+		"gcr.io/foo/bar@sha256:abcd":               "sha256:abcd",
+		"index.docker.io/library/busybox:latest":   "",
+		"index.docker.io/istio/proxyv2:1.0.2":      "",
+	}
+	if diff := cmp.Diff(want, ImagesOf(thing)); diff != "" {
+		t.Errorf("ImagesOf() (-want, +got) = %v", diff)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	x := New()
+	deploy := func(namespace, name string) Workload {
+		return Workload{Group: "apps", Kind: "Deployment", Namespace: namespace, Name: name}
+	}
+	x.Set(deploy("b", "api"), map[string]string{
+		"index.docker.io/library/openssl-base:1.2": "sha256:1234",
+		"gcr.io/foo/api:v1":                        "",
+	})
+	x.Set(deploy("a", "web"), map[string]string{
+		"index.docker.io/library/openssl-base:1.2": "sha256:5678",
+	})
+	x.Set(deploy("a", "old"), map[string]string{
+		"gcr.io/foo/old:v1": "",
+	})
+	// Updates replace the workload's images, and deletes drop them.
+	x.Set(deploy("a", "web"), map[string]string{
+		"index.docker.io/library/openssl-base:1.2": "sha256:1234",
+		"gcr.io/foo/web:v2":                        "",
+	})
+	x.Delete(deploy("a", "old"))
+
+	openssl := Match{
+		Image: "index.docker.io/library/openssl-base:1.2",
+		Workloads: []Use{
+			{Workload: deploy("a", "web"), Digest: "sha256:1234"},
+			{Workload: deploy("b", "api"), Digest: "sha256:1234"},
+		},
+	}
+	api := Match{
+		Image:     "gcr.io/foo/api:v1",
+		Workloads: []Use{{Workload: deploy("b", "api")}},
+	}
+	web := Match{
+		Image:     "gcr.io/foo/web:v2",
+		Workloads: []Use{{Workload: deploy("a", "web")}},
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []Match
+	}{{
+		name: "all",
+		want: []Match{api, web, openssl},
+	}, {
+		name:  "image is normalized",
+		query: Query{Image: "openssl-base:1.2"},
+		want:  []Match{openssl},
+	}, {
+		name:  "prefix",
+		query: Query{Prefix: "gcr.io/foo/"},
+		want:  []Match{api, web},
+	}, {
+		name:  "regexp",
+		query: Query{Regexp: regexp.MustCompile(`/(web|old):`)},
+		want:  []Match{web},
+	}, {
+		name:  "digest",
+		query: Query{Digest: "sha256:1234"},
+		want:  []Match{openssl},
+	}, {
+		name:  "unknown digest",
+		query: Query{Image: "openssl-base:1.2", Digest: "sha256:5678"},
+	}, {
+		name:  "deleted",
+		query: Query{Image: "gcr.io/foo/old:v1"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.want, x.Lookup(test.query)); diff != "" {
+				t.Errorf("Lookup() (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	x := New()
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	h := x.Handler(gvk, func(obj runtime.Object) (*v1alpha1.WithPod, error) {
+		return obj.(*v1alpha1.WithPod), nil
+	})
+	w := Workload{Group: "apps", Kind: "Deployment", Namespace: "ns", Name: "foo"}
+
+	h.OnAdd(withPod("ns", "foo", "gcr.io/foo/a:v1"))
+	h.OnUpdate(nil, withPod("ns", "foo", "gcr.io/foo/a:v2"))
+	want := []Match{{Image: "gcr.io/foo/a:v2", Workloads: []Use{{Workload: w}}}}
+	if diff := cmp.Diff(want, x.Lookup(Query{})); diff != "" {
+		t.Errorf("Lookup() (-want, +got) = %v", diff)
+	}
+
+	h.OnDelete(cache.DeletedFinalStateUnknown{Key: "ns/foo"})
+	if got := x.Lookup(Query{}); len(got) != 0 {
+		t.Errorf("Lookup() = %v, wanted nothing after delete", got)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	want := Query{
+		Image:  "openssl-base:1.2",
+		Prefix: "index.docker.io/",
+		Regexp: regexp.MustCompile("openssl"),
+		Digest: "sha256:1234",
+	}
+	got, err := ParseQuery(want.Values())
+	if err != nil {
+		t.Fatalf("ParseQuery() = %v", err)
+	}
+	if got.Image != want.Image || got.Prefix != want.Prefix || got.Digest != want.Digest || got.Regexp.String() != want.Regexp.String() {
+		t.Errorf("ParseQuery() = %+v, wanted %+v", got, want)
+	}
+
+	if _, err := ParseQuery(url.Values{"regex": []string{"("}}); err == nil {
+		t.Error("ParseQuery() = nil, wanted error for an invalid regex")
+	}
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package informers
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NamespacedListWatch returns a cache.ListerWatcher over the given
+// namespaces, each of which is listed and watched on its own via the
+// ListerWatcher that newLW returns for it.  With no namespaces, it lists and
+// watches all of them (metav1.NamespaceAll), so that callers needn't treat
+// the cluster-wide mode specially.
+func NamespacedListWatch(namespaces []string, newLW func(namespace string) cache.ListerWatcher) cache.ListerWatcher {
+	switch len(namespaces) {
+	case 0:
+		return newLW(metav1.NamespaceAll)
+	case 1:
+		return newLW(namespaces[0])
+	}
+	m := &multiNamespaceListWatch{
+		namespaces: namespaces,
+		lws:        make(map[string]cache.ListerWatcher, len(namespaces)),
+		versions:   make(map[string]string, len(namespaces)),
+	}
+	for _, ns := range namespaces {
+		m.lws[ns] = newLW(ns)
+	}
+	return m
+}
+
+// withNamespaceIndex returns the given indexers, along with the namespace
+// index that listers use.
+func withNamespaceIndex(indexers cache.Indexers) cache.Indexers {
+	out := cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	}
+	for name, f := range indexers {
+		out[name] = f
+	}
+	return out
+}
+
+// NewNamespacedInformer returns an informer for the given resource of a
+// generated clientset's REST client (e.g. the "images" of
+// CachingV1alpha1().RESTClient()) that only watches the given namespaces
+// (all of them when empty).  Its elements have the type of objType.  This
+// takes the place of the informers of generated factories, which watch one
+// namespace or all of them.
+func NewNamespacedInformer(client cache.Getter, resource string, objType runtime.Object, namespaces []string, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	lw := NamespacedListWatch(namespaces, func(ns string) cache.ListerWatcher {
+		return cache.NewListWatchFromClient(client, resource, ns, fields.Everything())
+	})
+	return cache.NewSharedIndexInformer(lw, objType, resyncPeriod, cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	})
+}
+
+// multiNamespaceListWatch lists and watches several namespaces as one.
+type multiNamespaceListWatch struct {
+	namespaces []string
+	lws        map[string]cache.ListerWatcher
+
+	// versions holds the resourceVersion up to which we have seen each
+	// namespace, from which its watch resumes.  The resourceVersion that
+	// the reflector passes us is that of some single namespace, so resuming
+	// every namespace from it could miss events.
+	m        sync.Mutex
+	versions map[string]string
+}
+
+var _ cache.ListerWatcher = (*multiNamespaceListWatch)(nil)
+
+// List implements cache.ListerWatcher
+func (m *multiNamespaceListWatch) List(opts metav1.ListOptions) (runtime.Object, error) {
+	var list runtime.Object
+	var items []runtime.Object
+	versions := make(map[string]string, len(m.namespaces))
+	for _, ns := range m.namespaces {
+		l, err := m.lws[ns].List(opts)
+		if err != nil {
+			return nil, err
+		}
+		lm, err := meta.ListAccessor(l)
+		if err != nil {
+			return nil, err
+		}
+		versions[ns] = lm.GetResourceVersion()
+		nsItems, err := meta.ExtractList(l)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, nsItems...)
+		if list == nil {
+			// Return the items of every namespace in the list of the first.
+			list = l
+		}
+	}
+	if err := meta.SetList(list, items); err != nil {
+		return nil, err
+	}
+
+	m.m.Lock()
+	defer m.m.Unlock()
+	m.versions = versions
+	return list, nil
+}
+
+// Watch implements cache.ListerWatcher
+func (m *multiNamespaceListWatch) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	mw := &multiWatcher{
+		result: make(chan watch.Event),
+		stopCh: make(chan struct{}),
+	}
+	for _, ns := range m.namespaces {
+		nsOpts := opts
+		nsOpts.ResourceVersion = m.version(ns)
+		w, err := m.lws[ns].Watch(nsOpts)
+		if err != nil {
+			mw.Stop()
+			return nil, err
+		}
+		mw.upstreams = append(mw.upstreams, w)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(m.namespaces))
+	for i, ns := range m.namespaces {
+		go func(ns string, w watch.Interface) {
+			defer wg.Done()
+			mw.forward(w, func(version string) { m.setVersion(ns, version) })
+		}(ns, mw.upstreams[i])
+	}
+	go func() {
+		wg.Wait()
+		close(mw.result)
+	}()
+	return mw, nil
+}
+
+func (m *multiNamespaceListWatch) version(ns string) string {
+	m.m.Lock()
+	defer m.m.Unlock()
+	return m.versions[ns]
+}
+
+func (m *multiNamespaceListWatch) setVersion(ns, version string) {
+	m.m.Lock()
+	defer m.m.Unlock()
+	m.versions[ns] = version
+}
+
+// multiWatcher merges the events of several upstream watches, and ends
+// when any of them does, so that the reflector resumes them all together.
+type multiWatcher struct {
+	upstreams []watch.Interface
+	result    chan watch.Event
+	stopCh    chan struct{}
+	once      sync.Once
+}
+
+var _ watch.Interface = (*multiWatcher)(nil)
+
+// forward sends the events of w on, recording the resourceVersion of each
+// object once it has been delivered.
+func (mw *multiWatcher) forward(w watch.Interface, record func(version string)) {
+	defer mw.Stop()
+	for {
+		select {
+		case e, ok := <-w.ResultChan():
+			if !ok {
+				return
+			}
+			select {
+			case mw.result <- e:
+			case <-mw.stopCh:
+				return
+			}
+			if e.Type == watch.Error {
+				continue
+			}
+			if obj, err := meta.Accessor(e.Object); err == nil {
+				record(obj.GetResourceVersion())
+			}
+		case <-mw.stopCh:
+			return
+		}
+	}
+}
+
+// Stop implements watch.Interface
+func (mw *multiWatcher) Stop() {
+	mw.once.Do(func() {
+		close(mw.stopCh)
+		for _, w := range mw.upstreams {
+			w.Stop()
+		}
+	})
+}
+
+// ResultChan implements watch.Interface
+func (mw *multiWatcher) ResultChan() <-chan watch.Event {
+	return mw.result
+}
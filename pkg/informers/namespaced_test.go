@@ -0,0 +1,156 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package informers
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// fakeNamespace lists a single object, and records the resourceVersions
+// that its watches start from.
+type fakeNamespace struct {
+	name    string
+	version string
+	watches []string
+	watcher *watch.FakeWatcher
+}
+
+func (f *fakeNamespace) object(name, version string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetNamespace(f.name)
+	u.SetName(name)
+	u.SetResourceVersion(version)
+	return u
+}
+
+func (f *fakeNamespace) listWatch() cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(metav1.ListOptions) (runtime.Object, error) {
+			l := &unstructured.UnstructuredList{Items: []unstructured.Unstructured{*f.object("thing", f.version)}}
+			l.SetResourceVersion(f.version)
+			return l, nil
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			f.watches = append(f.watches, opts.ResourceVersion)
+			f.watcher = watch.NewFake()
+			return f.watcher, nil
+		},
+	}
+}
+
+func fakeNamespaces(names ...string) (map[string]*fakeNamespace, func(string) cache.ListerWatcher) {
+	fakes := make(map[string]*fakeNamespace, len(names))
+	for i, name := range names {
+		fakes[name] = &fakeNamespace{name: name, version: strconv.Itoa(i + 1)}
+	}
+	return fakes, func(ns string) cache.ListerWatcher {
+		if ns == metav1.NamespaceAll {
+			ns = "all"
+		}
+		return fakes[ns].listWatch()
+	}
+}
+
+func TestNamespacedListWatchAll(t *testing.T) {
+	_, newLW := fakeNamespaces("all")
+	lw := NamespacedListWatch(nil, newLW)
+
+	l, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	if got := len(l.(*unstructured.UnstructuredList).Items); got != 1 {
+		t.Errorf("List() got %d items, wanted 1", got)
+	}
+}
+
+func TestNamespacedListWatch(t *testing.T) {
+	fakes, newLW := fakeNamespaces("a", "b", "c")
+	lw := NamespacedListWatch([]string{"a", "b", "c"}, newLW)
+
+	l, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	var got []string
+	for _, item := range l.(*unstructured.UnstructuredList).Items {
+		got = append(got, item.GetNamespace()+"/"+item.GetName())
+	}
+	sort.Strings(got)
+	if want := []string{"a/thing", "b/thing", "c/thing"}; !cmp.Equal(want, got) {
+		t.Errorf("List() (-want, +got) = %v", cmp.Diff(want, got))
+	}
+
+	// Each namespace resumes from its own resourceVersion, whatever the
+	// reflector passes.
+	w, err := lw.Watch(metav1.ListOptions{ResourceVersion: "1"})
+	if err != nil {
+		t.Fatalf("Watch() = %v", err)
+	}
+	for ns, want := range map[string]string{"a": "1", "b": "2", "c": "3"} {
+		if got := fakes[ns].watches; !cmp.Equal([]string{want}, got) {
+			t.Errorf("Watch(%s) started from %v, wanted %s", ns, got, want)
+		}
+	}
+
+	// Events from every namespace are merged.
+	fakes["b"].watcher.Modify(fakes["b"].object("thing", "7"))
+	select {
+	case e := <-w.ResultChan():
+		if got := e.Object.(*unstructured.Unstructured).GetNamespace(); got != "b" {
+			t.Errorf("Watch() got an event from %q, wanted b", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the event")
+	}
+
+	// When one namespace's watch ends, so does the merged watch.
+	fakes["c"].watcher.Stop()
+	select {
+	case _, ok := <-w.ResultChan():
+		if ok {
+			t.Error("Watch() got an event, wanted the watch to end")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the watch to end")
+	}
+	for ns, fake := range fakes {
+		if !fake.watcher.IsStopped() {
+			t.Errorf("Watch(%s) wasn't stopped", ns)
+		}
+	}
+
+	// The next watch resumes each namespace from what we've seen of it.
+	if _, err := lw.Watch(metav1.ListOptions{ResourceVersion: "7"}); err != nil {
+		t.Fatalf("Watch() = %v", err)
+	}
+	for ns, want := range map[string][]string{"a": {"1", "1"}, "b": {"2", "7"}, "c": {"3", "3"}} {
+		if got := fakes[ns].watches; !cmp.Equal(want, got) {
+			t.Errorf("Watch(%s) started from (-want, +got) = %v", ns, cmp.Diff(want, got))
+		}
+	}
+}
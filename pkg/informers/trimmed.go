@@ -0,0 +1,173 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package informers
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/knative/pkg/apis/duck"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+)
+
+// TrimmedInformerFactory implements duck.InformerFactory such that the
+// elements tracked by the informer/lister are *v1alpha1.WithPod, projected
+// from the shape of Type and trimmed (see v1alpha1.WithPod.Trim), so that
+// only the fields cachier needs are kept in memory.  When Namespaces is
+// set, only those namespaces are watched.
+type TrimmedInformerFactory struct {
+	Client       dynamic.Interface
+	Type         v1alpha1.Podable
+	Namespaces   []string
+	ResyncPeriod time.Duration
+	StopChannel  <-chan struct{}
+
+	// Indexers, when set, index the informers' elements alongside the
+	// namespace index.  They must be set here, since Get starts the
+	// informers, after which they can't be added.
+	Indexers cache.Indexers
+}
+
+// Check that TrimmedInformerFactory implements duck.InformerFactory.
+var _ duck.InformerFactory = (*TrimmedInformerFactory)(nil)
+
+// Get implements duck.InformerFactory.
+func (tif *TrimmedInformerFactory) Get(gvr schema.GroupVersionResource) (cache.SharedIndexInformer, cache.GenericLister, error) {
+	lw := NamespacedListWatch(tif.Namespaces, func(ns string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+				ul, err := tif.Client.Resource(gvr).Namespace(ns).List(opts)
+				if err != nil {
+					return nil, err
+				}
+				return tif.trimList(ul)
+			},
+			WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+				uw, err := tif.Client.Resource(gvr).Namespace(ns).Watch(opts)
+				if err != nil {
+					return nil, err
+				}
+				return tif.trimWatch(uw), nil
+			},
+		}
+	})
+	inf := cache.NewSharedIndexInformer(lw, &v1alpha1.WithPod{}, tif.ResyncPeriod, withNamespaceIndex(tif.Indexers))
+
+	lister := cache.NewGenericLister(inf.GetIndexer(), gvr.GroupResource())
+
+	go inf.Run(tif.StopChannel)
+
+	if ok := cache.WaitForCacheSync(tif.StopChannel, inf.HasSynced); !ok {
+		return nil, nil, fmt.Errorf("Failed starting shared index informer for %v with type %T", gvr, tif.Type)
+	}
+
+	return inf, lister, nil
+}
+
+// Trim decodes the unstructured object into the shape of Type, and returns
+// its trimmed projection onto the WithPod shape.
+func (tif *TrimmedInformerFactory) Trim(u *unstructured.Unstructured) (*v1alpha1.WithPod, error) {
+	obj := tif.Type.DeepCopyObject()
+	if err := duck.FromUnstructured(u, obj); err != nil {
+		return nil, err
+	}
+	return obj.(v1alpha1.Podable).AsWithPod().Trim(), nil
+}
+
+func (tif *TrimmedInformerFactory) trimList(ul *unstructured.UnstructuredList) (*v1alpha1.WithPodList, error) {
+	out := &v1alpha1.WithPodList{ListMeta: metav1.ListMeta{
+		ResourceVersion: ul.GetResourceVersion(),
+		Continue:        ul.GetContinue(),
+	}}
+	out.Items = make([]v1alpha1.WithPod, 0, len(ul.Items))
+	for i := range ul.Items {
+		wp, err := tif.Trim(&ul.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		out.Items = append(out.Items, *wp)
+	}
+	return out, nil
+}
+
+func (tif *TrimmedInformerFactory) trimWatch(uw watch.Interface) watch.Interface {
+	tw := &trimmedWatcher{
+		upstream: uw,
+		result:   make(chan watch.Event),
+		stopCh:   make(chan struct{}),
+	}
+	go func() {
+		defer close(tw.result)
+		for ue := range uw.ResultChan() {
+			if u, ok := ue.Object.(*unstructured.Unstructured); ok {
+				if wp, err := tif.Trim(u); err != nil {
+					ue = watch.Event{
+						Type: watch.Error,
+						Object: &metav1.Status{
+							Status:  metav1.StatusFailure,
+							Code:    http.StatusUnprocessableEntity,
+							Reason:  metav1.StatusReasonInvalid,
+							Message: err.Error(),
+						},
+					}
+				} else {
+					ue.Object = wp
+				}
+			}
+			// Anything else (e.g. errors) is forwarded as-is.
+			select {
+			case tw.result <- ue:
+			case <-tw.stopCh:
+				return
+			}
+		}
+	}()
+	return tw
+}
+
+// trimmedWatcher forwards the trimmed events of an upstream watch.
+type trimmedWatcher struct {
+	upstream watch.Interface
+	result   chan watch.Event
+	stopCh   chan struct{}
+	once     sync.Once
+}
+
+var _ watch.Interface = (*trimmedWatcher)(nil)
+
+// Stop implements watch.Interface
+func (tw *trimmedWatcher) Stop() {
+	tw.once.Do(func() {
+		close(tw.stopCh)
+		tw.upstream.Stop()
+	})
+}
+
+// ResultChan implements watch.Interface
+func (tw *trimmedWatcher) ResultChan() <-chan watch.Event {
+	return tw.result
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package informers
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/knative/pkg/apis/duck"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+)
+
+var deploymentGVK = schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+
+// makeDeployment returns a Deployment with the sort of bulk (env, volumes,
+// probes, resources, and the last applied configuration) that real ones
+// have, none of which cachier needs.
+func makeDeployment(i int) *unstructured.Unstructured {
+	env := make([]interface{}, 0, 20)
+	for j := 0; j < 20; j++ {
+		env = append(env, map[string]interface{}{
+			"name":  fmt.Sprintf("SETTING_%d", j),
+			"value": strings.Repeat("x", 40),
+		})
+	}
+	probe := map[string]interface{}{
+		"httpGet":             map[string]interface{}{"path": "/healthz", "port": int64(8080)},
+		"initialDelaySeconds": int64(5),
+	}
+	container := func(name, image string) map[string]interface{} {
+		return map[string]interface{}{
+			"name":           name,
+			"image":          image,
+			"args":           []interface{}{"--verbose", "--port=8080", "--config=/etc/config/config.yaml"},
+			"env":            env,
+			"readinessProbe": probe,
+			"livenessProbe":  probe,
+			"resources": map[string]interface{}{
+				"requests": map[string]interface{}{"cpu": "100m", "memory": "128Mi"},
+				"limits":   map[string]interface{}{"cpu": "1", "memory": "1Gi"},
+			},
+			"volumeMounts": []interface{}{
+				map[string]interface{}{"name": "config", "mountPath": "/etc/config"},
+				map[string]interface{}{"name": "data", "mountPath": "/var/data"},
+			},
+		}
+	}
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"namespace":  fmt.Sprintf("ns-%d", i%100),
+			"name":       fmt.Sprintf("workload-%d", i),
+			"uid":        fmt.Sprintf("uid-%d", i),
+			"generation": int64(1),
+			"labels":     map[string]interface{}{"app": fmt.Sprintf("workload-%d", i)},
+			"annotations": map[string]interface{}{
+				v1alpha1.LastAppliedConfigAnnotation: strings.Repeat("{}", 1000),
+			},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"labels": map[string]interface{}{"app": fmt.Sprintf("workload-%d", i)},
+				},
+				"spec": map[string]interface{}{
+					"serviceAccountName": "workload",
+					"imagePullSecrets":   []interface{}{map[string]interface{}{"name": "creds"}},
+					"containers": []interface{}{
+						container("app", fmt.Sprintf("gcr.io/project/app-%d:v1", i)),
+						container("sidecar", "gcr.io/project/sidecar:v1"),
+					},
+					"volumes": []interface{}{
+						map[string]interface{}{"name": "config", "configMap": map[string]interface{}{"name": "config"}},
+						map[string]interface{}{"name": "data", "emptyDir": map[string]interface{}{}},
+					},
+				},
+			},
+		},
+	}}
+	u.SetGroupVersionKind(deploymentGVK)
+	return u
+}
+
+func TestTrim(t *testing.T) {
+	tif := &TrimmedInformerFactory{Type: v1alpha1.DuckTypeFor(deploymentGVK)}
+	got, err := tif.Trim(makeDeployment(7))
+	if err != nil {
+		t.Fatalf("Trim() = %v", err)
+	}
+
+	replicas := int32(3)
+	want := &v1alpha1.WithPod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "ns-7",
+			Name:        "workload-7",
+			UID:         "uid-7",
+			Generation:  1,
+			Labels:      map[string]string{"app": "workload-7"},
+			Annotations: map[string]string{},
+		},
+		Spec: v1alpha1.WithPodSpec{
+			Replicas: &replicas,
+			Template: v1alpha1.PodSpecable{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"app": "workload-7"},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "app",
+						Image: "gcr.io/project/app-7:v1",
+					}, {
+						Name:  "sidecar",
+						Image: "gcr.io/project/sidecar:v1",
+					}},
+					ServiceAccountName: "workload",
+					ImagePullSecrets:   []corev1.LocalObjectReference{{Name: "creds"}},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Trim (-want, +got) = %v", diff)
+	}
+}
+
+func TestTrimServing(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: v1alpha1.ServingGroupName, Version: "v1alpha1", Kind: "Service"}
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"namespace": "ns", "name": "svc"},
+		"spec": map[string]interface{}{
+			"runLatest": map[string]interface{}{
+				"configuration": map[string]interface{}{
+					"revisionTemplate": map[string]interface{}{
+						"spec": map[string]interface{}{
+							"container": map[string]interface{}{
+								"image": "gcr.io/foo/bar",
+								"env":   []interface{}{map[string]interface{}{"name": "A", "value": "B"}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}}
+	u.SetGroupVersionKind(gvk)
+
+	tif := &TrimmedInformerFactory{Type: v1alpha1.DuckTypeFor(gvk)}
+	got, err := tif.Trim(u)
+	if err != nil {
+		t.Fatalf("Trim() = %v", err)
+	}
+	want := []corev1.Container{{Image: "gcr.io/foo/bar"}}
+	if diff := cmp.Diff(want, got.Spec.Template.Spec.Containers); diff != "" {
+		t.Errorf("Trim (-want, +got) = %v", diff)
+	}
+}
+
+func TestTrimWatch(t *testing.T) {
+	tif := &TrimmedInformerFactory{Type: v1alpha1.DuckTypeFor(deploymentGVK)}
+	upstream := watch.NewFakeWithChanSize(2, false)
+	tw := tif.trimWatch(upstream)
+
+	status := &metav1.Status{Status: metav1.StatusFailure, Message: "expired"}
+	upstream.Add(makeDeployment(1))
+	upstream.Error(status)
+
+	got := <-tw.ResultChan()
+	if wp, ok := got.Object.(*v1alpha1.WithPod); got.Type != watch.Added || !ok {
+		t.Errorf("ResultChan() = %v %T, wanted ADDED *v1alpha1.WithPod", got.Type, got.Object)
+	} else if n := len(wp.Spec.Template.Spec.Containers[0].Env); n != 0 {
+		t.Errorf("len(Env) = %d, wanted 0", n)
+	}
+	if got := <-tw.ResultChan(); got.Type != watch.Error || got.Object != status {
+		t.Errorf("ResultChan() = %v, wanted the error forwarded", got)
+	}
+
+	tw.Stop()
+	if !upstream.IsStopped() {
+		t.Error("Stop() didn't stop the upstream watch")
+	}
+	if _, ok := <-tw.ResultChan(); ok {
+		t.Error("ResultChan() is still open after Stop()")
+	}
+}
+
+// BenchmarkInformerHeap measures the heap used to cache 50k Deployments the
+// way duck.TypedInformerFactory does (as full WithPods), and the way
+// TrimmedInformerFactory does.  It reports the heap retained by the cache
+// as MB/cache.  Since each iteration is expensive, run it with
+// -benchtime=1x.
+func BenchmarkInformerHeap(b *testing.B) {
+	const workloads = 50000
+
+	tif := &TrimmedInformerFactory{Type: v1alpha1.DuckTypeFor(deploymentGVK)}
+	decode := map[string]func(*unstructured.Unstructured) (interface{}, error){
+		"full": func(u *unstructured.Unstructured) (interface{}, error) {
+			obj := tif.Type.DeepCopyObject()
+			return obj, duck.FromUnstructured(u, obj)
+		},
+		"trimmed": func(u *unstructured.Unstructured) (interface{}, error) {
+			return tif.Trim(u)
+		},
+	}
+
+	for _, name := range []string{"full", "trimmed"} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				before := heapInUse()
+				store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+					cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+				})
+				for j := 0; j < workloads; j++ {
+					obj, err := decode[name](makeDeployment(j))
+					if err != nil {
+						b.Fatalf("decode() = %v", err)
+					}
+					store.Add(obj)
+				}
+				after := heapInUse()
+				b.ReportMetric(float64(after-before)/(1<<20), "MB/cache")
+				runtime.KeepAlive(store)
+			}
+		})
+	}
+}
+
+func heapInUse() uint64 {
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.HeapAlloc
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package informers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/knative/pkg/apis"
+	"github.com/knative/pkg/apis/duck"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+// TypedInformerFactory is like duck.TypedInformerFactory (the elements
+// tracked by the informer/lister have the type of Type), except that when
+// Namespaces is set, only those namespaces are watched.
+type TypedInformerFactory struct {
+	Client       dynamic.Interface
+	Type         apis.Listable
+	Namespaces   []string
+	ResyncPeriod time.Duration
+	StopChannel  <-chan struct{}
+
+	// Indexers, when set, index the informers' elements alongside the
+	// namespace index.  They must be set here, since Get starts the
+	// informers, after which they can't be added.
+	Indexers cache.Indexers
+}
+
+// Check that TypedInformerFactory implements duck.InformerFactory.
+var _ duck.InformerFactory = (*TypedInformerFactory)(nil)
+
+// Get implements duck.InformerFactory.
+func (tif *TypedInformerFactory) Get(gvr schema.GroupVersionResource) (cache.SharedIndexInformer, cache.GenericLister, error) {
+	lw := NamespacedListWatch(tif.Namespaces, func(ns string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+				ul, err := tif.Client.Resource(gvr).Namespace(ns).List(opts)
+				if err != nil {
+					return nil, err
+				}
+				res := tif.Type.GetListType().DeepCopyObject()
+				if err := duck.FromUnstructured(ul, res); err != nil {
+					return nil, err
+				}
+				return res, nil
+			},
+			WatchFunc: duck.AsStructuredWatcher(tif.Client.Resource(gvr).Namespace(ns).Watch, tif.Type),
+		}
+	})
+	inf := cache.NewSharedIndexInformer(lw, tif.Type, tif.ResyncPeriod, withNamespaceIndex(tif.Indexers))
+
+	lister := cache.NewGenericLister(inf.GetIndexer(), gvr.GroupResource())
+
+	go inf.Run(tif.StopChannel)
+
+	if ok := cache.WaitForCacheSync(tif.StopChannel, inf.HasSynced); !ok {
+		return nil, nil, fmt.Errorf("Failed starting shared index informer for %v with type %T", gvr, tif.Type)
+	}
+
+	return inf, lister, nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package informers contains duck.InformerFactory implementations to
+// complement those in github.com/knative/pkg/apis/duck.
+package informers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/knative/pkg/apis/duck"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+// UnstructuredInformerFactory implements duck.InformerFactory such that the
+// elements tracked by the informer/lister are *unstructured.Unstructured.
+// When Namespaces is set, only those namespaces are watched.
+type UnstructuredInformerFactory struct {
+	Client       dynamic.Interface
+	Namespaces   []string
+	ResyncPeriod time.Duration
+	StopChannel  <-chan struct{}
+
+	// Indexers, when set, index the informers' elements alongside the
+	// namespace index.  They must be set here, since Get starts the
+	// informers, after which they can't be added.
+	Indexers cache.Indexers
+}
+
+// Check that UnstructuredInformerFactory implements duck.InformerFactory.
+var _ duck.InformerFactory = (*UnstructuredInformerFactory)(nil)
+
+// Get implements duck.InformerFactory.
+func (uif *UnstructuredInformerFactory) Get(gvr schema.GroupVersionResource) (cache.SharedIndexInformer, cache.GenericLister, error) {
+	lw := NamespacedListWatch(uif.Namespaces, func(ns string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+				return uif.Client.Resource(gvr).Namespace(ns).List(opts)
+			},
+			WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+				return uif.Client.Resource(gvr).Namespace(ns).Watch(opts)
+			},
+		}
+	})
+	inf := cache.NewSharedIndexInformer(lw, &unstructured.Unstructured{}, uif.ResyncPeriod, withNamespaceIndex(uif.Indexers))
+
+	lister := cache.NewGenericLister(inf.GetIndexer(), gvr.GroupResource())
+
+	go inf.Run(uif.StopChannel)
+
+	if ok := cache.WaitForCacheSync(uif.StopChannel, inf.HasSynced); !ok {
+		return nil, nil, fmt.Errorf("Failed starting shared index informer for %v", gvr)
+	}
+
+	return inf, lister, nil
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package inspect helps explain what the controller did for a resource:
+// finding the resource named on a command line, and grouping its Images by
+// the generation of the resource for which they were created.
+package inspect
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	"github.com/knative/pkg/kmeta"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// generationLabel is the label that kmeta.MakeGenerationLabels puts the
+// generation of the resource in.
+const generationLabel = "generation"
+
+// FindResource returns the resource and kind that name refers to, amongst
+// those of the API server's discovery lists (e.g. those of
+// discovery.ServerPreferredResources).  Like kubectl, name may be the
+// resource's plural or singular name, its kind, or a short name, case
+// insensitively, optionally followed by its group or version and group
+// (e.g. "deploy", "Deployment", "deployments.apps", "deployment.v1.apps").
+// When several groups have the resource, the deprecated extensions group
+// loses out.
+func FindResource(lists []*metav1.APIResourceList, name string) (schema.GroupVersionResource, schema.GroupVersionKind, error) {
+	name = strings.ToLower(name)
+	resource, qualifier := name, ""
+	if i := strings.Index(name, "."); i >= 0 {
+		resource, qualifier = name[:i], name[i+1:]
+	}
+
+	type match struct {
+		gvr schema.GroupVersionResource
+		gvk schema.GroupVersionKind
+	}
+	var matches []match
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		if qualifier != "" && qualifier != gv.Group && qualifier != gv.Version+"."+gv.Group {
+			continue
+		}
+		for _, r := range list.APIResources {
+			// Skip subresources.
+			if strings.Contains(r.Name, "/") || !matchesName(r, resource) {
+				continue
+			}
+			matches = append(matches, match{
+				gvr: gv.WithResource(r.Name),
+				gvk: gv.WithKind(r.Kind),
+			})
+		}
+	}
+
+	if len(matches) == 0 {
+		return schema.GroupVersionResource{}, schema.GroupVersionKind{}, fmt.Errorf("the server doesn't have a resource type %q", name)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].gvr.Group != "extensions" && matches[j].gvr.Group == "extensions"
+	})
+	return matches[0].gvr, matches[0].gvk, nil
+}
+
+// matchesName returns whether the lowercased name refers to the resource.
+func matchesName(r metav1.APIResource, name string) bool {
+	if name == r.Name || name == r.SingularName || name == strings.ToLower(r.Kind) {
+		return true
+	}
+	for _, short := range r.ShortNames {
+		if name == short {
+			return true
+		}
+	}
+	return false
+}
+
+// Generation holds the Images created for one generation of a resource.
+type Generation struct {
+	// Generation is that of the resource, from the Images' labels.
+	Generation int64
+
+	// Current is whether it is the resource's current generation.
+	Current bool
+
+	// Images are sorted by image.
+	Images []Image
+}
+
+// Image summarizes an Image and its Ready condition.
+type Image struct {
+	Name  string
+	Image string
+
+	// Ready is the status of the Ready condition, or Unknown if it has
+	// none yet.
+	Ready   corev1.ConditionStatus
+	Reason  string
+	Message string
+}
+
+// ImagesSelector selects the Images of every generation of the resource.
+func ImagesSelector(thing metav1.ObjectMetaAccessor) labels.Selector {
+	set := kmeta.MakeGenerationLabels(thing)
+	delete(set, generationLabel)
+	return labels.SelectorFromSet(set)
+}
+
+// Generations groups the Images of the resource (e.g. those that
+// ImagesSelector selects) by the generation for which they were created,
+// newest first.
+func Generations(thing metav1.ObjectMetaAccessor, imgs []caching.Image) []Generation {
+	current := kmeta.MakeGenerationLabels(thing)[generationLabel]
+
+	byGeneration := map[string]*Generation{}
+	var out []*Generation
+	for _, img := range imgs {
+		label := img.Labels[generationLabel]
+		g, ok := byGeneration[label]
+		if !ok {
+			n, _ := strconv.ParseInt(label, 10, 64)
+			g = &Generation{Generation: n, Current: label == current}
+			byGeneration[label] = g
+			out = append(out, g)
+		}
+
+		i := Image{
+			Name:  img.Name,
+			Image: img.Spec.Image,
+			Ready: corev1.ConditionUnknown,
+		}
+		if c := img.Status.GetCondition(caching.ImageConditionReady); c != nil {
+			i.Ready, i.Reason, i.Message = c.Status, c.Reason, c.Message
+		}
+		g.Images = append(g.Images, i)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Generation > out[j].Generation })
+	gens := make([]Generation, 0, len(out))
+	for _, g := range out {
+		sort.Slice(g.Images, func(i, j int) bool { return g.Images[i].Image < g.Images[j].Image })
+		gens = append(gens, *g)
+	}
+	return gens
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inspect
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var lists = []*metav1.APIResourceList{{
+	GroupVersion: "v1",
+	APIResources: []metav1.APIResource{{
+		Name: "pods", SingularName: "pod", Kind: "Pod", ShortNames: []string{"po"},
+	}, {
+		Name: "pods/status", Kind: "Pod",
+	}},
+}, {
+	GroupVersion: "extensions/v1beta1",
+	APIResources: []metav1.APIResource{{
+		Name: "deployments", SingularName: "deployment", Kind: "Deployment", ShortNames: []string{"deploy"},
+	}},
+}, {
+	GroupVersion: "apps/v1",
+	APIResources: []metav1.APIResource{{
+		Name: "deployments", SingularName: "deployment", Kind: "Deployment", ShortNames: []string{"deploy"},
+	}, {
+		Name: "statefulsets", SingularName: "statefulset", Kind: "StatefulSet", ShortNames: []string{"sts"},
+	}},
+}, {
+	GroupVersion: "serving.knative.dev/v1alpha1",
+	APIResources: []metav1.APIResource{{
+		Name: "services", SingularName: "service", Kind: "Service", ShortNames: []string{"ksvc"},
+	}},
+}}
+
+func TestFindResource(t *testing.T) {
+	deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	tests := []struct {
+		name    string
+		want    schema.GroupVersionResource
+		wantErr bool
+	}{{
+		name: "Deployment",
+		want: deployments,
+	}, {
+		name: "deploy",
+		want: deployments,
+	}, {
+		name: "deployments.apps",
+		want: deployments,
+	}, {
+		name: "deployment.v1.apps",
+		want: deployments,
+	}, {
+		name: "deployments.extensions",
+		want: schema.GroupVersionResource{Group: "extensions", Version: "v1beta1", Resource: "deployments"},
+	}, {
+		name: "po",
+		want: schema.GroupVersionResource{Version: "v1", Resource: "pods"},
+	}, {
+		name: "ksvc",
+		want: schema.GroupVersionResource{Group: "serving.knative.dev", Version: "v1alpha1", Resource: "services"},
+	}, {
+		name:    "deployments.batch",
+		wantErr: true,
+	}, {
+		name:    "status",
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gvr, gvk, err := FindResource(lists, test.name)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("FindResource() = %v, wanted error: %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if gvr != test.want {
+				t.Errorf("FindResource() = %v, wanted %v", gvr, test.want)
+			}
+			if gvk.GroupVersion() != gvr.GroupVersion() {
+				t.Errorf("FindResource() kind = %v, wanted it in %v", gvk, gvr.GroupVersion())
+			}
+		})
+	}
+}
+
+func image(name, ref, generation string, ready *caching.ImageCondition) caching.Image {
+	img := caching.Image{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"controller": "uid", "generation": generation},
+		},
+		Spec: caching.ImageSpec{Image: ref},
+	}
+	if ready != nil {
+		img.Status.Conditions = []caching.ImageCondition{*ready}
+	}
+	return img
+}
+
+func TestGenerations(t *testing.T) {
+	thing := &metav1.ObjectMeta{Name: "foo", UID: "uid", Generation: 3}
+	imgs := []caching.Image{
+		image("foo-01-a", "gcr.io/foo/b", "00003", &caching.ImageCondition{
+			Type:    caching.ImageConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  "ErrImagePull",
+			Message: "not found",
+		}),
+		image("foo-00-b", "gcr.io/foo/a:v1", "00002", &caching.ImageCondition{
+			Type:   caching.ImageConditionReady,
+			Status: corev1.ConditionTrue,
+		}),
+		image("foo-00-c", "gcr.io/foo/a:v2", "00003", nil),
+	}
+
+	want := []Generation{{
+		Generation: 3,
+		Current:    true,
+		Images: []Image{{
+			Name:  "foo-00-c",
+			Image: "gcr.io/foo/a:v2",
+			Ready: corev1.ConditionUnknown,
+		}, {
+			Name:    "foo-01-a",
+			Image:   "gcr.io/foo/b",
+			Ready:   corev1.ConditionFalse,
+			Reason:  "ErrImagePull",
+			Message: "not found",
+		}},
+	}, {
+		Generation: 2,
+		Images: []Image{{
+			Name:  "foo-00-b",
+			Image: "gcr.io/foo/a:v1",
+			Ready: corev1.ConditionTrue,
+		}},
+	}}
+	if diff := cmp.Diff(want, Generations(thing, imgs)); diff != "" {
+		t.Errorf("Generations() (-want, +got) = %v", diff)
+	}
+
+	if got, want := ImagesSelector(thing).String(), "controller=uid"; got != want {
+		t.Errorf("ImagesSelector() = %q, wanted %q", got, want)
+	}
+}
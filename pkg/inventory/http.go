@@ -0,0 +1,180 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+// csvHeader names the columns of WriteCSV.
+var csvHeader = []string{
+	"namespace", "group", "kind", "workload", "cached", "reason",
+	"image_name", "image", "ready", "ready_reason", "created", "age",
+}
+
+// WriteCSV writes a row for each Image of the Report, and for each workload
+// without Images.  The decision columns are empty for the workloads with no
+// reported decision.
+func WriteCSV(w io.Writer, r *Report) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, ns := range r.Namespaces {
+		for _, wl := range ns.Workloads {
+			row := []string{ns.Name, wl.Group, wl.Kind, wl.Name, "", ""}
+			if wl.Decision != nil {
+				row[4], row[5] = fmt.Sprint(wl.Decision.Cached), wl.Decision.Reason
+			}
+			if len(wl.Images) == 0 {
+				if err := cw.Write(append(row, "", "", "", "", "", "")); err != nil {
+					return err
+				}
+				continue
+			}
+			for _, i := range wl.Images {
+				if err := cw.Write(append(row[:6:6], i.Name, i.Image, string(i.Ready), i.Reason,
+					i.Created.UTC().Format(time.RFC3339), i.Age)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// APIHandler serves the Report as JSON, or with ?format=csv as CSV (see
+// WriteCSV).  With ?namespace= it reports on just that namespace.
+func (inv *Inventory) APIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = formatJSON
+		}
+		if format != formatJSON && format != formatCSV {
+			http.Error(w, fmt.Sprintf("format must be %q or %q, got %q", formatJSON, formatCSV, format), http.StatusBadRequest)
+			return
+		}
+		report, err := inv.Report(r.URL.Query().Get("namespace"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if format == formatCSV {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", `attachment; filename="cachier-inventory.csv"`)
+			if err := WriteCSV(w, report); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// PageHandler serves a read-only HTML page of the Report, linking to the
+// exports that APIHandler serves at apiPath.  With ?namespace= it reports on
+// just that namespace.
+func (inv *Inventory) PageHandler(apiPath string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		namespace := r.URL.Query().Get("namespace")
+		report, err := inv.Report(namespace)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := page.Execute(w, struct {
+			*Report
+			Namespace string
+			APIPath   string
+		}{report, namespace, apiPath}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+var page = template.Must(template.New("inventory").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>cachier inventory</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; vertical-align: top; }
+th { background: #eee; }
+.True { color: green; }
+.False { color: #b00; }
+.Unknown { color: #a60; }
+</style>
+</head>
+<body>
+<h1>cachier inventory{{if .Namespace}}: {{.Namespace}}{{end}}</h1>
+<form method="get">
+Namespace: <input name="namespace" value="{{.Namespace}}"> <input type="submit" value="Filter">
+{{if .Namespace}}<a href="?">all namespaces</a>{{end}}
+</form>
+<p>Generated {{.Generated.UTC.Format "2006-01-02 15:04:05 MST"}}.
+Export: <a href="{{.APIPath}}?namespace={{.Namespace}}">JSON</a>,
+<a href="{{.APIPath}}?format=csv&namespace={{.Namespace}}">CSV</a>.</p>
+
+<h2>Summary</h2>
+<table>
+<tr><th>Namespaces</th><td>{{.Summary.Namespaces}}</td></tr>
+<tr><th>Workloads</th><td>{{.Summary.Workloads}} ({{.Summary.CachedWorkloads}} cached)</td></tr>
+<tr><th>Images</th><td>{{.Summary.Images}} ({{.Summary.ReadyImages}} ready)</td></tr>
+<tr><th>Distinct references</th><td>{{.Summary.References}}</td></tr>
+</table>
+
+<h2>References</h2>
+<table>
+<tr><th>Image</th><th>Images</th><th>Ready</th><th>Workloads</th><th>Namespaces</th></tr>
+{{range .References}}<tr><td>{{.Image}}</td><td>{{.Images}}</td><td>{{.Ready}}</td><td>{{.Workloads}}</td><td>{{.Namespaces}}</td></tr>
+{{end}}</table>
+
+{{range .Namespaces}}<h2>Namespace {{.Name}}</h2>
+<table>
+<tr><th>Workload</th><th>Decision</th><th>Image</th><th>Ready</th><th>Age</th></tr>
+{{range .Workloads}}{{$n := len .Images}}{{if eq $n 0}}{{$n = 1}}{{end}}
+<tr><td rowspan="{{$n}}">{{if .Kind}}{{.Kind}}{{if .Group}}.{{.Group}}{{end}} {{.Name}}{{else}}(no owner){{end}}</td>
+<td rowspan="{{$n}}">{{with .Decision}}{{if .Cached}}cached{{else}}not cached{{end}}{{with .Reason}} ({{.}}){{end}}{{with .Policy}}<br>policy: {{.}}{{end}}{{with .ExcludedImages}}<br>excluding {{range $i, $x := .}}{{if $i}}, {{end}}{{$x}}{{end}}{{end}}{{else}}-{{end}}</td>
+{{range $i, $img := .Images}}{{if $i}}<tr>{{end}}<td title="{{$img.Name}}">{{$img.Image}}</td><td class="{{$img.Ready}}" title="{{$img.Message}}">{{$img.Ready}}{{with $img.Reason}} ({{.}}){{end}}</td><td>{{$img.Age}}</td></tr>
+{{else}}<td>-</td><td></td><td></td></tr>
+{{end}}{{end}}</table>
+{{else}}<p>Nothing to report.</p>
+{{end}}</body>
+</html>
+`))
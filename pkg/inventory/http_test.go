@@ -0,0 +1,131 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+)
+
+func testInventory(t *testing.T) *Inventory {
+	return newInventory(t, []*v1alpha1.WithPod{
+		workload("a", "web", &v1alpha1.Status{Cached: true, Reason: "NamespaceDefault"}),
+		workload("a", "idle", nil),
+	}, []*caching.Image{
+		image("a", "web-00-x", "gcr.io/foo/web:v1", controllerRef("apps/v1", "Deployment", "a", "web"), time.Hour, corev1.ConditionTrue),
+	})
+}
+
+func TestWriteCSV(t *testing.T) {
+	report, err := testInventory(t).Report("")
+	if err != nil {
+		t.Fatalf("Report() = %v", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, report); err != nil {
+		t.Fatalf("WriteCSV() = %v", err)
+	}
+
+	want := strings.Join([]string{
+		"namespace,group,kind,workload,cached,reason,image_name,image,ready,ready_reason,created,age",
+		"a,apps,Deployment,idle,,,,,,,,",
+		"a,apps,Deployment,web,true,NamespaceDefault,web-00-x,gcr.io/foo/web:v1,True,,2018-10-01T11:00:00Z,1h0m0s",
+		"",
+	}, "\n")
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("WriteCSV() (-want, +got) = %v", diff)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	inv := testInventory(t)
+	tests := []struct {
+		name        string
+		handler     http.Handler
+		url         string
+		wantCode    int
+		wantType    string
+		wantContent string
+	}{{
+		name:        "json",
+		handler:     inv.APIHandler(),
+		url:         "/api/inventory",
+		wantCode:    http.StatusOK,
+		wantType:    "application/json",
+		wantContent: `"image": "gcr.io/foo/web:v1"`,
+	}, {
+		name:        "csv",
+		handler:     inv.APIHandler(),
+		url:         "/api/inventory?format=csv&namespace=a",
+		wantCode:    http.StatusOK,
+		wantType:    "text/csv",
+		wantContent: "a,apps,Deployment,web,true",
+	}, {
+		name:     "bad format",
+		handler:  inv.APIHandler(),
+		url:      "/api/inventory?format=xml",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:        "page",
+		handler:     inv.PageHandler("/api/inventory"),
+		url:         "/inventory?namespace=a",
+		wantCode:    http.StatusOK,
+		wantType:    "text/html; charset=utf-8",
+		wantContent: `<td title="web-00-x">gcr.io/foo/web:v1</td>`,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			test.handler.ServeHTTP(w, httptest.NewRequest("GET", test.url, nil))
+			if w.Code != test.wantCode {
+				t.Fatalf("ServeHTTP() = %d, wanted %d: %s", w.Code, test.wantCode, w.Body)
+			}
+			if test.wantCode != http.StatusOK {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != test.wantType {
+				t.Errorf("Content-Type = %q, wanted %q", got, test.wantType)
+			}
+			if !strings.Contains(w.Body.String(), test.wantContent) {
+				t.Errorf("ServeHTTP() = %s, wanted it to contain %s", w.Body, test.wantContent)
+			}
+		})
+	}
+
+	// The JSON round trips.
+	w := httptest.NewRecorder()
+	inv.APIHandler().ServeHTTP(w, httptest.NewRequest("GET", "/api/inventory", nil))
+	var report Report
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+	if got, want := report.Summary, (Summary{Namespaces: 1, Workloads: 2, CachedWorkloads: 1, Images: 1, ReadyImages: 1, References: 1}); got != want {
+		t.Errorf("Summary = %+v, wanted %+v", got, want)
+	}
+}
@@ -0,0 +1,344 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package inventory reports everything that the controller manages: the
+// Images of each workload in each namespace, the decisions that led to them,
+// and how many Images share each reference.
+package inventory
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	cachinglisters "github.com/knative/caching/pkg/client/listers/caching/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+)
+
+// Report is the inventory at a point in time.
+type Report struct {
+	Generated time.Time `json:"generated"`
+	Summary   Summary   `json:"summary"`
+
+	// Namespaces are sorted by name.
+	Namespaces []Namespace `json:"namespaces"`
+
+	// References are sorted by image.
+	References []Reference `json:"references"`
+}
+
+// Summary totals the Report.
+type Summary struct {
+	Namespaces      int `json:"namespaces"`
+	Workloads       int `json:"workloads"`
+	CachedWorkloads int `json:"cachedWorkloads"`
+	Images          int `json:"images"`
+	ReadyImages     int `json:"readyImages"`
+
+	// References is the number of distinct images that the Images cache.
+	References int `json:"references"`
+}
+
+// Namespace holds the workloads of a namespace.
+type Namespace struct {
+	Name string `json:"name"`
+
+	// Workloads are sorted by group, kind and name.
+	Workloads []Workload `json:"workloads"`
+}
+
+// Workload is a resource whose images the controller considers, or that
+// owns Images (e.g. a CachedImageSet).
+type Workload struct {
+	Group string `json:"group,omitempty"`
+	Kind  string `json:"kind,omitempty"`
+	Name  string `json:"name,omitempty"`
+
+	// Replicas is the desired number of Pods of the workload, if it has
+	// one (e.g. DaemonSets don't).
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Decision is the decision that the controller last reported on the
+	// workload, or nil if it hasn't (e.g. for CachedImageSets).
+	Decision *Decision `json:"decision,omitempty"`
+
+	// PinnedImages maps the workload's images to the digests (in the form
+	// repository@digest) that its Images are pinned to, if any.
+	PinnedImages map[string]string `json:"pinnedImages,omitempty"`
+
+	// Images are sorted by image.
+	Images []Image `json:"images,omitempty"`
+}
+
+// Decision is whether, and why, the workload's images are cached.
+type Decision struct {
+	Cached bool   `json:"cached"`
+	Reason string `json:"reason,omitempty"`
+
+	// Policy describes the CachePolicy or ClusterCachePolicy that applies
+	// to the workload, if any (e.g. `CachePolicy "foo"`).
+	Policy         string   `json:"policy,omitempty"`
+	ExcludedImages []string `json:"excludedImages,omitempty"`
+}
+
+// Image summarizes an Image.
+type Image struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+
+	// Ready is the status of the Ready condition, or Unknown if it has none
+	// yet.
+	Ready   corev1.ConditionStatus `json:"ready"`
+	Reason  string                 `json:"reason,omitempty"`
+	Message string                 `json:"message,omitempty"`
+
+	Created time.Time `json:"created"`
+	// Age is the time since Created, to the second (e.g. "26h3m4s").
+	Age string `json:"age"`
+}
+
+// Reference counts the Images that cache an image.
+type Reference struct {
+	Image      string `json:"image"`
+	Images     int    `json:"images"`
+	Ready      int    `json:"ready"`
+	Workloads  int    `json:"workloads"`
+	Namespaces int    `json:"namespaces"`
+}
+
+// source is a kind of workload, and how to read its resources.
+type source struct {
+	gvk     schema.GroupVersionKind
+	lister  cache.GenericLister
+	convert func(runtime.Object) (*v1alpha1.WithPod, error)
+}
+
+// Inventory builds Reports from the controller's informer caches.  It is
+// safe for concurrent use.
+type Inventory struct {
+	images cachinglisters.ImageLister
+	clock  clock.Clock
+
+	m       sync.RWMutex
+	sources []source
+}
+
+// New returns an Inventory of the Images in the lister, and of the
+// workloads of the kinds that are tracked (see Track).
+func New(images cachinglisters.ImageLister, clk clock.Clock) *Inventory {
+	return &Inventory{images: images, clock: clk}
+}
+
+// Track adds the resources of the given kind in the lister to the
+// inventory, whether or not they have Images.
+func (inv *Inventory) Track(gvk schema.GroupVersionKind, lister cache.GenericLister, convert func(runtime.Object) (*v1alpha1.WithPod, error)) {
+	inv.m.Lock()
+	defer inv.m.Unlock()
+	inv.sources = append(inv.sources, source{gvk: gvk, lister: lister, convert: convert})
+}
+
+// Report returns the inventory of the given namespace, or of all of them
+// when it is empty.
+func (inv *Inventory) Report(namespace string) (*Report, error) {
+	now := inv.clock.Now()
+
+	// workloads are keyed by UID, so that we can find the owners of Images.
+	type entry struct {
+		namespace string
+		workload  *Workload
+	}
+	workloads := map[types.UID]*entry{}
+
+	inv.m.RLock()
+	sources := inv.sources
+	inv.m.RUnlock()
+	for _, s := range sources {
+		var objs []runtime.Object
+		var err error
+		if namespace == "" {
+			objs, err = s.lister.List(labels.Everything())
+		} else {
+			objs, err = s.lister.ByNamespace(namespace).List(labels.Everything())
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objs {
+			thing, err := s.convert(obj)
+			if err != nil {
+				// The controller reports these when it reconciles them.
+				continue
+			}
+			w := &Workload{
+				Group:    s.gvk.Group,
+				Kind:     s.gvk.Kind,
+				Name:     thing.Name,
+				Replicas: thing.Spec.Replicas,
+			}
+			if status := v1alpha1.GetStatus(thing); status != nil {
+				w.Decision = &Decision{
+					Cached:         status.Cached,
+					Reason:         status.Reason,
+					Policy:         status.Policy,
+					ExcludedImages: status.ExcludedImages,
+				}
+				w.PinnedImages = status.PinnedImages
+			}
+			workloads[thing.UID] = &entry{namespace: thing.Namespace, workload: w}
+		}
+	}
+
+	var imgs []*caching.Image
+	var err error
+	if namespace == "" {
+		imgs, err = inv.images.List(labels.Everything())
+	} else {
+		imgs, err = inv.images.Images(namespace).List(labels.Everything())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	type refCounts struct {
+		Reference
+		workloads  sets.String
+		namespaces sets.String
+	}
+	refs := map[string]*refCounts{}
+	// unowned holds the Images without a controller, by namespace.
+	unowned := map[string]*entry{}
+	for _, img := range imgs {
+		var e *entry
+		if owner := metav1.GetControllerOf(img); owner != nil {
+			e = workloads[owner.UID]
+			if e == nil {
+				// Owners we don't track (e.g. CachedImageSets) have no
+				// decision to report.
+				gv, _ := schema.ParseGroupVersion(owner.APIVersion)
+				e = &entry{
+					namespace: img.Namespace,
+					workload:  &Workload{Group: gv.Group, Kind: owner.Kind, Name: owner.Name},
+				}
+				workloads[owner.UID] = e
+			}
+		} else {
+			e = unowned[img.Namespace]
+			if e == nil {
+				e = &entry{namespace: img.Namespace, workload: &Workload{}}
+				unowned[img.Namespace] = e
+			}
+		}
+
+		i := Image{
+			Name:    img.Name,
+			Image:   img.Spec.Image,
+			Ready:   corev1.ConditionUnknown,
+			Created: img.CreationTimestamp.Time,
+			Age:     now.Sub(img.CreationTimestamp.Time).Round(time.Second).String(),
+		}
+		if c := img.Status.GetCondition(caching.ImageConditionReady); c != nil {
+			i.Ready, i.Reason, i.Message = c.Status, c.Reason, c.Message
+		}
+		e.workload.Images = append(e.workload.Images, i)
+
+		r := refs[i.Image]
+		if r == nil {
+			r = &refCounts{
+				Reference:  Reference{Image: i.Image},
+				workloads:  sets.NewString(),
+				namespaces: sets.NewString(),
+			}
+			refs[i.Image] = r
+		}
+		r.Images++
+		if i.Ready == corev1.ConditionTrue {
+			r.Ready++
+		}
+		r.workloads.Insert(e.namespace + "/" + e.workload.Group + "/" + e.workload.Kind + "/" + e.workload.Name)
+		r.namespaces.Insert(e.namespace)
+	}
+
+	report := &Report{
+		Generated:  now,
+		Namespaces: []Namespace{},
+		References: []Reference{},
+	}
+	byNamespace := map[string]*Namespace{}
+	add := func(e *entry) {
+		ns := byNamespace[e.namespace]
+		if ns == nil {
+			ns = &Namespace{Name: e.namespace}
+			byNamespace[e.namespace] = ns
+		}
+		ns.Workloads = append(ns.Workloads, *e.workload)
+
+		report.Summary.Workloads++
+		if e.workload.Decision != nil && e.workload.Decision.Cached {
+			report.Summary.CachedWorkloads++
+		}
+		for _, i := range e.workload.Images {
+			report.Summary.Images++
+			if i.Ready == corev1.ConditionTrue {
+				report.Summary.ReadyImages++
+			}
+		}
+	}
+	for _, e := range workloads {
+		add(e)
+	}
+	for _, e := range unowned {
+		add(e)
+	}
+
+	for _, ns := range byNamespace {
+		sort.Slice(ns.Workloads, func(i, j int) bool {
+			a, b := ns.Workloads[i], ns.Workloads[j]
+			if a.Group != b.Group {
+				return a.Group < b.Group
+			}
+			if a.Kind != b.Kind {
+				return a.Kind < b.Kind
+			}
+			return a.Name < b.Name
+		})
+		for _, w := range ns.Workloads {
+			sort.Slice(w.Images, func(i, j int) bool { return w.Images[i].Image < w.Images[j].Image })
+		}
+		report.Namespaces = append(report.Namespaces, *ns)
+	}
+	sort.Slice(report.Namespaces, func(i, j int) bool { return report.Namespaces[i].Name < report.Namespaces[j].Name })
+
+	for _, r := range refs {
+		r.Workloads, r.Namespaces = r.workloads.Len(), r.namespaces.Len()
+		report.References = append(report.References, r.Reference)
+	}
+	sort.Slice(report.References, func(i, j int) bool { return report.References[i].Image < report.References[j].Image })
+
+	report.Summary.Namespaces = len(report.Namespaces)
+	report.Summary.References = len(report.References)
+	return report, nil
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	cachinglisters "github.com/knative/caching/pkg/client/listers/caching/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+)
+
+var (
+	now         = time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+	deployments = schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+)
+
+func workload(namespace, name string, status *v1alpha1.Status) *v1alpha1.WithPod {
+	thing := &v1alpha1.WithPod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			UID:       types.UID(namespace + "-" + name),
+		},
+	}
+	if status != nil {
+		raw, _ := status.Serialize()
+		thing.Annotations = map[string]string{v1alpha1.StatusAnnotationKey: raw}
+	}
+	return thing
+}
+
+func image(namespace, name, ref string, owner *metav1.OwnerReference, age time.Duration, ready corev1.ConditionStatus) *caching.Image {
+	img := &caching.Image{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         namespace,
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(now.Add(-age)),
+		},
+		Spec: caching.ImageSpec{Image: ref},
+	}
+	if owner != nil {
+		img.OwnerReferences = []metav1.OwnerReference{*owner}
+	}
+	if ready != "" {
+		img.Status.Conditions = []caching.ImageCondition{{
+			Type:   caching.ImageConditionReady,
+			Status: ready,
+		}}
+	}
+	return img
+}
+
+func controllerRef(apiVersion, kind, namespace, name string) *metav1.OwnerReference {
+	isController := true
+	return &metav1.OwnerReference{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Name:       name,
+		UID:        types.UID(namespace + "-" + name),
+		Controller: &isController,
+	}
+}
+
+func newInventory(t *testing.T, workloads []*v1alpha1.WithPod, imgs []*caching.Image) *Inventory {
+	things := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, w := range workloads {
+		if err := things.Add(w); err != nil {
+			t.Fatalf("Add() = %v", err)
+		}
+	}
+	images := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, img := range imgs {
+		if err := images.Add(img); err != nil {
+			t.Fatalf("Add() = %v", err)
+		}
+	}
+
+	inv := New(cachinglisters.NewImageLister(images), clock.NewFakeClock(now))
+	inv.Track(deployments, cache.NewGenericLister(things, schema.GroupResource{Group: "apps", Resource: "deployments"}),
+		func(obj runtime.Object) (*v1alpha1.WithPod, error) { return obj.(*v1alpha1.WithPod), nil })
+	return inv
+}
+
+func TestReport(t *testing.T) {
+	web := workload("a", "web", &v1alpha1.Status{
+		Cached:         true,
+		Reason:         `cached per CachePolicy "p"`,
+		Policy:         `CachePolicy "p"`,
+		ExcludedImages: []string{"gcr.io/foo/debug"},
+		PinnedImages:   map[string]string{"gcr.io/foo/app:v1": "gcr.io/foo/app@sha256:1234"},
+	})
+	replicas := int32(3)
+	web.Spec.Replicas = &replicas
+	inv := newInventory(t, []*v1alpha1.WithPod{
+		web,
+		workload("a", "opted-out", &v1alpha1.Status{Cached: false, Reason: "ResourceOptOut"}),
+		workload("b", "api", nil),
+	}, []*caching.Image{
+		image("a", "web-00-x", "gcr.io/foo/web:v1", controllerRef("apps/v1", "Deployment", "a", "web"), time.Hour, corev1.ConditionTrue),
+		image("a", "web-01-y", "gcr.io/foo/base", controllerRef("apps/v1", "Deployment", "a", "web"), 90*time.Second, ""),
+		image("b", "api-00-z", "gcr.io/foo/base", controllerRef("apps/v1", "Deployment", "b", "api"), time.Minute, corev1.ConditionFalse),
+		image("b", "set-00-w", "gcr.io/foo/base", controllerRef("cachier.mattmoor.io/v1alpha1", "CachedImageSet", "b", "set"), time.Minute, corev1.ConditionTrue),
+		image("b", "manual", "gcr.io/foo/manual", nil, 0, ""),
+	})
+
+	want := &Report{
+		Generated: now,
+		Summary: Summary{
+			Namespaces:      2,
+			Workloads:       5,
+			CachedWorkloads: 1,
+			Images:          5,
+			ReadyImages:     2,
+			References:      3,
+		},
+		Namespaces: []Namespace{{
+			Name: "a",
+			Workloads: []Workload{{
+				Group: "apps", Kind: "Deployment", Name: "opted-out",
+				Decision: &Decision{Reason: "ResourceOptOut"},
+			}, {
+				Group: "apps", Kind: "Deployment", Name: "web",
+				Replicas: &replicas,
+				Decision: &Decision{
+					Cached:         true,
+					Reason:         `cached per CachePolicy "p"`,
+					Policy:         `CachePolicy "p"`,
+					ExcludedImages: []string{"gcr.io/foo/debug"},
+				},
+				PinnedImages: map[string]string{"gcr.io/foo/app:v1": "gcr.io/foo/app@sha256:1234"},
+				Images: []Image{{
+					Name: "web-01-y", Image: "gcr.io/foo/base", Ready: corev1.ConditionUnknown,
+					Created: now.Add(-90 * time.Second), Age: "1m30s",
+				}, {
+					Name: "web-00-x", Image: "gcr.io/foo/web:v1", Ready: corev1.ConditionTrue,
+					Created: now.Add(-time.Hour), Age: "1h0m0s",
+				}},
+			}},
+		}, {
+			Name: "b",
+			Workloads: []Workload{{
+				Images: []Image{{
+					Name: "manual", Image: "gcr.io/foo/manual", Ready: corev1.ConditionUnknown,
+					Created: now, Age: "0s",
+				}},
+			}, {
+				Group: "apps", Kind: "Deployment", Name: "api",
+				Images: []Image{{
+					Name: "api-00-z", Image: "gcr.io/foo/base", Ready: corev1.ConditionFalse,
+					Created: now.Add(-time.Minute), Age: "1m0s",
+				}},
+			}, {
+				Group: "cachier.mattmoor.io", Kind: "CachedImageSet", Name: "set",
+				Images: []Image{{
+					Name: "set-00-w", Image: "gcr.io/foo/base", Ready: corev1.ConditionTrue,
+					Created: now.Add(-time.Minute), Age: "1m0s",
+				}},
+			}},
+		}},
+		References: []Reference{
+			{Image: "gcr.io/foo/base", Images: 3, Ready: 1, Workloads: 3, Namespaces: 2},
+			{Image: "gcr.io/foo/manual", Images: 1, Workloads: 1, Namespaces: 1},
+			{Image: "gcr.io/foo/web:v1", Images: 1, Ready: 1, Workloads: 1, Namespaces: 1},
+		},
+	}
+
+	got, err := inv.Report("")
+	if err != nil {
+		t.Fatalf("Report() = %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Report() (-want, +got) = %v", diff)
+	}
+
+	// Reports on a namespace count just what's in it.
+	got, err = inv.Report("a")
+	if err != nil {
+		t.Fatalf("Report(a) = %v", err)
+	}
+	if diff := cmp.Diff(want.Namespaces[:1], got.Namespaces); diff != "" {
+		t.Errorf("Report(a) (-want, +got) = %v", diff)
+	}
+	wantSummary := Summary{Namespaces: 1, Workloads: 2, CachedWorkloads: 1, Images: 2, ReadyImages: 1, References: 2}
+	if diff := cmp.Diff(wantSummary, got.Summary); diff != "" {
+		t.Errorf("Report(a).Summary (-want, +got) = %v", diff)
+	}
+}
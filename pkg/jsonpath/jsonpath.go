@@ -0,0 +1,166 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package jsonpath implements the subset of JSONPath needed to pluck
+// values out of unstructured Kubernetes resources, e.g.
+//
+//	{.spec.steps[*].image}
+//	.spec.tfReplicaSpecs.*.template.spec
+package jsonpath
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// step is a single element of a parsed Path.
+type step struct {
+	// field is the map key to select, when neither wildcard nor index is set.
+	field string
+	// wildcard selects every element of a list or every value of a map.
+	wildcard bool
+	// index selects a single element of a list, when non-nil.
+	index *int
+}
+
+// Path is a parsed JSONPath expression.
+type Path struct {
+	expr  string
+	steps []step
+}
+
+// String implements fmt.Stringer
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Parse parses the given JSONPath expression.  The surrounding braces
+// and leading `$` are optional.  Supported are `.field`, `['field']`,
+// `[N]`, and the wildcards `.*` and `[*]`, which range over both lists
+// and maps.
+func Parse(expr string) (*Path, error) {
+	s := strings.TrimSpace(expr)
+	if strings.HasPrefix(s, "{") {
+		if !strings.HasSuffix(s, "}") {
+			return nil, fmt.Errorf("unterminated expression: %q", expr)
+		}
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	s = strings.TrimPrefix(s, "$")
+
+	p := &Path{expr: expr}
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			if strings.HasPrefix(s, ".") {
+				return nil, fmt.Errorf("recursive descent is not supported: %q", expr)
+			}
+			end := strings.IndexAny(s, ".[")
+			if end == -1 {
+				end = len(s)
+			}
+			name := s[:end]
+			s = s[end:]
+			switch name {
+			case "":
+				return nil, fmt.Errorf("empty field name: %q", expr)
+			case "*":
+				p.steps = append(p.steps, step{wildcard: true})
+			default:
+				p.steps = append(p.steps, step{field: name})
+			}
+
+		case '[':
+			end := strings.Index(s, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated subscript: %q", expr)
+			}
+			sub := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+			switch {
+			case sub == "*":
+				p.steps = append(p.steps, step{wildcard: true})
+			case len(sub) >= 2 && (sub[0] == '\'' || sub[0] == '"') && sub[len(sub)-1] == sub[0]:
+				p.steps = append(p.steps, step{field: sub[1 : len(sub)-1]})
+			default:
+				idx, err := strconv.Atoi(sub)
+				if err != nil || idx < 0 {
+					return nil, fmt.Errorf("unsupported subscript %q: %q", sub, expr)
+				}
+				p.steps = append(p.steps, step{index: &idx})
+			}
+
+		default:
+			return nil, fmt.Errorf("unexpected character %q: %q", s[0], expr)
+		}
+	}
+	return p, nil
+}
+
+// Eval returns the values in obj selected by the Path.  Missing fields
+// and out-of-range indices simply select nothing.  Map wildcards are
+// expanded in key order so that results are deterministic.
+func (p *Path) Eval(obj interface{}) []interface{} {
+	current := []interface{}{obj}
+	for _, st := range p.steps {
+		next := make([]interface{}, 0, len(current))
+		for _, v := range current {
+			next = append(next, st.apply(v)...)
+		}
+		current = next
+	}
+	return current
+}
+
+func (st step) apply(v interface{}) []interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if st.wildcard {
+			keys := make([]string, 0, len(t))
+			for k := range t {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			out := make([]interface{}, 0, len(keys))
+			for _, k := range keys {
+				out = append(out, t[k])
+			}
+			return out
+		}
+		if st.index != nil {
+			return nil
+		}
+		if child, ok := t[st.field]; ok {
+			return []interface{}{child}
+		}
+		return nil
+
+	case []interface{}:
+		if st.wildcard {
+			return t
+		}
+		if st.index != nil && *st.index < len(t) {
+			return []interface{}{t[*st.index]}
+		}
+		return nil
+
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const doc = `{
+  "spec": {
+    "steps": [{"image": "a"}, {"image": "b"}, {"name": "no-image"}],
+    "driver": {"image": "c"},
+    "replicas": {
+      "Worker": {"template": {"spec": {"image": "e"}}},
+      "Chief": {"template": {"spec": {"image": "d"}}}
+    },
+    "dotted.key": "f"
+  }
+}`
+
+func TestEval(t *testing.T) {
+	var obj interface{}
+	if err := json.Unmarshal([]byte(doc), &obj); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want []interface{}
+	}{{
+		expr: "{.spec.steps[*].image}",
+		want: []interface{}{"a", "b"},
+	}, {
+		expr: ".spec.steps[1].image",
+		want: []interface{}{"b"},
+	}, {
+		expr: "$.spec.steps[7].image",
+		want: []interface{}{},
+	}, {
+		expr: "{.spec.driver.image}",
+		want: []interface{}{"c"},
+	}, {
+		expr: ".spec.replicas.*.template.spec.image",
+		want: []interface{}{"d", "e"},
+	}, {
+		expr: ".spec.replicas[*].template.spec.image",
+		want: []interface{}{"d", "e"},
+	}, {
+		expr: ".spec['dotted.key']",
+		want: []interface{}{"f"},
+	}, {
+		expr: ".spec.missing.image",
+		want: []interface{}{},
+	}, {
+		expr: ".spec.driver[0]",
+		want: []interface{}{},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			p, err := Parse(test.expr)
+			if err != nil {
+				t.Fatalf("Parse() = %v", err)
+			}
+			got := p.Eval(obj)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Eval (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"{.spec.image",
+		".spec..image",
+		".spec.steps[foo]",
+		".spec.steps[-1]",
+		".spec.steps[*",
+		"spec.image",
+		".spec.",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := Parse(expr); err == nil {
+				t.Errorf("Parse(%q) = nil, wanted error", expr)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ownerchain follows the chain of controlling OwnerReferences from
+// an object (e.g. a Pod) up to its top-level owner (e.g. a Deployment).
+package ownerchain
+
+import (
+	lru "github.com/hashicorp/golang-lru"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// maxDepth guards against cycles in malformed owner chains.
+const maxDepth = 10
+
+// Resolver follows owner chains, fetching intermediate owners (e.g. the
+// ReplicaSet between a Pod and its Deployment) through the dynamic client.
+// Since owner references are immutable, it remembers each owner's own
+// controller in a bounded cache.
+type Resolver struct {
+	client dynamic.Interface
+	// parents maps the UID of an object to its controlling OwnerReference,
+	// or nil if it has none.
+	parents *lru.Cache
+}
+
+// NewResolver returns a Resolver that caches the owners of at most size
+// intermediate objects.
+func NewResolver(client dynamic.Interface, size int) *Resolver {
+	parents, err := lru.New(size)
+	if err != nil {
+		// This only happens for non-positive sizes.
+		panic(err)
+	}
+	return &Resolver{
+		client:  client,
+		parents: parents,
+	}
+}
+
+// Chain returns the controlling OwnerReferences of obj, from its immediate
+// controller up to its top-level owner.  Owners that no longer exist end
+// the chain.
+func (r *Resolver) Chain(obj metav1.Object) ([]metav1.OwnerReference, error) {
+	var chain []metav1.OwnerReference
+	ref := metav1.GetControllerOf(obj)
+	for ref != nil && len(chain) < maxDepth {
+		chain = append(chain, *ref)
+		parent, err := r.parentOf(obj.GetNamespace(), ref)
+		if err != nil {
+			return nil, err
+		}
+		ref = parent
+	}
+	return chain, nil
+}
+
+// Root returns the top-level controlling OwnerReference of obj, or nil if
+// obj has no controller.
+func (r *Resolver) Root(obj metav1.Object) (*metav1.OwnerReference, error) {
+	chain, err := r.Chain(obj)
+	if err != nil || len(chain) == 0 {
+		return nil, err
+	}
+	return &chain[len(chain)-1], nil
+}
+
+// Owns returns whether the object with the given UID appears in the owner
+// chain of obj.  Unlike Chain, this stops as soon as it finds the owner.
+func (r *Resolver) Owns(uid types.UID, obj metav1.Object) (bool, error) {
+	ref := metav1.GetControllerOf(obj)
+	for depth := 0; ref != nil && depth < maxDepth; depth++ {
+		if ref.UID == uid {
+			return true, nil
+		}
+		parent, err := r.parentOf(obj.GetNamespace(), ref)
+		if err != nil {
+			return false, err
+		}
+		ref = parent
+	}
+	return false, nil
+}
+
+// parentOf returns the controlling OwnerReference of the object referenced
+// by ref, which lives in the given namespace.
+func (r *Resolver) parentOf(namespace string, ref *metav1.OwnerReference) (*metav1.OwnerReference, error) {
+	if v, ok := r.parents.Get(ref.UID); ok {
+		return v.(*metav1.OwnerReference), nil
+	}
+
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	gvr, _ := meta.UnsafeGuessKindToResource(gv.WithKind(ref.Kind))
+	owner, err := r.client.Resource(gvr).Namespace(namespace).Get(ref.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if owner.GetUID() != ref.UID {
+		// The owner has since been replaced by something else.
+		return nil, nil
+	}
+
+	parent := metav1.GetControllerOf(owner)
+	r.parents.Add(ref.UID, parent)
+	return parent, nil
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ownerchain
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	rtesting "github.com/mattmoor/cachier/pkg/reconciler/testing"
+)
+
+func controllerRef(kind, name, uid string) *metav1.OwnerReference {
+	isController := true
+	return &metav1.OwnerReference{
+		APIVersion: "apps/v1",
+		Kind:       kind,
+		Name:       name,
+		UID:        types.UID(uid),
+		Controller: &isController,
+	}
+}
+
+func object(kind, name, uid string, owner *metav1.OwnerReference) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("apps/v1")
+	u.SetKind(kind)
+	u.SetNamespace("ns")
+	u.SetName(name)
+	u.SetUID(types.UID(uid))
+	if owner != nil {
+		u.SetOwnerReferences([]metav1.OwnerReference{*owner})
+	}
+	return u
+}
+
+func pod(name string, owner *metav1.OwnerReference) *metav1.ObjectMeta {
+	om := &metav1.ObjectMeta{Namespace: "ns", Name: name}
+	if owner != nil {
+		om.OwnerReferences = []metav1.OwnerReference{*owner}
+	}
+	return om
+}
+
+func TestChain(t *testing.T) {
+	deploy := controllerRef("Deployment", "web", "deploy")
+	rs := controllerRef("ReplicaSet", "web-abc", "rs")
+	client := rtesting.NewDynamic(
+		object("Deployment", "web", "deploy", nil),
+		object("ReplicaSet", "web-abc", "rs", deploy),
+	)
+	r := NewResolver(client, 10)
+
+	chain := func(obj metav1.Object, want []metav1.OwnerReference, wantGets int) {
+		t.Helper()
+		got, err := r.Chain(obj)
+		if err != nil {
+			t.Fatalf("Chain() = %v", err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Chain (-want, +got) = %v", diff)
+		}
+		if got := client.Gets(); got != wantGets {
+			t.Errorf("Gets() = %d, wanted %d", got, wantGets)
+		}
+	}
+
+	// On a miss, we fetch each owner in the chain.
+	chain(pod("web-1", rs), []metav1.OwnerReference{*rs, *deploy}, 2)
+
+	// Then we remember them, for the Pod's siblings.
+	chain(pod("web-2", rs), []metav1.OwnerReference{*rs, *deploy}, 2)
+	if got, err := r.Root(pod("web-3", rs)); err != nil || !cmp.Equal(got, deploy) {
+		t.Errorf("Root() = %v, %v, wanted %v", got, err, deploy)
+	}
+	if got, err := r.Owns("deploy", pod("web-3", rs)); err != nil || !got {
+		t.Errorf("Owns(deploy) = %v, %v, wanted true", got, err)
+	}
+	if got := client.Gets(); got != 2 {
+		t.Errorf("Gets() = %d, wanted 2", got)
+	}
+
+	// Objects without a controller have no chain.
+	chain(pod("bare", nil), nil, 2)
+	if got, err := r.Root(pod("bare", nil)); err != nil || got != nil {
+		t.Errorf("Root() = %v, %v, wanted nil", got, err)
+	}
+}
+
+func TestChainDeletedOwner(t *testing.T) {
+	deploy := controllerRef("Deployment", "web", "deploy")
+	rs := controllerRef("ReplicaSet", "web-abc", "rs")
+	replaced := controllerRef("ReplicaSet", "web-def", "old-rs")
+	client := rtesting.NewDynamic(
+		object("ReplicaSet", "web-abc", "rs", deploy),
+		// Something else now has the name of the Pod's ReplicaSet.
+		object("ReplicaSet", "web-def", "new-rs", deploy),
+	)
+	r := NewResolver(client, 10)
+
+	// Owners that are gone end the chain.
+	got, err := r.Chain(pod("web-1", rs))
+	if err != nil {
+		t.Fatalf("Chain() = %v", err)
+	}
+	if diff := cmp.Diff([]metav1.OwnerReference{*rs, *deploy}, got); diff != "" {
+		t.Errorf("Chain (-want, +got) = %v", diff)
+	}
+	got, err = r.Chain(pod("web-2", replaced))
+	if err != nil {
+		t.Fatalf("Chain() = %v", err)
+	}
+	if diff := cmp.Diff([]metav1.OwnerReference{*replaced}, got); diff != "" {
+		t.Errorf("Chain (-want, +got) = %v", diff)
+	}
+	if got, err := r.Owns("deploy", pod("web-2", replaced)); err != nil || got {
+		t.Errorf("Owns(deploy) = %v, %v, wanted false", got, err)
+	}
+
+	// We don't remember that they're gone, so that we notice them come
+	// back (e.g. once the Deployment's informer catches up).
+	gets := client.Gets()
+	client.Add(object("Deployment", "web", "deploy", nil))
+	got, err = r.Chain(pod("web-3", rs))
+	if err != nil {
+		t.Fatalf("Chain() = %v", err)
+	}
+	if diff := cmp.Diff([]metav1.OwnerReference{*rs, *deploy}, got); diff != "" {
+		t.Errorf("Chain (-want, +got) = %v", diff)
+	}
+	if got := client.Gets() - gets; got != 1 {
+		t.Errorf("Gets() = %d more, wanted 1 (for the Deployment)", got)
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+// globMatch returns whether s matches the pattern, in which `*` matches
+// any sequence of characters (including `/`, unlike path.Match) and `?`
+// matches any single character.
+func globMatch(pattern, s string) bool {
+	p, i := 0, 0
+	// The position of the last `*` in pattern, and of s when we saw it.
+	star, mark := -1, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, mark = p, i
+			p++
+		case star != -1:
+			// Backtrack, letting the last `*` consume one more character.
+			p = star + 1
+			mark++
+			i = mark
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// firstMatch returns the first of the patterns that s matches.
+func firstMatch(patterns []string, s string) (string, bool) {
+	for _, pattern := range patterns {
+		if globMatch(pattern, s) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
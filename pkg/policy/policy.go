@@ -0,0 +1,293 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package policy decides whether, and which parts of, a resource should
+// have its images cached.
+package policy
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+)
+
+const (
+	// DecorateAnnotationKey enables or disables caching for a resource
+	// when placed on it, or on its pod template.  It may also be placed on
+	// a Namespace, as a label or an annotation.
+	DecorateAnnotationKey = "cachier.mattmoor.io/decorate"
+
+	// ExcludeContainersAnnotationKey holds a comma-separated list of the
+	// names of containers whose images should not be cached.
+	ExcludeContainersAnnotationKey = "cachier.mattmoor.io/exclude-containers"
+)
+
+// Decision records whether we should cache a resource's images, and why.
+type Decision struct {
+	// Cache is whether Images should be created for the resource.
+	Cache bool
+
+	// Reason is a human-readable explanation of the decision.
+	Reason string
+
+	// ExcludedContainers holds the names of the resource's containers
+	// that are excluded from caching.
+	ExcludedContainers []string
+
+	// Images filters the image references that are cached.
+	Images cachierv1alpha1.ImageFilter
+
+	// Policy describes the CachePolicy or ClusterCachePolicy that applies to
+	// the resource, if any (see Policy.Source), whether or not it decides.
+	Policy string
+}
+
+// AllowsImage returns whether the decision allows caching the given image
+// reference.
+func (d *Decision) AllowsImage(image string) bool {
+	allowed, _ := d.ExplainImage(image)
+	return allowed
+}
+
+// ExplainImage is AllowsImage, but also describes the pattern that decides.
+func (d *Decision) ExplainImage(image string) (bool, string) {
+	if pattern, ok := firstMatch(d.Images.Exclude, image); ok {
+		return false, fmt.Sprintf("excluded by pattern %q", pattern)
+	}
+	if len(d.Images.Include) == 0 {
+		return true, "no include patterns"
+	}
+	if pattern, ok := firstMatch(d.Images.Include, image); ok {
+		return true, fmt.Sprintf("included by pattern %q", pattern)
+	}
+	return false, "matches no include pattern"
+}
+
+// parseMode interprets the value of the decorate annotation, returning
+// whether it was recognized.
+func parseMode(v string) (enabled bool, ok bool) {
+	switch strings.ToLower(v) {
+	case "true", "on", "enable", "enabled":
+		return true, true
+	case "false", "off", "disable", "disabled":
+		return false, true
+	}
+	return false, false
+}
+
+// Options holds what, beyond the resource itself, informs a Decision.
+type Options struct {
+	// Policy is the effective policy for the resource, if any.
+	Policy *Policy
+
+	// Namespace is the resource's Namespace, if known.
+	Namespace *v1alpha1.WithMetadata
+
+	// DefaultMode applies when nothing else decides.  Defaults to OptOut.
+	DefaultMode cachierv1alpha1.Mode
+}
+
+// Decide determines whether the given resource's images should be cached.
+// In order of precedence, this is decided by:
+//  1. the decorate annotation on the resource (or its pod template), unless
+//     the policy disallows it,
+//  2. the resource having a controlling owner,
+//  3. the decorate label or annotation on the resource's Namespace,
+//  4. the mode of the policy,
+//  5. the default mode.
+func Decide(thing *v1alpha1.WithPod, opts Options) Decision {
+	d, _ := Explain(thing, opts)
+	return d
+}
+
+// Explain is Decide, but also returns the path to the Decision: what each
+// of the rules that it consulted found, in order.
+func Explain(thing *v1alpha1.WithPod, opts Options) (Decision, []string) {
+	var path []string
+	step := func(format string, args ...interface{}) {
+		path = append(path, fmt.Sprintf(format, args...))
+	}
+
+	p := opts.Policy
+	d := Decision{
+		ExcludedContainers: excludedContainers(thing),
+	}
+	if p != nil {
+		d.Images = p.Spec.Images
+		d.Policy = p.Source
+	}
+
+	if p.allowsAnnotations() {
+		// Check to see whether this resource has explicitly enabled or
+		// disabled caching.
+		if v, ok := thing.Annotations[DecorateAnnotationKey]; ok {
+			if enabled, ok := parseMode(v); ok {
+				d.Cache = enabled
+				d.Reason = fmt.Sprintf("resource annotated %s=%s", DecorateAnnotationKey, v)
+				step("%s: decides", d.Reason)
+				return d, path
+			}
+			// Proceed with default behavior
+			step("resource annotated %s=%s: not a mode, ignored", DecorateAnnotationKey, v)
+		} else {
+			step("resource not annotated %s", DecorateAnnotationKey)
+		}
+
+		// The pod template may disable caching too.  Pod templates are copied
+		// into the resources a controller creates (e.g. ReplicaSets), so an
+		// "enable" here doesn't override the owner heuristic below.
+		if v, ok := thing.Spec.Template.Annotations[DecorateAnnotationKey]; ok {
+			if enabled, ok := parseMode(v); ok && !enabled {
+				d.Reason = fmt.Sprintf("pod template annotated %s=%s", DecorateAnnotationKey, v)
+				step("%s: decides", d.Reason)
+				return d, path
+			}
+			step("pod template annotated %s=%s: only disabling decides", DecorateAnnotationKey, v)
+		} else {
+			step("pod template not annotated %s", DecorateAnnotationKey)
+		}
+	} else {
+		step("%s disallows annotation overrides: %s annotations ignored", p.Source, DecorateAnnotationKey)
+	}
+
+	// By heuristic, we only apply caching to objects without a controlling
+	// OwnerReferences. This keeps us from applying caching to ReplicaSet
+	// when Deployment is the more appropriate target (for example).
+	if owner := metav1.GetControllerOf(thing); owner != nil {
+		d.Reason = fmt.Sprintf("controlled by %s %q", owner.Kind, owner.Name)
+		step("%s: decides, since its owner is cached instead", d.Reason)
+		return d, path
+	}
+	step("no controlling owner")
+
+	// Check to see whether the namespace has enabled or disabled caching,
+	// with a label taking precedence over an annotation.
+	if ns := opts.Namespace; ns != nil {
+		for _, x := range []struct {
+			how string
+			m   map[string]string
+		}{{"labeled", ns.Labels}, {"annotated", ns.Annotations}} {
+			if v, ok := x.m[DecorateAnnotationKey]; ok {
+				if enabled, ok := parseMode(v); ok {
+					d.Cache = enabled
+					d.Reason = fmt.Sprintf("namespace %s %s=%s", x.how, DecorateAnnotationKey, v)
+					step("%s: decides", d.Reason)
+					return d, path
+				}
+				step("namespace %s %s=%s: not a mode, ignored", x.how, DecorateAnnotationKey, v)
+			}
+		}
+		step("namespace not labeled or annotated %s", DecorateAnnotationKey)
+	} else {
+		step("namespace unknown: its label and annotation aren't consulted")
+	}
+
+	if p != nil {
+		mode := p.modeFor(thing.GetGroupVersionKind())
+		switch mode {
+		case cachierv1alpha1.ModeOptIn:
+			d.Reason = fmt.Sprintf("%s requires opting in", p.Source)
+		default:
+			d.Cache = true
+			d.Reason = fmt.Sprintf("cached per %s", p.Source)
+		}
+		step("%s applies, with mode %s: decides", p.Source, mode)
+		return d, path
+	}
+	step("no CachePolicy or ClusterCachePolicy applies")
+
+	switch opts.DefaultMode {
+	case cachierv1alpha1.ModeOptIn:
+		d.Reason = "caching requires opting in"
+	default:
+		// We cache by default
+		d.Cache = true
+		d.Reason = "cached by default"
+	}
+	mode := opts.DefaultMode
+	if mode == "" {
+		mode = cachierv1alpha1.ModeOptOut
+	}
+	step("default mode %s: decides", mode)
+	return d, path
+}
+
+// NamespaceChanged returns whether the change to a Namespace may affect the
+// decisions for its resources.
+func NamespaceChanged(old, new *v1alpha1.WithMetadata) bool {
+	return !reflect.DeepEqual(old.Labels, new.Labels) ||
+		old.Annotations[DecorateAnnotationKey] != new.Annotations[DecorateAnnotationKey]
+}
+
+// ExcludedContainerNames returns the names of the containers that are
+// excluded via annotations on the resource or its pod template.
+func ExcludedContainerNames(thing *v1alpha1.WithPod) sets.String {
+	excluded := sets.NewString()
+	for _, annos := range []map[string]string{thing.Annotations, thing.Spec.Template.Annotations} {
+		for _, name := range strings.Split(annos[ExcludeContainersAnnotationKey], ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				excluded.Insert(name)
+			}
+		}
+	}
+	return excluded
+}
+
+// excludedContainers returns the sorted names of the resource's containers
+// that are excluded via annotations on the resource or its pod template.
+func excludedContainers(thing *v1alpha1.WithPod) []string {
+	excluded := ExcludedContainerNames(thing)
+	if excluded.Len() == 0 {
+		return nil
+	}
+
+	var names []string
+	for _, c := range thing.Spec.Template.Spec.Containers {
+		if excluded.Has(c.Name) {
+			names = append(names, c.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply returns a copy of the resource without the containers excluded by
+// the decision.  Only the list of containers is new; the containers and
+// everything else are thing's, so callers must treat both as read-only.
+func Apply(thing *v1alpha1.WithPod, d Decision) *v1alpha1.WithPod {
+	if len(d.ExcludedContainers) == 0 {
+		return thing
+	}
+	excluded := sets.NewString(d.ExcludedContainers...)
+
+	out := *thing
+	containers := make([]corev1.Container, 0, len(thing.Spec.Template.Spec.Containers))
+	for _, c := range thing.Spec.Template.Spec.Containers {
+		if !excluded.Has(c.Name) {
+			containers = append(containers, c)
+		}
+	}
+	out.Spec.Template.Spec.Containers = containers
+	return &out
+}
@@ -0,0 +1,427 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+)
+
+func withPod(annos, templateAnnos map[string]string, owners ...metav1.OwnerReference) *v1alpha1.WithPod {
+	return &v1alpha1.WithPod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "foo",
+			Namespace:       "bar",
+			Annotations:     annos,
+			OwnerReferences: owners,
+		},
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+		},
+		Spec: v1alpha1.WithPodSpec{
+			Template: v1alpha1.PodSpecable{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: templateAnnos,
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "app",
+						Image: "busybox",
+					}, {
+						Name:  "profiler",
+						Image: "profiler",
+					}, {
+						Name:  "debug",
+						Image: "debug",
+					}},
+				},
+			},
+		},
+	}
+}
+
+func namespace(labels, annos map[string]string) *v1alpha1.WithMetadata {
+	return &v1alpha1.WithMetadata{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "bar",
+			Labels:      labels,
+			Annotations: annos,
+		},
+	}
+}
+
+func TestDecide(t *testing.T) {
+	boolTrue := true
+	owner := metav1.OwnerReference{
+		Kind:       "Deployment",
+		Name:       "foo",
+		Controller: &boolTrue,
+	}
+
+	boolFalse := false
+	optIn := &Policy{
+		Source: `CachePolicy "opt-in"`,
+		Spec: cachierv1alpha1.CachePolicySpec{
+			Mode: cachierv1alpha1.ModeOptIn,
+			Images: cachierv1alpha1.ImageFilter{
+				Exclude: []string{"gcr.io/private/*"},
+			},
+		},
+	}
+	locked := &Policy{
+		Source: `ClusterCachePolicy "locked"`,
+		Spec: cachierv1alpha1.CachePolicySpec{
+			Mode:                    cachierv1alpha1.ModeOptIn,
+			AllowAnnotationOverride: &boolFalse,
+			Overrides: []cachierv1alpha1.ResourceOverride{{
+				Resource: "Deployment.apps",
+				Mode:     cachierv1alpha1.ModeOptOut,
+			}},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		thing       *v1alpha1.WithPod
+		policy      *Policy
+		namespace   *v1alpha1.WithMetadata
+		defaultMode cachierv1alpha1.Mode
+		want        Decision
+	}{{
+		name:  "default",
+		thing: withPod(nil, nil),
+		want: Decision{
+			Cache:  true,
+			Reason: "cached by default",
+		},
+	}, {
+		name:  "controlled",
+		thing: withPod(nil, nil, owner),
+		want: Decision{
+			Reason: `controlled by Deployment "foo"`,
+		},
+	}, {
+		name: "disabled on resource",
+		thing: withPod(map[string]string{
+			DecorateAnnotationKey: "disable",
+		}, nil),
+		want: Decision{
+			Reason: "resource annotated cachier.mattmoor.io/decorate=disable",
+		},
+	}, {
+		name: "enabled on controlled resource",
+		thing: withPod(map[string]string{
+			DecorateAnnotationKey: "On",
+		}, nil, owner),
+		want: Decision{
+			Cache:  true,
+			Reason: "resource annotated cachier.mattmoor.io/decorate=On",
+		},
+	}, {
+		name: "unrecognized on resource",
+		thing: withPod(map[string]string{
+			DecorateAnnotationKey: "maybe",
+		}, nil),
+		want: Decision{
+			Cache:  true,
+			Reason: "cached by default",
+		},
+	}, {
+		name: "disabled on template",
+		thing: withPod(nil, map[string]string{
+			DecorateAnnotationKey: "false",
+		}),
+		want: Decision{
+			Reason: "pod template annotated cachier.mattmoor.io/decorate=false",
+		},
+	}, {
+		name: "enabled on template of controlled resource",
+		thing: withPod(nil, map[string]string{
+			DecorateAnnotationKey: "true",
+		}, owner),
+		want: Decision{
+			Reason: `controlled by Deployment "foo"`,
+		},
+	}, {
+		name: "resource overrides template",
+		thing: withPod(map[string]string{
+			DecorateAnnotationKey: "enabled",
+		}, map[string]string{
+			DecorateAnnotationKey: "disabled",
+		}),
+		want: Decision{
+			Cache:  true,
+			Reason: "resource annotated cachier.mattmoor.io/decorate=enabled",
+		},
+	}, {
+		name: "excluded containers",
+		thing: withPod(map[string]string{
+			ExcludeContainersAnnotationKey: "debug, missing",
+		}, map[string]string{
+			ExcludeContainersAnnotationKey: "profiler",
+		}),
+		want: Decision{
+			Cache:              true,
+			Reason:             "cached by default",
+			ExcludedContainers: []string{"debug", "profiler"},
+		},
+	}, {
+		name:   "opt-in policy",
+		thing:  withPod(nil, nil),
+		policy: optIn,
+		want: Decision{
+			Reason: `CachePolicy "opt-in" requires opting in`,
+			Images: optIn.Spec.Images,
+			Policy: optIn.Source,
+		},
+	}, {
+		name: "opted in",
+		thing: withPod(map[string]string{
+			DecorateAnnotationKey: "true",
+		}, nil),
+		policy: optIn,
+		want: Decision{
+			Cache:  true,
+			Reason: "resource annotated cachier.mattmoor.io/decorate=true",
+			Images: optIn.Spec.Images,
+			Policy: optIn.Source,
+		},
+	}, {
+		name: "annotations disallowed, with override",
+		thing: withPod(map[string]string{
+			DecorateAnnotationKey: "false",
+		}, nil),
+		policy: locked,
+		want: Decision{
+			Cache:  true,
+			Reason: `cached per ClusterCachePolicy "locked"`,
+			Policy: locked.Source,
+		},
+	}, {
+		name:   "policy doesn't override owner heuristic",
+		thing:  withPod(nil, nil, owner),
+		policy: locked,
+		want: Decision{
+			Reason: `controlled by Deployment "foo"`,
+			Policy: locked.Source,
+		},
+	}, {
+		name:        "default opt-in",
+		thing:       withPod(nil, nil),
+		defaultMode: cachierv1alpha1.ModeOptIn,
+		want: Decision{
+			Reason: "caching requires opting in",
+		},
+	}, {
+		name:        "namespace label opts in",
+		thing:       withPod(nil, nil),
+		namespace:   namespace(map[string]string{DecorateAnnotationKey: "enabled"}, nil),
+		defaultMode: cachierv1alpha1.ModeOptIn,
+		want: Decision{
+			Cache:  true,
+			Reason: "namespace labeled cachier.mattmoor.io/decorate=enabled",
+		},
+	}, {
+		name:  "namespace label beats annotation",
+		thing: withPod(nil, nil),
+		namespace: namespace(map[string]string{DecorateAnnotationKey: "false"},
+			map[string]string{DecorateAnnotationKey: "true"}),
+		want: Decision{
+			Reason: "namespace labeled cachier.mattmoor.io/decorate=false",
+		},
+	}, {
+		name:      "namespace annotation beats policy",
+		thing:     withPod(nil, nil),
+		namespace: namespace(nil, map[string]string{DecorateAnnotationKey: "disable"}),
+		policy:    locked,
+		want: Decision{
+			Reason: "namespace annotated cachier.mattmoor.io/decorate=disable",
+			Policy: locked.Source,
+		},
+	}, {
+		name: "resource beats namespace",
+		thing: withPod(map[string]string{
+			DecorateAnnotationKey: "true",
+		}, nil),
+		namespace: namespace(map[string]string{DecorateAnnotationKey: "false"}, nil),
+		want: Decision{
+			Cache:  true,
+			Reason: "resource annotated cachier.mattmoor.io/decorate=true",
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Decide(test.thing, Options{
+				Policy:      test.policy,
+				Namespace:   test.namespace,
+				DefaultMode: test.defaultMode,
+			})
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Decide (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	thing := withPod(nil, nil)
+	got := Apply(thing, Decision{
+		Cache:              true,
+		ExcludedContainers: []string{"debug", "profiler"},
+	})
+
+	want := []corev1.Container{{
+		Name:  "app",
+		Image: "busybox",
+	}}
+	if diff := cmp.Diff(want, got.Spec.Template.Spec.Containers, cmpopts.IgnoreUnexported(resource.Quantity{})); diff != "" {
+		t.Errorf("Apply (-want, +got) = %v", diff)
+	}
+	if len(thing.Spec.Template.Spec.Containers) != 3 {
+		t.Errorf("Apply() mutated its input: %v", thing.Spec.Template.Spec.Containers)
+	}
+}
+
+func TestAllowsImage(t *testing.T) {
+	d := Decision{
+		Images: cachierv1alpha1.ImageFilter{
+			Include: []string{"gcr.io/*", "busybox"},
+			Exclude: []string{"gcr.io/private/*", "*:debug-??"},
+		},
+	}
+
+	for image, want := range map[string]bool{
+		"busybox":                 true,
+		"busybox:latest":          false,
+		"gcr.io/foo/bar":          true,
+		"gcr.io/private/bar":      false,
+		"gcr.io/foo/bar:debug-12": false,
+		"gcr.io/foo/bar:debug-1":  true,
+		"docker.io/library/nginx": false,
+	} {
+		if got := d.AllowsImage(image); got != want {
+			t.Errorf("AllowsImage(%q) = %v, wanted %v", image, got, want)
+		}
+	}
+
+	if !(&Decision{}).AllowsImage("anything") {
+		t.Error("AllowsImage() = false with an empty filter, wanted true")
+	}
+}
+
+func TestExplainImage(t *testing.T) {
+	d := Decision{
+		Images: cachierv1alpha1.ImageFilter{
+			Include: []string{"gcr.io/*"},
+			Exclude: []string{"gcr.io/private/*"},
+		},
+	}
+	for image, want := range map[string]string{
+		"gcr.io/foo/bar":     `included by pattern "gcr.io/*"`,
+		"gcr.io/private/bar": `excluded by pattern "gcr.io/private/*"`,
+		"busybox":            "matches no include pattern",
+	} {
+		if _, got := d.ExplainImage(image); got != want {
+			t.Errorf("ExplainImage(%q) = %q, wanted %q", image, got, want)
+		}
+	}
+	if _, got := (&Decision{}).ExplainImage("busybox"); got != "no include patterns" {
+		t.Errorf("ExplainImage() = %q with an empty filter, wanted no include patterns", got)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	boolTrue := true
+	boolFalse := false
+
+	tests := []struct {
+		name      string
+		thing     *v1alpha1.WithPod
+		policy    *Policy
+		namespace *v1alpha1.WithMetadata
+		want      []string
+	}{{
+		name:  "default",
+		thing: withPod(nil, nil),
+		want: []string{
+			"resource not annotated cachier.mattmoor.io/decorate",
+			"pod template not annotated cachier.mattmoor.io/decorate",
+			"no controlling owner",
+			"namespace unknown: its label and annotation aren't consulted",
+			"no CachePolicy or ClusterCachePolicy applies",
+			"default mode OptOut: decides",
+		},
+	}, {
+		name:  "owned",
+		thing: withPod(map[string]string{DecorateAnnotationKey: "maybe"}, nil, metav1.OwnerReference{Kind: "Deployment", Name: "foo", Controller: &boolTrue}),
+		want: []string{
+			"resource annotated cachier.mattmoor.io/decorate=maybe: not a mode, ignored",
+			"pod template not annotated cachier.mattmoor.io/decorate",
+			`controlled by Deployment "foo": decides, since its owner is cached instead`,
+		},
+	}, {
+		name:      "namespace",
+		thing:     withPod(nil, map[string]string{DecorateAnnotationKey: "enable"}),
+		namespace: namespace(nil, map[string]string{DecorateAnnotationKey: "disable"}),
+		want: []string{
+			"resource not annotated cachier.mattmoor.io/decorate",
+			"pod template annotated cachier.mattmoor.io/decorate=enable: only disabling decides",
+			"no controlling owner",
+			"namespace annotated cachier.mattmoor.io/decorate=disable: decides",
+		},
+	}, {
+		name:  "locked policy",
+		thing: withPod(map[string]string{DecorateAnnotationKey: "disable"}, nil),
+		policy: &Policy{
+			Source: `ClusterCachePolicy "locked"`,
+			Spec: cachierv1alpha1.CachePolicySpec{
+				Mode:                    cachierv1alpha1.ModeOptIn,
+				AllowAnnotationOverride: &boolFalse,
+			},
+		},
+		namespace: namespace(nil, nil),
+		want: []string{
+			`ClusterCachePolicy "locked" disallows annotation overrides: cachier.mattmoor.io/decorate annotations ignored`,
+			"no controlling owner",
+			"namespace not labeled or annotated cachier.mattmoor.io/decorate",
+			`ClusterCachePolicy "locked" applies, with mode OptIn: decides`,
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := Options{Policy: test.policy, Namespace: test.namespace}
+			d, got := Explain(test.thing, opts)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Explain (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(Decide(test.thing, opts), d); diff != "" {
+				t.Errorf("Explain() decision differs from Decide() (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	cachierlisters "github.com/mattmoor/cachier/pkg/client/listers/cachier/v1alpha1"
+)
+
+// Policy is the effective CachePolicy or ClusterCachePolicy for a resource.
+type Policy struct {
+	// Source describes the policy, e.g. `CachePolicy "foo"`.
+	Source string
+
+	// Spec is the defaulted spec of the policy.
+	Spec cachierv1alpha1.CachePolicySpec
+}
+
+// allowsAnnotations returns whether annotations on resources may override
+// the policy.  A nil Policy allows them.
+func (p *Policy) allowsAnnotations() bool {
+	return p == nil || p.Spec.AllowAnnotationOverride == nil || *p.Spec.AllowAnnotationOverride
+}
+
+// modeFor returns the mode of the policy for the given kind.  A nil Policy
+// is OptOut.
+func (p *Policy) modeFor(gvk schema.GroupVersionKind) cachierv1alpha1.Mode {
+	if p == nil {
+		return cachierv1alpha1.ModeOptOut
+	}
+	for _, o := range p.Spec.Overrides {
+		if o.GroupKind() == gvk.GroupKind() {
+			return o.Mode
+		}
+	}
+	return p.Spec.Mode
+}
+
+// Resolver picks the effective policy for resources.  A CachePolicy in the
+// resource's namespace takes precedence over any ClusterCachePolicy, and
+// amongst several matching policies of the same kind the first by name wins.
+type Resolver struct {
+	PolicyLister cachierlisters.CachePolicyLister
+
+	// ClusterPolicyLister, when set, enables ClusterCachePolicies.
+	ClusterPolicyLister cachierlisters.ClusterCachePolicyLister
+
+	// NamespaceLister provides the labels of Namespaces for matching the
+	// namespaceSelector of ClusterCachePolicies.  Its elements must be
+	// *v1alpha1.WithMetadata.
+	NamespaceLister cache.GenericLister
+}
+
+// Resolve returns the effective policy for the given resource, or nil if no
+// policy applies to it.  Invalid policies are ignored.
+func (r *Resolver) Resolve(thing *v1alpha1.WithPod) (*Policy, error) {
+	if r == nil {
+		return nil, nil
+	}
+	set := labels.Set(thing.Labels)
+
+	policies, err := r.PolicyLister.CachePolicies(thing.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Name < policies[j].Name })
+	for _, p := range policies {
+		p = p.DeepCopy()
+		p.SetDefaults()
+		if p.Validate() != nil || !matches(p.Spec.Selector, set) {
+			continue
+		}
+		return &Policy{
+			Source: fmt.Sprintf("CachePolicy %q", p.Name),
+			Spec:   p.Spec,
+		}, nil
+	}
+
+	if r.ClusterPolicyLister == nil {
+		return nil, nil
+	}
+	clusterPolicies, err := r.ClusterPolicyLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	if len(clusterPolicies) == 0 {
+		return nil, nil
+	}
+	nsLabels, err := r.namespaceLabels(thing.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(clusterPolicies, func(i, j int) bool { return clusterPolicies[i].Name < clusterPolicies[j].Name })
+	for _, p := range clusterPolicies {
+		p = p.DeepCopy()
+		p.SetDefaults()
+		if p.Validate() != nil || !matches(p.Spec.Selector, set) || !matches(p.Spec.NamespaceSelector, nsLabels) {
+			continue
+		}
+		return &Policy{
+			Source: fmt.Sprintf("ClusterCachePolicy %q", p.Name),
+			Spec:   p.Spec,
+		}, nil
+	}
+	return nil, nil
+}
+
+// namespaceLabels returns the labels of the named Namespace.
+func (r *Resolver) namespaceLabels(name string) (labels.Set, error) {
+	ns, err := GetNamespace(r.NamespaceLister, name)
+	if err != nil || ns == nil {
+		return labels.Set{}, err
+	}
+	return labels.Set(ns.Labels), nil
+}
+
+// GetNamespace returns the named Namespace from the lister, whose elements
+// must be *v1alpha1.WithMetadata, or nil if it isn't known (or there is no
+// lister).
+func GetNamespace(lister cache.GenericLister, name string) (*v1alpha1.WithMetadata, error) {
+	if lister == nil {
+		return nil, nil
+	}
+	ns, err := lister.Get(name)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return ns.(*v1alpha1.WithMetadata), nil
+}
+
+// matches returns whether the selector matches the labels.  A nil selector
+// matches everything.
+func matches(s *metav1.LabelSelector, set labels.Set) bool {
+	if s == nil {
+		return true
+	}
+	selector, err := metav1.LabelSelectorAsSelector(s)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(set)
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	cachierlisters "github.com/mattmoor/cachier/pkg/client/listers/cachier/v1alpha1"
+)
+
+func TestResolve(t *testing.T) {
+	selector := func(key, value string) *metav1.LabelSelector {
+		return &metav1.LabelSelector{MatchLabels: map[string]string{key: value}}
+	}
+
+	tests := []struct {
+		name       string
+		labels     map[string]string
+		nsLabels   map[string]string
+		policies   []*cachierv1alpha1.CachePolicy
+		clusters   []*cachierv1alpha1.ClusterCachePolicy
+		noClusters bool
+		wantSource string
+	}{{
+		name: "no policies",
+	}, {
+		name: "namespaced beats cluster",
+		policies: []*cachierv1alpha1.CachePolicy{{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "bar", Name: "local"},
+		}},
+		clusters: []*cachierv1alpha1.ClusterCachePolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "global"},
+		}},
+		wantSource: `CachePolicy "local"`,
+	}, {
+		name: "other namespace",
+		policies: []*cachierv1alpha1.CachePolicy{{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "baz", Name: "local"},
+		}},
+		clusters: []*cachierv1alpha1.ClusterCachePolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "global"},
+		}},
+		wantSource: `ClusterCachePolicy "global"`,
+	}, {
+		name:   "first matching by name",
+		labels: map[string]string{"app": "foo"},
+		policies: []*cachierv1alpha1.CachePolicy{{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "bar", Name: "c"},
+		}, {
+			ObjectMeta: metav1.ObjectMeta{Namespace: "bar", Name: "a"},
+			Spec:       cachierv1alpha1.CachePolicySpec{Selector: selector("app", "bar")},
+		}, {
+			ObjectMeta: metav1.ObjectMeta{Namespace: "bar", Name: "b"},
+			Spec:       cachierv1alpha1.CachePolicySpec{Selector: selector("app", "foo")},
+		}},
+		wantSource: `CachePolicy "b"`,
+	}, {
+		name:     "namespace selector",
+		nsLabels: map[string]string{"env": "prod"},
+		clusters: []*cachierv1alpha1.ClusterCachePolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "a-dev"},
+			Spec:       cachierv1alpha1.CachePolicySpec{NamespaceSelector: selector("env", "dev")},
+		}, {
+			ObjectMeta: metav1.ObjectMeta{Name: "b-prod"},
+			Spec:       cachierv1alpha1.CachePolicySpec{NamespaceSelector: selector("env", "prod")},
+		}},
+		wantSource: `ClusterCachePolicy "b-prod"`,
+	}, {
+		name: "invalid policies are ignored",
+		policies: []*cachierv1alpha1.CachePolicy{{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "bar", Name: "bad"},
+			Spec:       cachierv1alpha1.CachePolicySpec{Mode: "Sometimes"},
+		}},
+		clusters: []*cachierv1alpha1.ClusterCachePolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "global"},
+		}},
+		wantSource: `ClusterCachePolicy "global"`,
+	}, {
+		name: "cluster policies disabled",
+		clusters: []*cachierv1alpha1.ClusterCachePolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "global"},
+		}},
+		noClusters: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+			policies := cache.NewIndexer(cache.MetaNamespaceKeyFunc, indexers)
+			for _, p := range test.policies {
+				policies.Add(p)
+			}
+			clusters := cache.NewIndexer(cache.MetaNamespaceKeyFunc, indexers)
+			for _, p := range test.clusters {
+				clusters.Add(p)
+			}
+			namespaces := cache.NewIndexer(cache.MetaNamespaceKeyFunc, indexers)
+			namespaces.Add(&v1alpha1.WithMetadata{
+				ObjectMeta: metav1.ObjectMeta{Name: "bar", Labels: test.nsLabels},
+			})
+
+			r := &Resolver{
+				PolicyLister:        cachierlisters.NewCachePolicyLister(policies),
+				ClusterPolicyLister: cachierlisters.NewClusterCachePolicyLister(clusters),
+				NamespaceLister:     cache.NewGenericLister(namespaces, v1alpha1.NamespacesResource.GroupResource()),
+			}
+			if test.noClusters {
+				r.ClusterPolicyLister = nil
+			}
+			thing := withPod(nil, nil)
+			thing.Labels = test.labels
+
+			got, err := r.Resolve(thing)
+			if err != nil {
+				t.Fatalf("Resolve() = %v", err)
+			}
+			gotSource := ""
+			if got != nil {
+				gotSource = got.Source
+				if got.Spec.Mode != cachierv1alpha1.ModeOptOut {
+					t.Errorf("Resolve() = %v, wanted defaulted mode", got.Spec.Mode)
+				}
+			}
+			if gotSource != test.wantSource {
+				t.Errorf("Resolve() = %q, wanted %q", gotSource, test.wantSource)
+			}
+		})
+	}
+}
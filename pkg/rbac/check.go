@@ -0,0 +1,116 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+)
+
+var selfSubjectAccessReviewsResource = authorizationv1.SchemeGroupVersion.WithResource("selfsubjectaccessreviews")
+
+// Reviewer returns whether we may do what attrs describe.
+type Reviewer func(attrs authorizationv1.ResourceAttributes) (bool, error)
+
+// SelfSubjectAccessReviewer returns a Reviewer that asks the API server,
+// via SelfSubjectAccessReviews.
+func SelfSubjectAccessReviewer(client dynamic.Interface) Reviewer {
+	return func(attrs authorizationv1.ResourceAttributes) (bool, error) {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: authorizationv1.SchemeGroupVersion.String(),
+				Kind:       "SelfSubjectAccessReview",
+			},
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attrs},
+		}
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(review)
+		if err != nil {
+			return false, err
+		}
+		got, err := client.Resource(selfSubjectAccessReviewsResource).Create(&unstructured.Unstructured{Object: u})
+		if err != nil {
+			return false, err
+		}
+		allowed, _, err := unstructured.NestedBool(got.Object, "status", "allowed")
+		return allowed, err
+	}
+}
+
+// Missing returns the permissions among rules that review denies, in the
+// given namespaces (or across the cluster when empty), described like
+// `list apps/deployments in namespace "foo"`.
+func Missing(rules []Rule, namespaces []string, review Reviewer) ([]string, error) {
+	var missing []string
+	for _, r := range rules {
+		resource, subresource := r.Resource, ""
+		if parts := strings.SplitN(r.Resource, "/", 2); len(parts) == 2 {
+			resource, subresource = parts[0], parts[1]
+		}
+		scopes := namespaces
+		switch {
+		case r.Cluster:
+			scopes = nil
+		case r.Namespace != "":
+			scopes = []string{r.Namespace}
+		}
+		if len(scopes) == 0 {
+			scopes = []string{metav1.NamespaceAll}
+		}
+
+		for _, ns := range scopes {
+			for _, verb := range r.Verbs {
+				allowed, err := review(authorizationv1.ResourceAttributes{
+					Namespace:   ns,
+					Verb:        verb,
+					Group:       r.Group,
+					Resource:    resource,
+					Subresource: subresource,
+				})
+				if err != nil {
+					return nil, err
+				}
+				if !allowed {
+					missing = append(missing, describe(verb, r, ns))
+				}
+			}
+		}
+	}
+	return missing, nil
+}
+
+// describe describes the permission to do verb on the rule's resource in
+// the given namespace.
+func describe(verb string, r Rule, ns string) string {
+	resource := r.Resource
+	if r.Group != "" {
+		resource = r.Group + "/" + r.Resource
+	}
+	switch {
+	case r.Cluster:
+		return fmt.Sprintf("%s %s", verb, resource)
+	case ns == metav1.NamespaceAll:
+		return fmt.Sprintf("%s %s in all namespaces", verb, resource)
+	default:
+		return fmt.Sprintf("%s %s in namespace %q", verb, resource, ns)
+	}
+}
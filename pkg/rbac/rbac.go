@@ -0,0 +1,298 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rbac works out the permissions that the controller needs for its
+// configuration, so that it can run without cluster-admin.  It renders them
+// as ClusterRoles or Roles, and checks that the controller has them with
+// SelfSubjectAccessReviews.
+package rbac
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/dryrun"
+	"github.com/mattmoor/cachier/pkg/sharding"
+)
+
+// WellKnownOwners are the built-in resources that commonly appear in the
+// owner chains of Pods, which we read when following those chains.
+var WellKnownOwners = []schema.GroupVersionResource{
+	{Group: "apps", Version: "v1", Resource: "deployments"},
+	{Group: "apps", Version: "v1", Resource: "replicasets"},
+	{Group: "apps", Version: "v1", Resource: "statefulsets"},
+	{Group: "apps", Version: "v1", Resource: "daemonsets"},
+	{Group: "batch", Version: "v1", Resource: "jobs"},
+	{Group: "batch", Version: "v1beta1", Resource: "cronjobs"},
+}
+
+var eventsResource = schema.GroupVersionResource{Version: "v1", Resource: "events"}
+
+// Options describes what the controller is configured to do.
+type Options struct {
+	// Resources are those whose images we cache, and on which we report our
+	// status annotation.
+	Resources []schema.GroupVersionResource
+
+	// Owners are those that we read when following the owner chains of
+	// Pods, which is only done when Pods are watched.
+	Owners []schema.GroupVersionResource
+
+	// Pods is whether we watch Pods.
+	Pods bool
+
+	// Credentials is whether we watch ServiceAccounts and Secrets to
+	// resolve pull credentials.
+	Credentials bool
+
+	// ValidateImages is whether we read pull secrets on demand to check
+	// images with their registries, and report Events on resources.
+	ValidateImages bool
+
+	// CachePolicies and ClusterCachePolicies are whether we watch those.
+	CachePolicies        bool
+	ClusterCachePolicies bool
+
+	// Namespaces is whether we watch Namespaces.
+	Namespaces bool
+
+	// CachedImageSets and PrewarmSchedules are whether we reconcile those.
+	CachedImageSets  bool
+	PrewarmSchedules bool
+
+	// DryRunReports is whether we write dry-run reports to ConfigMaps.
+	DryRunReports bool
+
+	// ShardNamespace, when set, holds the Leases with which replicas share
+	// namespaces.
+	ShardNamespace string
+}
+
+// Rule is a permission that the controller needs.
+type Rule struct {
+	Group    string
+	Resource string
+	Verbs    []string
+
+	// Cluster is whether Resource is cluster-scoped.
+	Cluster bool
+
+	// Namespace, when set, is the only namespace in which the rule is
+	// needed.  Otherwise, namespaced rules are needed in each of the
+	// namespaces that we watch.
+	Namespace string
+}
+
+// Rules returns the permissions that the controller needs with the given
+// Options, in a deterministic order.
+func Rules(opts Options) []Rule {
+	var rules []Rule
+	add := func(gvr schema.GroupVersionResource, verbs ...string) {
+		rules = append(rules, Rule{Group: gvr.Group, Resource: gvr.Resource, Verbs: verbs})
+	}
+
+	add(caching.SchemeGroupVersion.WithResource("images"),
+		"list", "watch", "create", "delete", "deletecollection")
+	for _, gvr := range opts.Resources {
+		add(gvr, "list", "watch", "patch")
+	}
+	if opts.Pods {
+		add(v1alpha1.PodsResource, "list", "watch")
+		for _, gvr := range opts.Owners {
+			add(gvr, "get")
+		}
+	}
+	if opts.Credentials {
+		add(v1alpha1.ServiceAccountsResource, "list", "watch")
+		add(v1alpha1.SecretsResource, "list", "watch")
+	}
+	if opts.ValidateImages {
+		add(v1alpha1.SecretsResource, "get")
+		add(eventsResource, "create")
+	}
+	if opts.CachePolicies {
+		add(cachierv1alpha1.SchemeGroupVersion.WithResource("cachepolicies"), "list", "watch")
+	}
+	if opts.CachedImageSets {
+		add(cachierv1alpha1.SchemeGroupVersion.WithResource("cachedimagesets"), "list", "watch")
+		add(cachierv1alpha1.SchemeGroupVersion.WithResource("cachedimagesets/status"), "update")
+	}
+	if opts.PrewarmSchedules {
+		add(cachierv1alpha1.SchemeGroupVersion.WithResource("prewarmschedules"), "list", "watch")
+		add(cachierv1alpha1.SchemeGroupVersion.WithResource("prewarmschedules/status"), "update")
+	}
+	if opts.ClusterCachePolicies {
+		add(cachierv1alpha1.SchemeGroupVersion.WithResource("clustercachepolicies"), "list", "watch")
+		rules[len(rules)-1].Cluster = true
+	}
+	if opts.Namespaces {
+		add(v1alpha1.NamespacesResource, "list", "watch")
+		rules[len(rules)-1].Cluster = true
+	}
+	if opts.DryRunReports {
+		add(dryrun.ConfigMapsResource, "get", "create", "update")
+	}
+	if opts.ShardNamespace != "" {
+		add(sharding.LeasesResource, "get", "list", "create", "update", "delete")
+		rules[len(rules)-1].Namespace = opts.ShardNamespace
+	}
+	return merge(rules)
+}
+
+// merge combines the verbs of the rules for the same resource in the same
+// scope, keeping the order in which each first appears.
+func merge(rules []Rule) []Rule {
+	type key struct {
+		group, resource, namespace string
+		cluster                    bool
+	}
+	index := make(map[key]int, len(rules))
+	out := make([]Rule, 0, len(rules))
+	for _, r := range rules {
+		k := key{r.Group, r.Resource, r.Namespace, r.Cluster}
+		i, ok := index[k]
+		if !ok {
+			index[k] = len(out)
+			out = append(out, Rule{Group: r.Group, Resource: r.Resource, Cluster: r.Cluster, Namespace: r.Namespace})
+			i = len(out) - 1
+		}
+		for _, verb := range r.Verbs {
+			if !contains(out[i].Verbs, verb) {
+				out[i].Verbs = append(out[i].Verbs, verb)
+			}
+		}
+	}
+	return out
+}
+
+func contains(l []string, s string) bool {
+	for _, x := range l {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderOptions configures the RBAC resources that Render returns.
+type RenderOptions struct {
+	// Name names the ClusterRoles, Roles, and their bindings.
+	Name string
+
+	// Subject is bound to the roles, e.g. the controller's ServiceAccount.
+	Subject rbacv1.Subject
+
+	// Namespaces are those that the controller watches, or all of them when
+	// empty.
+	Namespaces []string
+}
+
+// Render returns the roles (and their bindings) that grant the given rules.
+// When the controller watches all namespaces, its namespaced rules are
+// granted by a ClusterRole, and otherwise by a Role in each of the watched
+// namespaces.  Cluster-scoped rules always need a ClusterRole, and rules for
+// a particular namespace a Role there.
+func Render(rules []Rule, opts RenderOptions) []runtime.Object {
+	var cluster []rbacv1.PolicyRule
+	namespaced := map[string][]rbacv1.PolicyRule{}
+	for _, r := range rules {
+		pr := rbacv1.PolicyRule{
+			APIGroups: []string{r.Group},
+			Resources: []string{r.Resource},
+			Verbs:     r.Verbs,
+		}
+		switch {
+		case r.Cluster:
+			cluster = append(cluster, pr)
+		case r.Namespace != "":
+			namespaced[r.Namespace] = append(namespaced[r.Namespace], pr)
+		case len(opts.Namespaces) == 0:
+			cluster = append(cluster, pr)
+		default:
+			for _, ns := range opts.Namespaces {
+				namespaced[ns] = append(namespaced[ns], pr)
+			}
+		}
+	}
+
+	var objs []runtime.Object
+	roleRef := func(kind string) rbacv1.RoleRef {
+		return rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: kind, Name: opts.Name}
+	}
+	if len(cluster) > 0 {
+		objs = append(objs, &rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: opts.Name},
+			Rules:      cluster,
+		}, &rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: opts.Name},
+			Subjects:   []rbacv1.Subject{opts.Subject},
+			RoleRef:    roleRef("ClusterRole"),
+		})
+	}
+	namespaces := make([]string, 0, len(namespaced))
+	for ns := range namespaced {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	for _, ns := range namespaces {
+		objs = append(objs, &rbacv1.Role{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+			ObjectMeta: metav1.ObjectMeta{Name: opts.Name, Namespace: ns},
+			Rules:      namespaced[ns],
+		}, &rbacv1.RoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: opts.Name, Namespace: ns},
+			Subjects:   []rbacv1.Subject{opts.Subject},
+			RoleRef:    roleRef("Role"),
+		})
+	}
+	return objs
+}
+
+// ToYAML serializes the given objects as a multi-document YAML stream.
+func ToYAML(objs []runtime.Object) ([]byte, error) {
+	var buf bytes.Buffer
+	for i, obj := range objs {
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		// Leave out the zero creationTimestamp that ObjectMeta serializes.
+		if md, ok := u["metadata"].(map[string]interface{}); ok {
+			delete(md, "creationTimestamp")
+		}
+		b, err := yaml.Marshal(u)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(b)
+	}
+	return buf.Bytes(), nil
+}
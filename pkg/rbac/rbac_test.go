@@ -0,0 +1,229 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var deployments = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+func TestRules(t *testing.T) {
+	got := Rules(Options{
+		Resources:            []schema.GroupVersionResource{deployments},
+		Credentials:          true,
+		ValidateImages:       true,
+		CachedImageSets:      true,
+		ClusterCachePolicies: true,
+		ShardNamespace:       "cachier-system",
+	})
+	want := []Rule{{
+		Group:    "caching.internal.knative.dev",
+		Resource: "images",
+		Verbs:    []string{"list", "watch", "create", "delete", "deletecollection"},
+	}, {
+		Group:    "apps",
+		Resource: "deployments",
+		Verbs:    []string{"list", "watch", "patch"},
+	}, {
+		Resource: "serviceaccounts",
+		Verbs:    []string{"list", "watch"},
+	}, {
+		// The verbs for Secrets are merged.
+		Resource: "secrets",
+		Verbs:    []string{"list", "watch", "get"},
+	}, {
+		Resource: "events",
+		Verbs:    []string{"create"},
+	}, {
+		Group:    "cachier.mattmoor.io",
+		Resource: "cachedimagesets",
+		Verbs:    []string{"list", "watch"},
+	}, {
+		Group:    "cachier.mattmoor.io",
+		Resource: "cachedimagesets/status",
+		Verbs:    []string{"update"},
+	}, {
+		Group:    "cachier.mattmoor.io",
+		Resource: "clustercachepolicies",
+		Verbs:    []string{"list", "watch"},
+		Cluster:  true,
+	}, {
+		Group:     "coordination.k8s.io",
+		Resource:  "leases",
+		Verbs:     []string{"get", "list", "create", "update", "delete"},
+		Namespace: "cachier-system",
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Rules() (-want, +got) = %v", diff)
+	}
+}
+
+func TestRender(t *testing.T) {
+	rules := Rules(Options{
+		Resources:      []schema.GroupVersionResource{deployments},
+		ShardNamespace: "cachier-system",
+	})
+	subject := rbacv1.Subject{Kind: "ServiceAccount", Namespace: "cachier-system", Name: "cachier-controller"}
+
+	tests := []struct {
+		name       string
+		namespaces []string
+		want       []string
+	}{{
+		name: "cluster-wide",
+		want: []string{
+			"ClusterRole /cachier-controller",
+			"ClusterRoleBinding /cachier-controller",
+			"Role cachier-system/cachier-controller",
+			"RoleBinding cachier-system/cachier-controller",
+		},
+	}, {
+		name:       "namespaced",
+		namespaces: []string{"foo", "bar"},
+		want: []string{
+			"Role bar/cachier-controller",
+			"RoleBinding bar/cachier-controller",
+			"Role cachier-system/cachier-controller",
+			"RoleBinding cachier-system/cachier-controller",
+			"Role foo/cachier-controller",
+			"RoleBinding foo/cachier-controller",
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objs := Render(rules, RenderOptions{
+				Name:       "cachier-controller",
+				Subject:    subject,
+				Namespaces: test.namespaces,
+			})
+			var got []string
+			for _, obj := range objs {
+				m, err := meta.Accessor(obj)
+				if err != nil {
+					t.Fatalf("Accessor() = %v", err)
+				}
+				got = append(got, obj.GetObjectKind().GroupVersionKind().Kind+" "+m.GetNamespace()+"/"+m.GetName())
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Render() (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestRenderNamespacedRules(t *testing.T) {
+	rules := Rules(Options{
+		Resources:      []schema.GroupVersionResource{deployments},
+		ShardNamespace: "foo",
+	})
+	objs := Render(rules, RenderOptions{Name: "cachier-controller", Namespaces: []string{"foo", "bar"}})
+
+	// The Leases are only granted in the shard namespace, alongside the
+	// rules for the namespaces we watch.
+	got := map[string][]string{}
+	for _, obj := range objs {
+		if role, ok := obj.(*rbacv1.Role); ok {
+			for _, r := range role.Rules {
+				got[role.Namespace] = append(got[role.Namespace], r.Resources...)
+			}
+		}
+	}
+	want := map[string][]string{
+		"bar": {"images", "deployments"},
+		"foo": {"images", "deployments", "leases"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Render() rules (-want, +got) = %v", diff)
+	}
+}
+
+func TestToYAML(t *testing.T) {
+	objs := Render(Rules(Options{}), RenderOptions{Name: "cachier-controller", Namespaces: []string{"foo"}})
+	b, err := ToYAML(objs)
+	if err != nil {
+		t.Fatalf("ToYAML() = %v", err)
+	}
+	got := string(b)
+	for _, want := range []string{"kind: Role\n", "---\n", "kind: RoleBinding\n", "namespace: foo\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("ToYAML() = %s, wanted it to contain %q", got, want)
+		}
+	}
+	if strings.Contains(got, "creationTimestamp") {
+		t.Errorf("ToYAML() = %s, wanted no creationTimestamp", got)
+	}
+}
+
+func TestMissing(t *testing.T) {
+	rules := Rules(Options{
+		Resources:       []schema.GroupVersionResource{deployments},
+		Namespaces:      true,
+		CachedImageSets: true,
+		ShardNamespace:  "cachier-system",
+	})
+
+	var reviewed []authorizationv1.ResourceAttributes
+	review := func(attrs authorizationv1.ResourceAttributes) (bool, error) {
+		reviewed = append(reviewed, attrs)
+		// Deny patching Deployments in bar, and listing Namespaces.
+		switch {
+		case attrs.Resource == "deployments" && attrs.Verb == "patch" && attrs.Namespace == "bar":
+			return false, nil
+		case attrs.Resource == "namespaces" && attrs.Verb == "list":
+			return false, nil
+		}
+		return true, nil
+	}
+
+	got, err := Missing(rules, []string{"foo", "bar"}, review)
+	if err != nil {
+		t.Fatalf("Missing() = %v", err)
+	}
+	want := []string{
+		`patch apps/deployments in namespace "bar"`,
+		"list namespaces",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Missing() (-want, +got) = %v", diff)
+	}
+
+	for _, attrs := range reviewed {
+		switch attrs.Resource {
+		case "namespaces":
+			if attrs.Namespace != "" {
+				t.Errorf("Reviewed %v in namespace %q, wanted cluster-wide", attrs.Resource, attrs.Namespace)
+			}
+		case "leases":
+			if attrs.Namespace != "cachier-system" {
+				t.Errorf("Reviewed %v in namespace %q, wanted cachier-system", attrs.Resource, attrs.Namespace)
+			}
+		case "cachedimagesets":
+			if attrs.Verb == "update" && attrs.Subresource != "status" {
+				t.Errorf("Reviewed update of %v, wanted the status subresource", attrs.Resource)
+			}
+		}
+	}
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cachedimageset
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	cachingclientset "github.com/knative/caching/pkg/client/clientset/versioned"
+	cachinginformers "github.com/knative/caching/pkg/client/informers/externalversions/caching/v1alpha1"
+	cachinglisters "github.com/knative/caching/pkg/client/listers/caching/v1alpha1"
+	"github.com/knative/pkg/controller"
+	"github.com/knative/pkg/logging"
+	"github.com/knative/pkg/logging/logkey"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/budget"
+	cachierclientset "github.com/mattmoor/cachier/pkg/client/clientset/versioned"
+	cachierinformers "github.com/mattmoor/cachier/pkg/client/informers/externalversions/cachier/v1alpha1"
+	cachierlisters "github.com/mattmoor/cachier/pkg/client/listers/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/fairqueue"
+	"github.com/mattmoor/cachier/pkg/reconciler/cachier/resources"
+	"github.com/mattmoor/cachier/pkg/reconciler/images"
+	"github.com/mattmoor/cachier/pkg/sharding"
+)
+
+const controllerAgentName = "cachedimageset-controller"
+
+// Reconciler is the controller implementation for CachedImageSet resources
+type Reconciler struct {
+	// For creating/deleting caching resources, and updating our status.
+	cachingclient cachingclientset.Interface
+	cachierclient cachierclientset.Interface
+
+	// For reading the state of the world.
+	lister      cachierlisters.CachedImageSetLister
+	imageLister cachinglisters.ImageLister
+
+	// For only reconciling the namespaces that this replica owns, when
+	// sharding.
+	shard *sharding.Sharder
+
+	// For limiting the rate at which we create Images for each registry,
+	// and revisiting sets once their Images may be created.
+	budget       *budget.Budget
+	enqueueAfter func(key string, d time.Duration)
+
+	// Sugared logger is easier to use but is not as performant as the
+	// raw logger. In performance critical paths, call logger.Desugar()
+	// and use the returned raw logger instead. In addition to the
+	// performance benefits, raw logger also preserves type-safety at
+	// the expense of slightly greater verbosity.
+	Logger *zap.SugaredLogger
+}
+
+// Check that we implement the controller.Reconciler interface.
+var _ controller.Reconciler = (*Reconciler)(nil)
+
+// NewController returns a new CachedImageSet controller
+func NewController(
+	logger *zap.SugaredLogger,
+	cachierClient cachierclientset.Interface,
+	setInformer cachierinformers.CachedImageSetInformer,
+	cachingClient cachingclientset.Interface,
+	imageInformer cachinginformers.ImageInformer,
+	imageBudget *budget.Budget,
+	shard *sharding.Sharder,
+) *fairqueue.Impl {
+
+	r := &Reconciler{
+		cachingclient: cachingClient,
+		cachierclient: cachierClient,
+		lister:        setInformer.Lister(),
+		imageLister:   imageInformer.Lister(),
+		budget:        imageBudget,
+		shard:         shard,
+		// Enrich the logs with controller name
+		Logger: logger.Named(controllerAgentName).
+			With(zap.String(logkey.ControllerType, controllerAgentName)),
+	}
+	impl, resyncs := fairqueue.NewImpl(r, r.Logger, "CachedImageSets")
+	r.enqueueAfter = func(key string, d time.Duration) {
+		impl.WorkQueue.AddAfter(key, d)
+	}
+
+	r.Logger.Info("Setting up event handlers")
+
+	if shard != nil {
+		// Whenever the replicas change, enqueue the CachedImageSets in the
+		// namespaces that we now own.
+		shard.AddListener(func() {
+			objs, err := setInformer.Lister().List(labels.Everything())
+			if err != nil {
+				r.Logger.Errorf("Error listing CachedImageSets: %v", err)
+				return
+			}
+			for _, obj := range objs {
+				if shard.Owns(obj.Namespace) {
+					resyncs.Enqueue(obj)
+				}
+			}
+		})
+	}
+
+	setInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    impl.Enqueue,
+		UpdateFunc: fairqueue.PassNew(impl.Enqueue, resyncs.Enqueue),
+	})
+
+	// Whenever one of our Images changes (e.g. becomes ready), enqueue the
+	// CachedImageSet that owns it to update its status.
+	imageInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: controller.Filter(cachierv1alpha1.SchemeGroupVersion.WithKind("CachedImageSet")),
+		Handler: cache.ResourceEventHandlerFuncs{
+			AddFunc:    impl.EnqueueControllerOf,
+			UpdateFunc: fairqueue.PassNew(impl.EnqueueControllerOf, resyncs.EnqueueControllerOf),
+			DeleteFunc: impl.EnqueueControllerOf,
+		},
+	})
+
+	return impl
+}
+
+// Reconcile implements controller.Reconciler
+func (c *Reconciler) Reconcile(ctx context.Context, key string) error {
+	logger := logging.FromContext(ctx)
+	// Convert the namespace/name string into a distinct namespace and name
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		logger.Errorf("invalid resource key: %s", key)
+		return nil
+	}
+	if !c.shard.Owns(namespace) {
+		// Another replica reconciles this namespace.
+		return nil
+	}
+
+	// Get the CachedImageSet resource with this namespace/name
+	original, err := c.lister.CachedImageSets(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		logger.Errorf("CachedImageSet %q in work queue no longer exists", key)
+		c.budget.Forget(controllerAgentName + "/" + key)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	// Don't modify the informer's copy.
+	set := original.DeepCopy()
+
+	if err := set.Validate(); err != nil {
+		logger.Errorf("Invalid CachedImageSet %q: %v", key, err)
+		return nil
+	}
+
+	thing := resources.MakeWithPod(set.GetGroupVersionKind(), set.ObjectMeta, corev1.PodSpec{
+		ServiceAccountName: set.Spec.ServiceAccountName,
+		ImagePullSecrets:   set.Spec.ImagePullSecrets,
+	}, set.Spec.Images)
+	imgs, retry, err := images.Reconcile(c.cachingclient.CachingV1alpha1(), c.imageLister,
+		c.budget, controllerAgentName+"/"+key, thing)
+	if err != nil {
+		return err
+	}
+	if retry > 0 {
+		// Come back for the Images that the budget deferred.
+		c.enqueueAfter(key, retry)
+	}
+
+	set.Status.ObservedGeneration = set.Generation
+	set.Status.PropagateImages(imageStates(imgs))
+	if equality.Semantic.DeepEqual(original.Status, set.Status) {
+		return nil
+	}
+	_, err = c.cachierclient.CachierV1alpha1().CachedImageSets(namespace).UpdateStatus(set)
+	return err
+}
+
+// imageStates summarizes the readiness of each of the Images, in a
+// deterministic order to make testing sane.
+func imageStates(imgs map[string]*caching.Image) []cachierv1alpha1.CachedImageState {
+	order := make([]string, 0, len(imgs))
+	for ref := range imgs {
+		order = append(order, ref)
+	}
+	sort.Strings(order)
+
+	states := make([]cachierv1alpha1.CachedImageState, 0, len(order))
+	for _, ref := range order {
+		img := imgs[ref]
+		if img == nil {
+			states = append(states, cachierv1alpha1.CachedImageState{
+				Image:   ref,
+				Ready:   corev1.ConditionUnknown,
+				Message: "Waiting for the budget of its registry",
+			})
+			continue
+		}
+		states = append(states, imageState(img))
+	}
+	return states
+}
+
+// imageState summarizes the readiness of the Image.
+func imageState(img *caching.Image) cachierv1alpha1.CachedImageState {
+	state := cachierv1alpha1.CachedImageState{
+		Image: img.Spec.Image,
+		Name:  img.Name,
+		Ready: corev1.ConditionUnknown,
+	}
+	for _, cond := range img.Status.Conditions {
+		if cond.Type == caching.ImageConditionReady {
+			if cond.Status != "" {
+				state.Ready = cond.Status
+			}
+			state.Message = cond.Message
+		}
+	}
+	return state
+}
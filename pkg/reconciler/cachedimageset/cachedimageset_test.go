@@ -0,0 +1,210 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cachedimageset
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	"github.com/knative/pkg/logging"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/reconciler/cachier/resources"
+	rtesting "github.com/mattmoor/cachier/pkg/reconciler/testing"
+)
+
+func set(generation int64, images ...string) *cachierv1alpha1.CachedImageSet {
+	return &cachierv1alpha1.CachedImageSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "ns",
+			Name:       "base",
+			UID:        "base-uid",
+			Generation: generation,
+		},
+		Spec: cachierv1alpha1.CachedImageSetSpec{Images: images},
+	}
+}
+
+// image returns the Image that the set has for ref, named name, with the
+// given readiness (if any).
+func image(s *cachierv1alpha1.CachedImageSet, ref, name string, ready corev1.ConditionStatus) *caching.Image {
+	thing := resources.MakeWithPod(s.GetGroupVersionKind(), s.ObjectMeta, corev1.PodSpec{}, s.Spec.Images)
+	img := resources.MakeImages(thing)[ref]
+	img.Name = name
+	if ready != "" {
+		img.Status.Conditions = []caching.ImageCondition{{
+			Type:   caching.ImageConditionReady,
+			Status: ready,
+		}}
+	}
+	return &img
+}
+
+func TestReconcile(t *testing.T) {
+	const (
+		base  = "gcr.io/foo/base:v1"
+		tools = "gcr.io/foo/tools:v1"
+	)
+
+	tests := []struct {
+		name        string
+		set         *cachierv1alpha1.CachedImageSet
+		images      []*caching.Image
+		wantCreated []string
+		wantDeleted []string
+		// wantStatus is nil when the status shouldn't be updated.
+		wantStatus *cachierv1alpha1.CachedImageSetStatus
+	}{{
+		name:        "create",
+		set:         set(1, tools, base),
+		wantCreated: []string{"ns/base-00-0", "ns/base-01-0"},
+		wantStatus: &cachierv1alpha1.CachedImageSetStatus{
+			ObservedGeneration: 1,
+			Conditions: []cachierv1alpha1.CachedImageSetCondition{{
+				Type:    cachierv1alpha1.CachedImageSetConditionReady,
+				Status:  corev1.ConditionUnknown,
+				Reason:  "ImagesPending",
+				Message: "waiting for 2 of 2 images",
+			}},
+			Images: []cachierv1alpha1.CachedImageState{
+				{Image: base, Name: "base-01-0", Ready: corev1.ConditionUnknown},
+				{Image: tools, Name: "base-00-0", Ready: corev1.ConditionUnknown},
+			},
+		},
+	}, {
+		name: "ready",
+		set:  set(1, tools, base),
+		images: []*caching.Image{
+			image(set(1, tools, base), tools, "base-00-a", corev1.ConditionTrue),
+			image(set(1, tools, base), base, "base-01-a", corev1.ConditionTrue),
+		},
+		wantStatus: &cachierv1alpha1.CachedImageSetStatus{
+			ObservedGeneration: 1,
+			Conditions: []cachierv1alpha1.CachedImageSetCondition{{
+				Type:   cachierv1alpha1.CachedImageSetConditionReady,
+				Status: corev1.ConditionTrue,
+			}},
+			Images: []cachierv1alpha1.CachedImageState{
+				{Image: base, Name: "base-01-a", Ready: corev1.ConditionTrue},
+				{Image: tools, Name: "base-00-a", Ready: corev1.ConditionTrue},
+			},
+		},
+	}, {
+		name: "drift repair: an Image was deleted",
+		set:  set(1, tools, base),
+		images: []*caching.Image{
+			image(set(1, tools, base), tools, "base-00-a", corev1.ConditionTrue),
+		},
+		wantCreated: []string{"ns/base-01-0"},
+		wantStatus: &cachierv1alpha1.CachedImageSetStatus{
+			ObservedGeneration: 1,
+			Conditions: []cachierv1alpha1.CachedImageSetCondition{{
+				Type:    cachierv1alpha1.CachedImageSetConditionReady,
+				Status:  corev1.ConditionUnknown,
+				Reason:  "ImagesPending",
+				Message: "waiting for 1 of 2 images",
+			}},
+			Images: []cachierv1alpha1.CachedImageState{
+				{Image: base, Name: "base-01-0", Ready: corev1.ConditionUnknown},
+				{Image: tools, Name: "base-00-a", Ready: corev1.ConditionTrue},
+			},
+		},
+	}, {
+		name: "drift repair: the images changed",
+		set:  set(2, base),
+		images: []*caching.Image{
+			image(set(1, tools, base), tools, "base-00-a", corev1.ConditionTrue),
+			image(set(1, tools, base), base, "base-01-a", corev1.ConditionTrue),
+		},
+		wantCreated: []string{"ns/base-00-0"},
+		wantDeleted: []string{"ns/base-00-a", "ns/base-01-a"},
+		wantStatus: &cachierv1alpha1.CachedImageSetStatus{
+			ObservedGeneration: 2,
+			Conditions: []cachierv1alpha1.CachedImageSetCondition{{
+				Type:    cachierv1alpha1.CachedImageSetConditionReady,
+				Status:  corev1.ConditionUnknown,
+				Reason:  "ImagesPending",
+				Message: "waiting for 1 of 1 images",
+			}},
+			Images: []cachierv1alpha1.CachedImageState{
+				{Image: base, Name: "base-00-0", Ready: corev1.ConditionUnknown},
+			},
+		},
+	}, {
+		// The Images go with the set, by their OwnerReferences.
+		name: "delete",
+		images: []*caching.Image{
+			image(set(1, tools, base), tools, "base-00-a", corev1.ConditionTrue),
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var objs []runtime.Object
+			if test.set != nil {
+				objs = append(objs, test.set)
+			}
+			cachier := rtesting.NewCachier(objs...)
+			cachingFake := rtesting.NewCaching(test.images...)
+			r := &Reconciler{
+				cachingclient: cachingFake,
+				cachierclient: cachier,
+				lister:        cachier.CachedImageSetLister(),
+				imageLister:   cachingFake.ImageLister(),
+				enqueueAfter:  func(string, time.Duration) {},
+				Logger:        zap.NewNop().Sugar(),
+			}
+
+			if err := r.Reconcile(logging.WithLogger(context.Background(), zap.NewNop().Sugar()), "ns/base"); err != nil {
+				t.Fatalf("Reconcile() = %v", err)
+			}
+			if diff := cmp.Diff(test.wantCreated, cachingFake.Created()); diff != "" {
+				t.Errorf("Created (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(test.wantDeleted, cachingFake.Deleted()); diff != "" {
+				t.Errorf("Deleted (-want, +got) = %v", diff)
+			}
+
+			updates := cachier.StatusUpdates()
+			if test.wantStatus == nil {
+				if updates != 0 {
+					t.Errorf("StatusUpdates() = %d, wanted none", updates)
+				}
+				return
+			}
+			if updates != 1 {
+				t.Errorf("StatusUpdates() = %d, wanted 1", updates)
+			}
+			got, err := cachier.CachedImageSetLister().CachedImageSets("ns").Get("base")
+			if err != nil {
+				t.Fatalf("Get() = %v", err)
+			}
+			ignoreTime := cmpopts.IgnoreFields(cachierv1alpha1.CachedImageSetCondition{}, "LastTransitionTime")
+			if diff := cmp.Diff(*test.wantStatus, got.Status, ignoreTime); diff != "" {
+				t.Errorf("Status (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
@@ -18,11 +18,14 @@ package cachier
 
 import (
 	"context"
+	"fmt"
 	"sort"
 	"strings"
+	"time"
 
-	// caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
 	cachingclientset "github.com/knative/caching/pkg/client/clientset/versioned"
+	cachingv1alpha1 "github.com/knative/caching/pkg/client/clientset/versioned/typed/caching/v1alpha1"
 	cachinginformers "github.com/knative/caching/pkg/client/informers/externalversions/caching/v1alpha1"
 	cachinglisters "github.com/knative/caching/pkg/client/listers/caching/v1alpha1"
 	"github.com/knative/pkg/apis/duck"
@@ -34,27 +37,92 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/tools/cache"
 
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
 	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/budget"
+	cachierinformers "github.com/mattmoor/cachier/pkg/client/informers/externalversions/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/dryrun"
+	"github.com/mattmoor/cachier/pkg/fairqueue"
+	"github.com/mattmoor/cachier/pkg/imageindex"
+	"github.com/mattmoor/cachier/pkg/inventory"
+	"github.com/mattmoor/cachier/pkg/ownerchain"
+	"github.com/mattmoor/cachier/pkg/policy"
 	"github.com/mattmoor/cachier/pkg/reconciler/cachier/resources"
+	"github.com/mattmoor/cachier/pkg/reconciler/images"
+	"github.com/mattmoor/cachier/pkg/reconciler/podowners"
+	"github.com/mattmoor/cachier/pkg/registry"
+	"github.com/mattmoor/cachier/pkg/sharding"
 )
 
 const controllerAgentName = "cachier-controller"
 
-const annotationKey = "cachier.mattmoor.io/decorate"
-
 // Reconciler is the controller implementation for PodSpecable resources
 type Reconciler struct {
 	// For creating/deleting caching resources.
 	cachingclient cachingclientset.Interface
 
+	// For reporting status on the resources we process.
+	dynamicClient dynamic.Interface
+	gvr           schema.GroupVersionResource
+	gvk           schema.GroupVersionKind
+
 	// For reading the state of the world.
 	lister      cache.GenericLister
 	imageLister cachinglisters.ImageLister
 
+	// Whether to leave the images of Knative Serving resources that Serving
+	// has already cached for their Revisions to it.
+	trimServing bool
+
+	// For projecting the elements of lister onto the WithPod shape.
+	convert Converter
+
+	// For learning images (and their digests) from running Pods, when
+	// enabled.
+	podLister   cache.GenericLister
+	resolver    *ownerchain.Resolver
+	learnImages bool
+	pinDigests  bool
+
+	// For resolving and checking pull credentials, when enabled.
+	serviceAccountLister cache.GenericLister
+	secretLister         cache.GenericLister
+
+	// For checking that images exist before caching them, when enabled,
+	// and telling the time of the Events that report those that don't.
+	checker *registry.Checker
+	clock   clock.Clock
+
+	// For limiting the rate at which we create Images for each registry,
+	// and revisiting resources once their Images may be created.
+	budget       *budget.Budget
+	enqueueAfter func(key string, d time.Duration)
+
+	// For only reconciling the namespaces that this replica owns, when
+	// sharding.
+	shard *sharding.Sharder
+
+	// For resolving the effective CachePolicy of resources, when enabled.
+	policies *policy.Resolver
+
+	// For consulting the decorate label and annotation of Namespaces.
+	namespaceLister cache.GenericLister
+
+	// The mode for resources that nothing else decides.
+	defaultMode cachierv1alpha1.Mode
+
+	// For reporting the writes to Images that we would make, instead of
+	// making them, in the namespaces in dry-run mode.
+	dryRun   bool
+	reporter *dryrun.Reporter
+
 	// Sugared logger is easier to use but is not as performant as the
 	// raw logger. In performance critical paths, call logger.Desugar()
 	// and use the returned raw logger instead. In addition to the
@@ -66,15 +134,112 @@ type Reconciler struct {
 // Check that we implement the controller.Reconciler interface.
 var _ controller.Reconciler = (*Reconciler)(nil)
 
+// Converter projects the elements of an informer's cache onto the WithPod
+// shape from which we make Images.
+type Converter func(runtime.Object) (*v1alpha1.WithPod, error)
+
+// AsWithPod is the Converter for informers whose elements implement
+// v1alpha1.Podable (e.g. those from duck.TypedInformerFactory).
+func AsWithPod(obj runtime.Object) (*v1alpha1.WithPod, error) {
+	p, ok := obj.(v1alpha1.Podable)
+	if !ok {
+		return nil, fmt.Errorf("expected v1alpha1.Podable, got %T", obj)
+	}
+	return p.AsWithPod(), nil
+}
+
+// Options holds the optional features of the controller.
+type Options struct {
+	// PodInformer, when set, enables learning from resources' running Pods.
+	// Its elements must be *v1alpha1.PodImages.
+	PodInformer cache.SharedIndexInformer
+
+	// Resolver follows the owner chains of Pods, and PodRouter routes their
+	// events to the controllers of their owners.  They are required with
+	// PodInformer.
+	Resolver  *ownerchain.Resolver
+	PodRouter *podowners.Router
+
+	// LearnImages enables learning the images of Pods' containers that
+	// aren't in the pod template (e.g. sidecars injected by webhooks).  It
+	// requires PodInformer.
+	LearnImages bool
+
+	// PinDigests enables pinning Images to the digests that the kubelet
+	// reports Pods' containers run.  It requires PodInformer.
+	PinDigests bool
+
+	// ServiceAccountInformer and SecretInformer, when set, enable resolving
+	// the pull secrets attached to resources' ServiceAccounts, and checking
+	// that pull credentials exist before creating Images.  Their elements
+	// must be *v1alpha1.WithImagePullSecrets and *v1alpha1.WithSecretType.
+	// We look up the resources that use them by index, which can't be added
+	// to informers that have started (e.g. those of the informer factories),
+	// so those must be made with the CredentialIndexers of the resources, and
+	// ServiceAccountInformer with the ServiceAccountIndexers.
+	ServiceAccountInformer cache.SharedIndexInformer
+	SecretInformer         cache.SharedIndexInformer
+
+	// Checker, when set, enables checking with their registries that images
+	// exist (and that we may pull them) before caching them.
+	Checker *registry.Checker
+
+	// Clock tells the time.  Defaults to the real clock.
+	Clock clock.Clock
+
+	// TrimServingImages, when set, skips the images of Knative Serving
+	// resources for which Serving has already created Images for one of
+	// their Revisions.
+	TrimServingImages bool
+
+	// Budget, when set, limits the rate at which Images are created for the
+	// images of each registry.
+	Budget *budget.Budget
+
+	// Shard, when set, limits reconciliation to the namespaces that this
+	// replica owns.
+	Shard *sharding.Sharder
+
+	// CachePolicyInformer, when set, enables CachePolicy resources, and
+	// ClusterCachePolicyInformer, when also set, ClusterCachePolicies.
+	CachePolicyInformer        cachierinformers.CachePolicyInformer
+	ClusterCachePolicyInformer cachierinformers.ClusterCachePolicyInformer
+
+	// NamespaceInformer, when set, enables the decorate label and annotation
+	// on Namespaces, and provides the labels that ClusterCachePolicies'
+	// namespaceSelectors match.  Its elements must be *v1alpha1.WithMetadata.
+	NamespaceInformer cache.SharedIndexInformer
+
+	// DefaultMode is the mode for resources that nothing else decides.
+	// Defaults to OptOut.
+	DefaultMode cachierv1alpha1.Mode
+
+	// DryRun, when set, has Reconcile report the Images that it would
+	// create and delete to Reporter, rather than make any writes, unless the
+	// resource's Namespace disables it.  Namespaces may also enable it when
+	// DryRun is unset (see dryrun.Enabled).
+	DryRun   bool
+	Reporter *dryrun.Reporter
+
+	// Index, when set, is kept up to date with the images of the resources
+	// of our GVK, whether or not we cache them.
+	Index *imageindex.Index
+
+	// Inventory, when set, reports on the resources of our GVK.
+	Inventory *inventory.Inventory
+}
+
 // NewController returns a new PodSpecable controller
 func NewController(
 	logger *zap.SugaredLogger,
 	dynamicClient dynamic.Interface,
 	psif duck.InformerFactory,
+	convert Converter,
 	cachingClient cachingclientset.Interface,
 	imageInformer cachinginformers.ImageInformer,
 	gvk schema.GroupVersionKind,
-) *controller.Impl {
+	opts Options,
+) *fairqueue.Impl {
 
 	// GVK => GVR
 	gvr, _ := meta.UnsafeGuessKindToResource(gvk)
@@ -85,15 +250,35 @@ func NewController(
 		logger.Fatalf("Error building informer for %v: %v", gvr, err)
 	}
 
+	clk := opts.Clock
+	if clk == nil {
+		clk = clock.RealClock{}
+	}
+
 	r := &Reconciler{
 		cachingclient: cachingClient,
+		dynamicClient: dynamicClient,
+		gvr:           gvr,
+		gvk:           gvk,
 		lister:        lister,
 		imageLister:   imageInformer.Lister(),
+		convert:       convert,
+		defaultMode:   opts.DefaultMode,
+		dryRun:        opts.DryRun,
+		reporter:      opts.Reporter,
+		checker:       opts.Checker,
+		trimServing:   opts.TrimServingImages,
+		clock:         clk,
+		budget:        opts.Budget,
+		shard:         opts.Shard,
 		// Enrich the logs with controller name
 		Logger: logger.Named(controllerAgentName).
 			With(zap.String(logkey.ControllerType, controllerAgentName)),
 	}
-	impl := controller.NewImpl(r, r.Logger, gvr.String())
+	impl, resyncs := fairqueue.NewImpl(r, r.Logger, gvr.String())
+	r.enqueueAfter = func(key string, d time.Duration) {
+		impl.WorkQueue.AddAfter(key, d)
+	}
 
 	r.Logger.Info("Setting up event handlers")
 
@@ -101,19 +286,98 @@ func NewController(
 	// queue those resources for reconciliation.
 	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    impl.Enqueue,
-		UpdateFunc: controller.PassNew(impl.Enqueue),
+		UpdateFunc: fairqueue.PassNew(impl.Enqueue, resyncs.Enqueue),
 	})
 
+	if opts.Index != nil {
+		informer.AddEventHandler(opts.Index.Handler(gvk, convert))
+	}
+	if opts.Inventory != nil {
+		opts.Inventory.Track(gvk, lister, convert)
+	}
+
 	// Whenever we reconcile an image that's got a controlling OwnerReference with
 	// our GVK then enqueue the controlling reference into our workqueue.
 	imageInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
 		FilterFunc: controller.Filter(gvk),
 		Handler: cache.ResourceEventHandlerFuncs{
 			AddFunc:    impl.EnqueueControllerOf,
-			UpdateFunc: controller.PassNew(impl.EnqueueControllerOf),
+			UpdateFunc: fairqueue.PassNew(impl.EnqueueControllerOf, resyncs.EnqueueControllerOf),
 		},
 	})
 
+	if opts.Shard != nil {
+		// Whenever the replicas change, enqueue the resources in the
+		// namespaces that we now own (as resyncs, since they haven't
+		// changed).
+		opts.Shard.AddListener(func() {
+			r.enqueueOwned(resyncs)
+		})
+	}
+
+	if opts.PodInformer != nil {
+		r.podLister = cache.NewGenericLister(opts.PodInformer.GetIndexer(),
+			v1alpha1.PodsResource.GroupResource())
+		r.resolver = opts.Resolver
+		r.learnImages = opts.LearnImages
+		r.pinDigests = opts.PinDigests
+
+		// Whenever a Pod starts running, have the router enqueue the
+		// resources of our GVK in its owner chain to learn what it runs.
+		opts.PodRouter.Route(gvk, func(key string) {
+			r.enqueueAfter(key, 0)
+		})
+	}
+
+	if opts.ServiceAccountInformer != nil && opts.SecretInformer != nil {
+		r.serviceAccountLister = cache.NewGenericLister(opts.ServiceAccountInformer.GetIndexer(),
+			v1alpha1.ServiceAccountsResource.GroupResource())
+		r.secretLister = cache.NewGenericLister(opts.SecretInformer.GetIndexer(),
+			v1alpha1.SecretsResource.GroupResource())
+
+		// Whenever pull credentials change, enqueue the resources that use
+		// them, which we look up by index.
+		if err := addIndexers(informer, CredentialIndexers(convert)); err != nil {
+			logger.Fatalf("Error indexing %v by their credentials (see CredentialIndexers): %v", gvr, err)
+		}
+		if err := addIndexers(opts.ServiceAccountInformer, ServiceAccountIndexers()); err != nil {
+			logger.Fatalf("Error indexing ServiceAccounts by their Secrets (see ServiceAccountIndexers): %v", err)
+		}
+		saHandler, secretHandler := r.credentialHandlers(impl, resyncs,
+			informer.GetIndexer(), opts.ServiceAccountInformer.GetIndexer())
+		opts.ServiceAccountInformer.AddEventHandler(saHandler)
+		opts.SecretInformer.AddEventHandler(secretHandler)
+	}
+
+	if opts.CachePolicyInformer != nil {
+		r.policies = &policy.Resolver{
+			PolicyLister: opts.CachePolicyInformer.Lister(),
+		}
+
+		// Whenever a policy changes, enqueue the resources it may apply to.
+		namespacedHandler, clusterHandler := r.policyHandlers(impl, resyncs)
+		opts.CachePolicyInformer.Informer().AddEventHandler(namespacedHandler)
+		if opts.ClusterCachePolicyInformer != nil {
+			r.policies.ClusterPolicyLister = opts.ClusterCachePolicyInformer.Lister()
+			opts.ClusterCachePolicyInformer.Informer().AddEventHandler(clusterHandler)
+		}
+	}
+
+	if opts.NamespaceInformer != nil {
+		r.namespaceLister = cache.NewGenericLister(opts.NamespaceInformer.GetIndexer(),
+			v1alpha1.NamespacesResource.GroupResource())
+		if r.policies != nil {
+			r.policies.NamespaceLister = r.namespaceLister
+		}
+
+		// Whenever a Namespace's labels or decorate annotation change,
+		// enqueue the resources in it, which cleans up the Images of those
+		// that are no longer cached.
+		opts.NamespaceInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			UpdateFunc: r.namespaceChanged(impl),
+		})
+	}
+
 	return impl
 }
 
@@ -126,66 +390,168 @@ func (c *Reconciler) Reconcile(ctx context.Context, key string) error {
 		logger.Errorf("invalid resource key: %s", key)
 		return nil
 	}
+	if !c.shard.Owns(namespace) {
+		// Another replica reconciles this namespace.
+		return nil
+	}
 
 	// Get the thing resource with this namespace/name
 	untyped, err := c.lister.ByNamespace(namespace).Get(name)
 	if errors.IsNotFound(err) {
 		logger.Errorf("thing %q in work queue no longer exists", key)
+		c.budget.Forget(c.budgetID(key))
+		c.reporter.Forget(namespace, c.reportName(name))
 		return nil
 	} else if err != nil {
 		return err
 	}
-	thing := untyped.(*v1alpha1.WithPod)
+	thing, err := c.convert(untyped)
+	if err != nil {
+		logger.Errorf("unable to extract images from %q: %v", key, err)
+		return nil
+	}
+
+	// In dry-run mode, the writes to Images are recorded in a plan instead.
+	ns, err := policy.GetNamespace(c.namespaceLister, namespace)
+	if err != nil {
+		return err
+	}
+	var plan *dryrun.Plan
+	if dryrun.Enabled(ns, c.dryRun) {
+		plan = dryrun.NewPlan(c.cachingclient.CachingV1alpha1(), c.imageLister)
+	} else {
+		c.reporter.Forget(namespace, c.reportName(name))
+	}
 
-	if c.shouldCache(ctx, thing) {
+	decision, err := c.shouldCache(ctx, thing)
+	if err != nil {
+		return err
+	}
+	status := &v1alpha1.Status{
+		ObservedGeneration: thing.Generation,
+		Cached:             decision.Cache,
+		Reason:             decision.Reason,
+		Policy:             decision.Policy,
+		ExcludedContainers: decision.ExcludedContainers,
+	}
+	if decision.Cache {
 		// Ensure that we have all of the Image resources that we should.
-		if err := c.reconcileMissingImages(ctx, thing); err != nil {
+		if err := c.reconcileImages(ctx, thing, decision, status, plan); err != nil {
 			return err
 		}
 	} else {
+		c.budget.Forget(c.budgetID(key))
+
 		// Delete any Image resources for the current version.
-		propPolicy := metav1.DeletePropagationForeground
-		err := c.cachingclient.CachingV1alpha1().Images(namespace).DeleteCollection(
-			&metav1.DeleteOptions{PropagationPolicy: &propPolicy},
-			metav1.ListOptions{LabelSelector: kmeta.MakeGenerationLabelSelector(thing).String()},
-		)
+		err := images.DeleteStale(c.imagesClient(plan), c.imageLister,
+			namespace, kmeta.MakeGenerationLabelSelector(thing))
 		if err != nil {
 			return err
 		}
 	}
 
 	// Delete any Image resource for older versions.
-	propPolicy := metav1.DeletePropagationForeground
-	return c.cachingclient.CachingV1alpha1().Images(namespace).DeleteCollection(
-		&metav1.DeleteOptions{PropagationPolicy: &propPolicy},
-		metav1.ListOptions{LabelSelector: kmeta.MakeOldGenerationLabelSelector(thing).String()},
-	)
+	err = images.DeleteStale(c.imagesClient(plan), c.imageLister,
+		namespace, kmeta.MakeOldGenerationLabelSelector(thing))
+	if err != nil {
+		return err
+	}
+
+	if plan != nil {
+		// Report what we would have done, rather than write our status.
+		report := plan.Report()
+		if !report.Empty() {
+			logger.Infof("Dry run: would create Images for %v, and delete those for %v",
+				report.Create, report.Delete)
+		}
+		c.reporter.Report(namespace, c.reportName(name), report)
+		return nil
+	}
+
+	if fresh := newImageErrors(v1alpha1.GetStatus(thing), status); len(fresh) > 0 {
+		if err := c.reportImageErrors(thing, fresh); err != nil {
+			logger.Errorf("Error reporting Events for %q: %v", key, err)
+		}
+	}
+
+	return c.updateStatus(thing, status)
+}
+
+func (c *Reconciler) shouldCache(ctx context.Context, thing *v1alpha1.WithPod) (policy.Decision, error) {
+	logger := logging.FromContext(ctx)
+
+	p, err := c.policies.Resolve(thing)
+	if err != nil {
+		return policy.Decision{}, err
+	}
+	ns, err := policy.GetNamespace(c.namespaceLister, thing.Namespace)
+	if err != nil {
+		return policy.Decision{}, err
+	}
+	decision, path := policy.Explain(thing, policy.Options{
+		Policy:      p,
+		Namespace:   ns,
+		DefaultMode: c.defaultMode,
+	})
+	logger.Debugf("Decision path: %s", strings.Join(path, "; "))
+	if len(decision.ExcludedContainers) > 0 {
+		logger.Infof("Cache: %v (%s), excluding containers: %v", decision.Cache, decision.Reason,
+			strings.Join(decision.ExcludedContainers, ","))
+	} else {
+		logger.Infof("Cache: %v (%s)", decision.Cache, decision.Reason)
+	}
+	return decision, nil
 }
 
-func (c *Reconciler) shouldCache(ctx context.Context, thing *v1alpha1.WithPod) bool {
-	// Check to see whether this Deployment has explicitly disabled caching.
-	if v, ok := thing.Annotations[annotationKey]; ok {
-		switch strings.ToLower(v) {
-		case "true", "on", "enable", "enabled":
-			return true // Forced on
-		case "false", "off", "disable", "disabled":
-			return false // Forced off
+// reconcileImages determines the full set of images to cache for the
+// resource and the credentials with which to pull them, and then creates
+// the Images that are missing.  It records what it learns in status.
+func (c *Reconciler) reconcileImages(ctx context.Context, thing *v1alpha1.WithPod, decision policy.Decision, status *v1alpha1.Status, plan *dryrun.Plan) error {
+	logger := logging.FromContext(ctx)
+
+	if c.learnImages {
+		learned, err := c.learnImagesOf(ctx, thing)
+		if err != nil {
+			return err
+		}
+		status.DiscoveredImages = learned
+	}
+	want := withImages(policy.Apply(thing, decision), status.DiscoveredImages)
+
+	if c.pinDigests {
+		pods, err := c.podsOf(thing, maxSampledPods)
+		if err != nil {
+			return err
+		}
+		status.PinnedImages, status.DigestConflicts = resolveDigests(thing, pods)
+		if len(status.DigestConflicts) > 0 {
+			logger.Infof("Not pinning images whose Pods disagree on digests: %v", status.DigestConflicts)
 		}
-		// Proceed with default behavior
 	}
 
-	// By heuristic, we only apply caching to objects without a controlling
-	// OwnerReferences. This keeps us from applying caching to ReplicaSet
-	// when Deployment is the more appropriate target (for example).
-	if owner := metav1.GetControllerOf(thing); owner != nil {
-		return false
+	if c.serviceAccountLister != nil {
+		secrets, problems, err := c.resolveCredentials(thing)
+		if err != nil {
+			return err
+		}
+		if len(problems) > 0 {
+			// Rather than keep (or create) Images that are bound to fail,
+			// report the problem and wait for the credentials to change.
+			logger.Warnf("Not caching due to problems with pull credentials: %v", problems)
+			status.CredentialErrors = problems
+			status.Cached = false
+			status.Reason = "problems with pull credentials"
+			c.budget.Forget(c.budgetID(thing.Namespace + "/" + thing.Name))
+			return images.DeleteStale(c.imagesClient(plan), c.imageLister,
+				thing.Namespace, kmeta.MakeGenerationLabelSelector(thing))
+		}
+		want = withPullSecrets(want, secrets)
 	}
 
-	// We cache by default
-	return true
+	return c.reconcileMissingImages(ctx, want, decision, status, plan)
 }
 
-func (c *Reconciler) reconcileMissingImages(ctx context.Context, thing *v1alpha1.WithPod) error {
+func (c *Reconciler) reconcileMissingImages(ctx context.Context, thing *v1alpha1.WithPod, decision policy.Decision, status *v1alpha1.Status, plan *dryrun.Plan) error {
 	logger := logging.FromContext(ctx)
 
 	// Fetch the set of Image resources for this generation of the thing.
@@ -197,19 +563,55 @@ func (c *Reconciler) reconcileMissingImages(ctx context.Context, thing *v1alpha1
 	// Compute the set of Image resources that we expect for this thing.
 	want := resources.MakeImages(thing)
 
-	// Delete the overlap.
+	// Drop the images that the policy excludes.
+	for ref := range want {
+		if !decision.AllowsImage(ref) {
+			delete(want, ref)
+			status.ExcludedImages = append(status.ExcludedImages, ref)
+		}
+	}
+	sort.Strings(status.ExcludedImages)
+
+	// Drop the images that their registries say don't exist (or that we may
+	// not pull), rather than leave them to fail on every node.
+	if c.checker != nil {
+		if err := c.validateImages(ctx, thing, want, status); err != nil {
+			return err
+		}
+	}
+
+	// Pin images to the digests that the resource's Pods run.
+	for ref, pinned := range status.PinnedImages {
+		if img, ok := want[ref]; ok {
+			delete(want, ref)
+			img.Spec.Image = pinned
+			want[pinned] = img
+		}
+	}
+
+	// Knative Serving creates Images for each of its Revisions, so don't
+	// duplicate the ones it already has.
+	if c.trimServing {
+		if err := c.trimServingImages(thing, want); err != nil {
+			return err
+		}
+	}
+
+	// Delete the overlap, and the Images that we no longer want, e.g.
+	// because the policy changed to exclude the image since we created
+	// it, the image no longer exists, it was pinned to a different digest
+	// (or none), it was removed from the extra images annotation, or the
+	// resource's Pods stopped running it.  Duplicates go too.
 	for _, gotImg := range got {
 		if _, ok := want[gotImg.Spec.Image]; ok {
 			delete(want, gotImg.Spec.Image)
 			continue
 		}
-		// Maybe this could happen if we get duplicate images?
-		logger.Warnf("Got unexpected Image: %v", gotImg.Spec.Image)
-	}
-
-	// If nothing is left, than we have everything we want.
-	if len(want) == 0 {
-		return nil
+		logger.Infof("Deleting Image %s for %v", gotImg.Name, gotImg.Spec.Image)
+		err := c.imagesClient(plan).Images(gotImg.Namespace).Delete(gotImg.Name, &metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
 	}
 
 	// Compute a deterministic order to make testing sane.
@@ -219,14 +621,61 @@ func (c *Reconciler) reconcileMissingImages(ctx context.Context, thing *v1alpha1
 	}
 	sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
 
-	// Create all of the missing Image resources.
-	for _, key := range order {
-		img := want[key]
-		_, err := c.cachingclient.CachingV1alpha1().Images(img.Namespace).Create(&img)
-		if err != nil {
-			return err
+	// Create the missing Image resources that the budgets of their
+	// registries allow, and come back for the rest.  Dry runs don't spend
+	// the budgets.
+	admitted := order
+	if plan == nil {
+		key := thing.Namespace + "/" + thing.Name
+		var retry time.Duration
+		admitted, retry = c.budget.Admit(c.budgetID(key), order)
+		if retry > 0 {
+			logger.Infof("Deferring %d Images for %v until their registries' budgets allow",
+				len(order)-len(admitted), retry)
+			c.enqueueAfter(key, retry)
+		}
+	}
+	create := make([]caching.Image, 0, len(admitted))
+	for _, ref := range admitted {
+		create = append(create, want[ref])
+	}
+	_, err = images.Create(c.imagesClient(plan), create)
+	return err
+}
+
+// imagesClient returns the client through which to write Images: the plan,
+// in dry-run mode, and otherwise the API server.
+func (c *Reconciler) imagesClient(plan *dryrun.Plan) cachingv1alpha1.ImagesGetter {
+	if plan != nil {
+		return plan
+	}
+	return c.cachingclient.CachingV1alpha1()
+}
+
+// enqueueOwned enqueues the resources in the namespaces this replica owns.
+func (c *Reconciler) enqueueOwned(impl *fairqueue.Impl) {
+	objs, err := c.lister.List(labels.Everything())
+	if err != nil {
+		c.Logger.Errorf("Error listing resources: %v", err)
+		return
+	}
+	for _, obj := range objs {
+		if object, err := meta.Accessor(obj); err == nil && c.shard.Owns(object.GetNamespace()) {
+			impl.Enqueue(obj)
 		}
 	}
+}
+
+// reportName identifies the named resource in the dry-run report of its
+// namespace, which is shared with the controllers of other resources.
+func (c *Reconciler) reportName(name string) string {
+	gr := c.gvr.GroupResource()
+	return gr.String() + "." + name
+}
 
-	return nil
+// budgetID identifies the resource with the given key to the budget, which
+// is shared with the controllers of other resources.
+func (c *Reconciler) budgetID(key string) string {
+	gr := c.gvr.GroupResource()
+	return gr.String() + "/" + key
 }
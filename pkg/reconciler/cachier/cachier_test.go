@@ -0,0 +1,416 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cachier
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	"github.com/knative/pkg/logging"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/cache"
+
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/fairqueue"
+	"github.com/mattmoor/cachier/pkg/policy"
+	"github.com/mattmoor/cachier/pkg/reconciler/cachier/resources"
+	rtesting "github.com/mattmoor/cachier/pkg/reconciler/testing"
+)
+
+var deployments = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+// deployment returns a Deployment with the given annotations and a
+// container for each of the images.
+func deployment(annotations map[string]string, images ...string) *v1alpha1.WithPod {
+	thing := &v1alpha1.WithPod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "web",
+			UID:         "web-uid",
+			Generation:  1,
+			Annotations: annotations,
+		},
+	}
+	for _, img := range images {
+		thing.Spec.Template.Spec.Containers = append(thing.Spec.Template.Spec.Containers,
+			corev1.Container{Image: img})
+	}
+	return thing
+}
+
+type fixture struct {
+	r       *Reconciler
+	things  cache.Indexer
+	caching *rtesting.Caching
+	dynamic *rtesting.Dynamic
+	queued  []string
+}
+
+func newFixture(t *testing.T, things ...*v1alpha1.WithPod) *fixture {
+	f := &fixture{
+		things:  rtesting.NewIndexer(),
+		caching: rtesting.NewCaching(),
+		dynamic: rtesting.NewDynamic(),
+	}
+	for _, thing := range things {
+		f.set(t, thing)
+	}
+	f.r = &Reconciler{
+		cachingclient: f.caching,
+		dynamicClient: f.dynamic,
+		gvr:           deployments,
+		gvk:           deployments.GroupVersion().WithKind("Deployment"),
+		lister:        cache.NewGenericLister(f.things, deployments.GroupResource()),
+		imageLister:   f.caching.ImageLister(),
+		convert: func(obj runtime.Object) (*v1alpha1.WithPod, error) {
+			return obj.(*v1alpha1.WithPod), nil
+		},
+		defaultMode: cachierv1alpha1.ModeOptOut,
+		clock:       clock.NewFakeClock(time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)),
+		enqueueAfter: func(key string, d time.Duration) {
+			f.queued = append(f.queued, key)
+		},
+		Logger: zap.NewNop().Sugar(),
+	}
+	return f
+}
+
+// set adds (or replaces) the resource, in the lister and the API server.
+func (f *fixture) set(t *testing.T, thing *v1alpha1.WithPod) {
+	t.Helper()
+	if err := f.things.Update(thing); err != nil {
+		t.Fatalf("Update() = %v", err)
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(thing)
+	if err != nil {
+		t.Fatalf("ToUnstructured() = %v", err)
+	}
+	f.dynamic.Add(&unstructured.Unstructured{Object: obj})
+}
+
+func (f *fixture) reconcile(t *testing.T) {
+	t.Helper()
+	if err := f.r.Reconcile(logging.WithLogger(context.Background(), zap.NewNop().Sugar()), "ns/web"); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+}
+
+// lastStatus returns the Status that the last status patch reported.
+func (f *fixture) lastStatus(t *testing.T) *v1alpha1.Status {
+	t.Helper()
+	patches := f.dynamic.Patches()
+	if len(patches) == 0 {
+		t.Fatal("No status patches")
+	}
+	var patch struct {
+		Metadata metav1.ObjectMeta `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(patches[len(patches)-1].Data), &patch); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+	return v1alpha1.GetStatus(&v1alpha1.WithPod{ObjectMeta: patch.Metadata})
+}
+
+// imageRefs returns the images of the Images, in the order of their names.
+func imageRefs(imgs []*caching.Image) []string {
+	var refs []string
+	for _, img := range imgs {
+		refs = append(refs, img.Spec.Image)
+	}
+	return refs
+}
+
+func TestReconcileExtraImages(t *testing.T) {
+	extras := map[string]string{
+		resources.ExtraImagesAnnotationKey: "gcr.io/foo/proxy:v1, index.docker.io/library/busybox:latest",
+	}
+	f := newFixture(t, deployment(extras, "gcr.io/foo/web:v1", "busybox"))
+
+	// Extra images are cached alongside the containers' images, and
+	// references to the same image are cached once.
+	f.reconcile(t)
+	want := []string{"gcr.io/foo/web:v1", "busybox", "gcr.io/foo/proxy:v1"}
+	if diff := cmp.Diff(want, imageRefs(f.caching.Images())); diff != "" {
+		t.Errorf("Images (-want, +got) = %v", diff)
+	}
+
+	// Removing an extra image (which doesn't change the resource's
+	// generation) deletes its Image.
+	f.set(t, deployment(nil, "gcr.io/foo/web:v1", "busybox"))
+	f.reconcile(t)
+	want = []string{"gcr.io/foo/web:v1", "busybox"}
+	if diff := cmp.Diff(want, imageRefs(f.caching.Images())); diff != "" {
+		t.Errorf("Images (-want, +got) = %v", diff)
+	}
+	if diff := cmp.Diff([]string{"ns/web-02-0"}, f.caching.Deleted()); diff != "" {
+		t.Errorf("Deleted() (-want, +got) = %v", diff)
+	}
+
+	// With nothing to change, nothing changes.
+	f.caching.Created()
+	f.reconcile(t)
+	if got := append(f.caching.Created(), f.caching.Deleted()...); len(got) != 0 {
+		t.Errorf("Reconcile() changed %v, wanted no changes", got)
+	}
+}
+
+func TestReconcileSteadyState(t *testing.T) {
+	annotations := map[string]string{
+		resources.ExtraImagesAnnotationKey: "gcr.io/foo/proxy:v1, gcr.io/foo/agent:v2",
+	}
+	thing := deployment(annotations, "gcr.io/foo/web:v1", "busybox", "gcr.io/foo/db:v3")
+	f := newFixture(t, thing)
+
+	// The first reconcile records the status.
+	f.reconcile(t)
+	patches := f.dynamic.Patches()
+	if len(patches) != 1 {
+		t.Fatalf("Reconcile() patched %v, wanted one patch", patches)
+	}
+	var patch struct {
+		Metadata metav1.ObjectMeta `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(patches[0].Data), &patch); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+	for k, v := range patch.Metadata.Annotations {
+		thing.Annotations[k] = v
+	}
+	f.set(t, thing)
+
+	// Once it's there, reconciling again doesn't rewrite it.
+	for i := 0; i < 3; i++ {
+		f.reconcile(t)
+		if got := f.dynamic.Patches(); len(got) != 0 {
+			t.Errorf("Reconcile() patched %v, wanted no patches", got)
+		}
+	}
+}
+
+func TestReconcileServingImages(t *testing.T) {
+	services := schema.GroupVersionResource{Group: v1alpha1.ServingGroupName, Version: "v1alpha1", Resource: "services"}
+	extras := map[string]string{
+		resources.ExtraImagesAnnotationKey: "gcr.io/knative/queue:v1",
+	}
+
+	for _, trim := range []bool{true, false} {
+		thing := deployment(extras, "gcr.io/foo/web:v1", "gcr.io/foo/sidecar:v1")
+		thing.APIVersion = services.GroupVersion().String()
+		thing.Kind = "Service"
+		f := newFixture(t, thing)
+		f.r.gvr = services
+		f.r.gvk = services.GroupVersion().WithKind("Service")
+		f.r.trimServing = trim
+
+		// Serving has cached the queue-proxy and sidecar images of one
+		// of the Service's Revisions.
+		for _, ref := range []string{"gcr.io/knative/queue:v1", "gcr.io/foo/sidecar:v1"} {
+			if _, err := f.caching.CachingV1alpha1().Images("ns").Create(&caching.Image{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "web-00001-",
+					Labels:       map[string]string{v1alpha1.ServingServiceLabelKey: "web"},
+				},
+				Spec: caching.ImageSpec{Image: ref},
+			}); err != nil {
+				t.Fatalf("Create() = %v", err)
+			}
+		}
+		f.caching.Created()
+
+		f.reconcile(t)
+		want := []string{"gcr.io/foo/web:v1"}
+		if !trim {
+			// Without trimming, we cache them too.
+			want = []string{"gcr.io/foo/sidecar:v1", "gcr.io/foo/web:v1", "gcr.io/knative/queue:v1"}
+		}
+		var got []string
+		for _, img := range f.caching.Images() {
+			if _, ok := img.Labels[v1alpha1.ServingServiceLabelKey]; !ok {
+				got = append(got, img.Spec.Image)
+			}
+		}
+		sort.Strings(got)
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("trim=%v: Images (-want, +got) = %v", trim, diff)
+		}
+	}
+}
+
+func TestReconcileCredentialProblems(t *testing.T) {
+	f := newFixture(t, deployment(nil, "gcr.io/foo/web:v1"))
+	f.reconcile(t)
+
+	// Once we resolve credentials, the missing ServiceAccount keeps the
+	// resource from being cached, so its Images go.
+	serviceAccounts := rtesting.NewIndexer()
+	f.r.serviceAccountLister = cache.NewGenericLister(serviceAccounts,
+		v1alpha1.ServiceAccountsResource.GroupResource())
+	f.r.secretLister = cache.NewGenericLister(rtesting.NewIndexer(),
+		v1alpha1.SecretsResource.GroupResource())
+	f.reconcile(t)
+	if got := f.caching.Images(); len(got) != 0 {
+		t.Errorf("Images = %v, wanted none", imageRefs(got))
+	}
+	want := &v1alpha1.Status{
+		ObservedGeneration: 1,
+		Reason:             "problems with pull credentials",
+		CredentialErrors:   []string{`ServiceAccount "default" not found`},
+	}
+	if diff := cmp.Diff(want, f.lastStatus(t)); diff != "" {
+		t.Errorf("Status (-want, +got) = %v", diff)
+	}
+
+	// They come back with the ServiceAccount.
+	serviceAccounts.Add(&v1alpha1.WithImagePullSecrets{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "default"},
+	})
+	f.reconcile(t)
+	if diff := cmp.Diff([]string{"gcr.io/foo/web:v1"}, imageRefs(f.caching.Images())); diff != "" {
+		t.Errorf("Images (-want, +got) = %v", diff)
+	}
+	if got := f.lastStatus(t); !got.Cached {
+		t.Errorf("Status = %+v, wanted cached", got)
+	}
+}
+
+func TestCredentialHandlers(t *testing.T) {
+	convert := func(obj runtime.Object) (*v1alpha1.WithPod, error) {
+		return obj.(*v1alpha1.WithPod), nil
+	}
+	things := cache.NewIndexer(cache.MetaNamespaceKeyFunc, CredentialIndexers(convert))
+	serviceAccounts := cache.NewIndexer(cache.MetaNamespaceKeyFunc, ServiceAccountIndexers())
+
+	// web names the Secret in its pod template, and builder via its
+	// ServiceAccount, but neither db (whose default ServiceAccount doesn't)
+	// nor cron (in another namespace) use it.
+	named := func(namespace, name string) *v1alpha1.WithPod {
+		thing := deployment(nil, "gcr.io/foo/"+name)
+		thing.Namespace, thing.Name = namespace, name
+		return thing
+	}
+	web := named("ns", "web")
+	web.Spec.Template.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "creds"}}
+	builder := named("ns", "builder")
+	builder.Spec.Template.Spec.ServiceAccountName = "builder"
+	for _, thing := range []*v1alpha1.WithPod{web, builder, named("ns", "db"), named("other", "cron")} {
+		things.Add(thing)
+	}
+	for _, sa := range []*v1alpha1.WithImagePullSecrets{{
+		ObjectMeta:       metav1.ObjectMeta{Namespace: "ns", Name: "builder"},
+		ImagePullSecrets: []corev1.LocalObjectReference{{Name: "creds"}},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "default"},
+	}, {
+		ObjectMeta:       metav1.ObjectMeta{Namespace: "other", Name: "default"},
+		ImagePullSecrets: []corev1.LocalObjectReference{{Name: "other-creds"}},
+	}} {
+		serviceAccounts.Add(sa)
+	}
+
+	// The resyncs enqueue without rate limiting, so what they enqueue is
+	// ready right away.
+	_, resyncs := fairqueue.NewImpl(nil, zap.NewNop().Sugar(), "")
+	r := &Reconciler{Logger: zap.NewNop().Sugar()}
+	saHandler, secretHandler := r.credentialHandlers(resyncs, resyncs, things, serviceAccounts)
+	queued := func() []string {
+		var keys []string
+		for resyncs.WorkQueue.Len() > 0 {
+			key, _ := resyncs.WorkQueue.Get()
+			resyncs.WorkQueue.Done(key)
+			keys = append(keys, key.(string))
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	secretHandler.OnAdd(&v1alpha1.WithSecretType{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "creds"},
+	})
+	if diff := cmp.Diff([]string{"ns/builder", "ns/web"}, queued()); diff != "" {
+		t.Errorf("OnAdd(Secret) queued (-want, +got) = %v", diff)
+	}
+
+	saHandler.OnDelete(cache.DeletedFinalStateUnknown{
+		Key: "ns/default",
+		Obj: &v1alpha1.WithImagePullSecrets{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "default"}},
+	})
+	if diff := cmp.Diff([]string{"ns/db", "ns/web"}, queued()); diff != "" {
+		t.Errorf("OnDelete(ServiceAccount) queued (-want, +got) = %v", diff)
+	}
+}
+
+func TestReconcileControlledStatus(t *testing.T) {
+	isController := true
+	thing := deployment(nil, "gcr.io/foo/web:v1")
+	thing.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: "example.dev/v1",
+		Kind:       "App",
+		Name:       "web",
+		UID:        "app-uid",
+		Controller: &isController,
+	}}
+	f := newFixture(t, thing)
+
+	// Resources with a controller aren't cached (their owners are), and we
+	// don't annotate them to say so.
+	f.reconcile(t)
+	if got := f.caching.Images(); len(got) != 0 {
+		t.Errorf("Images = %v, wanted none", imageRefs(got))
+	}
+	if got := f.dynamic.Patches(); len(got) != 0 {
+		t.Errorf("Reconcile() patched %v, wanted no patches", got)
+	}
+
+	// Nor when they opt in.
+	thing.Annotations = map[string]string{policy.DecorateAnnotationKey: "enabled"}
+	f.set(t, thing)
+	f.reconcile(t)
+	if diff := cmp.Diff([]string{"gcr.io/foo/web:v1"}, imageRefs(f.caching.Images())); diff != "" {
+		t.Errorf("Images (-want, +got) = %v", diff)
+	}
+	if got := f.dynamic.Patches(); len(got) != 0 {
+		t.Errorf("Reconcile() patched %v, wanted no patches", got)
+	}
+
+	// But what we must remember (e.g. what their Pods were seen to run) is
+	// recorded, along with the decision.
+	status := &v1alpha1.Status{
+		ObservedGeneration: 1,
+		Cached:             true,
+		Reason:             "enabled by annotation",
+		DiscoveredImages:   []string{"gcr.io/foo/proxy:v1"},
+	}
+	if err := f.r.updateStatus(thing, status); err != nil {
+		t.Fatalf("updateStatus() = %v", err)
+	}
+	if diff := cmp.Diff(status, f.lastStatus(t)); diff != "" {
+		t.Errorf("Status (-want, +got) = %v", diff)
+	}
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cachier
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/fairqueue"
+)
+
+// defaultServiceAccountName is the ServiceAccount Pods run as when their
+// spec doesn't name one.
+const defaultServiceAccountName = "default"
+
+func serviceAccountName(thing *v1alpha1.WithPod) string {
+	if sa := thing.Spec.Template.Spec.ServiceAccountName; sa != "" {
+		return sa
+	}
+	return defaultServiceAccountName
+}
+
+// resolveCredentials returns the pull secrets of the resource's pod template,
+// followed by those attached to its ServiceAccount.  It also returns a
+// description of each of the problems with these credentials, e.g. the
+// ServiceAccount or a Secret is missing, or a Secret has the wrong type.
+func (c *Reconciler) resolveCredentials(thing *v1alpha1.WithPod) ([]corev1.LocalObjectReference, []string, error) {
+	var problems []string
+
+	secrets := append([]corev1.LocalObjectReference(nil), thing.Spec.Template.Spec.ImagePullSecrets...)
+	saName := serviceAccountName(thing)
+	sa, err := c.serviceAccountLister.ByNamespace(thing.Namespace).Get(saName)
+	if errors.IsNotFound(err) {
+		problems = append(problems, fmt.Sprintf("ServiceAccount %q not found", saName))
+	} else if err != nil {
+		return nil, nil, err
+	} else {
+		secrets = append(secrets, sa.(*v1alpha1.WithImagePullSecrets).ImagePullSecrets...)
+	}
+
+	// Deduplicate and check each of the Secrets.
+	seen := make(map[string]bool, len(secrets))
+	resolved := make([]corev1.LocalObjectReference, 0, len(secrets))
+	for _, ref := range secrets {
+		if seen[ref.Name] {
+			continue
+		}
+		seen[ref.Name] = true
+		resolved = append(resolved, ref)
+
+		secret, err := c.secretLister.ByNamespace(thing.Namespace).Get(ref.Name)
+		if errors.IsNotFound(err) {
+			problems = append(problems, fmt.Sprintf("Secret %q not found", ref.Name))
+		} else if err != nil {
+			return nil, nil, err
+		} else if s := secret.(*v1alpha1.WithSecretType); !s.IsDockerConfig() {
+			problems = append(problems, fmt.Sprintf("Secret %q has type %q, wanted %q or %q", ref.Name,
+				s.Type, corev1.SecretTypeDockerConfigJson, corev1.SecretTypeDockercfg))
+		}
+	}
+	return resolved, problems, nil
+}
+
+// withPullSecrets returns a shallow copy of the resource whose pod template
+// has the given pull secrets (which aren't copied either).
+func withPullSecrets(thing *v1alpha1.WithPod, secrets []corev1.LocalObjectReference) *v1alpha1.WithPod {
+	if len(secrets) == 0 {
+		return thing
+	}
+	out := *thing
+	out.Spec.Template.Spec.ImagePullSecrets = secrets
+	return &out
+}
+
+// enqueueNamespace enqueues the resources in the given namespace for which
+// the filter returns true.
+func (c *Reconciler) enqueueNamespace(impl *fairqueue.Impl, namespace string, filter func(*v1alpha1.WithPod) bool) {
+	objs, err := c.lister.ByNamespace(namespace).List(labels.Everything())
+	if err != nil {
+		c.Logger.Errorf("Error listing resources in namespace %q: %v", namespace, err)
+		return
+	}
+	for _, obj := range objs {
+		thing, err := c.convert(obj)
+		if err != nil {
+			continue
+		}
+		if filter(thing) {
+			impl.Enqueue(obj)
+		}
+	}
+}
+
+// unwrapDeleted returns the last known state of deleted objects.
+func unwrapDeleted(obj interface{}) interface{} {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		return tombstone.Obj
+	}
+	return obj
+}
+
+// The names of the indexes with which credentialHandlers find the resources
+// that use a ServiceAccount or Secret.  Their values are the namespace/name
+// keys of what they index by.
+const (
+	// pullSecretsIndex indexes resources by the pull secrets of their pod
+	// templates.
+	pullSecretsIndex = "cachier.mattmoor.io/pull-secrets"
+
+	// serviceAccountIndex indexes resources by their ServiceAccount.
+	serviceAccountIndex = "cachier.mattmoor.io/service-account"
+
+	// secretsIndex indexes ServiceAccounts by their pull secrets.
+	secretsIndex = "cachier.mattmoor.io/secrets"
+)
+
+// CredentialIndexers returns the indexers that the informers of resources
+// need to resolve credentials (see Options), given their Converter.
+func CredentialIndexers(convert Converter) cache.Indexers {
+	withPod := func(obj interface{}) *v1alpha1.WithPod {
+		robj, ok := obj.(runtime.Object)
+		if !ok {
+			return nil
+		}
+		thing, err := convert(robj)
+		if err != nil {
+			return nil
+		}
+		return thing
+	}
+	return cache.Indexers{
+		pullSecretsIndex: func(obj interface{}) ([]string, error) {
+			thing := withPod(obj)
+			if thing == nil {
+				return nil, nil
+			}
+			keys := make([]string, 0, len(thing.Spec.Template.Spec.ImagePullSecrets))
+			for _, ref := range thing.Spec.Template.Spec.ImagePullSecrets {
+				keys = append(keys, thing.Namespace+"/"+ref.Name)
+			}
+			return keys, nil
+		},
+		serviceAccountIndex: func(obj interface{}) ([]string, error) {
+			thing := withPod(obj)
+			if thing == nil {
+				return nil, nil
+			}
+			return []string{thing.Namespace + "/" + serviceAccountName(thing)}, nil
+		},
+	}
+}
+
+// ServiceAccountIndexers returns the indexers that the ServiceAccountInformer
+// needs (see Options).
+func ServiceAccountIndexers() cache.Indexers {
+	return cache.Indexers{
+		secretsIndex: func(obj interface{}) ([]string, error) {
+			sa, ok := obj.(*v1alpha1.WithImagePullSecrets)
+			if !ok {
+				return nil, nil
+			}
+			keys := make([]string, 0, len(sa.ImagePullSecrets))
+			for _, ref := range sa.ImagePullSecrets {
+				keys = append(keys, sa.Namespace+"/"+ref.Name)
+			}
+			return keys, nil
+		},
+	}
+}
+
+// addIndexers adds those of the indexers that the informer doesn't have yet,
+// which fails once it has started.
+func addIndexers(informer cache.SharedIndexInformer, indexers cache.Indexers) error {
+	existing := informer.GetIndexer().GetIndexers()
+	missing := cache.Indexers{}
+	for name, f := range indexers {
+		if _, ok := existing[name]; !ok {
+			missing[name] = f
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return informer.AddIndexers(missing)
+}
+
+// credentialHandlers returns event handlers that enqueue the resources that
+// use the ServiceAccount or Secret that changed, via impl (or resyncs, when
+// they didn't change).  They look these up in the indexes of CredentialIndexers
+// (of resources) and ServiceAccountIndexers (of serviceAccounts).
+func (c *Reconciler) credentialHandlers(impl, resyncs *fairqueue.Impl, resources, serviceAccounts cache.Indexer) (sa, secret cache.ResourceEventHandler) {
+	// enqueueByIndex enqueues the resources with the given index value.
+	enqueueByIndex := func(impl *fairqueue.Impl, index, key string) {
+		objs, err := resources.ByIndex(index, key)
+		if err != nil {
+			c.Logger.Errorf("Error looking up resources by %s %q: %v", index, key, err)
+			return
+		}
+		for _, obj := range objs {
+			impl.Enqueue(obj)
+		}
+	}
+
+	onServiceAccount := func(impl *fairqueue.Impl) func(interface{}) {
+		return func(obj interface{}) {
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err != nil {
+				return
+			}
+			enqueueByIndex(impl, serviceAccountIndex, key)
+		}
+	}
+
+	onSecret := func(impl *fairqueue.Impl) func(interface{}) {
+		return func(obj interface{}) {
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err != nil {
+				return
+			}
+			// The resources whose pod templates name the Secret, and those
+			// whose ServiceAccounts do.
+			enqueueByIndex(impl, pullSecretsIndex, key)
+			sas, err := serviceAccounts.ByIndex(secretsIndex, key)
+			if err != nil {
+				c.Logger.Errorf("Error looking up ServiceAccounts by Secret %q: %v", key, err)
+				return
+			}
+			for _, sa := range sas {
+				saKey, err := cache.MetaNamespaceKeyFunc(sa)
+				if err != nil {
+					continue
+				}
+				enqueueByIndex(impl, serviceAccountIndex, saKey)
+			}
+		}
+	}
+
+	sa = cache.ResourceEventHandlerFuncs{
+		AddFunc:    onServiceAccount(impl),
+		UpdateFunc: fairqueue.PassNew(onServiceAccount(impl), onServiceAccount(resyncs)),
+		DeleteFunc: onServiceAccount(impl),
+	}
+	secret = cache.ResourceEventHandlerFuncs{
+		AddFunc:    onSecret(impl),
+		UpdateFunc: fairqueue.PassNew(onSecret(impl), onSecret(resyncs)),
+		DeleteFunc: onSecret(impl),
+	}
+	return sa, secret
+}
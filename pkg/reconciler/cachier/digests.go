@@ -0,0 +1,104 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cachier
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+)
+
+// dockerPullablePrefix prefixes the imageIDs that Docker reports with the
+// repository digest of the image it pulled.
+const dockerPullablePrefix = "docker-pullable://"
+
+// digestOf returns the digest in an imageID reported by the kubelet, or ""
+// if it has none (e.g. Docker's local image IDs, "docker://sha256:...").
+func digestOf(imageID string) string {
+	imageID = strings.TrimPrefix(imageID, dockerPullablePrefix)
+	if i := strings.LastIndex(imageID, "@"); i >= 0 {
+		return imageID[i+1:]
+	}
+	return ""
+}
+
+// repository returns the image reference without its tag or digest.
+func repository(ref string) string {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+// resolveDigests maps the images of the Pods' containers to the digests that
+// the kubelet reports they run, in the form repository@digest.  Images that
+// are already pinned to a digest are skipped, as are those the Pods disagree
+// on, which are described in conflicts instead.  When there are no Pods, it
+// falls back on what was resolved previously, per the resource's status
+// annotation.
+func resolveDigests(thing *v1alpha1.WithPod, pods []*v1alpha1.PodImages) (pinned map[string]string, conflicts []string) {
+	if len(pods) == 0 {
+		if previous := v1alpha1.GetStatus(thing); previous != nil {
+			return previous.PinnedImages, previous.DigestConflicts
+		}
+		return nil, nil
+	}
+
+	digests := make(map[string]sets.String)
+	for _, pod := range pods {
+		images := make(map[string]string)
+		for _, c := range pod.AllContainers() {
+			images[c.Name] = c.Image
+		}
+		statuses := append(pod.Status.InitContainerStatuses[:len(pod.Status.InitContainerStatuses):len(pod.Status.InitContainerStatuses)],
+			pod.Status.ContainerStatuses...)
+		for _, s := range statuses {
+			image, ok := images[s.Name]
+			if !ok || strings.Contains(image, "@") {
+				continue
+			}
+			digest := digestOf(s.ImageID)
+			if digest == "" {
+				continue
+			}
+			if _, ok := digests[image]; !ok {
+				digests[image] = sets.NewString()
+			}
+			digests[image].Insert(digest)
+		}
+	}
+
+	for image, ds := range digests {
+		if ds.Len() > 1 {
+			conflicts = append(conflicts, fmt.Sprintf("%s: %s", image, strings.Join(ds.List(), ", ")))
+			continue
+		}
+		if pinned == nil {
+			pinned = make(map[string]string, len(digests))
+		}
+		pinned[image] = repository(image) + "@" + ds.List()[0]
+	}
+	sort.Strings(conflicts)
+	return pinned, conflicts
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cachier
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+)
+
+func TestRepository(t *testing.T) {
+	for ref, want := range map[string]string{
+		"nginx":                            "nginx",
+		"nginx:1.15":                       "nginx",
+		"localhost:5000/foo":               "localhost:5000/foo",
+		"localhost:5000/foo:v1":            "localhost:5000/foo",
+		"gcr.io/foo/bar@sha256:abcd":       "gcr.io/foo/bar",
+		"gcr.io/foo/bar:v1@sha256:abcd":    "gcr.io/foo/bar",
+		"docker.io/library/busybox:latest": "docker.io/library/busybox",
+	} {
+		if got := repository(ref); got != want {
+			t.Errorf("repository(%q) = %q, wanted %q", ref, got, want)
+		}
+	}
+}
+
+func TestResolveDigests(t *testing.T) {
+	pod := func(statuses ...v1alpha1.ContainerImageStatus) *v1alpha1.PodImages {
+		return &v1alpha1.PodImages{
+			Spec: v1alpha1.PodImagesSpec{
+				InitContainers: []v1alpha1.ContainerImage{{Name: "init", Image: "busybox"}},
+				Containers: []v1alpha1.ContainerImage{
+					{Name: "app", Image: "gcr.io/foo/app:v1"},
+					{Name: "pinned", Image: "gcr.io/foo/side@sha256:1111"},
+				},
+			},
+			Status: v1alpha1.PodImagesStatus{
+				ContainerStatuses: statuses,
+			},
+		}
+	}
+	app := func(imageID string) v1alpha1.ContainerImageStatus {
+		return v1alpha1.ContainerImageStatus{Name: "app", ImageID: imageID}
+	}
+
+	tests := []struct {
+		name          string
+		thing         *v1alpha1.WithPod
+		pods          []*v1alpha1.PodImages
+		wantPinned    map[string]string
+		wantConflicts []string
+	}{{
+		name: "docker",
+		pods: []*v1alpha1.PodImages{
+			pod(app("docker-pullable://gcr.io/foo/app@sha256:aaaa")),
+			pod(app("docker-pullable://gcr.io/foo/app@sha256:aaaa")),
+		},
+		wantPinned: map[string]string{"gcr.io/foo/app:v1": "gcr.io/foo/app@sha256:aaaa"},
+	}, {
+		name: "containerd, with init container",
+		pods: []*v1alpha1.PodImages{{
+			Spec: pod().Spec,
+			Status: v1alpha1.PodImagesStatus{
+				InitContainerStatuses: []v1alpha1.ContainerImageStatus{{
+					Name:    "init",
+					ImageID: "docker.io/library/busybox@sha256:bbbb",
+				}},
+				ContainerStatuses: []v1alpha1.ContainerImageStatus{
+					app("gcr.io/foo/app@sha256:aaaa"),
+					{Name: "pinned", ImageID: "gcr.io/foo/side@sha256:1111"},
+				},
+			},
+		}},
+		wantPinned: map[string]string{
+			"busybox":           "busybox@sha256:bbbb",
+			"gcr.io/foo/app:v1": "gcr.io/foo/app@sha256:aaaa",
+		},
+	}, {
+		name: "no digest",
+		pods: []*v1alpha1.PodImages{
+			pod(app("docker://sha256:cccc")),
+			pod(app("")),
+		},
+	}, {
+		name: "mid-rollout",
+		pods: []*v1alpha1.PodImages{
+			pod(app("gcr.io/foo/app@sha256:aaaa")),
+			pod(app("gcr.io/foo/app@sha256:dddd")),
+		},
+		wantConflicts: []string{"gcr.io/foo/app:v1: sha256:aaaa, sha256:dddd"},
+	}, {
+		name: "scaled to zero",
+		thing: &v1alpha1.WithPod{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					v1alpha1.StatusAnnotationKey: `{"cached":true,"pinnedImages":{"nginx":"nginx@sha256:eeee"}}`,
+				},
+			},
+		},
+		wantPinned: map[string]string{"nginx": "nginx@sha256:eeee"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			thing := test.thing
+			if thing == nil {
+				thing = &v1alpha1.WithPod{}
+			}
+			pinned, conflicts := resolveDigests(thing, test.pods)
+			if diff := cmp.Diff(test.wantPinned, pinned); diff != "" {
+				t.Errorf("resolveDigests pinned (-want, +got) = %v", diff)
+			}
+			if diff := cmp.Diff(test.wantConflicts, conflicts); diff != "" {
+				t.Errorf("resolveDigests conflicts (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
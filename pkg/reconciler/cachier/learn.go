@@ -0,0 +1,121 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cachier
+
+import (
+	"context"
+	"strings"
+
+	"github.com/knative/pkg/logging"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/policy"
+	"github.com/mattmoor/cachier/pkg/reconciler/cachier/resources"
+)
+
+// maxSampledPods bounds the number of a resource's Pods that we sample
+// to learn the images that they run.
+const maxSampledPods = 10
+
+// podsOf returns up to limit of the running Pods selected by the resource's
+// pod template labels, whose owner chain leads back to the resource.
+func (c *Reconciler) podsOf(thing *v1alpha1.WithPod, limit int) ([]*v1alpha1.PodImages, error) {
+	if len(thing.Spec.Template.Labels) == 0 {
+		return nil, nil
+	}
+	objs, err := c.podLister.ByNamespace(thing.Namespace).List(
+		labels.SelectorFromSet(thing.Spec.Template.Labels))
+	if err != nil {
+		return nil, err
+	}
+
+	pods := make([]*v1alpha1.PodImages, 0, limit)
+	for _, obj := range objs {
+		pod := obj.(*v1alpha1.PodImages)
+		if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		if owned, err := c.resolver.Owns(thing.UID, pod); err != nil {
+			return nil, err
+		} else if !owned {
+			continue
+		}
+		pods = append(pods, pod)
+		if len(pods) >= limit {
+			break
+		}
+	}
+	return pods, nil
+}
+
+// learnImagesOf returns the images that the resource's running Pods have in
+// addition to those in its pod template.  When there are no running Pods
+// we fall back on what we learned previously, per its status annotation.
+func (c *Reconciler) learnImagesOf(ctx context.Context, thing *v1alpha1.WithPod) ([]string, error) {
+	logger := logging.FromContext(ctx)
+
+	pods, err := c.podsOf(thing, maxSampledPods)
+	if err != nil {
+		return nil, err
+	}
+	if len(pods) == 0 {
+		if previous := v1alpha1.GetStatus(thing); previous != nil {
+			return previous.DiscoveredImages, nil
+		}
+		return nil, nil
+	}
+
+	known := sets.NewString(resources.ExtraImages(thing)...)
+	for _, c := range thing.Spec.Template.Spec.Containers {
+		known.Insert(c.Image)
+	}
+	excluded := policy.ExcludedContainerNames(thing)
+
+	learned := sets.NewString()
+	for _, pod := range pods {
+		for _, c := range pod.AllContainers() {
+			if excluded.Has(c.Name) || known.Has(c.Image) {
+				continue
+			}
+			learned.Insert(c.Image)
+		}
+	}
+	if learned.Len() > 0 {
+		logger.Infof("Learned images from %d Pods: %s", len(pods), strings.Join(learned.List(), ","))
+	}
+	return learned.List(), nil
+}
+
+// withImages returns a copy of the resource with a container added to its
+// pod template for each of the given images.  Beyond the new container
+// list, the copy is a shallow one, e.g. its metadata's maps are thing's.
+func withImages(thing *v1alpha1.WithPod, images []string) *v1alpha1.WithPod {
+	if len(images) == 0 {
+		return thing
+	}
+	out := *thing
+	containers := make([]corev1.Container, 0, len(thing.Spec.Template.Spec.Containers)+len(images))
+	containers = append(containers, thing.Spec.Template.Spec.Containers...)
+	for _, img := range images {
+		containers = append(containers, corev1.Container{Image: img})
+	}
+	out.Spec.Template.Spec.Containers = containers
+	return &out
+}
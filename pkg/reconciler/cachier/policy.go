@@ -0,0 +1,92 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cachier
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/dryrun"
+	"github.com/mattmoor/cachier/pkg/fairqueue"
+	"github.com/mattmoor/cachier/pkg/policy"
+)
+
+// all is a filter for enqueueNamespace that accepts every resource.
+func all(*v1alpha1.WithPod) bool {
+	return true
+}
+
+// policyHandlers returns event handlers that enqueue the resources whose
+// effective policy may have changed: those in the namespace of a changed
+// CachePolicy, and all of them for a changed ClusterCachePolicy, via impl
+// (or resyncs, when the policy didn't change).
+func (c *Reconciler) policyHandlers(impl, resyncs *fairqueue.Impl) (namespaced, cluster cache.ResourceEventHandler) {
+	onNamespaced := func(impl *fairqueue.Impl) func(interface{}) {
+		return func(obj interface{}) {
+			object, err := meta.Accessor(unwrapDeleted(obj))
+			if err != nil {
+				return
+			}
+			c.enqueueNamespace(impl, object.GetNamespace(), all)
+		}
+	}
+
+	onCluster := func(impl *fairqueue.Impl) func(interface{}) {
+		return func(obj interface{}) {
+			objs, err := c.lister.List(labels.Everything())
+			if err != nil {
+				c.Logger.Errorf("Error listing resources: %v", err)
+				return
+			}
+			for _, obj := range objs {
+				impl.Enqueue(obj)
+			}
+		}
+	}
+
+	namespaced = cache.ResourceEventHandlerFuncs{
+		AddFunc:    onNamespaced(impl),
+		UpdateFunc: fairqueue.PassNew(onNamespaced(impl), onNamespaced(resyncs)),
+		DeleteFunc: onNamespaced(impl),
+	}
+	cluster = cache.ResourceEventHandlerFuncs{
+		AddFunc:    onCluster(impl),
+		UpdateFunc: fairqueue.PassNew(onCluster(impl), onCluster(resyncs)),
+		DeleteFunc: onCluster(impl),
+	}
+	return namespaced, cluster
+}
+
+// namespaceChanged returns an update handler for Namespaces that enqueues
+// the resources in those whose changes may affect our decisions.
+func (c *Reconciler) namespaceChanged(impl *fairqueue.Impl) func(interface{}, interface{}) {
+	return func(oldObj, newObj interface{}) {
+		old, ok := oldObj.(*v1alpha1.WithMetadata)
+		if !ok {
+			return
+		}
+		new, ok := newObj.(*v1alpha1.WithMetadata)
+		if !ok {
+			return
+		}
+		if policy.NamespaceChanged(old, new) || dryrun.NamespaceChanged(old, new) {
+			c.enqueueNamespace(impl, new.Name, all)
+		}
+	}
+}
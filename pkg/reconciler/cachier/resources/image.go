@@ -18,23 +18,61 @@ package resources
 
 import (
 	"fmt"
+	"strings"
 
 	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
 	"github.com/knative/pkg/kmeta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/reference"
 )
 
+// ExtraImagesAnnotationKey holds a comma-separated list of images to cache
+// in addition to those of the pod template's containers (e.g. sidecars
+// injected by webhooks).  It may be placed on the resource, or on its pod
+// template.
+const ExtraImagesAnnotationKey = "cachier.mattmoor.io/extra-images"
+
+// ExtraImages returns the images listed in the ExtraImagesAnnotationKey
+// annotations of the resource and its pod template.
+func ExtraImages(ps *v1alpha1.WithPod) []string {
+	var extras []string
+	for _, annos := range []map[string]string{ps.Annotations, ps.Spec.Template.Annotations} {
+		if v, ok := annos[ExtraImagesAnnotationKey]; ok {
+			extras = append(extras, strings.Split(v, ",")...)
+		}
+	}
+	return extras
+}
+
+// MakeImages returns the deduplicated set of Image resources for the images
+// of the pod template's containers, and any extra images listed in its
+// annotations, keyed by image reference.  References to the same image
+// (e.g. busybox and index.docker.io/library/busybox:latest) are one Image,
+// with the first of their spellings.
 func MakeImages(ps *v1alpha1.WithPod) map[string]caching.Image {
+	podspec := ps.Spec.Template.Spec
+	refs := make([]string, 0, len(podspec.Containers))
+	for _, c := range podspec.Containers {
+		refs = append(refs, c.Image)
+	}
+	refs = append(refs, ExtraImages(ps)...)
+
 	images := make(map[string]caching.Image)
+	seen := make(map[string]bool)
 	// Build the deduplicated set of Image resources.
-	podspec := ps.Spec.Template.Spec
-	for idx, c := range podspec.Containers {
-		if _, ok := images[c.Image]; ok {
+	for idx, ref := range refs {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			continue
+		}
+		normalized := reference.Normalize(ref)
+		if seen[normalized] {
 			continue
 		}
-		images[c.Image] = caching.Image{
+		seen[normalized] = true
+		images[ref] = caching.Image{
 			ObjectMeta: metav1.ObjectMeta{
 				GenerateName:    fmt.Sprintf("%s-%02d-", ps.Name, idx),
 				Namespace:       ps.Namespace,
@@ -42,7 +80,7 @@ func MakeImages(ps *v1alpha1.WithPod) map[string]caching.Image {
 				OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(ps)},
 			},
 			Spec: caching.ImageSpec{
-				Image:              c.Image,
+				Image:              ref,
 				ServiceAccountName: podspec.ServiceAccountName,
 				ImagePullSecrets:   podspec.ImagePullSecrets,
 			},
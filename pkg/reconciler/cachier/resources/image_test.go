@@ -315,6 +315,108 @@ func TestMakeImages(t *testing.T) {
 				},
 			},
 		},
+	}, {
+		name: "with extra images",
+		ps: &v1alpha1.WithPod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "foo",
+				Namespace:  "bar",
+				UID:        "deadbeef",
+				Generation: 37837,
+				Annotations: map[string]string{
+					ExtraImagesAnnotationKey: "istio/proxyv2, busybox,,",
+				},
+			},
+			Spec: v1alpha1.WithPodSpec{
+				Template: v1alpha1.PodSpecable{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							ExtraImagesAnnotationKey: "fluentd,index.docker.io/library/busybox:latest",
+						},
+					},
+					Spec: corev1.PodSpec{
+						ServiceAccountName: "T-1000",
+						Containers: []corev1.Container{{
+							Image: "busybox",
+						}},
+						ImagePullSecrets: []corev1.LocalObjectReference{{
+							Name: "secret1",
+						}},
+					},
+				},
+			},
+		},
+		want: map[string]caching.Image{
+			"busybox": {
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "foo-00-",
+					Namespace:    "bar",
+					Labels: map[string]string{
+						"controller": "deadbeef",
+						"generation": "37837",
+					},
+					OwnerReferences: []metav1.OwnerReference{{
+						Name:               "foo",
+						UID:                "deadbeef",
+						Controller:         &boolTrue,
+						BlockOwnerDeletion: &boolTrue,
+					}},
+				},
+				Spec: caching.ImageSpec{
+					Image:              "busybox",
+					ServiceAccountName: "T-1000",
+					ImagePullSecrets: []corev1.LocalObjectReference{{
+						Name: "secret1",
+					}},
+				},
+			},
+			"istio/proxyv2": {
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "foo-01-",
+					Namespace:    "bar",
+					Labels: map[string]string{
+						"controller": "deadbeef",
+						"generation": "37837",
+					},
+					OwnerReferences: []metav1.OwnerReference{{
+						Name:               "foo",
+						UID:                "deadbeef",
+						Controller:         &boolTrue,
+						BlockOwnerDeletion: &boolTrue,
+					}},
+				},
+				Spec: caching.ImageSpec{
+					Image:              "istio/proxyv2",
+					ServiceAccountName: "T-1000",
+					ImagePullSecrets: []corev1.LocalObjectReference{{
+						Name: "secret1",
+					}},
+				},
+			},
+			"fluentd": {
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "foo-05-",
+					Namespace:    "bar",
+					Labels: map[string]string{
+						"controller": "deadbeef",
+						"generation": "37837",
+					},
+					OwnerReferences: []metav1.OwnerReference{{
+						Name:               "foo",
+						UID:                "deadbeef",
+						Controller:         &boolTrue,
+						BlockOwnerDeletion: &boolTrue,
+					}},
+				},
+				Spec: caching.ImageSpec{
+					Image:              "fluentd",
+					ServiceAccountName: "T-1000",
+					ImagePullSecrets: []corev1.LocalObjectReference{{
+						Name: "secret1",
+					}},
+				},
+			},
+		},
 	}}
 
 	for _, test := range tests {
@@ -0,0 +1,56 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+)
+
+// MakeWithPod projects a resource that lists images (e.g. a CachedImageSet)
+// onto the WithPod shape, with the given pod spec plus a container for each
+// of the images, so that its Images are made (and labeled) just like those
+// of workloads.
+func MakeWithPod(gvk schema.GroupVersionKind, om metav1.ObjectMeta, spec corev1.PodSpec, images []string) *v1alpha1.WithPod {
+	spec.Containers = make([]corev1.Container, 0, len(images))
+	for i, image := range images {
+		spec.Containers = append(spec.Containers, corev1.Container{
+			Name:  fmt.Sprintf("image-%02d", i),
+			Image: image,
+		})
+	}
+
+	// Objects from listers don't have their TypeMeta, which the Images'
+	// labels and OwnerReferences are derived from, so it is passed in.
+	return &v1alpha1.WithPod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: gvk.GroupVersion().String(),
+			Kind:       gvk.Kind,
+		},
+		ObjectMeta: om,
+		Spec: v1alpha1.WithPodSpec{
+			Template: v1alpha1.PodSpecable{
+				Spec: spec,
+			},
+		},
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+)
+
+func TestMakeWithPod(t *testing.T) {
+	set := &cachierv1alpha1.CachedImageSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "launch",
+			Namespace:  "bar",
+			UID:        "1234",
+			Generation: 2,
+		},
+		Spec: cachierv1alpha1.CachedImageSetSpec{
+			Images:             []string{"gcr.io/foo/bar", " busybox ", "gcr.io/foo/bar"},
+			ServiceAccountName: "builder",
+			ImagePullSecrets:   []corev1.LocalObjectReference{{Name: "creds"}},
+		},
+	}
+
+	images := MakeImages(MakeWithPod(set.GetGroupVersionKind(), set.ObjectMeta, corev1.PodSpec{
+		ServiceAccountName: set.Spec.ServiceAccountName,
+		ImagePullSecrets:   set.Spec.ImagePullSecrets,
+	}, set.Spec.Images))
+	if got, want := len(images), 2; got != want {
+		t.Fatalf("len(MakeImages()) = %d, wanted %d: %v", got, want, images)
+	}
+
+	img, ok := images["busybox"]
+	if !ok {
+		t.Fatalf("MakeImages() = %v, wanted busybox", images)
+	}
+	if got, want := img.GenerateName, "launch-01-"; got != want {
+		t.Errorf("GenerateName = %q, wanted %q", got, want)
+	}
+	wantOwners := []metav1.OwnerReference{{
+		APIVersion:         "cachier.mattmoor.io/v1alpha1",
+		Kind:               "CachedImageSet",
+		Name:               "launch",
+		UID:                "1234",
+		Controller:         &[]bool{true}[0],
+		BlockOwnerDeletion: &[]bool{true}[0],
+	}}
+	if diff := cmp.Diff(wantOwners, img.OwnerReferences); diff != "" {
+		t.Errorf("OwnerReferences (-want, +got) = %v", diff)
+	}
+	wantLabels := map[string]string{
+		"controller": "1234",
+		"generation": "00002",
+	}
+	if diff := cmp.Diff(wantLabels, img.Labels); diff != "" {
+		t.Errorf("Labels (-want, +got) = %v", diff)
+	}
+	if got, want := img.Spec.ServiceAccountName, "builder"; got != want {
+		t.Errorf("ServiceAccountName = %q, wanted %q", got, want)
+	}
+	if diff := cmp.Diff(set.Spec.ImagePullSecrets, img.Spec.ImagePullSecrets); diff != "" {
+		t.Errorf("ImagePullSecrets (-want, +got) = %v", diff)
+	}
+}
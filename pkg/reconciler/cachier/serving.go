@@ -0,0 +1,63 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cachier
+
+import (
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+)
+
+// servingSelector returns a selector for the Images that Knative Serving
+// has created for the Revisions of the given thing, or nil if the thing
+// isn't a Serving resource that stamps out Revisions.
+func servingSelector(thing *v1alpha1.WithPod) labels.Selector {
+	gvk := thing.GetGroupVersionKind()
+	if gvk.Group != v1alpha1.ServingGroupName {
+		return nil
+	}
+	switch gvk.Kind {
+	case "Configuration":
+		return labels.SelectorFromSet(labels.Set{
+			v1alpha1.ServingConfigurationLabelKey: thing.Name,
+		})
+	case "Service":
+		return labels.SelectorFromSet(labels.Set{
+			v1alpha1.ServingServiceLabelKey: thing.Name,
+		})
+	default:
+		return nil
+	}
+}
+
+// trimServingImages removes from want any image references that Knative
+// Serving has already cached for one of the thing's Revisions.
+func (c *Reconciler) trimServingImages(thing *v1alpha1.WithPod, want map[string]caching.Image) error {
+	selector := servingSelector(thing)
+	if selector == nil || len(want) == 0 {
+		return nil
+	}
+	imgs, err := c.imageLister.Images(thing.Namespace).List(selector)
+	if err != nil {
+		return err
+	}
+	for _, img := range imgs {
+		delete(want, img.Spec.Image)
+	}
+	return nil
+}
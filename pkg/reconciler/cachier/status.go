@@ -0,0 +1,67 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cachier
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+)
+
+// updateStatus reports the given Status on the resource via its status
+// annotation, unless it is already up to date.  Resources with a controller
+// (e.g. the ReplicaSets of Deployments) are only annotated when the Status
+// holds something we read back on later reconciles: their controllers own
+// them, and they churn with every rollout, so otherwise we'd only add writes.
+func (c *Reconciler) updateStatus(thing *v1alpha1.WithPod, status *v1alpha1.Status) error {
+	if metav1.GetControllerOf(thing) != nil && !remembers(status) {
+		return nil
+	}
+	want, err := status.Serialize()
+	if err != nil {
+		return err
+	}
+	if got, ok := thing.Annotations[v1alpha1.StatusAnnotationKey]; ok && got == want {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				v1alpha1.StatusAnnotationKey: want,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.dynamicClient.Resource(c.gvr).Namespace(thing.Namespace).Patch(
+		thing.Name, types.MergePatchType, patch)
+	return err
+}
+
+// remembers returns whether the Status holds state that later reconciles
+// read back from the status annotation: what was learned from Pods, which
+// must survive scaling to zero, and the image errors already reported as
+// Events.
+func remembers(status *v1alpha1.Status) bool {
+	return len(status.DiscoveredImages) > 0 || len(status.PinnedImages) > 0 ||
+		len(status.DigestConflicts) > 0 || len(status.ImageErrors) > 0
+}
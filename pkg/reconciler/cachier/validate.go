@@ -0,0 +1,244 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cachier
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	"github.com/knative/pkg/logging"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/registry"
+)
+
+// eventsResource is the resource through which we report Events.
+var eventsResource = schema.GroupVersionResource{Version: "v1", Resource: "events"}
+
+// Reasons of the Events we report for images that fail validation.
+const (
+	reasonImageNotFound     = "ImageNotFound"
+	reasonImageUnauthorized = "ImageUnauthorized"
+)
+
+// The prefixes of the descriptions of images that fail validation.
+const (
+	notFoundPrefix     = "image not found: "
+	unauthorizedPrefix = "unauthorized: "
+)
+
+// imageError describes an image that failed validation.
+func imageError(result registry.Result, ref string) string {
+	if result == registry.NotFound {
+		return notFoundPrefix + ref
+	}
+	return unauthorizedPrefix + ref
+}
+
+// validateImages checks with their registries that the images we want
+// exist and that we may pull them with the resource's credentials.  Those
+// that fail are dropped from want (so that their Images are deleted), and
+// reported in status.  When a registry can't be reached, or its rate limit
+// is spent, we give the image the benefit of the doubt, and in the latter
+// case come back once the limit allows.
+func (c *Reconciler) validateImages(ctx context.Context, thing *v1alpha1.WithPod, want map[string]caching.Image, status *v1alpha1.Status) error {
+	logger := logging.FromContext(ctx)
+
+	secrets := &pullSecrets{c: c, thing: thing}
+	auth := secrets.auth()
+	var retry time.Duration
+	for ref := range want {
+		result, err := c.checker.Check(ctx, ref, auth)
+		if te, ok := err.(*registry.ThrottledError); ok {
+			if retry == 0 || te.Delay < retry {
+				retry = te.Delay
+			}
+			continue
+		} else if err != nil {
+			logger.Warnf("Unable to validate image %q: %v", ref, err)
+			continue
+		}
+		if result == registry.Found {
+			continue
+		}
+		delete(want, ref)
+		status.ImageErrors = append(status.ImageErrors, imageError(result, ref))
+	}
+	sort.Strings(status.ImageErrors)
+	if secrets.err != nil {
+		// Try again, rather than judge images without their credentials.
+		return secrets.err
+	}
+	if retry > 0 {
+		logger.Infof("Validating images again in %v, once their registries' rate limits allow", retry)
+		c.enqueueAfter(thing.Namespace+"/"+thing.Name, retry)
+	}
+	return nil
+}
+
+// pullSecrets resolves the credentials in the pull secrets of a resource's
+// pod template.  We read these on demand (and only when the checker doesn't
+// remember a result), rather than watching Secrets, so that we never hold on
+// to their contents.
+type pullSecrets struct {
+	c     *Reconciler
+	thing *v1alpha1.WithPod
+
+	keychain registry.Keychain
+	// err is the error reading the secrets, if any.
+	err error
+}
+
+// auth returns the registry.Auth of the pull secrets, identified by their
+// names.
+func (ps *pullSecrets) auth() registry.Auth {
+	secrets := ps.thing.Spec.Template.Spec.ImagePullSecrets
+	if len(secrets) == 0 {
+		return registry.Auth{}
+	}
+	names := make([]string, 0, len(secrets))
+	for _, ref := range secrets {
+		names = append(names, ref.Name)
+	}
+	return registry.Auth{
+		ID:       ps.thing.Namespace + "/" + strings.Join(names, ","),
+		Keychain: ps.resolve,
+	}
+}
+
+// resolve reads the pull secrets once for all of the resource's images.
+func (ps *pullSecrets) resolve() (registry.Keychain, error) {
+	if ps.keychain == nil && ps.err == nil {
+		ps.keychain, ps.err = ps.c.keychainFor(ps.thing)
+	}
+	return ps.keychain, ps.err
+}
+
+// keychainFor returns the credentials in the pull secrets of the resource's
+// pod template.
+func (c *Reconciler) keychainFor(thing *v1alpha1.WithPod) (registry.Keychain, error) {
+	keychain := registry.Keychain{}
+	for _, ref := range thing.Spec.Template.Spec.ImagePullSecrets {
+		secret, err := c.dynamicClient.Resource(v1alpha1.SecretsResource).
+			Namespace(thing.Namespace).Get(ref.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			// Like the kubelet, carry on without it.
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		data, err := secretData(secret)
+		if err != nil {
+			return nil, fmt.Errorf("secret %q: %v", ref.Name, err)
+		}
+		secretType, _, _ := unstructured.NestedString(secret.Object, "type")
+		if err := keychain.AddSecret(corev1.SecretType(secretType), data); err != nil {
+			c.Logger.Warnf("Ignoring pull secret %s/%s: %v", thing.Namespace, ref.Name, err)
+		}
+	}
+	return keychain, nil
+}
+
+// secretData returns the decoded data of the given Secret.
+func secretData(secret *unstructured.Unstructured) (map[string][]byte, error) {
+	encoded, _, err := unstructured.NestedStringMap(secret.Object, "data")
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string][]byte, len(encoded))
+	for k, v := range encoded {
+		raw, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("malformed %s: %v", k, err)
+		}
+		data[k] = raw
+	}
+	return data, nil
+}
+
+// newImageErrors returns the image errors in status that weren't reported
+// before, so that we only report an Event for each once.
+func newImageErrors(previous, status *v1alpha1.Status) []string {
+	seen := make(map[string]bool)
+	if previous != nil {
+		for _, e := range previous.ImageErrors {
+			seen[e] = true
+		}
+	}
+	var fresh []string
+	for _, e := range status.ImageErrors {
+		if !seen[e] {
+			fresh = append(fresh, e)
+		}
+	}
+	return fresh
+}
+
+// reportImageErrors reports a Warning Event on the resource for each of
+// the given image errors.
+func (c *Reconciler) reportImageErrors(thing *v1alpha1.WithPod, imageErrors []string) error {
+	for _, e := range imageErrors {
+		reason := reasonImageUnauthorized
+		if strings.HasPrefix(e, notFoundPrefix) {
+			reason = reasonImageNotFound
+		}
+		if _, err := c.dynamicClient.Resource(eventsResource).Namespace(thing.Namespace).Create(
+			makeEvent(thing, c.gvk, reason, e, c.clock.Now())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// makeEvent returns a Warning Event about the resource.
+func makeEvent(thing *v1alpha1.WithPod, gvk schema.GroupVersionKind, reason, message string, now time.Time) *unstructured.Unstructured {
+	apiVersion, kind := gvk.ToAPIVersionAndKind()
+	ts := metav1.NewTime(now).Rfc3339Copy().Format(time.RFC3339)
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Event",
+		"metadata": map[string]interface{}{
+			"generateName": thing.Name + ".",
+			"namespace":    thing.Namespace,
+		},
+		"involvedObject": map[string]interface{}{
+			"apiVersion":      apiVersion,
+			"kind":            kind,
+			"namespace":       thing.Namespace,
+			"name":            thing.Name,
+			"uid":             string(thing.UID),
+			"resourceVersion": thing.ResourceVersion,
+		},
+		"reason":         reason,
+		"message":        message,
+		"type":           corev1.EventTypeWarning,
+		"source":         map[string]interface{}{"component": controllerAgentName},
+		"firstTimestamp": ts,
+		"lastTimestamp":  ts,
+		"count":          int64(1),
+	}}
+}
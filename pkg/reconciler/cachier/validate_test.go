@@ -0,0 +1,88 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cachier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/registry"
+)
+
+func TestNewImageErrors(t *testing.T) {
+	notFound := imageError(registry.NotFound, "gcr.io/foo/bar:v1")
+	unauthorized := imageError(registry.Unauthorized, "gcr.io/foo/baz:v1")
+
+	tests := []struct {
+		name     string
+		previous *v1alpha1.Status
+		status   *v1alpha1.Status
+		want     []string
+	}{{
+		name:   "no previous status",
+		status: &v1alpha1.Status{ImageErrors: []string{notFound}},
+		want:   []string{notFound},
+	}, {
+		name:     "already reported",
+		previous: &v1alpha1.Status{ImageErrors: []string{notFound}},
+		status:   &v1alpha1.Status{ImageErrors: []string{notFound, unauthorized}},
+		want:     []string{unauthorized},
+	}, {
+		name:     "fixed",
+		previous: &v1alpha1.Status{ImageErrors: []string{notFound}},
+		status:   &v1alpha1.Status{},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := newImageErrors(test.previous, test.status)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("newImageErrors (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestReportImageErrors(t *testing.T) {
+	f := newFixture(t)
+	notFound := imageError(registry.NotFound, "gcr.io/foo/bar:v1")
+	if err := f.r.reportImageErrors(deployment(nil, "gcr.io/foo/bar:v1"), []string{notFound}); err != nil {
+		t.Fatalf("reportImageErrors() = %v", err)
+	}
+
+	// The Events are stamped with the time of the Reconciler's clock.
+	events := f.dynamic.Creates()
+	if len(events) != 1 {
+		t.Fatalf("reportImageErrors() created %d Events, wanted 1", len(events))
+	}
+	want := map[string]interface{}{
+		"reason":         reasonImageNotFound,
+		"message":        notFound,
+		"firstTimestamp": f.r.clock.Now().Format(time.RFC3339),
+		"lastTimestamp":  f.r.clock.Now().Format(time.RFC3339),
+	}
+	got := map[string]interface{}{}
+	for k := range want {
+		got[k] = events[0].Object[k]
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Event (-want, +got) = %v", diff)
+	}
+}
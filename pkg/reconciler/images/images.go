@@ -0,0 +1,149 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package images holds the writes to the Images API that the controllers
+// share, which are shaped to keep load on the API server down.
+package images
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	cachingv1alpha1 "github.com/knative/caching/pkg/client/clientset/versioned/typed/caching/v1alpha1"
+	cachinglisters "github.com/knative/caching/pkg/client/listers/caching/v1alpha1"
+	"github.com/knative/pkg/kmeta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/budget"
+	"github.com/mattmoor/cachier/pkg/reconciler/cachier/resources"
+)
+
+// Parallelism bounds the number of Images that Create creates at once.
+const Parallelism = 8
+
+// Create creates the given Images, up to Parallelism at a time.  It returns
+// the created Images (in the same order, with nil for those that failed)
+// and the first error encountered.
+func Create(client cachingv1alpha1.ImagesGetter, imgs []caching.Image) ([]*caching.Image, error) {
+	out := make([]*caching.Image, len(imgs))
+	if len(imgs) == 1 {
+		// Don't bother with goroutines for the common case.
+		img, err := client.Images(imgs[0].Namespace).Create(&imgs[0])
+		if err != nil {
+			return out, err
+		}
+		out[0] = img
+		return out, nil
+	}
+
+	var (
+		wg       sync.WaitGroup
+		m        sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, Parallelism)
+	for i := range imgs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			img, err := client.Images(imgs[i].Namespace).Create(&imgs[i])
+			if err != nil {
+				m.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				m.Unlock()
+				return
+			}
+			out[i] = img
+		}(i)
+	}
+	wg.Wait()
+	return out, firstErr
+}
+
+// DeleteStale deletes the Images in the namespace that match the selector
+// (e.g. those of older generations of a resource).  It consults the lister
+// first, so that the API server is only asked to delete anything when there
+// is something to delete.
+func DeleteStale(client cachingv1alpha1.ImagesGetter, lister cachinglisters.ImageLister, namespace string, selector labels.Selector) error {
+	stale, err := lister.Images(namespace).List(selector)
+	if err != nil {
+		return err
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	propPolicy := metav1.DeletePropagationForeground
+	return client.Images(namespace).DeleteCollection(
+		&metav1.DeleteOptions{PropagationPolicy: &propPolicy},
+		metav1.ListOptions{LabelSelector: selector.String()},
+	)
+}
+
+// Reconcile creates the Images that are missing for the current generation of
+// thing (as the budgets of their registries admit them under id), and
+// deletes those of older generations.  It returns the Image of each of
+// thing's images, keyed by reference, with nil for those the budget
+// deferred, and how long until it may admit them (zero if it deferred none).
+func Reconcile(client cachingv1alpha1.ImagesGetter, lister cachinglisters.ImageLister, b *budget.Budget, id string, thing *v1alpha1.WithPod) (map[string]*caching.Image, time.Duration, error) {
+	got, err := lister.Images(thing.Namespace).List(kmeta.MakeGenerationLabelSelector(thing))
+	if err != nil {
+		return nil, 0, err
+	}
+	have := make(map[string]*caching.Image, len(got))
+	for _, img := range got {
+		have[img.Spec.Image] = img
+	}
+
+	// Ask the budget about the missing Images in a deterministic order, to
+	// make testing sane.
+	want := resources.MakeImages(thing)
+	missing := make([]string, 0, len(want))
+	for ref := range want {
+		if _, ok := have[ref]; !ok {
+			missing = append(missing, ref)
+		}
+	}
+	sort.Strings(missing)
+	admitted, retry := b.Admit(id, missing)
+	create := make([]caching.Image, 0, len(admitted))
+	for _, ref := range admitted {
+		create = append(create, want[ref])
+	}
+	created, err := Create(client, create)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	images := make(map[string]*caching.Image, len(want))
+	for ref := range want {
+		images[ref] = have[ref]
+	}
+	for _, img := range created {
+		images[img.Spec.Image] = img
+	}
+
+	// Delete any Image resource for older versions.
+	err = DeleteStale(client, lister, thing.Namespace, kmeta.MakeOldGenerationLabelSelector(thing))
+	return images, retry, err
+}
@@ -0,0 +1,215 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package images
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	"github.com/knative/pkg/kmeta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+
+	rtesting "github.com/mattmoor/cachier/pkg/reconciler/testing"
+)
+
+// trackInFlight has the client's Creates take delay, and returns the most
+// that were in flight at once.
+func trackInFlight(client *rtesting.Caching, delay time.Duration) (maxInFlight func() int32) {
+	var inFlight, max int32
+	client.React(func(a rtesting.Action) error {
+		if a.Verb != rtesting.VerbCreate {
+			return nil
+		}
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(delay)
+		return nil
+	})
+	return func() int32 {
+		return atomic.LoadInt32(&max)
+	}
+}
+
+func makeImages(n int) []caching.Image {
+	imgs := make([]caching.Image, n)
+	for i := range imgs {
+		imgs[i] = caching.Image{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: fmt.Sprintf("img-%d", i)},
+			Spec:       caching.ImageSpec{Image: fmt.Sprintf("gcr.io/foo/bar:%d", i)},
+		}
+	}
+	return imgs
+}
+
+func TestCreate(t *testing.T) {
+	client := rtesting.NewCaching()
+	maxInFlight := trackInFlight(client, 5*time.Millisecond)
+	imgs := makeImages(3 * Parallelism)
+
+	got, err := Create(client.CachingV1alpha1(), imgs)
+	if err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+	for i := range imgs {
+		if diff := cmp.Diff(&imgs[i], got[i]); diff != "" {
+			t.Errorf("Create[%d] (-want, +got) = %v", i, diff)
+		}
+	}
+	if got, want := len(client.Created()), len(imgs); got != want {
+		t.Errorf("creates = %d, wanted %d", got, want)
+	}
+	if got := maxInFlight(); got > Parallelism || got < 2 {
+		t.Errorf("maxInFlight = %d, wanted between 2 and %d", got, Parallelism)
+	}
+}
+
+func TestCreateError(t *testing.T) {
+	imgs := makeImages(5)
+	client := rtesting.NewCaching()
+	client.React(func(a rtesting.Action) error {
+		if img, ok := a.Object.(*caching.Image); ok && img.Spec.Image == imgs[2].Spec.Image {
+			return errors.New("boom")
+		}
+		return nil
+	})
+
+	got, err := Create(client.CachingV1alpha1(), imgs)
+	if err == nil {
+		t.Fatal("Create() = nil, wanted error")
+	}
+	if got[2] != nil {
+		t.Errorf("Create[2] = %v, wanted nil", got[2])
+	}
+	// The others are still created.
+	if got := len(client.Created()); got != 4 {
+		t.Errorf("creates = %d, wanted 4", got)
+	}
+}
+
+func TestDeleteStale(t *testing.T) {
+	stale := makeImages(1)[0]
+	stale.Labels = map[string]string{"generation": "00001"}
+	selector := labels.SelectorFromSet(labels.Set{"generation": "00001"})
+
+	tests := []struct {
+		name      string
+		imgs      []*caching.Image
+		namespace string
+		want      int
+	}{{
+		name:      "nothing stale",
+		namespace: "ns",
+	}, {
+		name:      "stale in another namespace",
+		imgs:      []*caching.Image{&stale},
+		namespace: "other",
+	}, {
+		name:      "stale",
+		imgs:      []*caching.Image{&stale},
+		namespace: "ns",
+		want:      1,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := rtesting.NewCaching(test.imgs...)
+			if err := DeleteStale(client.CachingV1alpha1(), client.ImageLister(), test.namespace, selector); err != nil {
+				t.Fatalf("DeleteStale() = %v", err)
+			}
+			if got := len(client.Take(rtesting.VerbDeleteCollection)); got != test.want {
+				t.Errorf("deleteCollections = %d, wanted %d", got, test.want)
+			}
+		})
+	}
+}
+
+// BenchmarkSteadyStateWrites compares the writes made by reconciling
+// resources whose Images are all up to date (e.g. on resync), the way we
+// used to (a DeleteCollection for older generations, and another for the
+// current one when caching is disabled, on every reconcile) with DeleteStale.
+// It reports the API calls made per reconcile as calls/op.
+func BenchmarkSteadyStateWrites(b *testing.B) {
+	const workloads = 1000
+	var imgs []*caching.Image
+	things := make([]*metav1.ObjectMeta, workloads)
+	for i := range things {
+		things[i] = &metav1.ObjectMeta{
+			Namespace:  "ns",
+			Name:       fmt.Sprintf("thing-%d", i),
+			UID:        types.UID(fmt.Sprintf("uid-%d", i)),
+			Generation: 2,
+		}
+		if i%10 == 0 {
+			// Caching is disabled for one in ten resources, which have
+			// no Images.
+			continue
+		}
+		img := makeImages(1)[0]
+		img.Name = things[i].Name
+		img.Labels = map[string]string{
+			"controller": string(things[i].UID),
+			"generation": "00002",
+		}
+		imgs = append(imgs, &img)
+	}
+
+	reconcile := map[string]func(client *rtesting.Caching, thing *metav1.ObjectMeta, cached bool){
+		"before": func(client *rtesting.Caching, thing *metav1.ObjectMeta, cached bool) {
+			propPolicy := metav1.DeletePropagationForeground
+			if !cached {
+				client.CachingV1alpha1().Images(thing.Namespace).DeleteCollection(
+					&metav1.DeleteOptions{PropagationPolicy: &propPolicy},
+					metav1.ListOptions{LabelSelector: kmeta.MakeGenerationLabelSelector(thing).String()})
+			}
+			client.CachingV1alpha1().Images(thing.Namespace).DeleteCollection(
+				&metav1.DeleteOptions{PropagationPolicy: &propPolicy},
+				metav1.ListOptions{LabelSelector: kmeta.MakeOldGenerationLabelSelector(thing).String()})
+		},
+		"after": func(client *rtesting.Caching, thing *metav1.ObjectMeta, cached bool) {
+			if !cached {
+				DeleteStale(client.CachingV1alpha1(), client.ImageLister(), thing.Namespace, kmeta.MakeGenerationLabelSelector(thing))
+			}
+			DeleteStale(client.CachingV1alpha1(), client.ImageLister(), thing.Namespace, kmeta.MakeOldGenerationLabelSelector(thing))
+		},
+	}
+
+	for _, name := range []string{"before", "after"} {
+		b.Run(name, func(b *testing.B) {
+			client := rtesting.NewCaching(imgs...)
+			calls := 0
+			for i := 0; i < b.N; i++ {
+				thing := things[i%workloads]
+				reconcile[name](client, thing, i%10 != 0)
+				calls += len(client.Take(rtesting.VerbCreate)) + len(client.Take(rtesting.VerbDeleteCollection))
+			}
+			b.ReportMetric(float64(calls)/float64(b.N), "calls/op")
+		})
+	}
+}
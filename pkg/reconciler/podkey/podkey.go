@@ -0,0 +1,40 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package podkey names Pods in the work queues of resources, so that the
+// owners of Pods are resolved on the workers (since that may take API
+// calls) rather than in the informers' handlers.
+package podkey
+
+import "strings"
+
+// Prefix marks the names in the work queue keys of Pods.  Resource names
+// can't contain it, nor can UIDs.
+const Prefix = "pod:"
+
+// Key returns the work queue key of the Pod.
+func Key(namespace, name string) string {
+	return namespace + "/" + Prefix + name
+}
+
+// Parse returns the name of the Pod, given the name part of a work queue key
+// (e.g. from cache.SplitMetaNamespaceKey), and whether it names a Pod.
+func Parse(name string) (string, bool) {
+	if !strings.HasPrefix(name, Prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(name, Prefix), true
+}
@@ -0,0 +1,39 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podkey
+
+import (
+	"testing"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestRoundTrip(t *testing.T) {
+	namespace, name, err := cache.SplitMetaNamespaceKey(Key("ns", "web-abc-1"))
+	if err != nil {
+		t.Fatalf("SplitMetaNamespaceKey() = %v", err)
+	}
+	if namespace != "ns" {
+		t.Errorf("namespace = %q, wanted ns", namespace)
+	}
+	if pod, ok := Parse(name); !ok || pod != "web-abc-1" {
+		t.Errorf("Parse(%q) = %q, %v, wanted web-abc-1, true", name, pod, ok)
+	}
+	if pod, ok := Parse("web"); ok {
+		t.Errorf("Parse(web) = %q, true, wanted false", pod)
+	}
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package podowners implements a controller that routes the events of Pods
+// to the work queues of the resources in their owner chains, so that the
+// controllers that learn from Pods share one Pod handler, and each Pod's
+// owners are resolved once rather than once per controller.
+package podowners
+
+import (
+	"context"
+	"sync"
+
+	"github.com/knative/pkg/controller"
+	"github.com/knative/pkg/logging"
+	"github.com/knative/pkg/logging/logkey"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/fairqueue"
+	"github.com/mattmoor/cachier/pkg/ownerchain"
+	"github.com/mattmoor/cachier/pkg/sharding"
+)
+
+const controllerAgentName = "podowners-controller"
+
+// Router is the controller implementation for Pods, keyed by their
+// namespace/name, which enqueues the owners of each Pod with the controller
+// that registered their kind.
+type Router struct {
+	// For reading the state of the world.
+	podLister cache.GenericLister
+
+	// For following the owner chains of Pods.
+	resolver *ownerchain.Resolver
+
+	// For only routing the Pods in the namespaces that this replica owns,
+	// when sharding.
+	shard *sharding.Sharder
+
+	// For queueing Pods for the workers.
+	enqueue func(key string)
+
+	// The enqueue funcs of the controllers, by the kind they reconcile.
+	m      sync.RWMutex
+	routes map[schema.GroupVersionKind]func(key string)
+
+	// Sugared logger is easier to use but is not as performant as the
+	// raw logger. In performance critical paths, call logger.Desugar()
+	// and use the returned raw logger instead. In addition to the
+	// performance benefits, raw logger also preserves type-safety at
+	// the expense of slightly greater verbosity.
+	Logger *zap.SugaredLogger
+}
+
+// Check that we implement the controller.Reconciler interface.
+var _ controller.Reconciler = (*Router)(nil)
+
+// NewController returns a new Pod routing controller, and the Router with
+// which controllers register the kinds they reconcile.  The elements of
+// podInformer must be *v1alpha1.PodImages.
+func NewController(
+	logger *zap.SugaredLogger,
+	podInformer cache.SharedIndexInformer,
+	resolver *ownerchain.Resolver,
+	shard *sharding.Sharder,
+) (*fairqueue.Impl, *Router) {
+	r := &Router{
+		podLister: cache.NewGenericLister(podInformer.GetIndexer(),
+			v1alpha1.PodsResource.GroupResource()),
+		resolver: resolver,
+		shard:    shard,
+		routes:   make(map[schema.GroupVersionKind]func(key string)),
+		// Enrich the logs with controller name
+		Logger: logger.Named(controllerAgentName).
+			With(zap.String(logkey.ControllerType, controllerAgentName)),
+	}
+	impl, _ := fairqueue.NewImpl(r, r.Logger, "PodOwners")
+	r.enqueue = func(key string) {
+		impl.WorkQueue.Add(key)
+	}
+
+	r.Logger.Info("Setting up event handlers")
+
+	// Whenever a Pod starts running, enqueue the resources in its owner
+	// chain to learn what it runs.
+	podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    r.onPod,
+		UpdateFunc: podPhaseChanged(r.onPod),
+	})
+
+	return impl, r
+}
+
+// Route has the Router enqueue the namespace/name keys of the resources of
+// the given kind in the owner chains of Pods with enqueue.
+func (r *Router) Route(gvk schema.GroupVersionKind, enqueue func(key string)) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.routes[gvk] = enqueue
+}
+
+// onPod enqueues the Pod, whose owners we resolve on the workers, since that
+// may take API calls.
+func (r *Router) onPod(obj interface{}) {
+	pod, ok := obj.(*v1alpha1.PodImages)
+	if !ok || !r.shard.Owns(pod.Namespace) {
+		return
+	}
+	r.enqueue(pod.Namespace + "/" + pod.Name)
+}
+
+// Reconcile implements controller.Reconciler
+func (r *Router) Reconcile(ctx context.Context, key string) error {
+	logger := logging.FromContext(ctx)
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		logger.Errorf("invalid resource key: %s", key)
+		return nil
+	}
+
+	obj, err := r.podLister.ByNamespace(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	chain, err := r.resolver.Chain(obj.(*v1alpha1.PodImages))
+	if err != nil {
+		return err
+	}
+
+	r.m.RLock()
+	defer r.m.RUnlock()
+	for _, ref := range chain {
+		if enqueue, ok := r.routes[schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)]; ok {
+			enqueue(namespace + "/" + ref.Name)
+		}
+	}
+	return nil
+}
+
+// podPhaseChanged filters Pod updates to those where the phase changed, which
+// is when a Pod we previously skipped may start running.
+func podPhaseChanged(f func(interface{})) func(interface{}, interface{}) {
+	return func(first, second interface{}) {
+		before, ok1 := first.(*v1alpha1.PodImages)
+		after, ok2 := second.(*v1alpha1.PodImages)
+		if ok1 && ok2 && before.Status.Phase != after.Status.Phase {
+			f(second)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podowners
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/knative/pkg/logging"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/ownerchain"
+	rtesting "github.com/mattmoor/cachier/pkg/reconciler/testing"
+)
+
+func TestRoute(t *testing.T) {
+	isController := true
+	rs := &unstructured.Unstructured{}
+	rs.SetAPIVersion("apps/v1")
+	rs.SetKind("ReplicaSet")
+	rs.SetNamespace("ns")
+	rs.SetName("web-abc")
+	rs.SetUID("rs-uid")
+	rs.SetOwnerReferences([]metav1.OwnerReference{{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Name:       "web",
+		UID:        "web-uid",
+		Controller: &isController,
+	}})
+	pod := &v1alpha1.PodImages{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "web-abc-1",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "apps/v1",
+				Kind:       "ReplicaSet",
+				Name:       "web-abc",
+				UID:        "rs-uid",
+				Controller: &isController,
+			}},
+		},
+	}
+
+	client := rtesting.NewDynamic()
+	client.Add(rs)
+	pods := rtesting.NewIndexer()
+	pods.Add(pod)
+	var queued []string
+	r := &Router{
+		podLister: cache.NewGenericLister(pods, v1alpha1.PodsResource.GroupResource()),
+		resolver:  ownerchain.NewResolver(client, 10),
+		routes:    make(map[schema.GroupVersionKind]func(key string)),
+		enqueue: func(key string) {
+			queued = append(queued, key)
+		},
+		Logger: zap.NewNop().Sugar(),
+	}
+	routed := map[string][]string{}
+	for _, kind := range []string{"Deployment", "StatefulSet"} {
+		kind := kind
+		r.Route(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: kind}, func(key string) {
+			routed[kind] = append(routed[kind], key)
+		})
+	}
+
+	// The handler leaves resolving the Pod's owners, which may take API
+	// calls, to the workers.
+	r.onPod(pod)
+	if diff := cmp.Diff([]string{"ns/web-abc-1"}, queued); diff != "" {
+		t.Errorf("onPod() queued (-want, +got) = %v", diff)
+	}
+	if got := client.Gets(); got != 0 {
+		t.Errorf("onPod() made %d Gets, wanted none", got)
+	}
+
+	// Which enqueue the owners of the kinds that are routed, once each.
+	if err := r.Reconcile(logging.WithLogger(context.Background(), zap.NewNop().Sugar()), queued[0]); err != nil {
+		t.Fatalf("Reconcile(%s) = %v", queued[0], err)
+	}
+	if diff := cmp.Diff(map[string][]string{"Deployment": {"ns/web"}}, routed); diff != "" {
+		t.Errorf("Reconcile(%s) routed (-want, +got) = %v", queued[0], diff)
+	}
+
+	// Pods that are gone are skipped.
+	if err := r.Reconcile(logging.WithLogger(context.Background(), zap.NewNop().Sugar()), "ns/gone"); err != nil {
+		t.Fatalf("Reconcile(ns/gone) = %v", err)
+	}
+}
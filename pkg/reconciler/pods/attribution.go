@@ -0,0 +1,178 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pods
+
+import (
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Owner identifies the top-level owner of Pods.
+type Owner struct {
+	Namespace string
+	UID       types.UID
+}
+
+// Key returns the workqueue key of the Owner.
+func (o Owner) Key() string {
+	return o.Namespace + "/" + string(o.UID)
+}
+
+// ownerState is what we track about each Owner with running Pods.
+type ownerState struct {
+	ref  metav1.OwnerReference
+	pods sets.String
+	// lastSeen records when each of the Owner's images was last seen in
+	// one of its Pods (or, for Images we find, when we found them).
+	lastSeen map[string]time.Time
+}
+
+// Attribution tracks which top-level owner each Pod belongs to.  It holds
+// one entry per Pod and per Owner with Pods, so its size is bounded by the
+// number of Pods.  It is safe for concurrent use.
+type Attribution struct {
+	mu     sync.Mutex
+	pods   map[string]Owner
+	owners map[Owner]*ownerState
+}
+
+// NewAttribution returns an empty Attribution.
+func NewAttribution() *Attribution {
+	return &Attribution{
+		pods:   make(map[string]Owner),
+		owners: make(map[Owner]*ownerState),
+	}
+}
+
+// Attribute records that the Pod with the given key (in the given namespace)
+// belongs to the owner referenced by ref.  It returns the Owner that the Pod
+// was previously attributed to, if that differs.
+func (a *Attribution) Attribute(podKey, namespace string, ref metav1.OwnerReference) *Owner {
+	owner := Owner{Namespace: namespace, UID: ref.UID}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var previous *Owner
+	if old, ok := a.pods[podKey]; ok && old != owner {
+		a.forget(podKey, old)
+		previous = &old
+	}
+	a.pods[podKey] = owner
+
+	state, ok := a.owners[owner]
+	if !ok {
+		state = &ownerState{
+			ref:      ref,
+			pods:     sets.NewString(),
+			lastSeen: make(map[string]time.Time),
+		}
+		a.owners[owner] = state
+	}
+	state.pods.Insert(podKey)
+	return previous
+}
+
+// Forget stops tracking the Pod with the given key, returning the Owner it
+// was attributed to, if any.
+func (a *Attribution) Forget(podKey string) *Owner {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	owner, ok := a.pods[podKey]
+	if !ok {
+		return nil
+	}
+	a.forget(podKey, owner)
+	return &owner
+}
+
+// forget removes the Pod from its Owner, and the Owner once it has no Pods.
+// a.mu must be held.
+func (a *Attribution) forget(podKey string, owner Owner) {
+	delete(a.pods, podKey)
+	if state, ok := a.owners[owner]; ok {
+		state.pods.Delete(podKey)
+		if state.pods.Len() == 0 {
+			delete(a.owners, owner)
+		}
+	}
+}
+
+// Get returns the OwnerReference of the Owner and the keys of its Pods, or
+// false if it has no Pods.
+func (a *Attribution) Get(owner Owner) (metav1.OwnerReference, []string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state, ok := a.owners[owner]
+	if !ok {
+		return metav1.OwnerReference{}, nil, false
+	}
+	return state.ref, state.pods.List(), true
+}
+
+// Owners returns the Owners with Pods in the given namespace, or in every
+// namespace when it is empty.
+func (a *Attribution) Owners(namespace string) []Owner {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var owners []Owner
+	for owner := range a.owners {
+		if namespace == "" || owner.Namespace == namespace {
+			owners = append(owners, owner)
+		}
+	}
+	return owners
+}
+
+// Observe records that the observed images were seen in the Owner's Pods at
+// now, and that the existing images (e.g. those with Images) were seen no
+// later than now.  It forgets any other images, and returns when each of the
+// remaining images was last seen.
+func (a *Attribution) Observe(owner Owner, observed, existing []string, now time.Time) map[string]time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state, ok := a.owners[owner]
+	if !ok {
+		return nil
+	}
+	lastSeen := make(map[string]time.Time, len(observed)+len(existing))
+	for _, image := range existing {
+		if t, ok := state.lastSeen[image]; ok {
+			lastSeen[image] = t
+		} else {
+			lastSeen[image] = now
+		}
+	}
+	for _, image := range observed {
+		lastSeen[image] = now
+	}
+	state.lastSeen = lastSeen
+
+	out := make(map[string]time.Time, len(lastSeen))
+	for k, v := range lastSeen {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pods
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAttribution(t *testing.T) {
+	a := NewAttribution()
+	deploy := metav1.OwnerReference{Kind: "Deployment", Name: "foo", UID: "deploy"}
+	job := metav1.OwnerReference{Kind: "Job", Name: "bar", UID: "job"}
+
+	if prev := a.Attribute("ns/pod-1", "ns", deploy); prev != nil {
+		t.Errorf("Attribute() = %v, wanted nil", prev)
+	}
+	a.Attribute("ns/pod-2", "ns", deploy)
+	if prev := a.Attribute("ns/pod-2", "ns", deploy); prev != nil {
+		t.Errorf("Attribute() = %v, wanted nil", prev)
+	}
+
+	ref, pods, ok := a.Get(Owner{Namespace: "ns", UID: "deploy"})
+	if !ok {
+		t.Fatal("Get() = false, wanted true")
+	}
+	if diff := cmp.Diff(deploy, ref); diff != "" {
+		t.Errorf("Get (-want, +got) = %v", diff)
+	}
+	if diff := cmp.Diff([]string{"ns/pod-1", "ns/pod-2"}, pods); diff != "" {
+		t.Errorf("Get (-want, +got) = %v", diff)
+	}
+
+	// Re-attributing a Pod reports its previous owner.
+	prev := a.Attribute("ns/pod-2", "ns", job)
+	if want := (&Owner{Namespace: "ns", UID: "deploy"}); !cmp.Equal(want, prev) {
+		t.Errorf("Attribute() = %v, wanted %v", prev, want)
+	}
+
+	// Owners are forgotten along with their last Pod.
+	if owner := a.Forget("ns/pod-1"); owner == nil || owner.UID != "deploy" {
+		t.Errorf("Forget() = %v, wanted deploy", owner)
+	}
+	if _, _, ok := a.Get(Owner{Namespace: "ns", UID: "deploy"}); ok {
+		t.Error("Get() = true after forgetting its Pods, wanted false")
+	}
+	if owner := a.Forget("ns/pod-1"); owner != nil {
+		t.Errorf("Forget() = %v, wanted nil", owner)
+	}
+	if got, want := len(a.pods)+len(a.owners), 2; got != want {
+		t.Errorf("Attribution holds %d entries, wanted %d", got, want)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	a := NewAttribution()
+	owner := Owner{Namespace: "ns", UID: "deploy"}
+	a.Attribute("ns/pod-1", "ns", metav1.OwnerReference{UID: "deploy"})
+
+	t0 := time.Unix(1000, 0)
+	got := a.Observe(owner, []string{"app:v1"}, []string{"stale"}, t0)
+	want := map[string]time.Time{"app:v1": t0, "stale": t0}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Observe (-want, +got) = %v", diff)
+	}
+
+	// After a rollout, the old image keeps when it was last seen.
+	t1 := t0.Add(time.Minute)
+	got = a.Observe(owner, []string{"app:v2"}, []string{"app:v1", "app:v2"}, t1)
+	want = map[string]time.Time{"app:v1": t0, "app:v2": t1}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Observe (-want, +got) = %v", diff)
+	}
+
+	if got := a.Observe(Owner{Namespace: "ns", UID: "other"}, nil, nil, t1); got != nil {
+		t.Errorf("Observe() = %v for unknown owner, wanted nil", got)
+	}
+}
@@ -0,0 +1,515 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package pods implements a controller that caches the images that Pods
+// actually run, attributing each Pod's images to its top-level owner.
+package pods
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	cachingclientset "github.com/knative/caching/pkg/client/clientset/versioned"
+	cachinginformers "github.com/knative/caching/pkg/client/informers/externalversions/caching/v1alpha1"
+	cachinglisters "github.com/knative/caching/pkg/client/listers/caching/v1alpha1"
+	"github.com/knative/pkg/controller"
+	"github.com/knative/pkg/logging"
+	"github.com/knative/pkg/logging/logkey"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/budget"
+	"github.com/mattmoor/cachier/pkg/fairqueue"
+	"github.com/mattmoor/cachier/pkg/ownerchain"
+	"github.com/mattmoor/cachier/pkg/policy"
+	"github.com/mattmoor/cachier/pkg/reconciler/cachier/resources"
+	"github.com/mattmoor/cachier/pkg/reconciler/images"
+	"github.com/mattmoor/cachier/pkg/reconciler/podkey"
+	"github.com/mattmoor/cachier/pkg/sharding"
+)
+
+const controllerAgentName = "pods-controller"
+
+// Reconciler is the controller implementation for the top-level owners of
+// Pods.  It is keyed by the namespace and UID of each owner, and by Pod (see
+// podkey.Key) to attribute Pods to their owners.
+type Reconciler struct {
+	// For creating/deleting caching resources.
+	cachingclient cachingclientset.Interface
+
+	// For reading the state of the world.
+	podLister       cache.GenericLister
+	imageLister     cachinglisters.ImageLister
+	namespaceLister cache.GenericLister
+
+	// For attributing Pods to their top-level owners.
+	resolver    *ownerchain.Resolver
+	attribution *Attribution
+
+	// The mode for owners whose Namespace doesn't decide.
+	defaultMode cachierv1alpha1.Mode
+
+	// For telling the time, and revisiting owners once images that their
+	// Pods stopped running have been gone for the grace period.
+	clock        clock.Clock
+	grace        time.Duration
+	enqueueAfter func(key string, d time.Duration)
+
+	// For limiting the rate at which we create Images for each registry.
+	budget *budget.Budget
+
+	// For only reconciling the namespaces that this replica owns, when
+	// sharding.
+	shard *sharding.Sharder
+
+	// Sugared logger is easier to use but is not as performant as the
+	// raw logger. In performance critical paths, call logger.Desugar()
+	// and use the returned raw logger instead. In addition to the
+	// performance benefits, raw logger also preserves type-safety at
+	// the expense of slightly greater verbosity.
+	Logger *zap.SugaredLogger
+}
+
+// Check that we implement the controller.Reconciler interface.
+var _ controller.Reconciler = (*Reconciler)(nil)
+
+// Options configures the controller.
+type Options struct {
+	// PodInformer watches Pods.  Its elements must be *v1alpha1.PodImages.
+	PodInformer cache.SharedIndexInformer
+
+	// Resolver follows the owner chains of Pods.
+	Resolver *ownerchain.Resolver
+
+	// NamespaceInformer, when set, enables the decorate label and annotation
+	// on Namespaces.  Its elements must be *v1alpha1.WithMetadata.
+	NamespaceInformer cache.SharedIndexInformer
+
+	// DefaultMode is the mode for owners whose Namespace doesn't decide.
+	// Defaults to OptOut.
+	DefaultMode cachierv1alpha1.Mode
+
+	// Debounce is how long to wait after a Pod changes before reconciling
+	// its owner, and how long an image must go unseen in the owner's Pods
+	// before its Image is deleted.  This keeps Pod churn (e.g. rollouts)
+	// from causing Image churn.
+	Debounce time.Duration
+
+	// Budget, when set, limits the rate at which Images are created for the
+	// images of each registry.
+	Budget *budget.Budget
+
+	// Shard, when set, limits reconciliation to the namespaces that this
+	// replica owns.
+	Shard *sharding.Sharder
+
+	// Clock tells the time.  Defaults to the real clock.
+	Clock clock.Clock
+}
+
+// NewController returns a new Pod-driven controller
+func NewController(
+	logger *zap.SugaredLogger,
+	cachingClient cachingclientset.Interface,
+	imageInformer cachinginformers.ImageInformer,
+	opts Options,
+) *fairqueue.Impl {
+	clk := opts.Clock
+	if clk == nil {
+		clk = clock.RealClock{}
+	}
+
+	r := &Reconciler{
+		cachingclient: cachingClient,
+		podLister: cache.NewGenericLister(opts.PodInformer.GetIndexer(),
+			v1alpha1.PodsResource.GroupResource()),
+		imageLister: imageInformer.Lister(),
+		resolver:    opts.Resolver,
+		attribution: NewAttribution(),
+		defaultMode: opts.DefaultMode,
+		clock:       clk,
+		grace:       opts.Debounce,
+		budget:      opts.Budget,
+		shard:       opts.Shard,
+		// Enrich the logs with controller name
+		Logger: logger.Named(controllerAgentName).
+			With(zap.String(logkey.ControllerType, controllerAgentName)),
+	}
+	impl, resyncs := fairqueue.NewImpl(r, r.Logger, "Pods")
+	r.enqueueAfter = func(key string, d time.Duration) {
+		impl.WorkQueue.AddAfter(key, d)
+	}
+
+	r.Logger.Info("Setting up event handlers")
+
+	if opts.Shard != nil {
+		// Whenever the replicas change, attribute the Pods in the
+		// namespaces that we now own, forget those in the namespaces that
+		// we don't, and enqueue the owners we keep.
+		opts.Shard.AddListener(func() {
+			objs, err := r.podLister.List(labels.Everything())
+			if err != nil {
+				r.Logger.Errorf("Error listing Pods: %v", err)
+				return
+			}
+			for _, obj := range objs {
+				pod := obj.(*v1alpha1.PodImages)
+				if r.shard.Owns(pod.Namespace) {
+					resyncs.EnqueueKey(podkey.Key(pod.Namespace, pod.Name))
+				} else {
+					r.attribution.Forget(pod.Namespace + "/" + pod.Name)
+				}
+			}
+			for _, owner := range r.attribution.Owners("") {
+				resyncs.EnqueueKey(owner.Key())
+			}
+		})
+	}
+
+	// As Pods come and go, attribute them to their top-level owners, and
+	// (after debouncing) reconcile those owners.  We only track the Pods in
+	// the namespaces that we own.
+	opts.PodInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    r.onPod,
+		UpdateFunc: podImagesChanged(r.onPod),
+		DeleteFunc: r.onPodDeleted,
+	})
+
+	// Recreate Images that are deleted out from under the owners we track.
+	imageInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: r.onImageDeleted,
+	})
+
+	if opts.NamespaceInformer != nil {
+		r.namespaceLister = cache.NewGenericLister(opts.NamespaceInformer.GetIndexer(),
+			v1alpha1.NamespacesResource.GroupResource())
+		opts.NamespaceInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			UpdateFunc: r.namespaceChanged,
+		})
+	}
+
+	return impl
+}
+
+// onPod enqueues the Pod, so that a worker attributes it to its top-level
+// owner.
+func (c *Reconciler) onPod(obj interface{}) {
+	pod, ok := obj.(*v1alpha1.PodImages)
+	if !ok || !c.shard.Owns(pod.Namespace) {
+		return
+	}
+	c.enqueueAfter(podkey.Key(pod.Namespace, pod.Name), 0)
+}
+
+// attribute attributes the Pod to its top-level owner, and enqueues that
+// owner (and any owner the Pod was previously attributed to) after the grace
+// period.  The queue coalesces the adds of an owner that is already waiting,
+// so a burst of Pod events (e.g. a rollout) reconciles it once.
+func (c *Reconciler) attribute(namespace, name string) error {
+	obj, err := c.podLister.ByNamespace(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		// onPodDeleted forgets it.
+		return nil
+	} else if err != nil {
+		return err
+	}
+	pod := obj.(*v1alpha1.PodImages)
+	root, err := c.resolver.Root(pod)
+	if err != nil {
+		return err
+	}
+	if root == nil {
+		// Without an owner, there is nothing to outlive the Pod and own
+		// its Images, so we leave bare Pods alone.
+		return nil
+	}
+	key := namespace + "/" + name
+	if previous := c.attribution.Attribute(key, namespace, *root); previous != nil {
+		c.enqueueAfter(previous.Key(), c.grace)
+	}
+	c.enqueueAfter(Owner{Namespace: namespace, UID: root.UID}.Key(), c.grace)
+	return nil
+}
+
+// onPodDeleted stops tracking the Pod, and enqueues its owner.
+func (c *Reconciler) onPodDeleted(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*v1alpha1.PodImages)
+	if !ok {
+		return
+	}
+	if owner := c.attribution.Forget(pod.Namespace + "/" + pod.Name); owner != nil {
+		c.enqueueAfter(owner.Key(), c.grace)
+	}
+}
+
+// onImageDeleted enqueues the owner of the Image, if it is one we track.
+func (c *Reconciler) onImageDeleted(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	object, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	owner := Owner{
+		Namespace: object.GetNamespace(),
+		UID:       types.UID(object.GetLabels()["controller"]),
+	}
+	if _, _, ok := c.attribution.Get(owner); ok {
+		c.enqueueAfter(owner.Key(), 0)
+	}
+}
+
+// podImagesChanged filters Pod updates to those that may change the images
+// we attribute to its owner: when its containers or owners change.
+func podImagesChanged(f func(interface{})) func(interface{}, interface{}) {
+	return func(first, second interface{}) {
+		before, ok1 := first.(*v1alpha1.PodImages)
+		after, ok2 := second.(*v1alpha1.PodImages)
+		if !ok1 || !ok2 {
+			return
+		}
+		if !equalContainers(before.AllContainers(), after.AllContainers()) ||
+			!equalOwners(metav1.GetControllerOf(before), metav1.GetControllerOf(after)) {
+			f(second)
+		}
+	}
+}
+
+func equalContainers(a, b []v1alpha1.ContainerImage) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalOwners(a, b *metav1.OwnerReference) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.UID == b.UID
+}
+
+// namespaceChanged enqueues the owners in a Namespace whose decorate setting
+// changed.
+func (c *Reconciler) namespaceChanged(oldObj, newObj interface{}) {
+	old, ok1 := oldObj.(*v1alpha1.WithMetadata)
+	new, ok2 := newObj.(*v1alpha1.WithMetadata)
+	if !ok1 || !ok2 || !policy.NamespaceChanged(old, new) {
+		return
+	}
+	for _, owner := range c.attribution.Owners(new.Name) {
+		c.enqueueAfter(owner.Key(), 0)
+	}
+}
+
+// Reconcile implements controller.Reconciler
+func (c *Reconciler) Reconcile(ctx context.Context, key string) error {
+	logger := logging.FromContext(ctx)
+	// Convert the namespace/uid string into a distinct namespace and uid
+	namespace, uid, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		logger.Errorf("invalid resource key: %s", key)
+		return nil
+	}
+	if !c.shard.Owns(namespace) {
+		// Another replica reconciles this namespace.
+		return nil
+	}
+	if pod, ok := podkey.Parse(uid); ok {
+		return c.attribute(namespace, pod)
+	}
+	owner := Owner{Namespace: namespace, UID: types.UID(uid)}
+	budgetID := controllerAgentName + "/" + key
+
+	ref, podKeys, ok := c.attribution.Get(owner)
+	if !ok {
+		// The owner has no Pods (e.g. it scaled to zero), so we keep its
+		// Images until it is deleted, and they are garbage collected.
+		c.budget.Forget(budgetID)
+		return nil
+	}
+
+	thing, err := c.observe(ref, namespace, podKeys)
+	if err != nil {
+		return err
+	}
+
+	// Only Namespaces and the default mode decide for owners, since we only
+	// know them by reference.
+	ns, err := policy.GetNamespace(c.namespaceLister, namespace)
+	if err != nil {
+		return err
+	}
+	decision := policy.Decide(thing, policy.Options{
+		Namespace:   ns,
+		DefaultMode: c.defaultMode,
+	})
+	logger.Infof("Cache: %v (%s)", decision.Cache, decision.Reason)
+
+	got, err := c.imageLister.Images(namespace).List(labels.SelectorFromSet(labels.Set{
+		"controller": uid,
+	}))
+	if err != nil {
+		return err
+	}
+
+	if !decision.Cache {
+		c.budget.Forget(budgetID)
+		for _, img := range got {
+			err := c.cachingclient.CachingV1alpha1().Images(namespace).Delete(img.Name, &metav1.DeleteOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Record when each image was last seen, and compute what we want.
+	observed := make([]string, 0, len(thing.Spec.Template.Spec.Containers))
+	for _, container := range thing.Spec.Template.Spec.Containers {
+		observed = append(observed, container.Image)
+	}
+	existing := make([]string, 0, len(got))
+	for _, img := range got {
+		existing = append(existing, img.Spec.Image)
+	}
+	now := c.clock.Now()
+	lastSeen := c.attribution.Observe(owner, observed, existing, now)
+	want := resources.MakeImages(thing)
+
+	// Delete the Images for images that have gone unseen for the grace
+	// period, and revisit the others once theirs has passed.
+	var requeue time.Duration
+	for _, img := range got {
+		if _, ok := want[img.Spec.Image]; ok {
+			delete(want, img.Spec.Image)
+			continue
+		}
+		if unseen := now.Sub(lastSeen[img.Spec.Image]); unseen < c.grace {
+			if remaining := c.grace - unseen; requeue == 0 || remaining < requeue {
+				requeue = remaining
+			}
+			continue
+		}
+		err := c.cachingclient.CachingV1alpha1().Images(namespace).Delete(img.Name, &metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	if requeue > 0 {
+		c.enqueueAfter(key, requeue)
+	}
+
+	// Create the missing Image resources that the budgets of their
+	// registries allow, in a deterministic order, and come back for the
+	// rest.
+	order := make([]string, 0, len(want))
+	for k := range want {
+		order = append(order, k)
+	}
+	sort.Strings(order)
+	admitted, retry := c.budget.Admit(budgetID, order)
+	if retry > 0 {
+		c.enqueueAfter(key, retry)
+	}
+	create := make([]caching.Image, 0, len(admitted))
+	for _, k := range admitted {
+		create = append(create, want[k])
+	}
+	_, err = images.Create(c.cachingclient.CachingV1alpha1(), create)
+	return err
+}
+
+// observe returns the WithPod shape of the owner, with a container for each
+// of the images that its Pods run, and the credentials that they pull them
+// with: the service account of the first of them to have one, and all of
+// their pull secrets.
+func (c *Reconciler) observe(ref metav1.OwnerReference, namespace string, podKeys []string) (*v1alpha1.WithPod, error) {
+	images := sets.NewString()
+	var (
+		serviceAccountName string
+		pullSecrets        []corev1.LocalObjectReference
+	)
+	secrets := sets.NewString()
+	for _, key := range podKeys {
+		obj, err := c.podLister.ByNamespace(namespace).Get(strings.TrimPrefix(key, namespace+"/"))
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		pod := obj.(*v1alpha1.PodImages)
+		for _, container := range pod.AllContainers() {
+			images.Insert(container.Image)
+		}
+		if serviceAccountName == "" {
+			serviceAccountName = pod.Spec.ServiceAccountName
+		}
+		for _, secret := range pod.Spec.ImagePullSecrets {
+			if !secrets.Has(secret.Name) {
+				secrets.Insert(secret.Name)
+				pullSecrets = append(pullSecrets, secret)
+			}
+		}
+	}
+
+	containers := make([]corev1.Container, 0, images.Len())
+	for _, image := range images.List() {
+		containers = append(containers, corev1.Container{Image: image})
+	}
+	return &v1alpha1.WithPod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: ref.APIVersion,
+			Kind:       ref.Kind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ref.Name,
+			Namespace: namespace,
+			UID:       ref.UID,
+		},
+		Spec: v1alpha1.WithPodSpec{
+			Template: v1alpha1.PodSpecable{
+				Spec: corev1.PodSpec{
+					Containers:         containers,
+					ServiceAccountName: serviceAccountName,
+					ImagePullSecrets:   pullSecrets,
+				},
+			},
+		},
+	}, nil
+}
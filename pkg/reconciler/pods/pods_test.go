@@ -0,0 +1,283 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pods
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	"github.com/knative/pkg/logging"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/fairqueue"
+	"github.com/mattmoor/cachier/pkg/ownerchain"
+	"github.com/mattmoor/cachier/pkg/reconciler/podkey"
+	rtesting "github.com/mattmoor/cachier/pkg/reconciler/testing"
+	"github.com/mattmoor/cachier/pkg/sharding"
+)
+
+const grace = time.Minute
+
+func owned(apiVersion, kind, name, uid string, owner *metav1.OwnerReference) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetNamespace("ns")
+	u.SetName(name)
+	u.SetUID(types.UID(uid))
+	if owner != nil {
+		u.SetOwnerReferences([]metav1.OwnerReference{*owner})
+	}
+	return u
+}
+
+func controllerRef(apiVersion, kind, name, uid string) *metav1.OwnerReference {
+	isController := true
+	return &metav1.OwnerReference{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Name:       name,
+		UID:        types.UID(uid),
+		Controller: &isController,
+	}
+}
+
+func pod(name string, owner *metav1.OwnerReference, secrets []string, images ...string) *v1alpha1.PodImages {
+	p := &v1alpha1.PodImages{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name},
+		Spec:       v1alpha1.PodImagesSpec{ServiceAccountName: "builder"},
+	}
+	if owner != nil {
+		p.OwnerReferences = []metav1.OwnerReference{*owner}
+	}
+	for _, s := range secrets {
+		p.Spec.ImagePullSecrets = append(p.Spec.ImagePullSecrets, corev1.LocalObjectReference{Name: s})
+	}
+	for _, img := range images {
+		p.Spec.Containers = append(p.Spec.Containers, v1alpha1.ContainerImage{Image: img})
+	}
+	return p
+}
+
+type queued struct {
+	key string
+	d   time.Duration
+}
+
+type fixture struct {
+	r       *Reconciler
+	pods    cache.Indexer
+	caching *rtesting.Caching
+	dynamic *rtesting.Dynamic
+	clock   *clock.FakeClock
+	queued  []queued
+}
+
+func newFixture(pods ...*v1alpha1.PodImages) *fixture {
+	f := &fixture{
+		pods:    rtesting.NewIndexer(),
+		caching: rtesting.NewCaching(),
+		// A Deployment's ReplicaSet.
+		dynamic: rtesting.NewDynamic(
+			owned("apps/v1", "Deployment", "web", "deploy", nil),
+			owned("apps/v1", "ReplicaSet", "web-abc", "rs", controllerRef("apps/v1", "Deployment", "web", "deploy")),
+		),
+		clock: clock.NewFakeClock(time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)),
+	}
+	for _, p := range pods {
+		f.pods.Add(p)
+	}
+	f.r = &Reconciler{
+		cachingclient: f.caching,
+		podLister:     cache.NewGenericLister(f.pods, v1alpha1.PodsResource.GroupResource()),
+		imageLister:   f.caching.ImageLister(),
+		resolver:      ownerchain.NewResolver(f.dynamic, 10),
+		attribution:   NewAttribution(),
+		defaultMode:   cachierv1alpha1.ModeOptOut,
+		clock:         f.clock,
+		grace:         grace,
+		enqueueAfter: func(key string, d time.Duration) {
+			f.queued = append(f.queued, queued{key: key, d: d})
+		},
+		Logger: zap.NewNop().Sugar(),
+	}
+	return f
+}
+
+// reconcile reconciles the key, and returns what it queued.
+func (f *fixture) reconcile(t *testing.T, key string) []queued {
+	t.Helper()
+	f.queued = nil
+	if err := f.r.Reconcile(logging.WithLogger(context.Background(), zap.NewNop().Sugar()), key); err != nil {
+		t.Fatalf("Reconcile(%s) = %v", key, err)
+	}
+	return f.queued
+}
+
+func specs(imgs []*caching.Image) []caching.ImageSpec {
+	var out []caching.ImageSpec
+	for _, img := range imgs {
+		out = append(out, img.Spec)
+	}
+	return out
+}
+
+func TestReconcile(t *testing.T) {
+	rs := controllerRef("apps/v1", "ReplicaSet", "web-abc", "rs")
+	f := newFixture(
+		pod("web-1", rs, []string{"a"}, "gcr.io/foo/web:v1", "gcr.io/foo/proxy:v1"),
+		pod("web-2", rs, []string{"a", "b"}, "gcr.io/foo/web:v1"),
+		pod("bare", nil, nil, "gcr.io/foo/bare:v1"),
+	)
+	deploy := Owner{Namespace: "ns", UID: "deploy"}.Key()
+
+	// Pods are attributed to their top-level owners on the workers, which
+	// reconcile the owners once Pods settle.
+	for _, name := range []string{"web-1", "web-2"} {
+		got := f.reconcile(t, podkey.Key("ns", name))
+		if diff := cmp.Diff([]queued{{key: deploy, d: grace}}, got, cmp.AllowUnexported(queued{})); diff != "" {
+			t.Errorf("Reconcile(%s) queued (-want, +got) = %v", name, diff)
+		}
+	}
+	if got := f.reconcile(t, podkey.Key("ns", "bare")); len(got) != 0 {
+		t.Errorf("Reconcile(bare) queued %v, wanted nothing", got)
+	}
+	// Gone Pods are left to onPodDeleted.
+	f.reconcile(t, podkey.Key("ns", "gone"))
+
+	// The owner's Images pull with its Pods' credentials.
+	secrets := []corev1.LocalObjectReference{{Name: "a"}, {Name: "b"}}
+	f.reconcile(t, deploy)
+	want := []caching.ImageSpec{{
+		Image:              "gcr.io/foo/proxy:v1",
+		ServiceAccountName: "builder",
+		ImagePullSecrets:   secrets,
+	}, {
+		Image:              "gcr.io/foo/web:v1",
+		ServiceAccountName: "builder",
+		ImagePullSecrets:   secrets,
+	}}
+	if diff := cmp.Diff(want, specs(f.caching.Images())); diff != "" {
+		t.Errorf("Images (-want, +got) = %v", diff)
+	}
+	if got := f.caching.Created(); len(got) != 2 {
+		t.Errorf("Created() = %v, wanted 2 Images", got)
+	}
+
+	// Once the proxy is gone from the Pods, its Image outlives it by the
+	// grace period.
+	f.pods.Update(pod("web-1", rs, []string{"a"}, "gcr.io/foo/web:v1"))
+	f.reconcile(t, deploy)
+	f.clock.Step(grace / 2)
+	got := f.reconcile(t, deploy)
+	if diff := cmp.Diff([]queued{{key: deploy, d: grace / 2}}, got, cmp.AllowUnexported(queued{})); diff != "" {
+		t.Errorf("Reconcile queued (-want, +got) = %v", diff)
+	}
+	if got := f.caching.Deleted(); len(got) != 0 {
+		t.Errorf("Deleted() = %v during the grace period", got)
+	}
+	f.clock.Step(grace / 2)
+	f.reconcile(t, deploy)
+	if diff := cmp.Diff(want[1:], specs(f.caching.Images())); diff != "" {
+		t.Errorf("Images (-want, +got) = %v", diff)
+	}
+
+	// Opting out deletes the owner's Images.
+	f.r.defaultMode = cachierv1alpha1.ModeOptIn
+	f.reconcile(t, deploy)
+	if got := f.caching.Images(); len(got) != 0 {
+		t.Errorf("Images = %v, wanted none", specs(got))
+	}
+}
+
+func TestOnPod(t *testing.T) {
+	rs := controllerRef("apps/v1", "ReplicaSet", "web-abc", "rs")
+	p := pod("web-1", rs, nil, "gcr.io/foo/web:v1")
+	f := newFixture(p)
+
+	// The handler leaves resolving owners, which may take API calls, to the
+	// workers.
+	f.r.onPod(p)
+	if diff := cmp.Diff([]queued{{key: podkey.Key("ns", "web-1")}}, f.queued, cmp.AllowUnexported(queued{})); diff != "" {
+		t.Errorf("onPod() queued (-want, +got) = %v", diff)
+	}
+	if got := f.dynamic.Gets(); got != 0 {
+		t.Errorf("onPod() made %d Gets, wanted none", got)
+	}
+
+	// We skip the Pods of the namespaces we don't own (and, before joining
+	// our group, we own none).
+	f.queued = nil
+	f.r.shard = sharding.New(sharding.Options{Identity: "a", Logger: zap.NewNop().Sugar()})
+	f.r.onPod(p)
+	if len(f.queued) != 0 {
+		t.Errorf("onPod() queued %v in a namespace we don't own", f.queued)
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	rs := controllerRef("apps/v1", "ReplicaSet", "web-abc", "rs")
+	f := newFixture(
+		pod("web-1", rs, nil, "gcr.io/foo/web:v1"),
+		pod("web-2", rs, nil, "gcr.io/foo/web:v1"),
+		pod("web-3", rs, nil, "gcr.io/foo/web:v1"),
+	)
+	q := fairqueue.New("", workqueue.DefaultControllerRateLimiter(), f.clock)
+	defer q.ShutDown()
+	f.r.enqueueAfter = func(key string, d time.Duration) {
+		q.AddAfter(key, d)
+	}
+
+	// A rollout's burst of Pod events, spread over the grace period,
+	// reconciles their owner once the first of them settles.
+	for _, name := range []string{"web-1", "web-2", "web-3"} {
+		f.reconcile(t, podkey.Key("ns", name))
+		f.clock.Step(grace / 4)
+	}
+	for q.Len() < 1 {
+		f.clock.Step(grace / 4)
+		time.Sleep(time.Millisecond)
+	}
+	var got []string
+	for q.Len() > 0 {
+		item, _ := q.Get()
+		got = append(got, item.(string))
+		q.Done(item)
+	}
+	f.clock.Step(grace)
+	time.Sleep(10 * time.Millisecond)
+	for q.Len() > 0 {
+		item, _ := q.Get()
+		got = append(got, item.(string))
+		q.Done(item)
+	}
+	if diff := cmp.Diff([]string{Owner{Namespace: "ns", UID: "deploy"}.Key()}, got); diff != "" {
+		t.Errorf("Reconciled (-want, +got) = %v", diff)
+	}
+}
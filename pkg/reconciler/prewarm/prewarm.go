@@ -0,0 +1,312 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prewarm
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	cachingclientset "github.com/knative/caching/pkg/client/clientset/versioned"
+	cachinginformers "github.com/knative/caching/pkg/client/informers/externalversions/caching/v1alpha1"
+	cachinglisters "github.com/knative/caching/pkg/client/listers/caching/v1alpha1"
+	"github.com/knative/pkg/controller"
+	"github.com/knative/pkg/logging"
+	"github.com/knative/pkg/logging/logkey"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/mattmoor/cachier/pkg/apis/cachier"
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/budget"
+	cachierclientset "github.com/mattmoor/cachier/pkg/client/clientset/versioned"
+	cachierinformers "github.com/mattmoor/cachier/pkg/client/informers/externalversions/cachier/v1alpha1"
+	cachierlisters "github.com/mattmoor/cachier/pkg/client/listers/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/fairqueue"
+	cachierresources "github.com/mattmoor/cachier/pkg/reconciler/cachier/resources"
+	"github.com/mattmoor/cachier/pkg/reconciler/images"
+	"github.com/mattmoor/cachier/pkg/reconciler/prewarm/resources"
+	"github.com/mattmoor/cachier/pkg/reference"
+	"github.com/mattmoor/cachier/pkg/sharding"
+)
+
+const controllerAgentName = "prewarm-controller"
+
+// Reconciler is the controller implementation for PrewarmSchedule resources
+type Reconciler struct {
+	// For creating/deleting caching resources, and updating our status.
+	cachingclient cachingclientset.Interface
+	cachierclient cachierclientset.Interface
+
+	// For reading the state of the world.
+	lister      cachierlisters.PrewarmScheduleLister
+	imageLister cachinglisters.ImageLister
+
+	// For telling the time, and revisiting schedules when windows open and
+	// close.
+	clock        clock.Clock
+	enqueueAfter func(key string, d time.Duration)
+
+	// For only reconciling the namespaces that this replica owns, when
+	// sharding.
+	shard *sharding.Sharder
+
+	// For limiting the rate at which we create Images for each registry.
+	budget *budget.Budget
+
+	// Sugared logger is easier to use but is not as performant as the
+	// raw logger. In performance critical paths, call logger.Desugar()
+	// and use the returned raw logger instead. In addition to the
+	// performance benefits, raw logger also preserves type-safety at
+	// the expense of slightly greater verbosity.
+	Logger *zap.SugaredLogger
+}
+
+// Check that we implement the controller.Reconciler interface.
+var _ controller.Reconciler = (*Reconciler)(nil)
+
+// NewController returns a new PrewarmSchedule controller
+func NewController(
+	logger *zap.SugaredLogger,
+	cachierClient cachierclientset.Interface,
+	scheduleInformer cachierinformers.PrewarmScheduleInformer,
+	cachingClient cachingclientset.Interface,
+	imageInformer cachinginformers.ImageInformer,
+	clk clock.Clock,
+	imageBudget *budget.Budget,
+	shard *sharding.Sharder,
+) *fairqueue.Impl {
+
+	r := &Reconciler{
+		cachingclient: cachingClient,
+		cachierclient: cachierClient,
+		lister:        scheduleInformer.Lister(),
+		imageLister:   imageInformer.Lister(),
+		clock:         clk,
+		budget:        imageBudget,
+		shard:         shard,
+		// Enrich the logs with controller name
+		Logger: logger.Named(controllerAgentName).
+			With(zap.String(logkey.ControllerType, controllerAgentName)),
+	}
+	impl, resyncs := fairqueue.NewImpl(r, r.Logger, "PrewarmSchedules")
+	r.enqueueAfter = func(key string, d time.Duration) {
+		impl.WorkQueue.AddAfter(key, d)
+	}
+
+	r.Logger.Info("Setting up event handlers")
+
+	if shard != nil {
+		// Whenever the replicas change, enqueue the PrewarmSchedules in the
+		// namespaces that we now own.
+		shard.AddListener(func() {
+			objs, err := scheduleInformer.Lister().List(labels.Everything())
+			if err != nil {
+				r.Logger.Errorf("Error listing PrewarmSchedules: %v", err)
+				return
+			}
+			for _, obj := range objs {
+				if shard.Owns(obj.Namespace) {
+					resyncs.Enqueue(obj)
+				}
+			}
+		})
+	}
+
+	scheduleInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    impl.Enqueue,
+		UpdateFunc: fairqueue.PassNew(impl.Enqueue, resyncs.Enqueue),
+	})
+
+	// Whenever one of our Images changes, enqueue the PrewarmSchedule that
+	// owns it, and whenever another Image is created or deleted, enqueue
+	// the schedules that may be retaining its image.
+	imageInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: controller.Filter(cachierv1alpha1.SchemeGroupVersion.WithKind("PrewarmSchedule")),
+		Handler: cache.ResourceEventHandlerFuncs{
+			AddFunc:    impl.EnqueueControllerOf,
+			UpdateFunc: fairqueue.PassNew(impl.EnqueueControllerOf, resyncs.EnqueueControllerOf),
+			DeleteFunc: impl.EnqueueControllerOf,
+		},
+	})
+	enqueueRetaining := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		object, err := meta.Accessor(obj)
+		if err != nil {
+			return
+		}
+		schedules, err := r.lister.PrewarmSchedules(object.GetNamespace()).List(labels.Everything())
+		if err != nil {
+			return
+		}
+		for _, ps := range schedules {
+			if len(ps.Status.RetainedImages) > 0 {
+				impl.Enqueue(ps)
+			}
+		}
+	}
+	imageInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueueRetaining,
+		DeleteFunc: enqueueRetaining,
+	})
+
+	return impl
+}
+
+// Reconcile implements controller.Reconciler
+func (c *Reconciler) Reconcile(ctx context.Context, key string) error {
+	logger := logging.FromContext(ctx)
+	// Convert the namespace/name string into a distinct namespace and name
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		logger.Errorf("invalid resource key: %s", key)
+		return nil
+	}
+	if !c.shard.Owns(namespace) {
+		// Another replica reconciles this namespace.
+		return nil
+	}
+
+	// Get the PrewarmSchedule resource with this namespace/name
+	original, err := c.lister.PrewarmSchedules(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		logger.Errorf("PrewarmSchedule %q in work queue no longer exists", key)
+		c.budget.Forget(controllerAgentName + "/" + key)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	// Don't modify the informer's copy.
+	ps := original.DeepCopy()
+
+	if err := ps.Validate(); err != nil {
+		logger.Errorf("Invalid PrewarmSchedule %q: %v", key, err)
+		return nil
+	}
+
+	now := c.clock.Now()
+	current, next := ps.Spec.Windows(now)
+	active := current != nil && current.Contains(now)
+
+	status := &ps.Status
+	status.ObservedGeneration = ps.Generation
+	status.Active = active
+	status.Error = ""
+	status.WindowStart, status.WindowEnd, status.NextWindowStart = nil, nil, nil
+	if current != nil {
+		start, end := metav1.NewTime(current.Start), metav1.NewTime(current.End)
+		status.WindowStart, status.WindowEnd = &start, &end
+	}
+	if next != nil {
+		start := metav1.NewTime(next.Start)
+		status.NextWindowStart = &start
+	}
+
+	thing, err := resources.MakeWithPod(ps)
+	if err != nil {
+		logger.Errorf("Unable to determine images for %q: %v", key, err)
+		status.Error = err.Error()
+		status.Images = nil
+	} else {
+		want := cachierresources.MakeImages(thing)
+		status.Images = make([]string, 0, len(want))
+		for ref := range want {
+			status.Images = append(status.Images, ref)
+		}
+		sort.Strings(status.Images)
+
+		if active {
+			var retry time.Duration
+			_, retry, err = images.Reconcile(c.cachingclient.CachingV1alpha1(), c.imageLister,
+				c.budget, controllerAgentName+"/"+key, thing)
+			if retry > 0 {
+				// Come back for the Images that the budget deferred.
+				c.enqueueAfter(key, retry)
+			}
+		} else {
+			c.budget.Forget(controllerAgentName + "/" + key)
+			err = c.expireImages(ps)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Revisit the schedule when its window closes, or the next one opens.
+	switch {
+	case active:
+		c.enqueueAfter(key, current.End.Sub(now))
+	case next != nil:
+		c.enqueueAfter(key, next.Start.Sub(now))
+	}
+
+	if equality.Semantic.DeepEqual(original.Status, ps.Status) {
+		return nil
+	}
+	_, err = c.cachierclient.CachierV1alpha1().PrewarmSchedules(namespace).UpdateStatus(ps)
+	return err
+}
+
+// expireImages deletes the schedule's Images outside of its windows, except
+// those whose image a workload has since adopted, which it records in the
+// schedule's status.
+func (c *Reconciler) expireImages(ps *cachierv1alpha1.PrewarmSchedule) error {
+	all, err := c.imageLister.Images(ps.Namespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	// Find the images that workloads (i.e. anything but cachier's own
+	// resources) cache, however they spell them.
+	adopted := make(map[string]bool)
+	for _, img := range all {
+		owner := metav1.GetControllerOf(img)
+		if owner == nil {
+			continue
+		}
+		if gv, err := schema.ParseGroupVersion(owner.APIVersion); err == nil && gv.Group != cachier.GroupName {
+			adopted[reference.Normalize(img.Spec.Image)] = true
+		}
+	}
+
+	var retained []string
+	for _, img := range all {
+		if owner := metav1.GetControllerOf(img); owner == nil || owner.UID != ps.UID {
+			continue
+		}
+		if adopted[reference.Normalize(img.Spec.Image)] {
+			retained = append(retained, img.Spec.Image)
+			continue
+		}
+		err := c.cachingclient.CachingV1alpha1().Images(img.Namespace).Delete(img.Name, &metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	sort.Strings(retained)
+	ps.Status.RetainedImages = retained
+	return nil
+}
@@ -0,0 +1,291 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prewarm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	"github.com/knative/pkg/logging"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	rtesting "github.com/mattmoor/cachier/pkg/reconciler/testing"
+)
+
+const key = "ns/nightly"
+
+type queued struct {
+	key string
+	d   time.Duration
+}
+
+type fixture struct {
+	r       *Reconciler
+	cachier *rtesting.Cachier
+	caching *rtesting.Caching
+	clock   *clock.FakeClock
+	queued  []queued
+}
+
+func newFixture(ps *cachierv1alpha1.PrewarmSchedule, now time.Time) *fixture {
+	f := &fixture{
+		cachier: rtesting.NewCachier(ps),
+		caching: rtesting.NewCaching(),
+		clock:   clock.NewFakeClock(now),
+	}
+	f.r = &Reconciler{
+		cachingclient: f.caching,
+		cachierclient: f.cachier,
+		lister:        f.cachier.PrewarmScheduleLister(),
+		imageLister:   f.caching.ImageLister(),
+		clock:         f.clock,
+		enqueueAfter: func(key string, d time.Duration) {
+			f.queued = append(f.queued, queued{key: key, d: d})
+		},
+		Logger: zap.NewNop().Sugar(),
+	}
+	return f
+}
+
+// reconcile reconciles the schedule, and returns what it queued.
+func (f *fixture) reconcile(t *testing.T) []queued {
+	t.Helper()
+	f.queued = nil
+	if err := f.r.Reconcile(logging.WithLogger(context.Background(), zap.NewNop().Sugar()), key); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+	return f.queued
+}
+
+// status returns the schedule's current status.
+func (f *fixture) status(t *testing.T) cachierv1alpha1.PrewarmScheduleStatus {
+	t.Helper()
+	ps, err := f.cachier.PrewarmScheduleLister().PrewarmSchedules("ns").Get("nightly")
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	return ps.Status
+}
+
+func imageRefs(imgs []*caching.Image) []string {
+	var out []string
+	for _, img := range imgs {
+		out = append(out, img.Spec.Image)
+	}
+	return out
+}
+
+func at(hour, min int) time.Time {
+	return time.Date(2018, 10, 1, hour, min, 0, 0, time.UTC)
+}
+
+func mtime(t time.Time) *metav1.Time {
+	mt := metav1.NewTime(t)
+	return &mt
+}
+
+func TestReconcile(t *testing.T) {
+	f := newFixture(&cachierv1alpha1.PrewarmSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "ns",
+			Name:       "nightly",
+			UID:        "nightly-uid",
+			Generation: 1,
+		},
+		Spec: cachierv1alpha1.PrewarmScheduleSpec{
+			Images:   []string{"gcr.io/foo/a:v1", "gcr.io/foo/b:v1"},
+			Schedule: "0 2 * * *",
+			Duration: &metav1.Duration{Duration: time.Hour},
+		},
+	}, at(1, 0))
+	cmpQueued := cmp.AllowUnexported(queued{})
+
+	// Before the window opens (yesterday's having closed), nothing is
+	// cached, and we come back when it does.
+	got := f.reconcile(t)
+	if diff := cmp.Diff([]queued{{key: key, d: time.Hour}}, got, cmpQueued); diff != "" {
+		t.Errorf("Reconcile queued (-want, +got) = %v", diff)
+	}
+	if got := f.caching.Images(); len(got) != 0 {
+		t.Errorf("Images = %v, wanted none", imageRefs(got))
+	}
+	want := cachierv1alpha1.PrewarmScheduleStatus{
+		ObservedGeneration: 1,
+		WindowStart:        mtime(at(2, 0).AddDate(0, 0, -1)),
+		WindowEnd:          mtime(at(3, 0).AddDate(0, 0, -1)),
+		NextWindowStart:    mtime(at(2, 0)),
+		Images:             []string{"gcr.io/foo/a:v1", "gcr.io/foo/b:v1"},
+	}
+	if diff := cmp.Diff(want, f.status(t)); diff != "" {
+		t.Errorf("Status (-want, +got) = %v", diff)
+	}
+
+	// Once it opens, the images are cached until it closes, when we come
+	// back.
+	f.clock.SetTime(at(2, 15))
+	got = f.reconcile(t)
+	if diff := cmp.Diff([]queued{{key: key, d: 45 * time.Minute}}, got, cmpQueued); diff != "" {
+		t.Errorf("Reconcile queued (-want, +got) = %v", diff)
+	}
+	if diff := cmp.Diff([]string{"gcr.io/foo/a:v1", "gcr.io/foo/b:v1"}, imageRefs(f.caching.Images())); diff != "" {
+		t.Errorf("Images (-want, +got) = %v", diff)
+	}
+	want = cachierv1alpha1.PrewarmScheduleStatus{
+		ObservedGeneration: 1,
+		Active:             true,
+		WindowStart:        mtime(at(2, 0)),
+		WindowEnd:          mtime(at(3, 0)),
+		NextWindowStart:    mtime(at(2, 0).AddDate(0, 0, 1)),
+		Images:             []string{"gcr.io/foo/a:v1", "gcr.io/foo/b:v1"},
+	}
+	if diff := cmp.Diff(want, f.status(t)); diff != "" {
+		t.Errorf("Status (-want, +got) = %v", diff)
+	}
+
+	// Reconciling again within the window changes nothing.
+	f.caching.Created()
+	f.cachier.StatusUpdates()
+	f.reconcile(t)
+	if got := f.caching.Created(); len(got) != 0 {
+		t.Errorf("Created() = %v, wanted nothing", got)
+	}
+	if got := f.cachier.StatusUpdates(); got != 0 {
+		t.Errorf("StatusUpdates() = %d, wanted none", got)
+	}
+
+	// Meanwhile, a workload starts caching one of the images.
+	isController := true
+	if _, err := f.caching.CachingV1alpha1().Images("ns").Create(&caching.Image{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "web-00-0",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       "web",
+				UID:        "web-uid",
+				Controller: &isController,
+			}},
+		},
+		Spec: caching.ImageSpec{Image: "gcr.io/foo/b:v1"},
+	}); err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+
+	// Once the window closes, our Images expire, except for the one the
+	// workload adopted, and we come back when the next window opens.
+	f.clock.SetTime(at(3, 30))
+	got = f.reconcile(t)
+	if diff := cmp.Diff([]queued{{key: key, d: 22*time.Hour + 30*time.Minute}}, got, cmpQueued); diff != "" {
+		t.Errorf("Reconcile queued (-want, +got) = %v", diff)
+	}
+	if got := f.caching.Deleted(); len(got) != 1 {
+		t.Errorf("Deleted() = %v, wanted the Image of gcr.io/foo/a:v1", got)
+	}
+	if diff := cmp.Diff([]string{"gcr.io/foo/b:v1", "gcr.io/foo/b:v1"}, imageRefs(f.caching.Images())); diff != "" {
+		t.Errorf("Images (-want, +got) = %v", diff)
+	}
+	want = cachierv1alpha1.PrewarmScheduleStatus{
+		ObservedGeneration: 1,
+		WindowStart:        mtime(at(2, 0)),
+		WindowEnd:          mtime(at(3, 0)),
+		NextWindowStart:    mtime(at(2, 0).AddDate(0, 0, 1)),
+		Images:             []string{"gcr.io/foo/a:v1", "gcr.io/foo/b:v1"},
+		RetainedImages:     []string{"gcr.io/foo/b:v1"},
+	}
+	if diff := cmp.Diff(want, f.status(t)); diff != "" {
+		t.Errorf("Status (-want, +got) = %v", diff)
+	}
+
+	// Once the workload stops caching it, our Image of it expires too.
+	if err := f.caching.CachingV1alpha1().Images("ns").Delete("web-00-0", nil); err != nil {
+		t.Fatalf("Delete() = %v", err)
+	}
+	f.caching.Deleted()
+	f.reconcile(t)
+	if got := f.caching.Images(); len(got) != 0 {
+		t.Errorf("Images = %v, wanted none", imageRefs(got))
+	}
+	if got := f.status(t).RetainedImages; len(got) != 0 {
+		t.Errorf("RetainedImages = %v, wanted none", got)
+	}
+}
+
+func TestExpireAdoptedSpellings(t *testing.T) {
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	ps := &cachierv1alpha1.PrewarmSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "ns",
+			Name:       "nightly",
+			UID:        "nightly-uid",
+			Generation: 1,
+		},
+		Spec: cachierv1alpha1.PrewarmScheduleSpec{
+			Images:   []string{"foo", "bar@" + digest, "busybox"},
+			Schedule: "0 2 * * *",
+			Duration: &metav1.Duration{Duration: time.Hour},
+		},
+	}
+	f := newFixture(ps, at(3, 30))
+
+	isController := true
+	image := func(name, apiVersion, kind, uid, ref string) *caching.Image {
+		return &caching.Image{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: apiVersion,
+					Kind:       kind,
+					Name:       name,
+					UID:        types.UID(uid),
+					Controller: &isController,
+				}},
+			},
+			Spec: caching.ImageSpec{Image: ref},
+		}
+	}
+	for _, img := range []*caching.Image{
+		// The schedule's Images, left from its last window.
+		image("nightly-00-0", "cachier.mattmoor.io/v1alpha1", "PrewarmSchedule", "nightly-uid", "foo"),
+		image("nightly-01-0", "cachier.mattmoor.io/v1alpha1", "PrewarmSchedule", "nightly-uid", "bar@"+digest),
+		image("nightly-02-0", "cachier.mattmoor.io/v1alpha1", "PrewarmSchedule", "nightly-uid", "busybox"),
+		// Workloads that spell two of the images differently.
+		image("web-00-0", "apps/v1", "Deployment", "web-uid", "docker.io/library/foo:latest"),
+		image("api-00-0", "apps/v1", "Deployment", "api-uid", "index.docker.io/library/bar@"+digest),
+	} {
+		if _, err := f.caching.CachingV1alpha1().Images("ns").Create(img); err != nil {
+			t.Fatalf("Create() = %v", err)
+		}
+	}
+	f.caching.Created()
+
+	// Outside of the window, only the Image of the image that no workload
+	// caches expires.
+	f.reconcile(t)
+	if diff := cmp.Diff([]string{"ns/nightly-02-0"}, f.caching.Deleted()); diff != "" {
+		t.Errorf("Deleted() (-want, +got) = %v", diff)
+	}
+	if diff := cmp.Diff([]string{"bar@" + digest, "foo"}, f.status(t).RetainedImages); diff != "" {
+		t.Errorf("RetainedImages (-want, +got) = %v", diff)
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	cachierresources "github.com/mattmoor/cachier/pkg/reconciler/cachier/resources"
+)
+
+// ManifestImages returns the images that the workload in the manifest
+// would have cached: those of its pod template's containers, and any extra
+// images listed in its annotations.
+func ManifestImages(raw []byte) ([]string, error) {
+	tm := metav1.TypeMeta{}
+	if err := json.Unmarshal(raw, &tm); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	if tm.Kind == "" {
+		return nil, fmt.Errorf("manifest has no kind")
+	}
+
+	// Decode the manifest with the shape that knows where it keeps its pod
+	// template, just as we would the resource itself.
+	obj := v1alpha1.DuckTypeFor(tm.GroupVersionKind())
+	if err := json.Unmarshal(raw, obj); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	wp := obj.AsWithPod()
+
+	var images []string
+	for _, c := range wp.Spec.Template.Spec.Containers {
+		images = append(images, c.Image)
+	}
+	return append(images, cachierresources.ExtraImages(wp)...), nil
+}
+
+// MakeWithPod projects the PrewarmSchedule onto the WithPod shape (see
+// cachierresources.MakeWithPod) with its images, and those of its manifest.
+func MakeWithPod(ps *cachierv1alpha1.PrewarmSchedule) (*v1alpha1.WithPod, error) {
+	images := ps.Spec.Images
+	if ps.Spec.Manifest != nil {
+		extracted, err := ManifestImages(ps.Spec.Manifest.Raw)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images[:len(images):len(images)], extracted...)
+	}
+
+	return cachierresources.MakeWithPod(ps.GetGroupVersionKind(), ps.ObjectMeta, corev1.PodSpec{
+		ServiceAccountName: ps.Spec.ServiceAccountName,
+		ImagePullSecrets:   ps.Spec.ImagePullSecrets,
+	}, images), nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	cachierresources "github.com/mattmoor/cachier/pkg/reconciler/cachier/resources"
+)
+
+func TestManifestImages(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     []string
+		wantErr  bool
+	}{{
+		name: "deployment",
+		manifest: `{
+  "apiVersion": "apps/v1",
+  "kind": "Deployment",
+  "metadata": {"name": "frontend"},
+  "spec": {"template": {
+    "metadata": {"annotations": {"cachier.mattmoor.io/extra-images": "istio/proxyv2"}},
+    "spec": {"containers": [{"name": "app", "image": "gcr.io/foo/frontend:v2"}]}
+  }}
+}`,
+		want: []string{"gcr.io/foo/frontend:v2", "istio/proxyv2"},
+	}, {
+		name: "knative service",
+		manifest: `{
+  "apiVersion": "serving.knative.dev/v1alpha1",
+  "kind": "Service",
+  "metadata": {"name": "frontend"},
+  "spec": {"runLatest": {"configuration": {"revisionTemplate": {
+    "spec": {"container": {"image": "gcr.io/foo/frontend:v2"}}
+  }}}}
+}`,
+		want: []string{"gcr.io/foo/frontend:v2"},
+	}, {
+		name:     "no kind",
+		manifest: `{"spec": {}}`,
+		wantErr:  true,
+	}, {
+		name:     "not json",
+		manifest: `frontend`,
+		wantErr:  true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ManifestImages([]byte(test.manifest))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ManifestImages() = %v, wanted error: %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("ManifestImages (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestMakeWithPod(t *testing.T) {
+	ps := &cachierv1alpha1.PrewarmSchedule{
+		Spec: cachierv1alpha1.PrewarmScheduleSpec{
+			Images: []string{"busybox"},
+			Manifest: &runtime.RawExtension{
+				Raw: []byte(`{"apiVersion": "apps/v1", "kind": "Deployment", "spec": {"template": {"spec": {"containers": [{"image": "nginx"}, {"image": "busybox"}]}}}}`),
+			},
+		},
+	}
+	ps.Name = "launch"
+
+	wp, err := MakeWithPod(ps)
+	if err != nil {
+		t.Fatalf("MakeWithPod() = %v", err)
+	}
+	images := cachierresources.MakeImages(wp)
+	if got, want := len(images), 2; got != want {
+		t.Errorf("len(MakeImages()) = %d, wanted %d: %v", got, want, images)
+	}
+	if got, want := images["nginx"].OwnerReferences[0].Kind, "PrewarmSchedule"; got != want {
+		t.Errorf("Owner kind = %q, wanted %q", got, want)
+	}
+}
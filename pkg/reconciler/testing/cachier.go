@@ -0,0 +1,126 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+
+	cachier "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	cachierclientset "github.com/mattmoor/cachier/pkg/client/clientset/versioned"
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/client/clientset/versioned/typed/cachier/v1alpha1"
+	cachierlisters "github.com/mattmoor/cachier/pkg/client/listers/cachier/v1alpha1"
+)
+
+var (
+	cachedImageSetsResource  = cachier.SchemeGroupVersion.WithResource("cachedimagesets")
+	prewarmSchedulesResource = cachier.SchemeGroupVersion.WithResource("prewarmschedules")
+)
+
+// Cachier is a stand-in for the cachier clientset, whose CachedImageSets
+// and PrewarmSchedules back their listers.  Only their status may be updated
+// through it.
+type Cachier struct {
+	cachierclientset.Interface
+	*Tracker
+}
+
+var _ cachierclientset.Interface = (*Cachier)(nil)
+
+// NewCachier returns a Cachier that holds the given CachedImageSets and
+// PrewarmSchedules.
+func NewCachier(objs ...runtime.Object) *Cachier {
+	c := &Cachier{Tracker: NewTracker()}
+	for _, obj := range objs {
+		c.Set(obj)
+	}
+	return c
+}
+
+// Set adds (or replaces) the CachedImageSet or PrewarmSchedule.
+func (c *Cachier) Set(obj runtime.Object) {
+	switch obj.(type) {
+	case *cachier.CachedImageSet:
+		c.Add(cachedImageSetsResource, obj)
+	case *cachier.PrewarmSchedule:
+		c.Add(prewarmSchedulesResource, obj)
+	}
+}
+
+// CachedImageSetLister lists the CachedImageSets that Cachier holds.
+func (c *Cachier) CachedImageSetLister() cachierlisters.CachedImageSetLister {
+	return cachierlisters.NewCachedImageSetLister(c.Indexer(cachedImageSetsResource.GroupResource()))
+}
+
+// PrewarmScheduleLister lists the PrewarmSchedules that Cachier holds.
+func (c *Cachier) PrewarmScheduleLister() cachierlisters.PrewarmScheduleLister {
+	return cachierlisters.NewPrewarmScheduleLister(c.Indexer(prewarmSchedulesResource.GroupResource()))
+}
+
+// StatusUpdates returns the number of status updates made through Cachier
+// since it was last called.
+func (c *Cachier) StatusUpdates() int {
+	return len(c.Take(VerbUpdateStatus))
+}
+
+// CachierV1alpha1 implements cachierclientset.Interface
+func (c *Cachier) CachierV1alpha1() cachierv1alpha1.CachierV1alpha1Interface {
+	return &cachierV1alpha1{t: c.Tracker}
+}
+
+type cachierV1alpha1 struct {
+	cachierv1alpha1.CachierV1alpha1Interface
+
+	t *Tracker
+}
+
+func (c *cachierV1alpha1) CachedImageSets(namespace string) cachierv1alpha1.CachedImageSetInterface {
+	return &cachedImageSets{t: c.t, namespace: namespace}
+}
+
+func (c *cachierV1alpha1) PrewarmSchedules(namespace string) cachierv1alpha1.PrewarmScheduleInterface {
+	return &prewarmSchedules{t: c.t, namespace: namespace}
+}
+
+type cachedImageSets struct {
+	cachierv1alpha1.CachedImageSetInterface
+
+	t         *Tracker
+	namespace string
+}
+
+func (s *cachedImageSets) UpdateStatus(set *cachier.CachedImageSet) (*cachier.CachedImageSet, error) {
+	obj, err := s.t.update(cachedImageSetsResource, VerbUpdateStatus, s.namespace, set)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*cachier.CachedImageSet), nil
+}
+
+type prewarmSchedules struct {
+	cachierv1alpha1.PrewarmScheduleInterface
+
+	t         *Tracker
+	namespace string
+}
+
+func (s *prewarmSchedules) UpdateStatus(ps *cachier.PrewarmSchedule) (*cachier.PrewarmSchedule, error) {
+	obj, err := s.t.update(prewarmSchedulesResource, VerbUpdateStatus, s.namespace, ps)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*cachier.PrewarmSchedule), nil
+}
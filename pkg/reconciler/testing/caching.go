@@ -0,0 +1,165 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package testing provides stand-ins for the clients that the reconcilers
+// use.  They serve the calls the reconcilers make from a Tracker, which
+// applies the writes to the indexers behind the reconcilers' listers, so that
+// tests may Reconcile repeatedly against a consistent view of the world.
+// They embed the client interfaces they stand in for, left nil, so that the
+// calls we don't expect panic.
+package testing
+
+import (
+	"sort"
+
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	cachingclientset "github.com/knative/caching/pkg/client/clientset/versioned"
+	cachingv1alpha1 "github.com/knative/caching/pkg/client/clientset/versioned/typed/caching/v1alpha1"
+	cachinglisters "github.com/knative/caching/pkg/client/listers/caching/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NewIndexer returns an Indexer like those of informers, which listers may
+// list by namespace.
+func NewIndexer() cache.Indexer {
+	return cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	})
+}
+
+var imagesResource = caching.SchemeGroupVersion.WithResource("images")
+
+// Caching is a stand-in for the caching clientset, whose Images back
+// ImageLister.
+type Caching struct {
+	cachingclientset.Interface
+	*Tracker
+}
+
+var _ cachingclientset.Interface = (*Caching)(nil)
+
+// NewCaching returns a Caching that holds the given Images.
+func NewCaching(imgs ...*caching.Image) *Caching {
+	c := &Caching{Tracker: NewTracker()}
+	for _, img := range imgs {
+		c.Add(imagesResource, img)
+	}
+	return c
+}
+
+// ImageLister lists the Images that Caching holds.
+func (c *Caching) ImageLister() cachinglisters.ImageLister {
+	return cachinglisters.NewImageLister(c.Indexer(imagesResource.GroupResource()))
+}
+
+// Images returns the Images that Caching holds, sorted by namespace and
+// name.
+func (c *Caching) Images() []*caching.Image {
+	var imgs []*caching.Image
+	for _, obj := range c.Indexer(imagesResource.GroupResource()).List() {
+		imgs = append(imgs, obj.(*caching.Image))
+	}
+	sort.Slice(imgs, func(i, j int) bool {
+		if imgs[i].Namespace != imgs[j].Namespace {
+			return imgs[i].Namespace < imgs[j].Namespace
+		}
+		return imgs[i].Name < imgs[j].Name
+	})
+	return imgs
+}
+
+// Created returns the sorted keys (namespace/name) of the Images created
+// through Caching since it was last called.
+func (c *Caching) Created() []string {
+	return c.take(VerbCreate)
+}
+
+// Updated returns the sorted keys of the Images updated through Caching
+// since it was last called.
+func (c *Caching) Updated() []string {
+	return c.take(VerbUpdate)
+}
+
+// Deleted returns the sorted keys of the Images deleted through Caching
+// since it was last called.
+func (c *Caching) Deleted() []string {
+	return c.take(VerbDelete)
+}
+
+func (c *Caching) take(verb string) []string {
+	actions := c.Take(verb)
+	if len(actions) == 0 {
+		return nil
+	}
+	keys := Keys(actions)
+	sort.Strings(keys)
+	return keys
+}
+
+// CachingV1alpha1 implements cachingclientset.Interface
+func (c *Caching) CachingV1alpha1() cachingv1alpha1.CachingV1alpha1Interface {
+	return &cachingV1alpha1{t: c.Tracker}
+}
+
+type cachingV1alpha1 struct {
+	cachingv1alpha1.CachingV1alpha1Interface
+
+	t *Tracker
+}
+
+func (c *cachingV1alpha1) Images(namespace string) cachingv1alpha1.ImageInterface {
+	return &images{t: c.t, namespace: namespace}
+}
+
+type images struct {
+	cachingv1alpha1.ImageInterface
+
+	t         *Tracker
+	namespace string
+}
+
+func (i *images) Create(img *caching.Image) (*caching.Image, error) {
+	obj, err := i.t.create(imagesResource, i.namespace, img)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*caching.Image), nil
+}
+
+func (i *images) Update(img *caching.Image) (*caching.Image, error) {
+	obj, err := i.t.update(imagesResource, VerbUpdate, i.namespace, img)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*caching.Image), nil
+}
+
+func (i *images) Get(name string, _ metav1.GetOptions) (*caching.Image, error) {
+	obj, err := i.t.get(imagesResource, i.namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*caching.Image), nil
+}
+
+func (i *images) Delete(name string, _ *metav1.DeleteOptions) error {
+	return i.t.delete(imagesResource, i.namespace, name)
+}
+
+func (i *images) DeleteCollection(_ *metav1.DeleteOptions, opts metav1.ListOptions) error {
+	return i.t.deleteCollection(imagesResource, i.namespace, opts.LabelSelector)
+}
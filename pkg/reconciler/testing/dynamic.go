@@ -0,0 +1,119 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// Dynamic is a stand-in for the dynamic client, which serves Gets of the
+// objects added to it, and records the Creates and Patches made through it.
+// Patches leave the objects as they are.
+type Dynamic struct {
+	*Tracker
+}
+
+var _ dynamic.Interface = (*Dynamic)(nil)
+
+// NewDynamic returns a Dynamic that serves the given objects.
+func NewDynamic(objs ...*unstructured.Unstructured) *Dynamic {
+	d := &Dynamic{Tracker: NewTracker()}
+	for _, obj := range objs {
+		d.Add(obj)
+	}
+	return d
+}
+
+// Add adds (or replaces) the object, under the resource that its kind
+// guesses.
+func (d *Dynamic) Add(obj *unstructured.Unstructured) {
+	gvr, _ := meta.UnsafeGuessKindToResource(obj.GroupVersionKind())
+	d.Tracker.Add(gvr, obj)
+}
+
+// Remove removes the object.
+func (d *Dynamic) Remove(obj *unstructured.Unstructured) {
+	gvr, _ := meta.UnsafeGuessKindToResource(obj.GroupVersionKind())
+	d.Tracker.Remove(gvr, obj)
+}
+
+// Gets returns the number of Gets made through Dynamic.
+func (d *Dynamic) Gets() int {
+	return d.Count(VerbGet)
+}
+
+// Creates returns the objects created through Dynamic, in order, and forgets
+// them.
+func (d *Dynamic) Creates() []*unstructured.Unstructured {
+	var objs []*unstructured.Unstructured
+	for _, a := range d.Take(VerbCreate) {
+		objs = append(objs, a.Object.(*unstructured.Unstructured).DeepCopy())
+	}
+	return objs
+}
+
+// Patches returns the patches made through Dynamic, in order, and forgets
+// them.
+func (d *Dynamic) Patches() []Action {
+	return d.Take(VerbPatch)
+}
+
+// Resource implements dynamic.Interface
+func (d *Dynamic) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return &dynamicResource{t: d.Tracker, gvr: gvr}
+}
+
+type dynamicResource struct {
+	dynamic.ResourceInterface
+
+	t         *Tracker
+	gvr       schema.GroupVersionResource
+	namespace string
+}
+
+func (r *dynamicResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return &dynamicResource{t: r.t, gvr: r.gvr, namespace: namespace}
+}
+
+func (r *dynamicResource) Get(name string, _ metav1.GetOptions, _ ...string) (*unstructured.Unstructured, error) {
+	obj, err := r.t.get(r.gvr, r.namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*unstructured.Unstructured), nil
+}
+
+func (r *dynamicResource) Create(obj *unstructured.Unstructured, _ ...string) (*unstructured.Unstructured, error) {
+	created, err := r.t.create(r.gvr, r.namespace, obj)
+	if err != nil {
+		return nil, err
+	}
+	return created.(*unstructured.Unstructured), nil
+}
+
+func (r *dynamicResource) Patch(name string, pt types.PatchType, data []byte, _ ...string) (*unstructured.Unstructured, error) {
+	obj, err := r.t.patch(r.gvr, r.namespace, name, pt, data)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*unstructured.Unstructured), nil
+}
@@ -0,0 +1,300 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+// The verbs of Actions.
+const (
+	VerbGet              = "get"
+	VerbCreate           = "create"
+	VerbUpdate           = "update"
+	VerbUpdateStatus     = "update-status"
+	VerbPatch            = "patch"
+	VerbDelete           = "delete"
+	VerbDeleteCollection = "delete-collection"
+)
+
+// Action is a call made through one of the stand-ins.
+type Action struct {
+	Verb      string
+	Resource  schema.GroupVersionResource
+	Namespace string
+	Name      string
+
+	// Object is what was created or updated.
+	Object runtime.Object
+
+	// Type and Data are those of patches.
+	Type types.PatchType
+	Data string
+}
+
+// Tracker keeps the objects of a stand-in in Indexers, by resource, and
+// records the calls made through it.
+type Tracker struct {
+	mu        sync.Mutex
+	indexers  map[schema.GroupResource]cache.Indexer
+	generated map[string]int
+	actions   []Action
+	reactor   func(Action) error
+}
+
+// NewTracker returns an empty Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{
+		indexers:  make(map[schema.GroupResource]cache.Indexer),
+		generated: make(map[string]int),
+	}
+}
+
+// Indexer returns the Indexer that holds the objects of the resource, e.g.
+// for its lister.
+func (t *Tracker) Indexer(gr schema.GroupResource) cache.Indexer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.indexer(gr)
+}
+
+func (t *Tracker) indexer(gr schema.GroupResource) cache.Indexer {
+	indexer, ok := t.indexers[gr]
+	if !ok {
+		indexer = NewIndexer()
+		t.indexers[gr] = indexer
+	}
+	return indexer
+}
+
+// React has f see each call before it is served, and fail it with f's error,
+// if any.  f is called without the Tracker's lock, so calls (and their
+// reactions) may overlap.
+func (t *Tracker) React(f func(Action) error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reactor = f
+}
+
+// Take returns the calls with the given verb made through the Tracker, in
+// order, and forgets them.
+func (t *Tracker) Take(verb string) []Action {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var taken, kept []Action
+	for _, a := range t.actions {
+		if a.Verb == verb {
+			taken = append(taken, a)
+		} else {
+			kept = append(kept, a)
+		}
+	}
+	t.actions = kept
+	return taken
+}
+
+// Count returns the number of calls with the given verb made through the
+// Tracker, without forgetting them.
+func (t *Tracker) Count(verb string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for _, a := range t.actions {
+		if a.Verb == verb {
+			n++
+		}
+	}
+	return n
+}
+
+// Keys returns the namespace/name keys of the Actions.
+func Keys(actions []Action) []string {
+	keys := make([]string, 0, len(actions))
+	for _, a := range actions {
+		keys = append(keys, a.Namespace+"/"+a.Name)
+	}
+	return keys
+}
+
+// react has the reactor see the call, and returns its error.
+func (t *Tracker) react(a Action) error {
+	t.mu.Lock()
+	reactor := t.reactor
+	t.mu.Unlock()
+	if reactor == nil {
+		return nil
+	}
+	return reactor(a)
+}
+
+// Add adds (or replaces) the object of the resource, without recording a
+// call.
+func (t *Tracker) Add(gvr schema.GroupVersionResource, obj runtime.Object) {
+	t.Indexer(gvr.GroupResource()).Update(obj.DeepCopyObject())
+}
+
+// Remove removes the object of the resource, without recording a call.
+func (t *Tracker) Remove(gvr schema.GroupVersionResource, obj runtime.Object) {
+	t.Indexer(gvr.GroupResource()).Delete(obj)
+}
+
+func (t *Tracker) get(gvr schema.GroupVersionResource, namespace, name string) (runtime.Object, error) {
+	a := Action{Verb: VerbGet, Resource: gvr, Namespace: namespace, Name: name}
+	if err := t.react(a); err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.actions = append(t.actions, a)
+	obj, ok, _ := t.indexer(gvr.GroupResource()).GetByKey(namespace + "/" + name)
+	if !ok {
+		return nil, errors.NewNotFound(gvr.GroupResource(), name)
+	}
+	return obj.(runtime.Object).DeepCopyObject(), nil
+}
+
+// create adds the object in the namespace, naming it after its GenerateName
+// when it has no name.  Objects made together have distinct GenerateNames,
+// so the names we generate don't depend on the order they're created in.
+func (t *Tracker) create(gvr schema.GroupVersionResource, namespace string, obj runtime.Object) (runtime.Object, error) {
+	obj = obj.DeepCopyObject()
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	m.SetNamespace(namespace)
+	if err := t.react(Action{Verb: VerbCreate, Resource: gvr, Namespace: namespace, Name: m.GetName(), Object: obj}); err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if m.GetName() == "" {
+		m.SetName(fmt.Sprintf("%s%d", m.GetGenerateName(), t.generated[m.GetGenerateName()]))
+		t.generated[m.GetGenerateName()]++
+	}
+	indexer := t.indexer(gvr.GroupResource())
+	if _, ok, _ := indexer.Get(obj); ok {
+		return nil, errors.NewAlreadyExists(gvr.GroupResource(), m.GetName())
+	}
+	indexer.Add(obj)
+	t.actions = append(t.actions, Action{Verb: VerbCreate, Resource: gvr, Namespace: namespace, Name: m.GetName(), Object: obj})
+	return obj.DeepCopyObject(), nil
+}
+
+// update replaces the object, which must exist, recording the call under the
+// given verb.
+func (t *Tracker) update(gvr schema.GroupVersionResource, verb, namespace string, obj runtime.Object) (runtime.Object, error) {
+	obj = obj.DeepCopyObject()
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	m.SetNamespace(namespace)
+	a := Action{Verb: verb, Resource: gvr, Namespace: namespace, Name: m.GetName(), Object: obj}
+	if err := t.react(a); err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	indexer := t.indexer(gvr.GroupResource())
+	if _, ok, _ := indexer.Get(obj); !ok {
+		return nil, errors.NewNotFound(gvr.GroupResource(), m.GetName())
+	}
+	indexer.Update(obj)
+	t.actions = append(t.actions, a)
+	return obj.DeepCopyObject(), nil
+}
+
+// patch records the patch, but leaves the object as it is.
+func (t *Tracker) patch(gvr schema.GroupVersionResource, namespace, name string, pt types.PatchType, data []byte) (runtime.Object, error) {
+	a := Action{Verb: VerbPatch, Resource: gvr, Namespace: namespace, Name: name, Type: pt, Data: string(data)}
+	if err := t.react(a); err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.actions = append(t.actions, a)
+	obj, ok, _ := t.indexer(gvr.GroupResource()).GetByKey(namespace + "/" + name)
+	if !ok {
+		return nil, errors.NewNotFound(gvr.GroupResource(), name)
+	}
+	return obj.(runtime.Object).DeepCopyObject(), nil
+}
+
+func (t *Tracker) delete(gvr schema.GroupVersionResource, namespace, name string) error {
+	a := Action{Verb: VerbDelete, Resource: gvr, Namespace: namespace, Name: name}
+	if err := t.react(a); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	indexer := t.indexer(gvr.GroupResource())
+	obj, ok, _ := indexer.GetByKey(namespace + "/" + name)
+	if !ok {
+		return errors.NewNotFound(gvr.GroupResource(), name)
+	}
+	t.actions = append(t.actions, a)
+	return indexer.Delete(obj)
+}
+
+// deleteCollection deletes the objects in the namespace that the label
+// selector selects, recording the call and each of the deletes.
+func (t *Tracker) deleteCollection(gvr schema.GroupVersionResource, namespace, labelSelector string) error {
+	selector, err := labels.Parse(labelSelector)
+	if err != nil {
+		return err
+	}
+	a := Action{Verb: VerbDeleteCollection, Resource: gvr, Namespace: namespace}
+	if err := t.react(a); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.actions = append(t.actions, a)
+	objs, err := t.indexer(gvr.GroupResource()).ByIndex(cache.NamespaceIndex, namespace)
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		m, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		if !selector.Matches(labels.Set(m.GetLabels())) {
+			continue
+		}
+		if err := t.delete(gvr, namespace, m.GetName()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package reference parses and normalizes container image references,
+// e.g. "nginx:1.15" is "index.docker.io/library/nginx:1.15".
+package reference
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// DockerHub is the canonical registry of references without one.
+	DockerHub = "index.docker.io"
+
+	// defaultTag is the tag of references without a tag or digest.
+	defaultTag = "latest"
+)
+
+// Reference is a parsed image reference.
+type Reference struct {
+	// Registry is the host (and port) of the registry.
+	Registry string
+
+	// Repository is the path of the repository within the registry.
+	Repository string
+
+	// Tag is the tag of the image, if it is referenced by tag.
+	Tag string
+
+	// Digest is the digest of the image, if it is referenced by digest.
+	Digest string
+}
+
+// Parse parses and normalizes the image reference.  References without a
+// registry are on Docker Hub, where unqualified repositories are in the
+// "library" namespace, and references without a tag or digest are tagged
+// "latest".
+func Parse(ref string) (*Reference, error) {
+	s := strings.TrimSpace(ref)
+	if s == "" {
+		return nil, fmt.Errorf("empty image reference")
+	}
+	r := &Reference{}
+
+	if i := strings.Index(s, "@"); i >= 0 {
+		r.Digest = s[i+1:]
+		s = s[:i]
+		if !strings.Contains(r.Digest, ":") {
+			return nil, fmt.Errorf("invalid digest in %q", ref)
+		}
+	}
+	if i := strings.LastIndex(s, ":"); i > strings.LastIndex(s, "/") {
+		r.Tag = s[i+1:]
+		s = s[:i]
+		if r.Tag == "" {
+			return nil, fmt.Errorf("empty tag in %q", ref)
+		}
+	}
+
+	// The first component is a registry if it looks like a host.
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		r.Registry, s = parts[0], parts[1]
+	} else {
+		r.Registry = DockerHub
+	}
+	r.Registry = NormalizeRegistry(r.Registry)
+	if r.Registry == DockerHub && !strings.Contains(s, "/") {
+		s = "library/" + s
+	}
+	if s == "" || s != strings.ToLower(s) {
+		return nil, fmt.Errorf("invalid repository in %q", ref)
+	}
+	r.Repository = s
+
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = defaultTag
+	}
+	return r, nil
+}
+
+// Normalize returns the normalized form of the reference, or the reference
+// itself if it doesn't parse.
+func Normalize(ref string) string {
+	r, err := Parse(ref)
+	if err != nil {
+		return strings.TrimSpace(ref)
+	}
+	return r.String()
+}
+
+// NormalizeRegistry returns the canonical name of the given registry, which
+// may be a URL (e.g. the https://index.docker.io/v1/ keys of docker config
+// files), so that its aliases (e.g. docker.io) are all named the same.
+func NormalizeRegistry(registry string) string {
+	if i := strings.Index(registry, "://"); i >= 0 {
+		registry = registry[i+3:]
+	}
+	if i := strings.Index(registry, "/"); i >= 0 {
+		registry = registry[:i]
+	}
+	switch registry {
+	case "docker.io", "registry-1.docker.io":
+		return DockerHub
+	}
+	return registry
+}
+
+// Identifier returns the digest of the reference, or else its tag, which is
+// how the registry API names manifests.
+func (r *Reference) Identifier() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// String returns the normalized form of the reference.
+func (r *Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reference
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want *Reference
+	}{{
+		ref:  "nginx",
+		want: &Reference{Registry: DockerHub, Repository: "library/nginx", Tag: "latest"},
+	}, {
+		ref:  "docker.io/istio/proxyv2:1.0.2",
+		want: &Reference{Registry: DockerHub, Repository: "istio/proxyv2", Tag: "1.0.2"},
+	}, {
+		ref:  "gcr.io/foo/bar@sha256:abcd",
+		want: &Reference{Registry: "gcr.io", Repository: "foo/bar", Digest: "sha256:abcd"},
+	}, {
+		ref:  "localhost:5000/foo:v1@sha256:abcd",
+		want: &Reference{Registry: "localhost:5000", Repository: "foo", Tag: "v1", Digest: "sha256:abcd"},
+	}, {
+		ref:  "localhost/foo",
+		want: &Reference{Registry: "localhost", Repository: "foo", Tag: "latest"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.ref, func(t *testing.T) {
+			got, err := Parse(test.ref)
+			if err != nil {
+				t.Fatalf("Parse() = %v", err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Parse (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, ref := range []string{"", " ", "foo:", "foo@abcd", "gcr.io/Foo/bar"} {
+		if got, err := Parse(ref); err == nil {
+			t.Errorf("Parse(%q) = %v, wanted error", ref, got)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	for ref, want := range map[string]string{
+		"nginx":                      "index.docker.io/library/nginx:latest",
+		"gcr.io/foo/bar@sha256:abcd": "gcr.io/foo/bar@sha256:abcd",
+		"localhost:5000/foo:v1":      "localhost:5000/foo:v1",
+	} {
+		r, err := Parse(ref)
+		if err != nil {
+			t.Fatalf("Parse(%q) = %v", ref, err)
+		}
+		if got := r.String(); got != want {
+			t.Errorf("String() = %q, wanted %q", got, want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for ref, want := range map[string]string{
+		"foo":                          "index.docker.io/library/foo:latest",
+		"docker.io/library/foo:latest": "index.docker.io/library/foo:latest",
+		"gcr.io/foo/bar@sha256:abc":    "gcr.io/foo/bar@sha256:abc",
+		" gcr.io/foo/Bar ":             "gcr.io/foo/Bar",
+	} {
+		if got := Normalize(ref); got != want {
+			t.Errorf("Normalize(%q) = %q, wanted %q", ref, got, want)
+		}
+	}
+}
+
+func TestNormalizeRegistry(t *testing.T) {
+	for registry, want := range map[string]string{
+		"docker.io":                   DockerHub,
+		"https://index.docker.io/v1/": DockerHub,
+		"registry-1.docker.io":        DockerHub,
+		"gcr.io":                      "gcr.io",
+		"http://localhost:5000":       "localhost:5000",
+	} {
+		if got := NormalizeRegistry(registry); got != want {
+			t.Errorf("NormalizeRegistry(%q) = %q, wanted %q", registry, got, want)
+		}
+	}
+}
@@ -0,0 +1,497 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package registry checks that images exist, by asking their registries for
+// their manifests.
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"github.com/mattmoor/cachier/pkg/reference"
+)
+
+// Result is the outcome of checking an image.
+type Result string
+
+const (
+	// Found means that the registry has the image.
+	Found Result = "Found"
+
+	// NotFound means that the registry doesn't have the image (e.g. the
+	// tag is misspelled).
+	NotFound Result = "NotFound"
+
+	// Unauthorized means that the registry refused to tell us about the
+	// image with the credentials we have.
+	Unauthorized Result = "Unauthorized"
+)
+
+// manifestTypes are the media types of the manifests we accept.
+var manifestTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v1+prettyjws",
+}
+
+// Options configures a Checker.
+type Options struct {
+	// Client is the client with which to talk to registries.  Defaults to
+	// http.DefaultClient.
+	Client *http.Client
+
+	// PositiveTTL is how long to remember that an image exists.  Defaults
+	// to an hour.
+	PositiveTTL time.Duration
+
+	// NegativeTTL is how long to remember that an image doesn't exist, or
+	// that we may not pull it.  Defaults to a minute, so that fixes (e.g.
+	// pushing the image) are noticed quickly.
+	NegativeTTL time.Duration
+
+	// CacheSize is the maximum number of results to remember.  Defaults
+	// to 4096.
+	CacheSize int
+
+	// QPS and Burst limit the rate of requests to each registry.  Defaults
+	// to 5 and 10.
+	QPS   float64
+	Burst int
+
+	// Insecure holds the registries to talk to over plain HTTP, in
+	// addition to those on localhost.
+	Insecure []string
+
+	// Clock tells the time.  Defaults to the real clock.
+	Clock clock.Clock
+}
+
+// Checker checks whether images exist by sending HEAD requests for their
+// manifests, and remembers the results.
+type Checker struct {
+	client      *http.Client
+	positiveTTL time.Duration
+	negativeTTL time.Duration
+	qps         rate.Limit
+	burst       int
+	insecure    map[string]bool
+	clock       clock.Clock
+
+	// results maps references (and the identity of the credentials used)
+	// to cachedResults.
+	results *lru.Cache
+
+	// tokens maps repositories (and the identity of the credentials used)
+	// to the cachedTokens that their registries issued for pulling them.
+	tokens *lru.Cache
+
+	m        sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+type cachedResult struct {
+	result  Result
+	expires time.Time
+}
+
+type cachedToken struct {
+	authorization string
+	expires       time.Time
+}
+
+// defaultTokenTTL is how long bearer tokens last when their registries don't
+// say, per the token spec of the registry API.
+const defaultTokenTTL = 60 * time.Second
+
+// NewChecker returns a new Checker with the given Options.
+func NewChecker(opts Options) *Checker {
+	c := &Checker{
+		client:      opts.Client,
+		positiveTTL: opts.PositiveTTL,
+		negativeTTL: opts.NegativeTTL,
+		qps:         rate.Limit(opts.QPS),
+		burst:       opts.Burst,
+		insecure:    make(map[string]bool, len(opts.Insecure)),
+		clock:       opts.Clock,
+		limiters:    make(map[string]*rate.Limiter),
+	}
+	if c.client == nil {
+		c.client = http.DefaultClient
+	}
+	if c.positiveTTL == 0 {
+		c.positiveTTL = time.Hour
+	}
+	if c.negativeTTL == 0 {
+		c.negativeTTL = time.Minute
+	}
+	if c.qps == 0 {
+		c.qps = 5
+	}
+	if c.burst == 0 {
+		c.burst = 10
+	}
+	if c.clock == nil {
+		c.clock = clock.RealClock{}
+	}
+	size := opts.CacheSize
+	if size <= 0 {
+		size = 4096
+	}
+	// This only fails for non-positive sizes.
+	c.results, _ = lru.New(size)
+	c.tokens, _ = lru.New(size)
+	for _, r := range opts.Insecure {
+		c.insecure[r] = true
+	}
+	return c
+}
+
+// ThrottledError is returned when the rate limit of a registry doesn't
+// allow a request yet.  Rather than wait, callers should retry after Delay.
+type ThrottledError struct {
+	Registry string
+	Delay    time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("requests to %s are rate limited for another %v", e.Registry, e.Delay)
+}
+
+// Auth identifies the credentials with which to check images, and resolves
+// them lazily, so that callers needn't fetch them when we remember results.
+type Auth struct {
+	// ID identifies the credentials (e.g. the pull secrets they come from),
+	// so that the results we remember for them aren't used for others.
+	ID string
+
+	// Keychain returns the credentials.  It is only called when we don't
+	// remember a result, and no credentials are used when it is nil.
+	Keychain func() (Keychain, error)
+}
+
+// Static returns the Auth of the given keychain, identified by its contents.
+func Static(keychain Keychain) Auth {
+	if len(keychain) == 0 {
+		return Auth{}
+	}
+	registries := make([]string, 0, len(keychain))
+	for registry := range keychain {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+	h := sha256.New()
+	for _, registry := range registries {
+		creds := keychain[registry]
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00", registry, creds.Username, creds.Password)
+	}
+	return Auth{
+		ID:       hex.EncodeToString(h.Sum(nil)[:8]),
+		Keychain: func() (Keychain, error) { return keychain, nil },
+	}
+}
+
+// Check returns whether the registry of the given image reference has it,
+// authenticating with the credentials of auth.  Errors are returned for
+// transient problems (e.g. the registry is unavailable, or its rate limit
+// is spent, per ThrottledError), which aren't remembered.
+func (c *Checker) Check(ctx context.Context, ref string, auth Auth) (Result, error) {
+	r, err := reference.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+
+	key := r.String()
+	if auth.ID != "" {
+		key += "#" + auth.ID
+	}
+	if v, ok := c.results.Get(key); ok {
+		if cr := v.(cachedResult); c.clock.Now().Before(cr.expires) {
+			return cr.result, nil
+		}
+		c.results.Remove(key)
+	}
+
+	var creds Credentials
+	var hasCreds bool
+	if auth.Keychain != nil {
+		keychain, err := auth.Keychain()
+		if err != nil {
+			return "", err
+		}
+		creds, hasCreds = keychain.Resolve(r.Registry)
+	}
+
+	result, err := c.check(ctx, r, auth.ID, creds, hasCreds)
+	if err != nil {
+		return "", err
+	}
+	ttl := c.positiveTTL
+	if result != Found {
+		ttl = c.negativeTTL
+	}
+	c.results.Add(key, cachedResult{result: result, expires: c.clock.Now().Add(ttl)})
+	return result, nil
+}
+
+func (c *Checker) check(ctx context.Context, r *reference.Reference, authID string, creds Credentials, hasCreds bool) (Result, error) {
+	manifestURL := fmt.Sprintf("%s://%s/v2/%s/manifests/%s",
+		c.scheme(r.Registry), apiHost(r.Registry), r.Repository, r.Identifier())
+
+	// Reuse the token that the registry last issued us for the repository,
+	// rather than fetch one for each of its images.
+	tokenKey := r.Registry + "/" + r.Repository
+	if authID != "" {
+		tokenKey += "#" + authID
+	}
+	authorization := ""
+	if v, ok := c.tokens.Get(tokenKey); ok {
+		if ct := v.(cachedToken); c.clock.Now().Before(ct.expires) {
+			authorization = ct.authorization
+		} else {
+			c.tokens.Remove(tokenKey)
+		}
+	}
+
+	resp, err := c.head(ctx, r.Registry, manifestURL, authorization)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		// Answer the registry's challenge and try again.
+		c.tokens.Remove(tokenKey)
+		var ttl time.Duration
+		authorization, ttl, err = c.authorize(ctx, r, resp.Header.Get("WWW-Authenticate"), creds, hasCreds)
+		if err != nil {
+			return "", err
+		}
+		if authorization == "" {
+			return Unauthorized, nil
+		}
+		if ttl > 0 {
+			c.tokens.Add(tokenKey, cachedToken{authorization: authorization, expires: c.clock.Now().Add(ttl)})
+		}
+		if resp, err = c.head(ctx, r.Registry, manifestURL, authorization); err != nil {
+			return "", err
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return Found, nil
+	case http.StatusNotFound:
+		return NotFound, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return Unauthorized, nil
+	default:
+		return "", fmt.Errorf("unexpected status checking %s: %s", r, resp.Status)
+	}
+}
+
+// head sends a HEAD request for the given manifest, subject to the rate
+// limit of the registry.
+func (c *Checker) head(ctx context.Context, registry, manifestURL, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodHead, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestTypes, ","))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return c.do(ctx, registry, req)
+}
+
+// do sends the request if the rate limit of the registry allows, and
+// otherwise returns a ThrottledError, rather than block the caller.
+func (c *Checker) do(ctx context.Context, registry string, req *http.Request) (*http.Response, error) {
+	reservation := c.limiter(registry).Reserve()
+	if !reservation.OK() {
+		return nil, fmt.Errorf("requests to %s are not allowed by its rate limit", registry)
+	}
+	if delay := reservation.Delay(); delay > 0 {
+		// Give the token back, for whoever comes back first.
+		reservation.Cancel()
+		return nil, &ThrottledError{Registry: registry, Delay: delay}
+	}
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	// We only look at headers and small token responses, so drain and
+	// close the body here, leaving the rest to readers of Body.
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+	return resp, nil
+}
+
+func (c *Checker) limiter(registry string) *rate.Limiter {
+	c.m.Lock()
+	defer c.m.Unlock()
+	l, ok := c.limiters[registry]
+	if !ok {
+		l = rate.NewLimiter(c.qps, c.burst)
+		c.limiters[registry] = l
+	}
+	return l
+}
+
+// authorize returns the Authorization header with which to answer the given
+// challenge, or "" if we have no way to, and for bearer tokens how long the
+// header lasts.
+func (c *Checker) authorize(ctx context.Context, r *reference.Reference, challenge string, creds Credentials, hasCreds bool) (string, time.Duration, error) {
+	scheme, params := parseChallenge(challenge)
+	switch scheme {
+	case "basic":
+		if !hasCreds {
+			return "", 0, nil
+		}
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(creds.Username, creds.Password)
+		return req.Header.Get("Authorization"), 0, nil
+
+	case "bearer":
+		realm, err := url.Parse(params["realm"])
+		if err != nil || realm.Host == "" {
+			return "", 0, fmt.Errorf("malformed bearer realm %q from %s", params["realm"], r.Registry)
+		}
+		q := realm.Query()
+		if s := params["service"]; s != "" {
+			q.Set("service", s)
+		}
+		q.Set("scope", fmt.Sprintf("repository:%s:pull", r.Repository))
+		realm.RawQuery = q.Encode()
+
+		req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+		if err != nil {
+			return "", 0, err
+		}
+		if hasCreds {
+			req.SetBasicAuth(creds.Username, creds.Password)
+		}
+		resp, err := c.do(ctx, r.Registry, req)
+		if err != nil {
+			return "", 0, err
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return "", 0, nil
+		default:
+			return "", 0, fmt.Errorf("unexpected status fetching token for %s: %s", r, resp.Status)
+		}
+		var token struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int    `json:"expires_in"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+			return "", 0, fmt.Errorf("malformed token for %s: %v", r, err)
+		}
+		if token.Token == "" {
+			token.Token = token.AccessToken
+		}
+		if token.Token == "" {
+			return "", 0, nil
+		}
+		ttl := time.Duration(token.ExpiresIn) * time.Second
+		if ttl <= 0 {
+			ttl = defaultTokenTTL
+		}
+		return "Bearer " + token.Token, ttl, nil
+
+	default:
+		return "", 0, nil
+	}
+}
+
+// parseChallenge parses a WWW-Authenticate header (e.g. Bearer
+// realm="https://auth.docker.io/token",service="registry.docker.io") into
+// its (lower-cased) scheme and parameters.
+func parseChallenge(challenge string) (string, map[string]string) {
+	challenge = strings.TrimSpace(challenge)
+	params := make(map[string]string)
+	i := strings.Index(challenge, " ")
+	if i < 0 {
+		return strings.ToLower(challenge), params
+	}
+	scheme, rest := strings.ToLower(challenge[:i]), challenge[i+1:]
+	for rest != "" {
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		name := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = strings.TrimSpace(rest[eq+1:])
+		var value string
+		if strings.HasPrefix(rest, "\"") {
+			end := strings.Index(rest[1:], "\"")
+			if end < 0 {
+				break
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else if comma := strings.Index(rest, ","); comma >= 0 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[name] = value
+		rest = strings.TrimLeft(rest, ", ")
+	}
+	return scheme, params
+}
+
+// scheme returns the scheme with which to talk to the registry.
+func (c *Checker) scheme(registry string) string {
+	host := registry
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	if c.insecure[registry] || host == "localhost" || host == "127.0.0.1" {
+		return "http"
+	}
+	return "https"
+}
+
+// apiHost returns the host that serves the registry API of the registry,
+// which differs from its name for Docker Hub.
+func apiHost(registry string) string {
+	if registry == reference.DockerHub {
+		return "registry-1.docker.io"
+	}
+	return registry
+}
@@ -0,0 +1,381 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// fakeRegistry is an in-process stand-in for a registry, which serves the
+// given manifests (by repository:tag), and requires the given credentials
+// (if any) via a basic or bearer challenge.
+type fakeRegistry struct {
+	*httptest.Server
+	requests int32
+}
+
+func newFakeRegistry(t *testing.T, auth string, creds *Credentials, manifests ...string) *fakeRegistry {
+	have := make(map[string]bool, len(manifests))
+	for _, m := range manifests {
+		have[m] = true
+	}
+	fr := &fakeRegistry{}
+	const token = "let-me-in"
+	fr.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fr.requests, 1)
+		if r.URL.Path == "/token" {
+			if got, want := r.URL.Query().Get("service"), "fake"; got != want {
+				t.Errorf("service = %q, wanted %q", got, want)
+			}
+			if creds != nil {
+				if u, p, ok := r.BasicAuth(); !ok || u != creds.Username || p != creds.Password {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+			}
+			fmt.Fprintf(w, `{"token": %q}`, token)
+			return
+		}
+
+		if r.Method != http.MethodHead {
+			t.Errorf("Method = %v, wanted HEAD", r.Method)
+		}
+		switch auth {
+		case "basic":
+			if u, p, ok := r.BasicAuth(); !ok || u != creds.Username || p != creds.Password {
+				w.Header().Set("WWW-Authenticate", `Basic realm="fake"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		case "bearer":
+			if r.Header.Get("Authorization") != "Bearer "+token {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/token",service="fake"`, r.Host))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/v2/"), "/manifests/", 2)
+		if len(parts) != 2 || !have[parts[0]+":"+parts[1]] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	return fr
+}
+
+func (fr *fakeRegistry) host() string {
+	return strings.TrimPrefix(fr.URL, "http://")
+}
+
+func TestCheck(t *testing.T) {
+	creds := &Credentials{Username: "user", Password: "pass"}
+	anonymous := Keychain{}
+
+	tests := []struct {
+		name     string
+		auth     string
+		creds    *Credentials
+		keychain func(host string) Keychain
+		ref      string
+		want     Result
+	}{{
+		name: "anonymous found",
+		ref:  "foo:v1",
+		want: Found,
+	}, {
+		name: "anonymous not found",
+		ref:  "foo:v2",
+		want: NotFound,
+	}, {
+		name: "anonymous bearer found",
+		auth: "bearer",
+		ref:  "foo:v1",
+		want: Found,
+	}, {
+		name:  "bearer with credentials",
+		auth:  "bearer",
+		creds: creds,
+		keychain: func(host string) Keychain {
+			return Keychain{host: *creds}
+		},
+		ref:  "foo:v1",
+		want: Found,
+	}, {
+		name:  "bearer with credentials not found",
+		auth:  "bearer",
+		creds: creds,
+		keychain: func(host string) Keychain {
+			return Keychain{host: *creds}
+		},
+		ref:  "bar:v1",
+		want: NotFound,
+	}, {
+		name:  "bearer without credentials",
+		auth:  "bearer",
+		creds: creds,
+		ref:   "foo:v1",
+		want:  Unauthorized,
+	}, {
+		name:  "bearer with wrong credentials",
+		auth:  "bearer",
+		creds: creds,
+		keychain: func(host string) Keychain {
+			return Keychain{host: Credentials{Username: "user", Password: "wrong"}}
+		},
+		ref:  "foo:v1",
+		want: Unauthorized,
+	}, {
+		name:  "basic with credentials",
+		auth:  "basic",
+		creds: creds,
+		keychain: func(host string) Keychain {
+			return Keychain{host: *creds}
+		},
+		ref:  "foo:v1",
+		want: Found,
+	}, {
+		name:  "basic without credentials",
+		auth:  "basic",
+		creds: creds,
+		ref:   "foo:v1",
+		want:  Unauthorized,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fr := newFakeRegistry(t, test.auth, test.creds, "foo:v1")
+			defer fr.Close()
+
+			keychain := anonymous
+			if test.keychain != nil {
+				keychain = test.keychain(fr.host())
+			}
+			c := NewChecker(Options{})
+			got, err := c.Check(context.Background(), fr.host()+"/"+test.ref, Static(keychain))
+			if err != nil {
+				t.Fatalf("Check() = %v", err)
+			}
+			if got != test.want {
+				t.Errorf("Check() = %v, wanted %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckCaching(t *testing.T) {
+	fr := newFakeRegistry(t, "", nil, "foo:v1")
+	defer fr.Close()
+
+	start := time.Now()
+	clk := clock.NewFakeClock(start)
+	c := NewChecker(Options{
+		PositiveTTL: time.Hour,
+		NegativeTTL: time.Minute,
+		Clock:       clk,
+	})
+
+	check := func(ref string, want Result, wantRequests int32) {
+		t.Helper()
+		got, err := c.Check(context.Background(), fr.host()+"/"+ref, Auth{})
+		if err != nil {
+			t.Fatalf("Check(%s) = %v", ref, err)
+		}
+		if got != want {
+			t.Errorf("Check(%s) = %v, wanted %v", ref, got, want)
+		}
+		if got := atomic.LoadInt32(&fr.requests); got != wantRequests {
+			t.Errorf("requests = %d, wanted %d", got, wantRequests)
+		}
+	}
+
+	check("foo:v1", Found, 1)
+	check("foo:v2", NotFound, 2)
+	// Both are remembered.
+	check("foo:v1", Found, 2)
+	check("foo:v2", NotFound, 2)
+
+	// The negative result expires first.
+	clk.SetTime(start.Add(2 * time.Minute))
+	check("foo:v1", Found, 2)
+	check("foo:v2", NotFound, 3)
+
+	// Then the positive one.
+	clk.SetTime(start.Add(2 * time.Hour))
+	check("foo:v1", Found, 4)
+
+	// Results aren't shared across credentials.
+	got, err := c.Check(context.Background(), fr.host()+"/foo:v1", Static(Keychain{fr.host(): {Username: "a", Password: "b"}}))
+	if err != nil || got != Found {
+		t.Errorf("Check() = %v, %v, wanted %v", got, err, Found)
+	}
+	if got := atomic.LoadInt32(&fr.requests); got != 5 {
+		t.Errorf("requests = %d, wanted 5", got)
+	}
+
+	// Credentials are only resolved when we don't remember a result.
+	resolved := 0
+	auth := Auth{ID: "lazy", Keychain: func() (Keychain, error) {
+		resolved++
+		return Keychain{}, nil
+	}}
+	for i := 0; i < 2; i++ {
+		if _, err := c.Check(context.Background(), fr.host()+"/foo:v1", auth); err != nil {
+			t.Fatalf("Check() = %v", err)
+		}
+	}
+	if resolved != 1 {
+		t.Errorf("Keychain() called %d times, wanted 1", resolved)
+	}
+}
+
+func TestCheckTokenCaching(t *testing.T) {
+	creds := &Credentials{Username: "user", Password: "pass"}
+	fr := newFakeRegistry(t, "bearer", creds, "foo:v1", "bar:v1")
+	defer fr.Close()
+
+	start := time.Now()
+	clk := clock.NewFakeClock(start)
+	c := NewChecker(Options{QPS: 1000, Burst: 100, Clock: clk})
+	auth := func(id string) Auth {
+		return Auth{ID: id, Keychain: func() (Keychain, error) {
+			return Keychain{fr.host(): *creds}, nil
+		}}
+	}
+
+	check := func(ref string, auth Auth, want Result, wantRequests int32) {
+		t.Helper()
+		got, err := c.Check(context.Background(), fr.host()+"/"+ref, auth)
+		if err != nil {
+			t.Fatalf("Check(%s) = %v", ref, err)
+		}
+		if got != want {
+			t.Errorf("Check(%s) = %v, wanted %v", ref, got, want)
+		}
+		if got := atomic.LoadInt32(&fr.requests); got != wantRequests {
+			t.Errorf("requests = %d, wanted %d", got, wantRequests)
+		}
+	}
+
+	// The first check of a repository is challenged, and fetches a token.
+	check("foo:v1", auth("a"), Found, 3)
+	// Which the checks of its other images reuse.
+	check("foo:v2", auth("a"), NotFound, 4)
+
+	// Tokens aren't shared across repositories, nor credentials.
+	check("bar:v1", auth("a"), Found, 7)
+	check("foo:v3", auth("b"), NotFound, 10)
+
+	// They last for the expires_in of the token response, which defaults
+	// to a minute.
+	clk.SetTime(start.Add(defaultTokenTTL - time.Second))
+	check("foo:v4", auth("a"), NotFound, 11)
+	clk.SetTime(start.Add(defaultTokenTTL))
+	check("foo:v5", auth("a"), NotFound, 14)
+}
+
+func TestCheckRateLimit(t *testing.T) {
+	fr := newFakeRegistry(t, "", nil, "foo:v1")
+	defer fr.Close()
+
+	c := NewChecker(Options{QPS: 0.001, Burst: 1})
+
+	if _, err := c.Check(context.Background(), fr.host()+"/foo:v1", Auth{}); err != nil {
+		t.Fatalf("Check() = %v", err)
+	}
+	// The cached result doesn't count against the limit.
+	if _, err := c.Check(context.Background(), fr.host()+"/foo:v1", Auth{}); err != nil {
+		t.Fatalf("Check() = %v", err)
+	}
+
+	// The burst is spent, so rather than wait, the next request is
+	// throttled until the limit allows it.
+	_, err := c.Check(context.Background(), fr.host()+"/foo:v2", Auth{})
+	if te, ok := err.(*ThrottledError); !ok || te.Delay <= 0 {
+		t.Errorf("Check() = %v, wanted a ThrottledError", err)
+	}
+	if got := atomic.LoadInt32(&fr.requests); got != 1 {
+		t.Errorf("requests = %d, wanted 1", got)
+	}
+}
+
+func TestCheckUnavailable(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	c := NewChecker(Options{})
+	ref := strings.TrimPrefix(ts.URL, "http://") + "/foo:v1"
+	if got, err := c.Check(context.Background(), ref, Auth{}); err == nil {
+		t.Errorf("Check() = %v, wanted error", got)
+	}
+}
+
+func TestAddSecret(t *testing.T) {
+	auth := base64.StdEncoding.EncodeToString([]byte("user:pa:ss"))
+
+	k := Keychain{}
+	if err := k.AddSecret(corev1.SecretTypeDockerConfigJson, map[string][]byte{
+		corev1.DockerConfigJsonKey: []byte(`{"auths": {
+			"https://index.docker.io/v1/": {"auth": "` + auth + `"},
+			"gcr.io": {"username": "_json_key", "password": "{}"}
+		}}`),
+	}); err != nil {
+		t.Fatalf("AddSecret() = %v", err)
+	}
+	// The first credentials for gcr.io win.
+	if err := k.AddSecret(corev1.SecretTypeDockercfg, map[string][]byte{
+		corev1.DockerConfigKey: []byte(`{
+			"gcr.io": {"username": "other", "password": "other"},
+			"localhost:5000": {"username": "local", "password": "local"}
+		}`),
+	}); err != nil {
+		t.Fatalf("AddSecret() = %v", err)
+	}
+
+	want := Keychain{
+		"index.docker.io": {Username: "user", Password: "pa:ss"},
+		"gcr.io":          {Username: "_json_key", Password: "{}"},
+		"localhost:5000":  {Username: "local", Password: "local"},
+	}
+	if diff := cmp.Diff(want, k); diff != "" {
+		t.Errorf("AddSecret (-want, +got) = %v", diff)
+	}
+
+	if err := k.AddSecret(corev1.SecretTypeOpaque, nil); err == nil {
+		t.Error("AddSecret(Opaque) = nil, wanted error")
+	}
+	if err := k.AddSecret(corev1.SecretTypeDockerConfigJson, map[string][]byte{
+		corev1.DockerConfigJsonKey: []byte("{"),
+	}); err == nil {
+		t.Error("AddSecret(malformed) = nil, wanted error")
+	}
+}
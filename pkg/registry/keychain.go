@@ -0,0 +1,95 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/mattmoor/cachier/pkg/reference"
+)
+
+// Credentials are the basic credentials for a registry.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Keychain maps registries (as reference.Reference names them) to the
+// credentials with which to pull from them.
+type Keychain map[string]Credentials
+
+// Resolve returns the credentials for the given registry, if any.
+func (k Keychain) Resolve(registry string) (Credentials, bool) {
+	creds, ok := k[registry]
+	return creds, ok
+}
+
+// dockerConfigEntry is an entry of a .dockercfg file, or of the auths of a
+// .dockerconfigjson file.
+type dockerConfigEntry struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Auth     string `json:"auth,omitempty"`
+}
+
+// AddSecret adds the credentials of a pull secret with the given type and
+// data to the keychain.  Like the kubelet, the first credentials for each
+// registry win, so callers should add secrets in order of precedence.
+func (k Keychain) AddSecret(secretType corev1.SecretType, data map[string][]byte) error {
+	var entries map[string]dockerConfigEntry
+	switch secretType {
+	case corev1.SecretTypeDockerConfigJson:
+		var cfg struct {
+			Auths map[string]dockerConfigEntry `json:"auths"`
+		}
+		if err := json.Unmarshal(data[corev1.DockerConfigJsonKey], &cfg); err != nil {
+			return fmt.Errorf("malformed %s: %v", corev1.DockerConfigJsonKey, err)
+		}
+		entries = cfg.Auths
+	case corev1.SecretTypeDockercfg:
+		if err := json.Unmarshal(data[corev1.DockerConfigKey], &entries); err != nil {
+			return fmt.Errorf("malformed %s: %v", corev1.DockerConfigKey, err)
+		}
+	default:
+		return fmt.Errorf("unsupported secret type %q", secretType)
+	}
+
+	for host, entry := range entries {
+		creds := Credentials{Username: entry.Username, Password: entry.Password}
+		if entry.Auth != "" {
+			raw, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return fmt.Errorf("malformed auth for %q: %v", host, err)
+			}
+			parts := strings.SplitN(string(raw), ":", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("malformed auth for %q", host)
+			}
+			creds = Credentials{Username: parts[0], Password: parts[1]}
+		}
+		registry := reference.NormalizeRegistry(host)
+		if _, ok := k[registry]; !ok {
+			k[registry] = creds
+		}
+	}
+	return nil
+}
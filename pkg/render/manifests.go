@@ -0,0 +1,97 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Read decodes the Kubernetes objects in the YAML or JSON stream, which may
+// hold several documents, and flattens any Lists (e.g. from kubectl).
+func Read(r io.Reader) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	dec := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		var m map[string]interface{}
+		if err := dec.Decode(&m); err == io.EOF {
+			return objs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		// Skip empty documents (e.g. a leading ---, or comments).
+		if len(m) == 0 {
+			continue
+		}
+		u := &unstructured.Unstructured{Object: m}
+		if !u.IsList() {
+			objs = append(objs, u)
+			continue
+		}
+		err := u.EachListItem(func(obj runtime.Object) error {
+			objs = append(objs, obj.(*unstructured.Unstructured))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ReadPath reads the objects in the named file, in the .yaml, .yml and
+// .json files under the named directory, or on stdin when path is "-".
+func ReadPath(path string) ([]*unstructured.Unstructured, error) {
+	if path == "-" {
+		return Read(os.Stdin)
+	}
+	var objs []*unstructured.Unstructured
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		// Files named explicitly are read whatever their extension.
+		if p != path {
+			switch strings.ToLower(filepath.Ext(p)) {
+			case ".yaml", ".yml", ".json":
+			default:
+				return nil
+			}
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		read, err := Read(f)
+		if err != nil {
+			return fmt.Errorf("%s: %v", p, err)
+		}
+		objs = append(objs, read...)
+		return nil
+	})
+	return objs, err
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func names(objs []*unstructured.Unstructured) []string {
+	var out []string
+	for _, obj := range objs {
+		out = append(out, obj.GetKind()+"/"+obj.GetName())
+	}
+	return out
+}
+
+func TestRead(t *testing.T) {
+	in := `---
+# A comment.
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: foo
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: apps/v1
+  kind: StatefulSet
+  metadata:
+    name: bar
+- apiVersion: v1
+  kind: Namespace
+  metadata:
+    name: baz
+`
+	objs, err := Read(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("Read() = %v", err)
+	}
+	want := []string{"Deployment/foo", "StatefulSet/bar", "Namespace/baz"}
+	if diff := cmp.Diff(want, names(objs)); diff != "" {
+		t.Errorf("Read() (-want, +got) = %v", diff)
+	}
+}
+
+func TestReadPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "render")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"a.yaml":        "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: a\n",
+		"sub/b.json":    `{"apiVersion": "apps/v1", "kind": "DaemonSet", "metadata": {"name": "b"}}`,
+		"README.md":     "Not a manifest.",
+		"sub/c.txt":     "Nor this.",
+		"sub/d/e.yml":   "apiVersion: apps/v1\nkind: StatefulSet\nmetadata:\n  name: e\n",
+		"sub/d/empty.y": "",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("MkdirAll() = %v", err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile() = %v", err)
+		}
+	}
+
+	objs, err := ReadPath(dir)
+	if err != nil {
+		t.Fatalf("ReadPath() = %v", err)
+	}
+	want := []string{"Deployment/a", "DaemonSet/b", "StatefulSet/e"}
+	if diff := cmp.Diff(want, names(objs)); diff != "" {
+		t.Errorf("ReadPath() (-want, +got) = %v", diff)
+	}
+
+	// Files named explicitly are read whatever their extension.
+	if _, err := ReadPath(filepath.Join(dir, "sub/c.txt")); err == nil {
+		t.Error("ReadPath(c.txt) = nil, wanted an error")
+	}
+}
@@ -0,0 +1,256 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package render works out the Images that the controller would create for
+// the resources in a set of manifests, without a cluster, so that changes to
+// manifests and policies can be reviewed (e.g. in CI).
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	caching "github.com/knative/caching/pkg/apis/caching/v1alpha1"
+	"github.com/knative/pkg/apis/duck"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/apis/podspec/v1alpha1"
+	cachierlisters "github.com/mattmoor/cachier/pkg/client/listers/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/extractors"
+	"github.com/mattmoor/cachier/pkg/policy"
+	"github.com/mattmoor/cachier/pkg/reconciler/cachier/resources"
+)
+
+// Options configures Render.
+type Options struct {
+	// Resources are the kinds whose pod templates we cache, as with the
+	// controller's -resource.  Only their group and kind are matched, since
+	// manifests may use any version.
+	Resources []schema.GroupVersionKind
+
+	// Extractors find the images of resources that aren't PodSpecable.
+	Extractors []*extractors.Extractor
+
+	// DefaultMode applies when nothing else decides.  Defaults to OptOut.
+	DefaultMode cachierv1alpha1.Mode
+
+	// Namespace is the namespace of the namespaced objects that don't name
+	// one.  Defaults to "default".
+	Namespace string
+}
+
+// Result is what the controller would do for one resource.
+type Result struct {
+	// Resource is the resource, in the shape from which Images are made.
+	Resource *v1alpha1.WithPod
+
+	// Decision is whether, and why, its images are cached.
+	Decision policy.Decision
+
+	// Path is the path to the Decision (see policy.Explain).
+	Path []string
+
+	// Images are the Images that would be created, sorted by image.
+	Images []caching.Image
+
+	// ExcludedImages are the sorted references of the images that the
+	// policy excludes.
+	ExcludedImages []string
+}
+
+// Render returns the Results for the resources amongst objs, deciding with
+// the CachePolicies, ClusterCachePolicies and Namespaces amongst them.  The
+// resources' ServiceAccounts, running Pods and registries aren't consulted.
+func Render(objs []*unstructured.Unstructured, opts Options) ([]Result, error) {
+	if opts.Namespace == "" {
+		opts.Namespace = "default"
+	}
+	policies := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	clusterPolicies := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	namespaces := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+
+	var things []*v1alpha1.WithPod
+	for _, u := range objs {
+		gvk := u.GroupVersionKind()
+		var err error
+		switch gk := gvk.GroupKind(); {
+		case gk == cachierv1alpha1.SchemeGroupVersion.WithKind("CachePolicy").GroupKind():
+			p := &cachierv1alpha1.CachePolicy{}
+			if err = decode(u, opts.Namespace, p); err == nil {
+				err = policies.Add(p)
+			}
+		case gk == cachierv1alpha1.SchemeGroupVersion.WithKind("ClusterCachePolicy").GroupKind():
+			p := &cachierv1alpha1.ClusterCachePolicy{}
+			if err = decode(u, "", p); err == nil {
+				err = clusterPolicies.Add(p)
+			}
+		case gk == v1alpha1.NamespacesResource.GroupVersion().WithKind("Namespace").GroupKind():
+			ns := &v1alpha1.WithMetadata{}
+			if err = decode(u, "", ns); err == nil {
+				err = namespaces.Add(ns)
+			}
+		default:
+			var thing *v1alpha1.WithPod
+			thing, err = convert(u, opts)
+			if thing != nil {
+				things = append(things, thing)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s %s/%s: %v", gvk.Kind, u.GetNamespace(), u.GetName(), err)
+		}
+	}
+
+	namespaceLister := cache.NewGenericLister(namespaces, v1alpha1.NamespacesResource.GroupResource())
+	resolver := &policy.Resolver{
+		PolicyLister:        cachierlisters.NewCachePolicyLister(policies),
+		ClusterPolicyLister: cachierlisters.NewClusterCachePolicyLister(clusterPolicies),
+		NamespaceLister:     namespaceLister,
+	}
+
+	results := make([]Result, 0, len(things))
+	for _, thing := range things {
+		r, err := decide(thing, resolver, namespaceLister, opts.DefaultMode)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s/%s: %v", thing.Kind, thing.Namespace, thing.Name, err)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// decide works out the Result for the resource as the controller's
+// Reconcile would.
+func decide(thing *v1alpha1.WithPod, resolver *policy.Resolver, namespaces cache.GenericLister, mode cachierv1alpha1.Mode) (Result, error) {
+	p, err := resolver.Resolve(thing)
+	if err != nil {
+		return Result{}, err
+	}
+	ns, err := policy.GetNamespace(namespaces, thing.Namespace)
+	if err != nil {
+		return Result{}, err
+	}
+	r := Result{Resource: thing}
+	r.Decision, r.Path = policy.Explain(thing, policy.Options{
+		Policy:      p,
+		Namespace:   ns,
+		DefaultMode: mode,
+	})
+	if !r.Decision.Cache {
+		return r, nil
+	}
+
+	want := resources.MakeImages(policy.Apply(thing, r.Decision))
+	for ref, img := range want {
+		if !r.Decision.AllowsImage(ref) {
+			r.ExcludedImages = append(r.ExcludedImages, ref)
+			continue
+		}
+		img.TypeMeta.SetGroupVersionKind(caching.SchemeGroupVersion.WithKind("Image"))
+		r.Images = append(r.Images, img)
+	}
+	sort.Strings(r.ExcludedImages)
+	sort.Slice(r.Images, func(i, j int) bool { return r.Images[i].Spec.Image < r.Images[j].Spec.Image })
+	return r, nil
+}
+
+// convert returns the resource in the shape from which Images are made, or
+// nil if it isn't one whose images we cache.
+func convert(u *unstructured.Unstructured, opts Options) (*v1alpha1.WithPod, error) {
+	gvk := u.GroupVersionKind()
+	for _, ext := range opts.Extractors {
+		if ext.GVK.GroupKind() == gvk.GroupKind() {
+			thing, err := ext.Extract(withNamespace(u, opts.Namespace))
+			if err != nil {
+				return nil, err
+			}
+			return thing, nil
+		}
+	}
+	for _, r := range opts.Resources {
+		if r.GroupKind() == gvk.GroupKind() {
+			p := v1alpha1.DuckTypeFor(gvk)
+			if err := decode(u, opts.Namespace, p); err != nil {
+				return nil, err
+			}
+			return p.AsWithPod(), nil
+		}
+	}
+	return nil, nil
+}
+
+// decode decodes u into target, placing it in namespace if it doesn't name
+// one.
+func decode(u *unstructured.Unstructured, namespace string, target interface{}) error {
+	return duck.FromUnstructured(withNamespace(u, namespace), target)
+}
+
+// withNamespace returns u, or a copy of it in namespace if it doesn't name
+// one.
+func withNamespace(u *unstructured.Unstructured, namespace string) *unstructured.Unstructured {
+	if u.GetNamespace() != "" || namespace == "" {
+		return u
+	}
+	u = u.DeepCopy()
+	u.SetNamespace(namespace)
+	return u
+}
+
+// Images returns the deduplicated, sorted references of the Images of the
+// results.
+func Images(results []Result) []string {
+	refs := sets.NewString()
+	for _, r := range results {
+		for _, img := range r.Images {
+			refs.Insert(img.Spec.Image)
+		}
+	}
+	return refs.List()
+}
+
+// ToYAML renders the Images of the results as a stream of YAML documents.
+func ToYAML(results []Result) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, r := range results {
+		for i := range r.Images {
+			u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&r.Images[i])
+			if err != nil {
+				return nil, err
+			}
+			// Leave out the zero creationTimestamp and empty status.
+			if md, ok := u["metadata"].(map[string]interface{}); ok {
+				delete(md, "creationTimestamp")
+			}
+			delete(u, "status")
+			b, err := yaml.Marshal(u)
+			if err != nil {
+				return nil, err
+			}
+			if buf.Len() > 0 {
+				buf.WriteString("---\n")
+			}
+			buf.Write(b)
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,253 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	cachierv1alpha1 "github.com/mattmoor/cachier/pkg/apis/cachier/v1alpha1"
+	"github.com/mattmoor/cachier/pkg/extractors"
+)
+
+const manifests = `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: quiet
+  labels:
+    cachier.mattmoor.io/decorate: disable
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    metadata:
+      annotations:
+        cachier.mattmoor.io/extra-images: gcr.io/foo/sidecar
+    spec:
+      serviceAccountName: builder
+      containers:
+      - name: app
+        image: gcr.io/foo/web:v1
+      - name: debug
+        image: busybox
+---
+apiVersion: apps/v1beta2
+kind: StatefulSet
+metadata:
+  name: db
+  namespace: data
+spec:
+  template:
+    metadata:
+      annotations:
+        cachier.mattmoor.io/exclude-containers: backup
+    spec:
+      containers:
+      - name: db
+        image: gcr.io/foo/db
+      - name: backup
+        image: gcr.io/foo/backup
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: hush
+  namespace: quiet
+spec:
+  template:
+    spec:
+      containers:
+      - image: gcr.io/foo/hush
+---
+apiVersion: cachier.mattmoor.io/v1alpha1
+kind: CachePolicy
+metadata:
+  name: no-busybox
+spec:
+  images:
+    exclude: ["busybox*"]
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: gadget
+  namespace: data
+spec:
+  image: gcr.io/foo/widget
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ignored
+`
+
+func TestRender(t *testing.T) {
+	objs, err := Read(strings.NewReader(manifests))
+	if err != nil {
+		t.Fatalf("Read() = %v", err)
+	}
+	ext, err := extractors.New(extractors.Spec{
+		Resource: "Widget.v1.example.com",
+		Images:   []string{"{.spec.image}"},
+	})
+	if err != nil {
+		t.Fatalf("extractors.New() = %v", err)
+	}
+	results, err := Render(objs, Options{
+		Resources: []schema.GroupVersionKind{
+			{Group: "apps", Version: "v1", Kind: "Deployment"},
+			{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+		},
+		Extractors: []*extractors.Extractor{ext},
+	})
+	if err != nil {
+		t.Fatalf("Render() = %v", err)
+	}
+
+	type summary struct {
+		Name     string
+		Cache    bool
+		Reason   string
+		Images   []string
+		Excluded []string
+	}
+	var got []summary
+	for _, r := range results {
+		s := summary{
+			Name:     r.Resource.Namespace + "/" + r.Resource.Name,
+			Cache:    r.Decision.Cache,
+			Reason:   r.Decision.Reason,
+			Excluded: r.ExcludedImages,
+		}
+		for _, img := range r.Images {
+			s.Images = append(s.Images, img.Spec.Image)
+			if img.Namespace != r.Resource.Namespace {
+				t.Errorf("Image %q in namespace %q, wanted %q", img.Spec.Image, img.Namespace, r.Resource.Namespace)
+			}
+		}
+		got = append(got, s)
+	}
+	want := []summary{{
+		Name:     "default/web",
+		Cache:    true,
+		Reason:   `cached per CachePolicy "no-busybox"`,
+		Images:   []string{"gcr.io/foo/sidecar", "gcr.io/foo/web:v1"},
+		Excluded: []string{"busybox"},
+	}, {
+		// The policy is in the default namespace, so it doesn't apply.
+		Name:   "data/db",
+		Cache:  true,
+		Reason: "cached by default",
+		Images: []string{"gcr.io/foo/db"},
+	}, {
+		Name:   "quiet/hush",
+		Reason: "namespace labeled cachier.mattmoor.io/decorate=disable",
+	}, {
+		Name:   "data/gadget",
+		Cache:  true,
+		Reason: "cached by default",
+		Images: []string{"gcr.io/foo/widget"},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Render() (-want, +got) = %v", diff)
+	}
+
+	if got, want := results[0].Images[0].Spec.ServiceAccountName, "builder"; got != want {
+		t.Errorf("ServiceAccountName = %q, wanted %q", got, want)
+	}
+
+	wantImages := []string{"gcr.io/foo/db", "gcr.io/foo/sidecar", "gcr.io/foo/web:v1", "gcr.io/foo/widget"}
+	if diff := cmp.Diff(wantImages, Images(results)); diff != "" {
+		t.Errorf("Images() (-want, +got) = %v", diff)
+	}
+}
+
+func TestRenderOptIn(t *testing.T) {
+	objs, err := Read(strings.NewReader(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: opted-in
+  namespace: foo
+  annotations:
+    cachier.mattmoor.io/decorate: enable
+spec:
+  template:
+    spec:
+      containers:
+      - image: gcr.io/foo/a
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: not
+  namespace: foo
+spec:
+  template:
+    spec:
+      containers:
+      - image: gcr.io/foo/b
+`))
+	if err != nil {
+		t.Fatalf("Read() = %v", err)
+	}
+	results, err := Render(objs, Options{
+		Resources:   []schema.GroupVersionKind{{Group: "apps", Version: "v1", Kind: "Deployment"}},
+		DefaultMode: cachierv1alpha1.ModeOptIn,
+	})
+	if err != nil {
+		t.Fatalf("Render() = %v", err)
+	}
+	if diff := cmp.Diff([]string{"gcr.io/foo/a"}, Images(results)); diff != "" {
+		t.Errorf("Images() (-want, +got) = %v", diff)
+	}
+}
+
+func TestToYAML(t *testing.T) {
+	objs, err := Read(strings.NewReader(manifests))
+	if err != nil {
+		t.Fatalf("Read() = %v", err)
+	}
+	results, err := Render(objs, Options{
+		Resources: []schema.GroupVersionKind{{Group: "apps", Version: "v1", Kind: "Deployment"}},
+	})
+	if err != nil {
+		t.Fatalf("Render() = %v", err)
+	}
+	b, err := ToYAML(results)
+	if err != nil {
+		t.Fatalf("ToYAML() = %v", err)
+	}
+	got := string(b)
+	for _, want := range []string{"kind: Image\n", "---\n", "image: gcr.io/foo/web:v1\n", "generateName: web-00-\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("ToYAML() = %s, wanted it to contain %q", got, want)
+		}
+	}
+	for _, unwanted := range []string{"creationTimestamp", "status", "busybox"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("ToYAML() = %s, wanted no %q", got, unwanted)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sharding divides namespaces among the replicas of the controller,
+// which coordinate their membership through Leases.
+package sharding
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// virtualNodes is the number of points each member has on the ring, which
+// evens out the share of namespaces each member owns.
+const virtualNodes = 128
+
+// Ring is a consistent hash ring, which assigns keys (namespaces) to its
+// members such that members joining or leaving only moves the keys of
+// their own share.
+type Ring struct {
+	points []uint32
+	owners map[uint32]string
+}
+
+// NewRing returns a Ring of the given members.
+func NewRing(members []string) *Ring {
+	r := &Ring{
+		points: make([]uint32, 0, len(members)*virtualNodes),
+		owners: make(map[uint32]string, len(members)*virtualNodes),
+	}
+	// Visit members in order, so that (unlikely) collisions between their
+	// points are settled the same way by every replica.
+	sorted := append([]string(nil), members...)
+	sort.Strings(sorted)
+	for _, m := range sorted {
+		for i := 0; i < virtualNodes; i++ {
+			p := hash(m + "#" + strconv.Itoa(i))
+			if _, ok := r.owners[p]; ok {
+				continue
+			}
+			r.owners[p] = m
+			r.points = append(r.points, p)
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// Owner returns the member that owns the key, or "" if the ring is empty.
+func (r *Ring) Owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+func hash(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
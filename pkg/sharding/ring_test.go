@@ -0,0 +1,86 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharding
+
+import (
+	"fmt"
+	"testing"
+)
+
+func namespaces(n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = fmt.Sprintf("namespace-%d", i)
+	}
+	return out
+}
+
+func TestRingEmpty(t *testing.T) {
+	if got := NewRing(nil).Owner("foo"); got != "" {
+		t.Errorf("Owner() = %q, wanted \"\"", got)
+	}
+}
+
+func TestRingBalance(t *testing.T) {
+	members := []string{"a", "b", "c", "d"}
+	r := NewRing(members)
+
+	counts := make(map[string]int)
+	const n = 10000
+	for _, ns := range namespaces(n) {
+		counts[r.Owner(ns)]++
+	}
+	for _, m := range members {
+		// Each member should own roughly a quarter.
+		if got := counts[m]; got < n/8 || got > n/2 {
+			t.Errorf("member %q owns %d of %d namespaces", m, got, n)
+		}
+	}
+}
+
+func TestRingOrderIndependent(t *testing.T) {
+	r1 := NewRing([]string{"a", "b", "c"})
+	r2 := NewRing([]string{"c", "a", "b"})
+	for _, ns := range namespaces(1000) {
+		if o1, o2 := r1.Owner(ns), r2.Owner(ns); o1 != o2 {
+			t.Errorf("Owner(%q) = %q and %q", ns, o1, o2)
+		}
+	}
+}
+
+func TestRingRebalance(t *testing.T) {
+	before := NewRing([]string{"a", "b", "c"})
+	after := NewRing([]string{"a", "b", "c", "d"})
+
+	moved := 0
+	all := namespaces(10000)
+	for _, ns := range all {
+		o1, o2 := before.Owner(ns), after.Owner(ns)
+		if o1 == o2 {
+			continue
+		}
+		moved++
+		// Only namespaces taken over by the new member move.
+		if o2 != "d" {
+			t.Errorf("Owner(%q) moved from %q to %q", ns, o1, o2)
+		}
+	}
+	// Roughly a quarter should move.
+	if moved < len(all)/8 || moved > len(all)/2 {
+		t.Errorf("%d of %d namespaces moved", moved, len(all))
+	}
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharding
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+)
+
+// LeasesResource is the resource through which replicas coordinate.
+var LeasesResource = schema.GroupVersionResource{
+	Group:    "coordination.k8s.io",
+	Version:  "v1",
+	Resource: "leases",
+}
+
+// GroupLabelKey is the label on the Leases of the replicas that share
+// namespaces among themselves.
+const GroupLabelKey = "cachier.mattmoor.io/shard-group"
+
+// Options configures a Sharder.
+type Options struct {
+	// Client reads and writes Leases.
+	Client dynamic.Interface
+
+	// Namespace holds the Leases.
+	Namespace string
+
+	// Group names the replicas that share namespaces among themselves.
+	Group string
+
+	// Identity names this replica (e.g. its Pod's name).
+	Identity string
+
+	// LeaseDuration is how long a replica is a member after it last renewed
+	// its Lease.  Defaults to 15s.
+	LeaseDuration time.Duration
+
+	// Clock tells the time.  Defaults to the real clock.
+	Clock clock.Clock
+
+	Logger *zap.SugaredLogger
+}
+
+// Sharder maintains this replica's membership of its group, and which of
+// the group's members owns each namespace.  A nil Sharder owns everything.
+type Sharder struct {
+	client        dynamic.Interface
+	namespace     string
+	group         string
+	identity      string
+	leaseDuration time.Duration
+	clock         clock.Clock
+	logger        *zap.SugaredLogger
+
+	m         sync.RWMutex
+	members   []string
+	ring      *Ring
+	listeners []func()
+}
+
+// New returns a Sharder with the given Options.
+func New(opts Options) *Sharder {
+	s := &Sharder{
+		client:        opts.Client,
+		namespace:     opts.Namespace,
+		group:         opts.Group,
+		identity:      opts.Identity,
+		leaseDuration: opts.LeaseDuration,
+		clock:         opts.Clock,
+		logger:        opts.Logger,
+		ring:          NewRing(nil),
+	}
+	if s.leaseDuration == 0 {
+		s.leaseDuration = 15 * time.Second
+	}
+	if s.clock == nil {
+		s.clock = clock.RealClock{}
+	}
+	return s
+}
+
+// Identity returns the name of this replica.
+func (s *Sharder) Identity() string {
+	return s.identity
+}
+
+// Owns returns whether this replica owns the namespace.
+func (s *Sharder) Owns(namespace string) bool {
+	if s == nil {
+		return true
+	}
+	return s.OwnerOf(namespace) == s.identity
+}
+
+// OwnerOf returns the replica that owns the namespace, or "" if there are
+// no members (e.g. before we've joined).
+func (s *Sharder) OwnerOf(namespace string) string {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	return s.ring.Owner(namespace)
+}
+
+// Members returns the live members of the group, in order.
+func (s *Sharder) Members() []string {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	return append([]string(nil), s.members...)
+}
+
+// AddListener registers f to be called whenever the members change, and
+// with them which namespaces this replica owns.
+func (s *Sharder) AddListener(f func()) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.listeners = append(s.listeners, f)
+}
+
+// Start joins the group, and keeps renewing our Lease and watching for
+// members joining and leaving until stopCh is closed, when we leave.  It
+// returns once we've joined, so that we know what we own before
+// reconciling anything.
+func (s *Sharder) Start(stopCh <-chan struct{}) error {
+	if err := s.sync(); err != nil {
+		return err
+	}
+	go func() {
+		wait.Until(func() {
+			if err := s.sync(); err != nil {
+				s.logger.Errorf("Error syncing shard membership: %v", err)
+			}
+		}, s.leaseDuration/3, stopCh)
+
+		// Leave, so that the others take over our namespaces promptly.
+		err := s.client.Resource(LeasesResource).Namespace(s.namespace).Delete(s.leaseName(), &metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			s.logger.Errorf("Error deleting Lease %s: %v", s.leaseName(), err)
+		}
+	}()
+	return nil
+}
+
+func (s *Sharder) leaseName() string {
+	return s.group + "-" + s.identity
+}
+
+// sync renews our Lease, and updates the members from the group's Leases.
+func (s *Sharder) sync() error {
+	if err := s.renew(); err != nil {
+		return err
+	}
+	leases, err := s.client.Resource(LeasesResource).Namespace(s.namespace).List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{GroupLabelKey: s.group}).String(),
+	})
+	if err != nil {
+		return err
+	}
+	s.setMembers(LiveMembers(leases.Items, s.clock.Now()))
+	return nil
+}
+
+// renew creates or updates our Lease.
+func (s *Sharder) renew() error {
+	leases := s.client.Resource(LeasesResource).Namespace(s.namespace)
+	now := metav1.NewMicroTime(s.clock.Now()).Format(metav1.RFC3339Micro)
+
+	lease, err := leases.Get(s.leaseName(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		lease = &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":      s.leaseName(),
+				"namespace": s.namespace,
+				"labels":    map[string]interface{}{GroupLabelKey: s.group},
+			},
+			"spec": map[string]interface{}{
+				"holderIdentity":       s.identity,
+				"leaseDurationSeconds": int64(s.leaseDuration / time.Second),
+				"acquireTime":          now,
+				"renewTime":            now,
+			},
+		}}
+		lease.SetGroupVersionKind(LeasesResource.GroupVersion().WithKind("Lease"))
+		_, err = leases.Create(lease)
+		return err
+	} else if err != nil {
+		return err
+	}
+	if err := unstructured.SetNestedField(lease.Object, now, "spec", "renewTime"); err != nil {
+		return err
+	}
+	_, err = leases.Update(lease)
+	return err
+}
+
+// setMembers updates the members, and tells the listeners if they changed.
+func (s *Sharder) setMembers(members []string) {
+	s.m.Lock()
+	if equal(s.members, members) {
+		s.m.Unlock()
+		return
+	}
+	s.logger.Infof("Shard members changed from %v to %v", s.members, members)
+	s.members = members
+	s.ring = NewRing(members)
+	listeners := append([]func(){}, s.listeners...)
+	s.m.Unlock()
+
+	for _, f := range listeners {
+		f()
+	}
+}
+
+// LiveMembers returns the holders of the given Leases that have renewed
+// them within their duration, in order.
+func LiveMembers(leases []unstructured.Unstructured, now time.Time) []string {
+	var members []string
+	for _, lease := range leases {
+		holder, _, _ := unstructured.NestedString(lease.Object, "spec", "holderIdentity")
+		renewed, _, _ := unstructured.NestedString(lease.Object, "spec", "renewTime")
+		seconds, _, _ := unstructured.NestedInt64(lease.Object, "spec", "leaseDurationSeconds")
+		if holder == "" || renewed == "" {
+			continue
+		}
+		t, err := time.Parse(metav1.RFC3339Micro, renewed)
+		if err != nil {
+			continue
+		}
+		if now.Before(t.Add(time.Duration(seconds) * time.Second)) {
+			members = append(members, holder)
+		}
+	}
+	sort.Strings(members)
+	return members
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
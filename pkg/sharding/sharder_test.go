@@ -0,0 +1,98 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharding
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func lease(holder string, renewed time.Time, seconds int64) unstructured.Unstructured {
+	spec := map[string]interface{}{
+		"holderIdentity":       holder,
+		"leaseDurationSeconds": seconds,
+	}
+	if !renewed.IsZero() {
+		spec["renewTime"] = metav1.NewMicroTime(renewed).Format(metav1.RFC3339Micro)
+	}
+	return unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+}
+
+func TestLiveMembers(t *testing.T) {
+	now := time.Now()
+	leases := []unstructured.Unstructured{
+		lease("c", now.Add(-5*time.Second), 15),
+		lease("a", now.Add(-14*time.Second), 15),
+		// Expired.
+		lease("b", now.Add(-16*time.Second), 15),
+		// Never renewed.
+		lease("d", time.Time{}, 15),
+		// No holder.
+		lease("", now, 15),
+	}
+	want := []string{"a", "c"}
+	if diff := cmp.Diff(want, LiveMembers(leases, now)); diff != "" {
+		t.Errorf("LiveMembers (-want, +got) = %v", diff)
+	}
+}
+
+func TestSharderOwns(t *testing.T) {
+	var nilSharder *Sharder
+	if !nilSharder.Owns("foo") {
+		t.Error("nil Sharder doesn't own foo")
+	}
+
+	s := New(Options{Identity: "a", Logger: zap.NewNop().Sugar()})
+	// Before joining, we own nothing.
+	if s.Owns("foo") {
+		t.Error("Owns(foo) = true before joining")
+	}
+
+	changes := 0
+	s.AddListener(func() { changes++ })
+
+	s.setMembers([]string{"a"})
+	if !s.Owns("foo") || s.OwnerOf("foo") != "a" {
+		t.Errorf("OwnerOf(foo) = %q, wanted a", s.OwnerOf("foo"))
+	}
+	s.setMembers([]string{"a"})
+	if changes != 1 {
+		t.Errorf("changes = %d, wanted 1", changes)
+	}
+
+	s.setMembers([]string{"a", "b"})
+	if changes != 2 {
+		t.Errorf("changes = %d, wanted 2", changes)
+	}
+	if diff := cmp.Diff([]string{"a", "b"}, s.Members()); diff != "" {
+		t.Errorf("Members (-want, +got) = %v", diff)
+	}
+	owned := 0
+	for _, ns := range namespaces(1000) {
+		if s.Owns(ns) {
+			owned++
+		}
+	}
+	if owned < 250 || owned > 750 {
+		t.Errorf("Owns %d of 1000 namespaces, wanted about half", owned)
+	}
+}